@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -359,3 +360,275 @@ services:
 		t.Fatal("Agent did not exit within 5 seconds after SIGTERM")
 	}
 }
+
+func TestIntegration_PrintConfig(t *testing.T) {
+	// Build the agent binary
+	agentBin := filepath.Join(os.TempDir(), "probixel-print-config-test")
+	buildCmd := exec.Command("go", "build", "-o", agentBin, ".")
+	if out, err := buildCmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build agent: %v\n%s", err, out)
+	}
+	defer func() { _ = os.Remove(agentBin) }()
+
+	configPath := filepath.Join(os.TempDir(), "print_config.yaml")
+	err := os.WriteFile(configPath, []byte(`
+global:
+  default_interval: "1m"
+services:
+  - name: "Print Test"
+    type: "host"
+    monitor_endpoint:
+      success:
+        url: "http://localhost/ok"
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Remove(configPath) }()
+
+	pidFile := filepath.Join(os.TempDir(), "probixel-print-config.pid")
+	defer func() { _ = os.Remove(pidFile) }()
+
+	cmd := exec.Command(agentBin, "-config", configPath, "-pidfile", pidFile, "-print-config")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Expected -print-config to succeed, got: %v", err)
+	}
+
+	output := string(out)
+	if !strings.Contains(output, `interval: 1m`) {
+		t.Errorf("Expected resolved interval in output, got:\n%s", output)
+	}
+	if !strings.Contains(output, `timeout: 5s`) {
+		t.Errorf("Expected default timeout in output, got:\n%s", output)
+	}
+	if _, err := os.Stat(pidFile); err == nil {
+		t.Error("Expected no PID file to be written in -print-config mode")
+	}
+}
+
+func TestIntegration_Validate(t *testing.T) {
+	// Build the agent binary
+	agentBin := filepath.Join(os.TempDir(), "probixel-validate-test")
+	buildCmd := exec.Command("go", "build", "-o", agentBin, ".")
+	if out, err := buildCmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build agent: %v\n%s", err, out)
+	}
+	defer func() { _ = os.Remove(agentBin) }()
+
+	dir := t.TempDir()
+	goodConfig := filepath.Join(dir, "good.yaml")
+	err := os.WriteFile(goodConfig, []byte(`
+global:
+  default_interval: "1m"
+docker-sockets:
+  local:
+    socket: "/var/run/docker.sock"
+services:
+  - name: "Host"
+    type: "host"
+    monitor_endpoint:
+      success:
+        url: "http://localhost/ok"
+  - name: "Web"
+    type: "docker"
+    interval: "30s"
+    targets: ["web"]
+    docker:
+      socket: "local"
+    monitor_endpoint:
+      success:
+        url: "http://localhost/ok"
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badConfig := filepath.Join(dir, "bad.yaml")
+	err = os.WriteFile(badConfig, []byte(`
+global:
+  default_interval: "1m"
+services:
+  - name: "Web"
+    type: "docker"
+    targets: ["web"]
+    docker:
+      socket: "missing"
+    monitor_endpoint:
+      success:
+        url: "http://localhost/ok"
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pidFile := filepath.Join(dir, "probixel.pid")
+
+	out, err := exec.Command(agentBin, "-config", goodConfig, "-pidfile", pidFile, "-validate").Output()
+	if err != nil {
+		t.Fatalf("Expected -validate to succeed, got: %v", err)
+	}
+	for _, want := range []string{
+		"is valid: 2 services, 0 tunnels, 1 docker sockets",
+		"service Host: host every 1m",
+		"service Web: docker every 30s",
+		"docker socket local: /var/run/docker.sock",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, out)
+		}
+	}
+
+	out, err = exec.Command(agentBin, "-config", badConfig, "-pidfile", pidFile, "-validate").CombinedOutput()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		t.Fatalf("Expected exit code 1 for an invalid config, got: %v", err)
+	}
+	if !strings.Contains(string(out), `unknown docker socket "missing"`) {
+		t.Errorf("Expected the validation error in output, got:\n%s", out)
+	}
+
+	if _, err := os.Stat(pidFile); err == nil {
+		t.Error("Expected no PID file to be written in -validate mode")
+	}
+}
+
+func TestIntegration_ListProbes(t *testing.T) {
+	cmd := exec.Command("go", "run", ".", "-list-probes")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Expected -list-probes to succeed, got: %v", err)
+	}
+
+	output := string(out)
+	for _, want := range []string{"http ", "wireguard ", "fields: targets, target_mode, quorum", "http.accepted_status_codes"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestIntegration_Once(t *testing.T) {
+	agentBin := filepath.Join(os.TempDir(), "probixel-once-test")
+	buildCmd := exec.Command("go", "build", "-o", agentBin, ".")
+	if out, err := buildCmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build agent: %v\n%s", err, out)
+	}
+	defer func() { _ = os.Remove(agentBin) }()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	pushed := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case pushed <- r.URL.Path:
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	configPath := filepath.Join(t.TempDir(), "once.yaml")
+	err = os.WriteFile(configPath, []byte(fmt.Sprintf(`
+global:
+  default_interval: "1m"
+services:
+  - name: "Up"
+    type: "tcp"
+    targets: ["%s"]
+    monitor_endpoint:
+      success:
+        url: "%s/up"
+  - name: "Down"
+    type: "tcp"
+    targets: ["127.0.0.1:1"]
+    retries: 0
+    monitor_endpoint:
+      success:
+        url: "%s/down"
+  - name: "Tunnelled"
+    type: "tcp"
+    targets: ["10.8.0.1:80"]
+    tunnel: "vpn"
+    interval: "2s"
+    retries: 0
+    monitor_endpoint:
+      retries: 0
+      success:
+        url: "%s/tunnelled"
+tunnels:
+  vpn:
+    type: "wireguard"
+    wireguard:
+      endpoint: "127.0.0.1:1"
+      private_key: "wOEI9rqqbDwnN8/Bpp22sVz48T71vJ4fYmFWujulwUU="
+      public_key: "wAUaJMhAq3NFutLHIdF8AN0B5WG8RndfQKLPTEDHal0="
+      addresses: "10.8.0.2/32"
+`, ln.Addr().String(), ts.URL, ts.URL, ts.URL)), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pidFile := filepath.Join(t.TempDir(), "probixel-once.pid")
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{"up", []string{"-service", "Up"}, 0, "Up UP (OK)"},
+		{"down", []string{"-service", "Down"}, 1, "Down DOWN ("},
+		{"tunnel never stabilizes", []string{"-service", "Tunnelled"}, 1, "Tunnelled WAITING ("},
+		{"unknown service", []string{"-service", "Missing"}, 2, ""},
+		{"no service", nil, 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-config", configPath, "-pidfile", pidFile, "-once"}, tt.args...)
+			out, err := exec.Command(agentBin, args...).Output()
+			code := 0
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("Failed to run agent: %v", err)
+			}
+			if code != tt.wantCode {
+				t.Errorf("Expected exit code %d, got %d (output %q)", tt.wantCode, code, out)
+			}
+			if !strings.Contains(string(out), tt.wantOut) {
+				t.Errorf("Expected %q in output, got %q", tt.wantOut, out)
+			}
+		})
+	}
+
+	select {
+	case path := <-pushed:
+		t.Errorf("Expected no push without -push, got %s", path)
+	default:
+	}
+	if _, err := os.Stat(pidFile); err == nil {
+		t.Error("Expected no PID file to be written in -once mode")
+	}
+
+	if err := exec.Command(agentBin, "-config", configPath, "-once", "-service", "Up", "-push").Run(); err != nil {
+		t.Fatalf("Expected -once -push to succeed, got: %v", err)
+	}
+	select {
+	case path := <-pushed:
+		if path != "/up" {
+			t.Errorf("Expected push to /up, got %s", path)
+		}
+	default:
+		t.Error("Expected the result to be pushed with -push")
+	}
+}
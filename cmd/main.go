@@ -3,15 +3,27 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
+	"maps"
+	"net"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"probixel/pkg/agent"
 	"probixel/pkg/config"
 	"probixel/pkg/health"
+	"probixel/pkg/monitor"
+	"probixel/pkg/notifier"
+	"probixel/pkg/tunnels"
 	"probixel/pkg/watchdog"
+
+	"gopkg.in/yaml.v3"
 )
 
 func main() {
@@ -19,12 +31,47 @@ func main() {
 	pidFile := flag.String("pidfile", "/tmp/probixel.pid", "Path to PID file")
 	healthCheck := flag.Bool("health", false, "Perform health check and exit")
 	delaySeconds := flag.Int("delay", 10, "Starting window delay in seconds (0 to disable)")
+	printConfig := flag.Bool("print-config", false, "Print the effective configuration (with defaults applied) and exit")
+	validate := flag.Bool("validate", false, "Load and validate the configuration, print a summary and exit 0 if it is valid, 1 otherwise")
+	listProbes := flag.Bool("list-probes", false, "List the available probe types and the fields they honor, then exit")
+	once := flag.Bool("once", false, "Check the service named by -service once, print the result and exit 0 if it is up, 1 otherwise")
+	serviceName := flag.String("service", "", "Service to check in -once mode")
+	push := flag.Bool("push", false, "Push the -once result to the service's monitor endpoints")
 	flag.Parse()
 
+	if *listProbes {
+		for _, info := range monitor.DescribeProbes() {
+			fmt.Printf("%-10s %s\n", info.Type, info.Description)
+			fmt.Printf("%-10s fields: %s\n", "", strings.Join(info.Fields, ", "))
+		}
+		return
+	}
+
 	if *healthCheck {
 		health.CheckHealth(*pidFile)
 	}
 
+	if *printConfig {
+		cfg, err := config.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		out, err := yaml.Marshal(cfg.WithDefaults().Redacted())
+		if err != nil {
+			log.Fatalf("Failed to encode config: %v", err)
+		}
+		fmt.Print(string(out))
+		return
+	}
+
+	if *validate {
+		os.Exit(runValidate(*configPath))
+	}
+
+	if *once {
+		os.Exit(runOnce(*configPath, *serviceName, *push))
+	}
+
 	// Write PID file
 	if err := health.WritePIDFile(*pidFile); err != nil {
 		log.Fatalf("Failed to write PID file: %v", err)
@@ -54,8 +101,155 @@ func main() {
 		cancel()
 	}()
 
+	// SIGHUP forces a config reload without touching the file
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-hupChan:
+				log.Println("Received SIGHUP, reloading config...")
+				wd.Reload()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
 	wd.Start(ctx)
 
 	<-ctx.Done()
 	log.Println("Agent stopped.")
 }
+
+// runValidate loads and validates the config without starting anything, and
+// prints a summary of what it defines. It returns the exit code: 0 if the
+// config is valid, 1 otherwise.
+func runValidate(configPath string) int {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Printf("Invalid config: %v", err)
+		return 1
+	}
+	cfg = cfg.WithDefaults()
+
+	fmt.Printf("%s is valid: %d services, %d tunnels, %d docker sockets\n", configPath, len(cfg.Services), len(cfg.Tunnels), len(cfg.DockerSockets))
+	for _, svc := range cfg.Services {
+		via := ""
+		if svc.Tunnel != "" {
+			via = fmt.Sprintf(" via tunnel %s", svc.Tunnel)
+		}
+		fmt.Printf("  service %s: %s every %s%s\n", svc.Name, svc.Type, svc.Interval, via)
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Tunnels)) {
+		fmt.Printf("  tunnel %s: %s\n", name, cfg.Tunnels[name].Type)
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.DockerSockets)) {
+		s := cfg.DockerSockets[name]
+		if s.Socket != "" {
+			fmt.Printf("  docker socket %s: %s\n", name, s.Socket)
+		} else {
+			fmt.Printf("  docker socket %s: %s\n", name, net.JoinHostPort(s.Host, strconv.Itoa(s.Port)))
+		}
+	}
+	return 0
+}
+
+// runOnce checks a single service once, for cron jobs and scripts, and
+// returns the exit code: 0 if the service is up, 1 if it is down or still
+// pending, 2 if it could not be checked at all. No PID file is written and
+// nothing is pushed unless push is set.
+func runOnce(configPath, serviceName string, push bool) int {
+	if serviceName == "" {
+		log.Print("-once requires -service")
+		return 2
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		log.Printf("Failed to load config: %v", err)
+		return 2
+	}
+	var svc *config.Service
+	for i := range cfg.Services {
+		if cfg.Services[i].Name == serviceName {
+			svc = &cfg.Services[i]
+			break
+		}
+	}
+	if svc == nil {
+		log.Printf("Service %q not found in %s", serviceName, configPath)
+		return 2
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	registry := tunnels.NewRegistry()
+	defer registry.StopAll()
+	if svc.Tunnel != "" {
+		t := agent.NewTunnel(svc.Tunnel, cfg.Tunnels[svc.Tunnel])
+		if t == nil {
+			log.Printf("Tunnel %q has no usable definition", svc.Tunnel)
+			return 2
+		}
+		if err := t.Initialize(); err != nil {
+			log.Printf("[Tunnel:%s] Failed to initialize: %v", svc.Tunnel, err)
+			return 2
+		}
+		_ = registry.Register(t)
+		if d, err := config.ParseDuration(cfg.Tunnels[svc.Tunnel].MaxStabilizationTime); err == nil {
+			registry.SetMaxStabilizationTime(svc.Tunnel, d)
+		}
+		// The probe would only report pending before the tunnel stabilizes,
+		// or fail once it exceeds max_stabilization_time. Without one, the
+		// tunnel gets one interval, after which the check reports pending.
+		intervalStr := svc.Interval
+		if intervalStr == "" {
+			intervalStr = cfg.Global.DefaultInterval
+		}
+		interval, _ := config.ParseDuration(intervalStr)
+		deadline := time.After(interval)
+	wait:
+		for !t.IsStabilized() && registry.CheckStabilization(svc.Tunnel) == nil {
+			select {
+			case <-ctx.Done():
+				return 2
+			case <-deadline:
+				break wait
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	probe, err := agent.SetupProbe(*svc, cfg, registry)
+	if err != nil {
+		log.Printf("Failed to setup probe: %v", err)
+		return 2
+	}
+	result := agent.RunCheck(ctx, probe, *svc, cfg, registry)
+
+	status := "DOWN"
+	if result.Pending {
+		status = "WAITING"
+	} else if result.Success {
+		status = "UP"
+	}
+	fmt.Printf("%s %s (%s) %v\n", svc.Name, status, result.Message, result.Duration)
+	if len(result.TargetResults) > 1 {
+		for _, tr := range result.TargetResults {
+			fmt.Printf("  %s: %s %v\n", tr.Target, tr.Message, tr.Duration)
+		}
+	}
+
+	if push {
+		pusher := notifier.NewPusher()
+		if err := pusher.Push(ctx, svc.Name, result, svc.MonitorEndpoint, cfg.Global.MonitorEndpoint); err != nil {
+			log.Printf("Failed to push result: %v", err)
+		}
+	}
+
+	if result.Success {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"probixel/pkg/monitor"
+)
+
+// Alerts tracks the last status of each service to decide which results are
+// pushed in on_change_only mode. Like History, it outlives config reloads,
+// which only drop removed services.
+type Alerts struct {
+	mu       sync.Mutex
+	services map[string]*alertState
+}
+
+type alertState struct {
+	success  bool
+	lastPush time.Time
+}
+
+func NewAlerts() *Alerts {
+	return &Alerts{services: make(map[string]*alertState)}
+}
+
+// Due reports whether res should be pushed for service. Without onChangeOnly
+// every result is. Otherwise only the first result of the service and status
+// changes are, plus, while it stays down, a reminder once reminder has passed
+// since the last push. Pending results are always passed through, the pusher
+// ignores them. Nothing is recorded until Pushed, so a push that fails is due
+// again on the next check.
+func (a *Alerts) Due(service string, res monitor.Result, onChangeOnly bool, reminder time.Duration) bool {
+	if res.Pending {
+		return true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s, known := a.services[service]
+	return !onChangeOnly || !known || s.success != res.Success ||
+		(!res.Success && reminder > 0 && time.Since(s.lastPush) >= reminder)
+}
+
+// Pushed records that res was delivered for service. Pending results are
+// ignored.
+func (a *Alerts) Pushed(service string, res monitor.Result) {
+	if res.Pending {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.services[service] = &alertState{success: res.Success, lastPush: time.Now()}
+}
+
+// Update drops services that are no longer configured.
+func (a *Alerts) Update(services []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for name := range a.services {
+		if !slices.Contains(services, name) {
+			delete(a.services, name)
+		}
+	}
+}
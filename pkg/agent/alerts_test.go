@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"probixel/pkg/monitor"
+)
+
+// record asks a whether res is due and records it as pushed if it is.
+func record(a *Alerts, res monitor.Result, onChangeOnly bool, reminder time.Duration) bool {
+	due := a.Due("svc", res, onChangeOnly, reminder)
+	if due {
+		a.Pushed("svc", res)
+	}
+	return due
+}
+
+func TestAlerts_Due(t *testing.T) {
+	up := monitor.Result{Success: true}
+	down := monitor.Result{Success: false}
+
+	tests := []struct {
+		name         string
+		onChangeOnly bool
+		results      []monitor.Result
+		want         []bool
+	}{
+		{"every result", false, []monitor.Result{up, up, down, down}, []bool{true, true, true, true}},
+		{"changes only", true, []monitor.Result{up, up, up, up, up}, []bool{true, false, false, false, false}},
+		{"flapping", true, []monitor.Result{up, down, down, up, up}, []bool{true, true, false, true, false}},
+		{"first down", true, []monitor.Result{down, down}, []bool{true, false}},
+		{"pending", true, []monitor.Result{up, {Pending: true}, up}, []bool{true, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAlerts()
+			for i, res := range tt.results {
+				if got := record(a, res, tt.onChangeOnly, 0); got != tt.want[i] {
+					t.Errorf("check %d: Due() = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAlerts_Reminder(t *testing.T) {
+	a := NewAlerts()
+	down := monitor.Result{Success: false}
+	reminder := 50 * time.Millisecond
+
+	if !record(a, down, true, reminder) {
+		t.Fatal("expected the first failure to be pushed")
+	}
+	if record(a, down, true, reminder) {
+		t.Error("expected no reminder before reminder_interval")
+	}
+	time.Sleep(reminder)
+	if !record(a, down, true, reminder) {
+		t.Error("expected a reminder once reminder_interval passed")
+	}
+	if record(a, down, true, reminder) {
+		t.Error("expected the reminder to restart the interval")
+	}
+
+	// Services that stay up are not reminded of
+	a = NewAlerts()
+	record(a, monitor.Result{Success: true}, true, reminder)
+	time.Sleep(reminder)
+	if record(a, monitor.Result{Success: true}, true, reminder) {
+		t.Error("expected no reminder for a service that is up")
+	}
+}
+
+func TestAlerts_Undelivered(t *testing.T) {
+	a := NewAlerts()
+	up := monitor.Result{Success: true}
+	down := monitor.Result{Success: false}
+	record(a, up, true, 0)
+
+	// The push of the transition failed, it stays due until delivered
+	for range 2 {
+		if !a.Due("svc", down, true, 0) {
+			t.Fatal("expected an undelivered status change to stay due")
+		}
+	}
+	a.Pushed("svc", down)
+	if a.Due("svc", down, true, 0) {
+		t.Error("expected a delivered status change not to be due again")
+	}
+}
+
+func TestAlerts_Update(t *testing.T) {
+	a := NewAlerts()
+	a.Pushed("kept", monitor.Result{Success: true})
+	a.Pushed("removed", monitor.Result{Success: true})
+
+	a.Update([]string{"kept"})
+	if a.Due("kept", monitor.Result{Success: true}, true, 0) {
+		t.Error("expected the kept service to remember its status")
+	}
+	if !a.Due("removed", monitor.Result{Success: true}, true, 0) {
+		t.Error("expected a removed, then re-added service to be pushed again")
+	}
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Checks lets the status API check a service now, out of its schedule.
+// Running monitors register their service for as long as they run.
+type Checks struct {
+	mu       sync.Mutex
+	services map[string]*checkRunner
+}
+
+// checkRunner checks a service once, taking turns with the service's other
+// checks.
+type checkRunner struct {
+	run func() (ServiceStatus, bool)
+}
+
+func NewChecks() *Checks {
+	return &Checks{services: make(map[string]*checkRunner)}
+}
+
+// register makes run the check of service, until the returned function is
+// called. A monitor restarted for a changed service replaces the entry of the
+// previous one, which then leaves it alone.
+func (c *Checks) register(service string, run func() (ServiceStatus, bool)) func() {
+	r := &checkRunner{run: run}
+	c.mu.Lock()
+	c.services[service] = r
+	c.mu.Unlock()
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.services[service] == r {
+			delete(c.services, service)
+		}
+	}
+}
+
+// Run checks service now, pushing the result as a scheduled check would, and
+// returns it. It returns false if no monitor runs for service. The check waits
+// for any other check of the service in progress, scheduled or not, and the
+// schedule is unaffected.
+func (c *Checks) Run(service string) (ServiceStatus, bool) {
+	c.mu.Lock()
+	r, ok := c.services[service]
+	c.mu.Unlock()
+	if !ok {
+		return ServiceStatus{}, false
+	}
+	return r.run()
+}
+
+// ServeHTTP answers POST /check/{service} with the result of a check run
+// now, or 404 if the service is unknown.
+func (c *Checks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("service")
+	st, ok := c.Run(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("unknown service %q", name)})
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
@@ -0,0 +1,181 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"probixel/pkg/config"
+	"probixel/pkg/monitor"
+	"probixel/pkg/notifier"
+	"probixel/pkg/tunnels"
+)
+
+func TestChecks(t *testing.T) {
+	var pushes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pushes.Add(1)
+	}))
+	defer server.Close()
+
+	checkOnStart := false
+	svc := config.Service{
+		Name:            "web",
+		Type:            "http",
+		URL:             "https://example.com",
+		Interval:        "1h",
+		Retries:         ptrInt(0),
+		MonitorEndpoint: config.MonitorEndpointConfig{Success: config.EndpointList{{URL: server.URL + "/up"}}},
+	}
+	state := NewConfigState(&config.Config{Global: config.GlobalConfig{CheckOnStart: &checkOnStart}, Services: []config.Service{svc}})
+	pusher := notifier.NewPusher()
+	noLimit := "0"
+	pusher.SetRateLimit(&noLimit)
+
+	var checks, running atomic.Int32
+	p := &statusMockProbe{checkFunc: func(ctx context.Context, target string) (monitor.Result, error) {
+		if running.Add(1) > 1 {
+			t.Error("expected checks of a service not to overlap")
+		}
+		defer running.Add(-1)
+		checks.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return monitor.Result{Success: true, Message: "HTTP 200", Target: target, Timestamp: time.Now()}, nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go RunServiceMonitor(ctx, svc, p, state, tunnels.NewRegistry(), pusher, wg)
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /check/{service}", state.Checks)
+	post := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("POST", path, nil))
+		return rec
+	}
+
+	// The monitor registers itself once started
+	deadline := time.Now().Add(2 * time.Second)
+	rec := post("/check/web")
+	for rec.Code == http.StatusNotFound && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		rec = post("/check/web")
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var st ServiceStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if st.Name != "web" || !st.Success || st.Message != "HTTP 200" || st.Target != "https://example.com" {
+		t.Errorf("unexpected result %+v", st)
+	}
+	if got, _ := state.Status.Get("web"); !got.Timestamp.Equal(st.Timestamp) {
+		t.Errorf("expected the result to be recorded for the status API, got %+v", got)
+	}
+	if pushes.Load() != 1 {
+		t.Errorf("expected the result to be pushed once, got %d pushes", pushes.Load())
+	}
+
+	var requests sync.WaitGroup
+	for range 5 {
+		requests.Go(func() {
+			if rec := post("/check/web"); rec.Code != http.StatusOK {
+				t.Errorf("expected 200, got %d", rec.Code)
+			}
+		})
+	}
+	requests.Wait()
+	if checks.Load() != 6 || pushes.Load() != 6 {
+		t.Errorf("expected 6 checks and pushes, got %d and %d", checks.Load(), pushes.Load())
+	}
+
+	if rec := post("/check/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown service, got %d", rec.Code)
+	}
+
+	cancel()
+	wg.Wait()
+	if rec := post("/check/web"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 once the monitor stopped, got %d", rec.Code)
+	}
+}
+
+func TestChecks_ReplacedMonitor(t *testing.T) {
+	c := NewChecks()
+	unregisterOld := c.register("web", func() (ServiceStatus, bool) { return ServiceStatus{Message: "old"}, true })
+	unregisterNew := c.register("web", func() (ServiceStatus, bool) { return ServiceStatus{Message: "new"}, true })
+
+	// The previous monitor stopping leaves the new one registered
+	unregisterOld()
+	if st, ok := c.Run("web"); !ok || st.Message != "new" {
+		t.Errorf("expected the new monitor's check, got %+v, %v", st, ok)
+	}
+	unregisterNew()
+	if _, ok := c.Run("web"); ok {
+		t.Error("expected no check once unregistered")
+	}
+}
+
+func TestChecks_DuringScheduledRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	svc := config.Service{
+		Name:            "web",
+		Type:            "http",
+		URL:             "https://example.com",
+		Interval:        "20ms",
+		Retries:         ptrInt(0),
+		MonitorEndpoint: config.MonitorEndpointConfig{Success: config.EndpointList{{URL: server.URL + "/up"}}},
+	}
+	state := NewConfigState(&config.Config{Services: []config.Service{svc}})
+	pusher := notifier.NewPusher()
+	noLimit := "0"
+	pusher.SetRateLimit(&noLimit)
+
+	// Left unsynchronized, so the race detector flags overlapping checks
+	calls := 0
+	scheduled := make(chan struct{}, 1)
+	p := &statusMockProbe{checkFunc: func(ctx context.Context, target string) (monitor.Result, error) {
+		calls++
+		select {
+		case scheduled <- struct{}{}:
+		default:
+		}
+		time.Sleep(5 * time.Millisecond)
+		return monitor.Result{Success: true, Message: "HTTP 200", Target: target, Timestamp: time.Now()}, nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go RunServiceMonitor(ctx, svc, p, state, tunnels.NewRegistry(), pusher, wg)
+
+	// On-demand checks fired while the scheduled ones keep running
+	<-scheduled
+	var requests sync.WaitGroup
+	for range 10 {
+		requests.Go(func() {
+			if _, ok := state.Checks.Run("web"); !ok {
+				t.Error("expected the running monitor to check the service")
+			}
+		})
+		time.Sleep(7 * time.Millisecond)
+	}
+	requests.Wait()
+	cancel()
+	wg.Wait()
+
+	if calls <= 10 {
+		t.Errorf("expected scheduled checks besides the 10 requested, got %d checks", calls)
+	}
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"probixel/pkg/config"
+	"probixel/pkg/logging"
+	"probixel/pkg/monitor"
+	"probixel/pkg/notifier"
+)
+
+// HeartbeatName is the name used for the agent heartbeat in logs.
+const HeartbeatName = "Heartbeat"
+
+// RunHeartbeat pushes to the heartbeat endpoint immediately and then on every
+// interval until ctx is cancelled. It does not depend on any service result, so
+// a missing heartbeat means the agent itself is down.
+func RunHeartbeat(ctx context.Context, hb config.HeartbeatConfig, state *ConfigState, pusher *notifier.Pusher, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	intervalStr := hb.Interval
+	if intervalStr == "" {
+		intervalStr = state.Get().Global.DefaultInterval
+	}
+
+	interval, err := config.ParseDuration(intervalStr)
+	if err != nil || interval <= 0 {
+		logging.Errorf(HeartbeatName, "Invalid interval %q: %v", intervalStr, err)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	push := func() {
+		result := monitor.Result{
+			Success:   true,
+			Message:   "agent alive",
+			Timestamp: time.Now(),
+		}
+		endpointCfg := config.MonitorEndpointConfig{Success: config.EndpointList{hb.EndpointConfig}}
+		if err := pusher.Push(ctx, HeartbeatName, result, endpointCfg, state.Get().Global.MonitorEndpoint); err != nil {
+			logging.Warnf(HeartbeatName, "Failed to push heartbeat: %v", err)
+		}
+	}
+
+	push()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			push()
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"probixel/pkg/config"
+	"probixel/pkg/notifier"
+)
+
+func TestRunHeartbeat_PushesOnInterval(t *testing.T) {
+	var hits atomic.Int32
+	var lastTS atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastTS.Store(r.URL.Query().Get("ts"))
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hb := config.HeartbeatConfig{
+		EndpointConfig: config.EndpointConfig{URL: server.URL + "?ts={%timestamp%}"},
+		Interval:       "50ms",
+	}
+	state := NewConfigState(&config.Config{})
+	noLimit := "0"
+	pusher := notifier.NewPusher()
+	pusher.SetRateLimit(&noLimit)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go RunHeartbeat(ctx, hb, state, pusher, wg)
+
+	deadline := time.After(2 * time.Second)
+	for hits.Load() < 2 {
+		select {
+		case <-deadline:
+			t.Fatalf("expected at least 2 heartbeats, got %d", hits.Load())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	wg.Wait()
+
+	ts, _ := lastTS.Load().(string)
+	if ts == "" || strings.Contains(ts, "{%") {
+		t.Errorf("expected timestamp to be substituted, got %q", ts)
+	}
+}
+
+func TestRunHeartbeat_UsesGlobalDefaultInterval(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	hb := config.HeartbeatConfig{EndpointConfig: config.EndpointConfig{URL: server.URL}}
+	state := NewConfigState(&config.Config{Global: config.GlobalConfig{DefaultInterval: "1h"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go RunHeartbeat(ctx, hb, state, notifier.NewPusher(), wg)
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	// Only the immediate push should have happened
+	if got := hits.Load(); got != 1 {
+		t.Errorf("expected 1 heartbeat, got %d", got)
+	}
+}
+
+func TestRunHeartbeat_InvalidInterval(t *testing.T) {
+	hb := config.HeartbeatConfig{
+		EndpointConfig: config.EndpointConfig{URL: "http://127.0.0.1:0"},
+		Interval:       "invalid",
+	}
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+
+	done := make(chan struct{})
+	go func() {
+		RunHeartbeat(context.Background(), hb, NewConfigState(&config.Config{}), notifier.NewPusher(), wg)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunHeartbeat should return immediately on invalid interval")
+	}
+}
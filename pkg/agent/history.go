@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"probixel/pkg/config"
+	"probixel/pkg/monitor"
+)
+
+// HistoryEntry is the outcome of one check of a service.
+type HistoryEntry struct {
+	Timestamp time.Time
+	Success   bool
+}
+
+// History keeps the most recent check outcomes of each service in a fixed
+// size ring buffer, so an uptime ratio can be computed without external storage.
+type History struct {
+	mu       sync.Mutex
+	size     int
+	services map[string]*historyRing
+}
+
+type historyRing struct {
+	entries []HistoryEntry
+	next    int // Index the next entry is written to
+	full    bool
+}
+
+// NewHistory returns a History keeping size entries per service. A size
+// below 1 uses config.DefaultHistorySize.
+func NewHistory(size int) *History {
+	return &History{size: historySize(size), services: make(map[string]*historyRing)}
+}
+
+// Record adds the outcome of a check. Pending results are not recorded as
+// they say nothing about the service yet.
+func (h *History) Record(service string, res monitor.Result) {
+	if res.Pending {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.services[service]
+	if !ok {
+		r = &historyRing{entries: make([]HistoryEntry, h.size)}
+		h.services[service] = r
+	}
+	r.entries[r.next] = HistoryEntry{Timestamp: res.Timestamp, Success: res.Success}
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Entries returns the recorded outcomes of service, oldest first.
+func (h *History) Entries(service string) []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.services[service]
+	if !ok {
+		return nil
+	}
+	return r.ordered()
+}
+
+// UptimeRatio returns the fraction of recorded checks of service that
+// succeeded, between 0 and 1. ok is false if nothing was recorded yet.
+func (h *History) UptimeRatio(service string) (ratio float64, ok bool) {
+	entries := h.Entries(service)
+	if len(entries) == 0 {
+		return 0, false
+	}
+	up := 0
+	for _, e := range entries {
+		if e.Success {
+			up++
+		}
+	}
+	return float64(up) / float64(len(entries)), true
+}
+
+// Update applies a new window size, keeping the most recent entries, and
+// drops services that are no longer configured.
+func (h *History) Update(size int, services []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	configured := make(map[string]bool, len(services))
+	for _, name := range services {
+		configured[name] = true
+	}
+	for name := range h.services {
+		if !configured[name] {
+			delete(h.services, name)
+		}
+	}
+
+	size = historySize(size)
+	if size == h.size {
+		return
+	}
+	h.size = size
+	for name, r := range h.services {
+		entries := r.ordered()
+		if len(entries) > size {
+			entries = entries[len(entries)-size:]
+		}
+		resized := &historyRing{entries: make([]HistoryEntry, size)}
+		resized.next = copy(resized.entries, entries) % size
+		resized.full = len(entries) == size
+		h.services[name] = resized
+	}
+}
+
+func (r *historyRing) ordered() []HistoryEntry {
+	if !r.full {
+		return append([]HistoryEntry(nil), r.entries[:r.next]...)
+	}
+	return append(append([]HistoryEntry(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+func historySize(size int) int {
+	if size < 1 {
+		return config.DefaultHistorySize
+	}
+	return size
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"probixel/pkg/config"
+	"probixel/pkg/monitor"
+)
+
+func TestHistory_RingBuffer(t *testing.T) {
+	h := NewHistory(3)
+	if _, ok := h.UptimeRatio("svc"); ok {
+		t.Fatal("expected no ratio before any check")
+	}
+
+	base := time.Unix(1700000000, 0)
+	outcomes := []bool{false, true, true, false}
+	for i, success := range outcomes {
+		h.Record("svc", monitor.Result{Success: success, Timestamp: base.Add(time.Duration(i) * time.Minute)})
+	}
+	h.Record("svc", monitor.Result{Pending: true, Timestamp: base.Add(time.Hour)})
+
+	entries := h.Entries("svc")
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	for i, e := range entries {
+		want := base.Add(time.Duration(i+1) * time.Minute)
+		if !e.Timestamp.Equal(want) || e.Success != outcomes[i+1] {
+			t.Errorf("entry %d = %+v, want timestamp %v success %v", i, e, want, outcomes[i+1])
+		}
+	}
+
+	ratio, ok := h.UptimeRatio("svc")
+	if !ok || ratio != 2.0/3.0 {
+		t.Errorf("UptimeRatio = %v, %v, want 0.667", ratio, ok)
+	}
+}
+
+func TestHistory_Update(t *testing.T) {
+	h := NewHistory(0)
+	if h.size != config.DefaultHistorySize {
+		t.Errorf("expected default size %d, got %d", config.DefaultHistorySize, h.size)
+	}
+
+	for i := range 5 {
+		h.Record("kept", monitor.Result{Success: i%2 == 0})
+		h.Record("removed", monitor.Result{Success: true})
+	}
+
+	h.Update(2, []string{"kept"})
+	if entries := h.Entries("removed"); entries != nil {
+		t.Errorf("expected removed service to be dropped, got %+v", entries)
+	}
+	entries := h.Entries("kept")
+	if len(entries) != 2 || entries[0].Success || !entries[1].Success {
+		t.Errorf("expected the 2 most recent entries (down, up), got %+v", entries)
+	}
+
+	// Growing keeps existing entries and appends after them
+	h.Update(4, []string{"kept"})
+	h.Record("kept", monitor.Result{Success: false})
+	if entries := h.Entries("kept"); len(entries) != 3 || entries[2].Success {
+		t.Errorf("expected 3 entries ending with the new failure, got %+v", entries)
+	}
+}
+
+func TestHistory_Concurrent(t *testing.T) {
+	h := NewHistory(10)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				h.Record("svc", monitor.Result{Success: true})
+				_, _ = h.UptimeRatio("svc")
+			}
+		}()
+	}
+	wg.Wait()
+	if entries := h.Entries("svc"); len(entries) != 10 {
+		t.Errorf("expected 10 entries, got %d", len(entries))
+	}
+}
@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"probixel/pkg/monitor"
+)
+
+// Metrics keeps the latest result and check counts of each service, exposed
+// in the Prometheus text format. Like History, it outlives config reloads,
+// which only drop removed services.
+type Metrics struct {
+	mu       sync.Mutex
+	services map[string]*serviceMetrics
+	history  *History // Source of the uptime ratio, optional
+}
+
+type serviceMetrics struct {
+	typ      string
+	checked  bool // A non-pending result was recorded, so up and duration are known
+	up       bool
+	duration float64                // Seconds
+	targets  []monitor.TargetResult // Outcome of each target of the latest result
+	checks   map[string]uint64
+}
+
+// Check result labels of probixel_probe_checks_total
+const (
+	checkResultSuccess = "success"
+	checkResultFailure = "failure"
+	checkResultPending = "pending"
+)
+
+// NewMetrics returns a Metrics exposing the uptime ratio of each service from
+// history, if not nil.
+func NewMetrics(history *History) *Metrics {
+	return &Metrics{services: make(map[string]*serviceMetrics), history: history}
+}
+
+// Record counts a check of service and, unless it is pending, makes it the
+// service's latest result.
+func (m *Metrics) Record(service, typ string, res monitor.Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.services[service]
+	if !ok {
+		s = &serviceMetrics{checks: make(map[string]uint64)}
+		m.services[service] = s
+	}
+	s.typ = typ
+
+	switch {
+	case res.Pending:
+		s.checks[checkResultPending]++
+		return
+	case res.Success:
+		s.checks[checkResultSuccess]++
+	default:
+		s.checks[checkResultFailure]++
+	}
+	s.checked = true
+	s.up = res.Success
+	s.duration = res.Duration.Seconds()
+	s.targets = slices.Clone(res.TargetResults)
+}
+
+// Update drops services that are no longer configured.
+func (m *Metrics) Update(services []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name := range m.services {
+		if !slices.Contains(services, name) {
+			delete(m.services, name)
+		}
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// write writes the metrics, services sorted by name.
+func (m *Metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.services))
+	for name := range m.services {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	_, _ = fmt.Fprintln(w, "# HELP probixel_probe_up Whether the last check of the service, or of one of its targets, succeeded.")
+	_, _ = fmt.Fprintln(w, "# TYPE probixel_probe_up gauge")
+	for _, name := range names {
+		if s := m.services[name]; s.checked {
+			up := 0
+			if s.up {
+				up = 1
+			}
+			_, _ = fmt.Fprintf(w, "probixel_probe_up{%s} %d\n", s.labels(name), up)
+			for _, tr := range s.targets {
+				up := 0
+				if tr.Success {
+					up = 1
+				}
+				_, _ = fmt.Fprintf(w, "probixel_probe_up{%s} %d\n", s.targetLabels(name, tr.Target), up)
+			}
+		}
+	}
+
+	_, _ = fmt.Fprintln(w, "# HELP probixel_probe_duration_seconds Duration of the last check of the service, or of one of its targets.")
+	_, _ = fmt.Fprintln(w, "# TYPE probixel_probe_duration_seconds gauge")
+	for _, name := range names {
+		if s := m.services[name]; s.checked {
+			_, _ = fmt.Fprintf(w, "probixel_probe_duration_seconds{%s} %g\n", s.labels(name), s.duration)
+			for _, tr := range s.targets {
+				_, _ = fmt.Fprintf(w, "probixel_probe_duration_seconds{%s} %g\n", s.targetLabels(name, tr.Target), tr.Duration.Seconds())
+			}
+		}
+	}
+
+	if m.history != nil {
+		_, _ = fmt.Fprintln(w, "# HELP probixel_probe_uptime_ratio Share of the recent checks of the service that succeeded.")
+		_, _ = fmt.Fprintln(w, "# TYPE probixel_probe_uptime_ratio gauge")
+		for _, name := range names {
+			if ratio, ok := m.history.UptimeRatio(name); ok {
+				_, _ = fmt.Fprintf(w, "probixel_probe_uptime_ratio{%s} %g\n", m.services[name].labels(name), ratio)
+			}
+		}
+	}
+
+	_, _ = fmt.Fprintln(w, "# HELP probixel_probe_checks_total Checks of the service by result.")
+	_, _ = fmt.Fprintln(w, "# TYPE probixel_probe_checks_total counter")
+	for _, name := range names {
+		s := m.services[name]
+		for _, result := range []string{checkResultSuccess, checkResultFailure, checkResultPending} {
+			if n, ok := s.checks[result]; ok {
+				_, _ = fmt.Fprintf(w, "probixel_probe_checks_total{service=\"%s\",type=\"%s\",result=\"%s\"} %d\n", labelValue(name), labelValue(s.typ), result, n)
+			}
+		}
+	}
+}
+
+func (s *serviceMetrics) labels(service string) string {
+	return fmt.Sprintf(`service="%s",type="%s"`, labelValue(service), labelValue(s.typ))
+}
+
+// targetLabels are the labels of the series of one target of the service.
+func (s *serviceMetrics) targetLabels(service, target string) string {
+	return fmt.Sprintf(`%s,target="%s"`, s.labels(service), labelValue(target))
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelValue escapes v for use between the quotes of a label value.
+func labelValue(v string) string {
+	return labelEscaper.Replace(v)
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"probixel/pkg/monitor"
+)
+
+func TestMetrics(t *testing.T) {
+	history := NewHistory(10)
+	m := NewMetrics(history)
+	for _, r := range []struct {
+		service, typ string
+		res          monitor.Result
+	}{
+		{"web", "http", monitor.Result{Success: true, Duration: 250 * time.Millisecond, Target: "https://example.com"}},
+		{"queue", "tcp", monitor.Result{Pending: true}},
+		{"web", "http", monitor.Result{Success: true, Duration: 120 * time.Millisecond, Target: "https://example.com"}},
+		{"dns", "dns", monitor.Result{Success: false, Duration: time.Second, Target: `ns"1\`}},
+		{"db", "tcp", monitor.Result{Success: true, Duration: 30 * time.Millisecond, Target: "db1:5432", TargetResults: []monitor.TargetResult{
+			{Target: "db1:5432", Success: true, Duration: 30 * time.Millisecond, Message: "OK"},
+			{Target: "db2:5432", Duration: 2 * time.Second, Message: "connection refused"},
+		}}},
+	} {
+		history.Record(r.service, r.res)
+		m.Record(r.service, r.typ, r.res)
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE probixel_probe_up gauge\n",
+		`probixel_probe_up{service="web",type="http"} 1` + "\n",
+		`probixel_probe_up{service="dns",type="dns"} 0` + "\n",
+		`probixel_probe_up{service="db",type="tcp"} 1` + "\n",
+		`probixel_probe_up{service="db",type="tcp",target="db1:5432"} 1` + "\n",
+		`probixel_probe_up{service="db",type="tcp",target="db2:5432"} 0` + "\n",
+		`probixel_probe_duration_seconds{service="web",type="http"} 0.12` + "\n",
+		`probixel_probe_duration_seconds{service="db",type="tcp",target="db2:5432"} 2` + "\n",
+		`probixel_probe_uptime_ratio{service="web",type="http"} 1` + "\n",
+		`probixel_probe_uptime_ratio{service="dns",type="dns"} 0` + "\n",
+		`probixel_probe_checks_total{service="web",type="http",result="success"} 2` + "\n",
+		`probixel_probe_checks_total{service="dns",type="dns",result="failure"} 1` + "\n",
+		`probixel_probe_checks_total{service="queue",type="tcp",result="pending"} 1` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+	// A service that is still pending has no known state yet
+	if strings.Contains(body, `probixel_probe_up{service="queue"`) || strings.Contains(body, `probixel_probe_uptime_ratio{service="queue"`) {
+		t.Errorf("expected no up gauge for a pending service, got:\n%s", body)
+	}
+	// The deciding target doesn't label the aggregate, which would split it
+	// into a new series on each failover
+	if strings.Count(body, `probixel_probe_up{service="web"`) != 1 || strings.Count(body, `probixel_probe_up{service="db"`) != 3 {
+		t.Errorf("expected a single aggregate series per service, got:\n%s", body)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	m.Update([]string{"web"})
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if body := rec.Body.String(); strings.Contains(body, `service="dns"`) || strings.Contains(body, `service="db"`) || strings.Contains(body, `service="queue"`) {
+		t.Errorf("expected removed services to be dropped, got:\n%s", body)
+	}
+}
@@ -2,17 +2,23 @@ package agent
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
 	"strings"
 	"sync"
 	"time"
 
 	"probixel/pkg/config"
+	"probixel/pkg/logging"
 	"probixel/pkg/monitor"
 	"probixel/pkg/notifier"
 	"probixel/pkg/tunnels"
 )
 
+// timeNow is the clock maintenance windows are evaluated against.
+var timeNow = time.Now
+
 func RunServiceMonitor(ctx context.Context, svc config.Service, probe monitor.Probe, state *ConfigState, registry *tunnels.Registry, pusher *notifier.Pusher, wg *sync.WaitGroup) {
 	defer wg.Done()
 
@@ -23,16 +29,32 @@ func RunServiceMonitor(ctx context.Context, svc config.Service, probe monitor.Pr
 
 	duration, err := config.ParseDuration(intervalStr)
 	if err != nil {
-		log.Printf("[%s] Invalid interval %q: %v", svc.Name, intervalStr, err)
+		logging.Errorf(svc.Name, "Invalid interval %q: %v", intervalStr, err)
 		return
 	}
 
+	// Ticks are offset by the service's jitter, which spreads the checks of
+	// services sharing an interval for as long as they run
+	global := state.Get().Global
+	checkOnStart := global.CheckOnStart == nil || *global.CheckOnStart
+	offset := jitterOffset(global, svc.Name, duration)
+	firstTick := duration + offset
+	if !checkOnStart && offset > 0 {
+		firstTick = offset
+	}
+	first := time.NewTimer(firstTick)
+	defer first.Stop()
 	ticker := time.NewTicker(duration)
+	ticker.Stop() // Started by the first tick
 	defer ticker.Stop()
 
 	var checkMu sync.Mutex
 	var checkCancel context.CancelFunc
 
+	// Probes keep per-check state, so the scheduled checks and those requested
+	// through the status API take turns
+	var runMu sync.Mutex
+
 	runCheck := func() {
 		checkMu.Lock()
 		if checkCancel != nil {
@@ -41,11 +63,22 @@ func RunServiceMonitor(ctx context.Context, svc config.Service, probe monitor.Pr
 		checkCtx, cancel := context.WithCancel(ctx)
 		checkCancel = cancel
 		checkMu.Unlock()
+		runMu.Lock()
+		defer runMu.Unlock()
 		CheckAndPush(checkCtx, probe, svc.Name, state, registry, pusher)
 	}
 
-	// First check
-	runCheck()
+	defer state.Checks.register(svc.Name, func() (ServiceStatus, bool) {
+		runMu.Lock()
+		defer runMu.Unlock()
+		return CheckAndPush(ctx, probe, svc.Name, state, registry, pusher)
+	})()
+
+	// First check, only pushed if the status changed since the last run
+	state.StateFile.Resume(svc.Name)
+	if checkOnStart {
+		runCheck()
+	}
 
 	for {
 		select {
@@ -56,13 +89,36 @@ func RunServiceMonitor(ctx context.Context, svc config.Service, probe monitor.Pr
 			}
 			checkMu.Unlock()
 			return
+		case <-first.C:
+			ticker.Reset(duration)
+			runCheck()
 		case <-ticker.C:
 			runCheck()
 		}
 	}
 }
 
-func CheckAndPush(ctx context.Context, probe monitor.Probe, serviceName string, state *ConfigState, registry *tunnels.Registry, pusher *notifier.Pusher) {
+// jitterOffset returns how far into interval the ticks of service are
+// offset, a random share of global.jitter percent of it. The share is derived
+// from the service name when global.jitter_seed is set, so it is the same
+// on every run.
+func jitterOffset(global config.GlobalConfig, service string, interval time.Duration) time.Duration {
+	if global.Jitter <= 0 {
+		return 0
+	}
+	share := rand.Float64()
+	if global.JitterSeed != nil {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(service))
+		share = rand.New(rand.NewPCG(uint64(*global.JitterSeed), h.Sum64())).Float64()
+	}
+	return time.Duration(share * float64(global.Jitter) / 100 * float64(interval))
+}
+
+// CheckAndPush checks the service once, records the result and pushes it if
+// due. It returns the result as reported by the status API, false if the
+// service is no longer configured.
+func CheckAndPush(ctx context.Context, probe monitor.Probe, serviceName string, state *ConfigState, registry *tunnels.Registry, pusher *notifier.Pusher) (ServiceStatus, bool) {
 	cfg := state.Get()
 	var svc *config.Service
 	for i := range cfg.Services {
@@ -73,9 +129,53 @@ func CheckAndPush(ctx context.Context, probe monitor.Probe, serviceName string,
 	}
 
 	if svc == nil {
-		return
+		return ServiceStatus{}, false
+	}
+
+	result := RunCheck(ctx, probe, *svc, cfg, registry)
+
+	status := "DOWN"
+	if result.Pending {
+		status = "WAITING"
+	} else if result.Success {
+		status = "UP"
+	}
+	logging.Check(svc.Name, status, result.Message, result.Duration)
+	state.History.Record(svc.Name, result)
+	st := state.Status.Record(svc.Name, svc.Type, result)
+	result.Since = st.InStatusFor()
+	state.Metrics.Record(svc.Name, svc.Type, result)
+	unchanged := state.StateFile.Unchanged(svc.Name, result)
+	if !result.Pending && cfg.InMaintenance(*svc, result.Success, timeNow()) {
+		// Kept out of Alerts, so a service still down once the window ends is
+		// pushed as a status change
+		logging.Infof(svc.Name, "%s suppressed (maintenance)", status)
+		return st, true
+	}
+	if unchanged {
+		logging.Infof(svc.Name, "Status unchanged since the last run, not pushing")
+		state.Alerts.Pushed(svc.Name, result) // Before the restart
+		return st, true
+	}
+	if !state.Alerts.Due(svc.Name, result, cfg.OnChangeOnly(*svc), cfg.ReminderInterval(*svc)) {
+		return st, true // on_change_only: same status as the last push
 	}
 
+	if err := pusher.Push(ctx, svc.Name, result, svc.MonitorEndpoint, cfg.Global.MonitorEndpoint); err != nil {
+		// Left unrecorded, so the next check pushes it again
+		logging.Warnf(svc.Name, "Failed to push alert: %v", err)
+		return st, true
+	}
+	state.Alerts.Pushed(svc.Name, result)
+	state.StateFile.Record(svc.Name, result)
+	return st, true
+}
+
+// RunCheck checks svc once with probe, retrying failed attempts up to the
+// service's probe retries, and reports a success to the service's tunnel.
+// Pending results fail once the tunnel exceeds its max_stabilization_time.
+// Successful attempts slower than the service's max_response_time fail.
+func RunCheck(ctx context.Context, probe monitor.Probe, svc config.Service, cfg *config.Config, registry *tunnels.Registry) monitor.Result {
 	target := svc.Target
 	if target == "" {
 		target = svc.URL
@@ -83,35 +183,47 @@ func CheckAndPush(ctx context.Context, probe monitor.Probe, serviceName string,
 	if target == "" && len(svc.Targets) > 0 {
 		target = strings.Join(svc.Targets, ",")
 	}
-
-	// Determine effective probe retries
-	retries := 3
-	if cfg.Global.Monitor.Retries != nil {
-		retries = *cfg.Global.Monitor.Retries
-	}
-	if svc.Retries != nil {
-		retries = *svc.Retries
-	}
-	// Exempt host and wireguard probes
-	if svc.Type == "host" || svc.Type == "wireguard" {
-		retries = 0
+	if svc.Type == "http" && len(svc.Targets) > 0 {
+		// The url is a template, the probe substitutes each target into it
+		target = strings.Join(svc.Targets, ",")
 	}
 
+	retries := cfg.ProbeRetries(svc)
+
 	var result monitor.Result
 	var lastErr error
 
+	// Bound each attempt across all targets, so long target lists can't overrun the interval
+	checkTimeout := cfg.CheckTimeout(svc)
+	retryInterval := cfg.RetryInterval(svc)
+	maxResponseTime := cfg.MaxResponseTime(svc)
+
 	for attempt := 0; attempt <= retries; attempt++ {
 		if attempt > 0 {
-			log.Printf("[%s] Retrying probe check (attempt %d/%d)...", svc.Name, attempt, retries)
+			if retryInterval > 0 && !sleepContext(ctx, retryInterval) {
+				// Shutting down, report the last attempt
+				break
+			}
+			logging.Infof(svc.Name, "Retrying probe check (attempt %d/%d)...", attempt, retries)
 		}
 
-		result, lastErr = probe.Check(ctx, target)
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if checkTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, checkTimeout)
+		}
+		result, lastErr = probe.Check(attemptCtx, target)
+		cancel()
+		if maxResponseTime > 0 && result.Success && result.Duration > maxResponseTime {
+			// Too slow to be of use, fail it like any other failure
+			result.Success = false
+			result.Message = fmt.Sprintf("%s (slow: %v > %v)", result.Message, result.Duration.Round(time.Millisecond), maxResponseTime)
+		}
 		if lastErr == nil && !result.Pending && result.Success {
 			// Success!
 			break
 		}
 		if lastErr != nil {
-			log.Printf("[%s] Probe internal error: %v", svc.Name, lastErr)
+			logging.Errorf(svc.Name, "Probe internal error: %v", lastErr)
 			// Continue to retry if internal error? Usually yes if it's a transient failure.
 		}
 		if !result.Success && !result.Pending && attempt < retries {
@@ -127,21 +239,31 @@ func CheckAndPush(ctx context.Context, probe monitor.Probe, serviceName string,
 		result.Message = lastErr.Error()
 	}
 
+	if svc.Tunnel != "" {
+		// A tunnel that never stabilizes would keep the service pending forever
+		if err := registry.CheckStabilization(svc.Tunnel); err != nil && result.Pending {
+			result.Pending = false
+			result.Success = false
+			result.Message = err.Error()
+		}
+	}
+
 	if result.Success && svc.Tunnel != "" {
 		if tunnel, ok := registry.Get(svc.Tunnel); ok {
 			tunnel.ReportSuccess()
 		}
 	}
+	return result
+}
 
-	status := "DOWN"
-	if result.Pending {
-		status = "WAITING"
-	} else if result.Success {
-		status = "UP"
-	}
-	log.Printf("[%s] %s (%s) %v", svc.Name, status, result.Message, result.Duration)
-
-	if err := pusher.Push(ctx, svc.Name, result, svc.MonitorEndpoint, cfg.Global.MonitorEndpoint); err != nil {
-		log.Printf("[%s] Failed to push alert: %v", svc.Name, err)
+// sleepContext waits for d, returning false if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
 }
@@ -2,6 +2,11 @@ package agent
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -32,6 +37,7 @@ func (m *mockProbe) SetTunnel(t tunnels.Tunnel) {
 	m.tunnel = t
 }
 func (m *mockProbe) SetTargetMode(mode string)        {}
+func (m *mockProbe) Describe() monitor.ProbeInfo      { return monitor.ProbeInfo{Type: "mock"} }
 func (m *mockProbe) SetTimeout(timeout time.Duration) {}
 
 func TestCheckAndPush(t *testing.T) {
@@ -63,6 +69,10 @@ func TestCheckAndPush(t *testing.T) {
 
 	// Just ensure it doesn't panic
 	CheckAndPush(ctx, p, svcName, state, registry, pusher)
+
+	if ratio, ok := state.History.UptimeRatio(svcName); !ok || ratio != 1 {
+		t.Errorf("expected the check to be recorded with uptime ratio 1, got %v (recorded: %v)", ratio, ok)
+	}
 }
 
 func TestRunServiceMonitor_StopsOnContextCancel(t *testing.T) {
@@ -263,6 +273,37 @@ func TestCheckAndPush_PendingResult(t *testing.T) {
 	CheckAndPush(ctx, p, svcName, state, registry, pusher)
 }
 
+func TestRunCheck_TunnelNeverStabilizes(t *testing.T) {
+	registry := tunnels.NewRegistry()
+	reported := false
+	unstableTunnel := &tunnels.MockTunnel{
+		NameFunc:          func() string { return "t1" },
+		ReportFailureFunc: func() { reported = true },
+	}
+	_ = registry.Register(unstableTunnel)
+	registry.SetMaxStabilizationTime("t1", 50*time.Millisecond)
+
+	svc := config.Service{Name: "service", Tunnel: "t1", Retries: ptrInt(1)}
+	cfg := &config.Config{Services: []config.Service{svc}}
+	p := &mockProbe{
+		name:        "service",
+		checkResult: monitor.Result{Pending: true, Message: `waiting for tunnel "t1" to stabilize`},
+	}
+
+	if res := RunCheck(context.Background(), p, svc, cfg, registry); !res.Pending {
+		t.Fatalf("expected pending within max_stabilization_time, got %+v", res)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	res := RunCheck(context.Background(), p, svc, cfg, registry)
+	if res.Pending || res.Success || res.Message != `tunnel "t1" not stabilized after 50ms` {
+		t.Errorf("expected DOWN after max_stabilization_time, got %+v", res)
+	}
+	if !reported {
+		t.Error("expected the failure to be reported to the tunnel")
+	}
+}
+
 func TestRunServiceMonitor_InvalidInterval(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -334,6 +375,98 @@ func TestRunServiceMonitor_UsesGlobalDefaultInterval(t *testing.T) {
 		t.Fatal("RunServiceMonitor did not stop after context cancellation")
 	}
 }
+
+func TestRunServiceMonitor_Jitter(t *testing.T) {
+	checkOnStart := false
+	seed := int64(42)
+	names := []string{"jitter-a", "jitter-b", "jitter-c", "jitter-d"}
+	cfg := &config.Config{
+		Global: config.GlobalConfig{
+			DefaultInterval: "1s",
+			Jitter:          100,
+			JitterSeed:      &seed,
+			CheckOnStart:    &checkOnStart,
+		},
+	}
+	for _, name := range names {
+		cfg.Services = append(cfg.Services, config.Service{Name: name})
+	}
+	state := NewConfigState(cfg)
+	registry := tunnels.NewRegistry()
+	pusher := notifier.NewPusher()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wg := &sync.WaitGroup{}
+	var mu sync.Mutex
+	firstChecks := make(map[string]time.Duration)
+	start := time.Now()
+	for _, svc := range cfg.Services {
+		p := &statusMockProbe{
+			mockProbe: mockProbe{name: svc.Name},
+			checkFunc: func(ctx context.Context, target string) (monitor.Result, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if _, ok := firstChecks[svc.Name]; !ok {
+					firstChecks[svc.Name] = time.Since(start)
+				}
+				return monitor.Result{Success: true, Message: "OK"}, nil
+			},
+		}
+		wg.Add(1)
+		go RunServiceMonitor(ctx, svc, p, state, registry, pusher, wg)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	seen := make(map[time.Duration]bool)
+	for _, name := range names {
+		got, ok := firstChecks[name]
+		if !ok {
+			t.Errorf("expected %s to be checked within its interval", name)
+			continue
+		}
+		want := jitterOffset(cfg.Global, name, time.Second)
+		if got < want || got > want+200*time.Millisecond {
+			t.Errorf("expected the first check of %s at %v, got %v", name, want, got)
+		}
+		seen[want] = true
+	}
+	if len(seen) < len(names) {
+		t.Errorf("expected staggered first checks, got %v", firstChecks)
+	}
+}
+
+func TestJitterOffset(t *testing.T) {
+	seed := int64(7)
+	global := config.GlobalConfig{Jitter: 50, JitterSeed: &seed}
+	interval := 10 * time.Second
+
+	for _, name := range []string{"api", "db", "cache"} {
+		offset := jitterOffset(global, name, interval)
+		if offset < 0 || offset >= interval/2 {
+			t.Errorf("expected the offset of %s within 50%% of %v, got %v", name, interval, offset)
+		}
+		if again := jitterOffset(global, name, interval); again != offset {
+			t.Errorf("expected a seeded offset to be stable, got %v then %v", offset, again)
+		}
+	}
+	if jitterOffset(global, "api", interval) == jitterOffset(global, "db", interval) {
+		t.Error("expected services to get different offsets")
+	}
+	other := int64(8)
+	if jitterOffset(config.GlobalConfig{Jitter: 50, JitterSeed: &other}, "api", interval) == jitterOffset(global, "api", interval) {
+		t.Error("expected another seed to give another offset")
+	}
+	if offset := jitterOffset(config.GlobalConfig{}, "api", interval); offset != 0 {
+		t.Errorf("expected no offset without jitter, got %v", offset)
+	}
+}
+
 func TestCheckAndPush_ProbeRetries(t *testing.T) {
 	ctx := context.Background()
 	svcName := "retry-service"
@@ -420,3 +553,425 @@ func TestCheckAndPush_ProbeRetries_Exemptions(t *testing.T) {
 		t.Errorf("Expected 1 attempt for exempt host service, got %d", attempts)
 	}
 }
+
+// blockingProbe blocks each check until its context is done.
+type blockingProbe struct {
+	mockProbe
+	deadlines []time.Duration
+}
+
+func (p *blockingProbe) Check(ctx context.Context, target string) (monitor.Result, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		p.deadlines = append(p.deadlines, time.Until(deadline))
+	}
+	<-ctx.Done()
+	return monitor.Result{Success: false, Message: ctx.Err().Error()}, nil
+}
+
+func TestCheckAndPush_TotalTimeout(t *testing.T) {
+	svcName := "slow-service"
+	cfg := &config.Config{
+		Services: []config.Service{
+			{Name: svcName, Type: "tcp", Targets: []string{"a:80", "b:80"}, Interval: "1m", TotalTimeout: "50ms", Retries: ptrInt(1)},
+		},
+	}
+	state := NewConfigState(cfg)
+	p := &blockingProbe{mockProbe: mockProbe{name: svcName}}
+
+	start := time.Now()
+	CheckAndPush(context.Background(), p, svcName, state, tunnels.NewRegistry(), notifier.NewPusher())
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Expected total_timeout to bound the check, took %v", elapsed)
+	}
+
+	if len(p.deadlines) != 2 {
+		t.Fatalf("Expected a deadline on each of the 2 attempts, got %d", len(p.deadlines))
+	}
+	for _, d := range p.deadlines {
+		if d > 50*time.Millisecond {
+			t.Errorf("Expected attempt deadline within 50ms, got %v", d)
+		}
+	}
+}
+
+func TestCheckAndPush_RetryInterval(t *testing.T) {
+	var pushed []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		pushed = append(pushed, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	svcName := "flaky-service"
+	cfg := &config.Config{
+		Services: []config.Service{{
+			Name:          svcName,
+			Target:        "target",
+			Type:          "tcp",
+			Interval:      "1m",
+			Retries:       ptrInt(2),
+			RetryInterval: "50ms",
+			MonitorEndpoint: config.MonitorEndpointConfig{
+				Success: config.EndpointList{{URL: server.URL + "/up"}},
+				Failure: config.EndpointList{{URL: server.URL + "/down"}},
+			},
+		}},
+	}
+	state := NewConfigState(cfg)
+
+	// Fails once, then succeeds on the second attempt
+	var attempts []time.Time
+	sp := &statusMockProbe{checkFunc: func(ctx context.Context, target string) (monitor.Result, error) {
+		attempts = append(attempts, time.Now())
+		if len(attempts) == 1 {
+			return monitor.Result{Success: false, Message: "connection reset"}, nil
+		}
+		return monitor.Result{Success: true, Message: "OK"}, nil
+	}}
+
+	CheckAndPush(context.Background(), sp, svcName, state, tunnels.NewRegistry(), notifier.NewPusher())
+
+	if len(attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(attempts))
+	}
+	if gap := attempts[1].Sub(attempts[0]); gap < 50*time.Millisecond {
+		t.Errorf("expected the retry to wait retry_interval, waited %v", gap)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(pushed) != 1 || pushed[0] != "/up" {
+		t.Errorf("expected only the success alert to be pushed, got %v", pushed)
+	}
+}
+
+func TestCheckAndPush_OnChangeOnly(t *testing.T) {
+	var pushed []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		pushed = append(pushed, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	svcName := "steady-service"
+	cfg := &config.Config{
+		Global: config.GlobalConfig{Notifier: config.NotifierConfig{OnChangeOnly: true}},
+		Services: []config.Service{{
+			Name:     svcName,
+			Target:   "target",
+			Type:     "tcp",
+			Interval: "1m",
+			Retries:  ptrInt(0),
+			MonitorEndpoint: config.MonitorEndpointConfig{
+				Success: config.EndpointList{{URL: server.URL + "/up"}},
+				Failure: config.EndpointList{{URL: server.URL + "/down"}},
+			},
+		}},
+	}
+	state := NewConfigState(cfg)
+	pusher := notifier.NewPusher()
+	noLimit := "0"
+	pusher.SetRateLimit(&noLimit)
+
+	up := true
+	sp := &statusMockProbe{checkFunc: func(ctx context.Context, target string) (monitor.Result, error) {
+		return monitor.Result{Success: up, Message: "checked"}, nil
+	}}
+
+	for range 5 {
+		CheckAndPush(context.Background(), sp, svcName, state, tunnels.NewRegistry(), pusher)
+	}
+	up = false
+	for range 2 {
+		CheckAndPush(context.Background(), sp, svcName, state, tunnels.NewRegistry(), pusher)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"/up", "/down"}; !slices.Equal(pushed, want) {
+		t.Errorf("expected only the first check and the status change to be pushed (%v), got %v", want, pushed)
+	}
+}
+
+func TestCheckAndPush_RetriesUndelivered(t *testing.T) {
+	var pushed []string
+	failNext := false
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		pushed = append(pushed, r.URL.Path)
+		if failNext {
+			failNext = false
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	svcName := "flaky-endpoint"
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	cfg := &config.Config{
+		Global: config.GlobalConfig{StateFile: statePath, Notifier: config.NotifierConfig{OnChangeOnly: true}},
+		Services: []config.Service{{
+			Name:     svcName,
+			Target:   "target",
+			Type:     "tcp",
+			Interval: "1m",
+			Retries:  ptrInt(0),
+			MonitorEndpoint: config.MonitorEndpointConfig{
+				Retries: ptrInt(0),
+				Success: config.EndpointList{{URL: server.URL + "/up"}},
+				Failure: config.EndpointList{{URL: server.URL + "/down"}},
+			},
+		}},
+	}
+	state := NewConfigState(cfg)
+	pusher := notifier.NewPusher()
+	noLimit := "0"
+	pusher.SetRateLimit(&noLimit)
+	pusher.SetMaxBackoff("0")
+
+	up := true
+	sp := &statusMockProbe{checkFunc: func(ctx context.Context, target string) (monitor.Result, error) {
+		return monitor.Result{Success: up, Message: "checked"}, nil
+	}}
+	check := func() { CheckAndPush(context.Background(), sp, svcName, state, tunnels.NewRegistry(), pusher) }
+
+	check()
+	up = false
+	mu.Lock()
+	failNext = true
+	mu.Unlock()
+	check() // The endpoint fails the DOWN push
+
+	if persisted := NewStateFile(statePath).services[svcName]; !persisted.Success {
+		t.Error("expected the undelivered DOWN not to be persisted")
+	}
+
+	check() // Pushed again
+	check()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"/up", "/down", "/down"}; !slices.Equal(pushed, want) {
+		t.Errorf("expected the failed DOWN push to be retried once (%v), got %v", want, pushed)
+	}
+	if persisted := NewStateFile(statePath).services[svcName]; persisted.Success {
+		t.Error("expected the delivered DOWN to be persisted")
+	}
+}
+
+func TestCheckAndPush_Since(t *testing.T) {
+	var pushed []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		pushed = append(pushed, r.URL.Path+" "+r.URL.Query().Get("since"))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	svcName := "flapping-service"
+	cfg := &config.Config{
+		Services: []config.Service{{
+			Name:     svcName,
+			Target:   "target",
+			Type:     "tcp",
+			Interval: "1m",
+			Retries:  ptrInt(0),
+			MonitorEndpoint: config.MonitorEndpointConfig{
+				Success: config.EndpointList{{URL: server.URL + "/up?since={%since%}"}},
+				Failure: config.EndpointList{{URL: server.URL + "/down?since={%since%}"}},
+			},
+		}},
+	}
+	state := NewConfigState(cfg)
+	pusher := notifier.NewPusher()
+	noLimit := "0"
+	pusher.SetRateLimit(&noLimit)
+
+	start := time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)
+	var result monitor.Result
+	sp := &statusMockProbe{checkFunc: func(ctx context.Context, target string) (monitor.Result, error) {
+		return result, nil
+	}}
+	checks := []struct {
+		minute  int
+		success bool
+		pending bool
+	}{
+		{0, true, false},
+		{1, true, false},
+		{2, false, false}, // Flips, so since starts over
+		{3, false, false},
+		{4, false, true}, // Pending results aren't a status change
+		{5, false, false},
+		{6, true, false},
+	}
+	for _, c := range checks {
+		result = monitor.Result{Success: c.success, Pending: c.pending, Message: "checked", Timestamp: start.Add(time.Duration(c.minute) * time.Minute)}
+		CheckAndPush(context.Background(), sp, svcName, state, tunnels.NewRegistry(), pusher)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"/up 0", "/up 60", "/down 0", "/down 60", "/down 180", "/up 0"}
+	if !slices.Equal(pushed, want) {
+		t.Errorf("expected since to start over on each status change (%v), got %v", want, pushed)
+	}
+	if st, _ := state.Status.Get(svcName); !st.Since.Equal(start.Add(6 * time.Minute)) {
+		t.Errorf("expected the status API to report the last change, got %v", st.Since)
+	}
+}
+
+func TestCheckAndPush_Maintenance(t *testing.T) {
+	var pushed []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		pushed = append(pushed, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	svcName := "nightly-service"
+	cfg := &config.Config{
+		Global: config.GlobalConfig{
+			Notifier: config.NotifierConfig{OnChangeOnly: true},
+			Maintenance: &config.MaintenanceConfig{
+				Timezone: "Europe/Paris",
+				Windows:  []config.MaintenanceWindow{{Start: "02:00", End: "03:00"}},
+			},
+		},
+		Services: []config.Service{{
+			Name:     svcName,
+			Target:   "target",
+			Type:     "tcp",
+			Interval: "1m",
+			Retries:  ptrInt(0),
+			MonitorEndpoint: config.MonitorEndpointConfig{
+				Success: config.EndpointList{{URL: server.URL + "/up"}},
+				Failure: config.EndpointList{{URL: server.URL + "/down"}},
+			},
+			Maintenance: &config.MaintenanceConfig{
+				Timezone:        "Europe/Paris",
+				SuppressSuccess: true,
+				Windows:         []config.MaintenanceWindow{{Days: []string{"sun"}, Start: "12:00", End: "13:00"}},
+			},
+		}},
+	}
+	state := NewConfigState(cfg)
+	pusher := notifier.NewPusher()
+	noLimit := "0"
+	pusher.SetRateLimit(&noLimit)
+
+	up := true
+	sp := &statusMockProbe{checkFunc: func(ctx context.Context, target string) (monitor.Result, error) {
+		return monitor.Result{Success: up, Message: "checked"}, nil
+	}}
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatalf("failed to load time zone: %v", err)
+	}
+	defer func() { timeNow = time.Now }()
+	check := func(at time.Time, success bool) {
+		timeNow = func() time.Time { return at }
+		up = success
+		CheckAndPush(context.Background(), sp, svcName, state, tunnels.NewRegistry(), pusher)
+	}
+
+	check(time.Date(2026, 3, 3, 1, 59, 0, 0, paris), true)  // Tuesday, before the global window
+	check(time.Date(2026, 3, 3, 2, 0, 0, 0, paris), false)  // Suppressed
+	check(time.Date(2026, 3, 3, 2, 59, 0, 0, paris), true)  // Successes are not
+	check(time.Date(2026, 3, 3, 2, 30, 0, 0, paris), false) // Suppressed
+	check(time.Date(2026, 3, 3, 3, 0, 0, 0, paris), false)  // Window over, still down
+	check(time.Date(2026, 3, 8, 12, 30, 0, 0, paris), true) // Sunday, service window suppresses successes too
+	check(time.Date(2026, 3, 8, 13, 0, 0, 0, paris), true)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"/up", "/down", "/up"}; !slices.Equal(pushed, want) {
+		t.Errorf("expected pushes outside maintenance only (%v), got %v", want, pushed)
+	}
+}
+
+func TestRunCheck_RetryIntervalCancel(t *testing.T) {
+	svc := config.Service{Name: "slow-retry", Target: "target", Type: "tcp", Interval: "1h", Retries: ptrInt(3), RetryInterval: "10m"}
+	cfg := &config.Config{Services: []config.Service{svc}}
+
+	attempts := 0
+	sp := &statusMockProbe{checkFunc: func(ctx context.Context, target string) (monitor.Result, error) {
+		attempts++
+		return monitor.Result{Success: false, Message: "failed"}, nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	res := RunCheck(ctx, sp, svc, cfg, tunnels.NewRegistry())
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected cancellation to interrupt the retry wait, took %v", elapsed)
+	}
+	if attempts != 1 || res.Success || res.Message != "failed" {
+		t.Errorf("expected the single failed attempt to be reported, got %d attempts and %+v", attempts, res)
+	}
+}
+
+func TestRunCheck_MaxResponseTime(t *testing.T) {
+	var hits int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name            string
+		maxResponseTime string
+		wantSuccess     bool
+	}{
+		{"within limit", "5s", true},
+		{"slow", "50ms", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			hits = 0
+			mu.Unlock()
+			svc := config.Service{Name: "slow-api", Type: "http", URL: server.URL, Interval: "1m", Retries: ptrInt(1), MaxResponseTime: tt.maxResponseTime}
+			cfg := &config.Config{Services: []config.Service{svc}}
+			probe, err := SetupProbe(svc, cfg, tunnels.NewRegistry())
+			if err != nil {
+				t.Fatalf("SetupProbe failed: %v", err)
+			}
+
+			res := RunCheck(context.Background(), probe, svc, cfg, tunnels.NewRegistry())
+			if res.Success != tt.wantSuccess {
+				t.Fatalf("expected success %v, got %+v", tt.wantSuccess, res)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.wantSuccess {
+				if hits != 1 {
+					t.Errorf("expected a single attempt, got %d", hits)
+				}
+				return
+			}
+			if !strings.Contains(res.Message, "(slow: ") || !strings.HasSuffix(res.Message, " > 50ms)") {
+				t.Errorf("expected a slow message, got %q", res.Message)
+			}
+			if hits != 2 {
+				t.Errorf("expected the slow attempt to be retried, got %d attempts", hits)
+			}
+		})
+	}
+}
@@ -3,11 +3,13 @@ package agent
 import (
 	"context"
 	"fmt"
-	"log"
 	"net"
+	"strings"
+	"sync"
 	"time"
 
 	"probixel/pkg/config"
+	"probixel/pkg/logging"
 	"probixel/pkg/monitor"
 	"probixel/pkg/tunnels"
 )
@@ -35,25 +37,112 @@ func SetupWireguardWindows(cfg *config.Config, registry *tunnels.Registry) {
 				}
 				successWindow := (maxInterval * time.Duration(threshold)) + 60*time.Second
 				wgTun.SetSuccessWindow(successWindow)
-				log.Printf("[Tunnel:%s] Set success window to %v (max interval %v * threshold %d + 60s grace)", tunnelName, successWindow, maxInterval, threshold)
+				logging.Infof("Tunnel:"+tunnelName, "Set success window to %v (max interval %v * threshold %d + 60s grace)", successWindow, maxInterval, threshold)
 			}
 		}
 	}
 }
 
+// NewTunnel builds the tunnel described by tCfg, or returns nil if its type
+// is unknown or its section is missing. The tunnel is not initialized.
+func NewTunnel(name string, tCfg config.TunnelConfig) tunnels.Tunnel {
+	switch tCfg.Type {
+	case "wireguard":
+		if tCfg.Wireguard != nil {
+			return tunnels.NewWireguardTunnel(name, tCfg.Wireguard)
+		}
+	case "ssh":
+		if tCfg.SSH != nil {
+			return tunnels.NewSSHTunnel(name, tCfg.Target, tCfg.SSH)
+		}
+	case "socks5":
+		return tunnels.NewSOCKS5Tunnel(name, tCfg.Target, tCfg.SOCKS5)
+	}
+	return nil
+}
+
+// dnsCache holds the resolutions shared by every probe set up by the agent.
+var dnsCache = &monitor.DNSCache{}
+
+// nameResolvers holds, per set of resolver servers, the resolver and the
+// resolutions shared by the probes using them, apart from dnsCache as the
+// servers may answer differently from the system resolver.
+var (
+	nameResolversMu sync.Mutex
+	nameResolvers   = map[string]nameResolver{}
+	dnsCacheTTL     time.Duration
+)
+
+type nameResolver struct {
+	resolver *net.Resolver
+	cache    *monitor.DNSCache
+}
+
+// ConfigureDNSCache applies global.dns_cache_ttl to the resolutions shared by
+// the probes, including those set up from an earlier config.
+func ConfigureDNSCache(cfg *config.Config) {
+	ttl, err := config.ParseDuration(cfg.Global.DNSCacheTTL)
+	if err != nil {
+		ttl = 0
+	}
+	dnsCache.SetTTL(ttl)
+
+	nameResolversMu.Lock()
+	defer nameResolversMu.Unlock()
+	dnsCacheTTL = ttl
+	for _, r := range nameResolvers {
+		r.cache.SetTTL(ttl)
+	}
+}
+
+// resolverFor returns the resolver querying servers, nil for the system
+// resolver, and the resolutions cache of the probes using it.
+func resolverFor(servers []string) (*net.Resolver, *monitor.DNSCache) {
+	if len(servers) == 0 {
+		return nil, dnsCache
+	}
+	key := strings.Join(servers, ",")
+	nameResolversMu.Lock()
+	defer nameResolversMu.Unlock()
+	r, ok := nameResolvers[key]
+	if !ok {
+		r.resolver = monitor.NewResolver(servers)
+		r.cache = &monitor.DNSCache{LookupHost: r.resolver.LookupHost}
+		r.cache.SetTTL(dnsCacheTTL)
+		nameResolvers[key] = r
+	}
+	return r.resolver, r.cache
+}
+
 func SetupProbe(svc config.Service, cfg *config.Config, registry *tunnels.Registry) (monitor.Probe, error) {
 	probe, err := monitor.GetProbe(svc.Type)
 	if err != nil {
 		return nil, err
 	}
+	resolver, cache := resolverFor(cfg.ServiceResolver(svc))
 
 	switch p := probe.(type) {
 	case *monitor.HTTPProbe:
 		if svc.HTTP != nil {
 			p.Method = svc.HTTP.Method
+			p.Body = svc.HTTP.Body
 			p.Headers = svc.HTTP.Headers
 			p.AcceptedStatusCodes = svc.HTTP.AcceptedStatusCodes
 			p.InsecureSkipVerify = svc.HTTP.InsecureSkipVerify
+			p.ClientCert = svc.HTTP.ClientCert
+			p.ClientKey = svc.HTTP.ClientKey
+			p.CACert = svc.HTTP.CACert
+			p.Proxy = svc.HTTP.Proxy
+			p.UseEnvProxy = svc.HTTP.UseEnvProxy
+			p.UnixSocket = svc.HTTP.UnixSocket
+			p.DisableKeepAlive = svc.HTTP.DisableKeepAlive
+			p.DisableRedirects = svc.HTTP.FollowRedirects != nil && !*svc.HTTP.FollowRedirects
+			p.MaxRedirects = svc.HTTP.MaxRedirects
+			p.MaxBodyBytes = svc.HTTP.MaxBodyBytes
+			p.FailOnOversizedBody = svc.HTTP.FailOnOversizedBody
+			if p.CACert != "" && p.InsecureSkipVerify {
+				logging.Warnf(svc.Name, "Both ca_cert and insecure_skip_verify are set: certificate verification is disabled")
+			}
 			p.MatchData = svc.HTTP.MatchData
 			if svc.HTTP.CertificateExpiry != "" {
 				if d, err := config.ParseDuration(svc.HTTP.CertificateExpiry); err == nil {
@@ -64,9 +153,63 @@ func SetupProbe(svc config.Service, cfg *config.Config, registry *tunnels.Regist
 		if p.Method == "" {
 			p.Method = "GET"
 		}
+		p.DNSCache = cache
+		if len(svc.Targets) > 0 {
+			p.URLTemplate = svc.URL
+		}
 	case *monitor.DNSProbe:
 		if svc.DNS != nil {
 			p.SetDomain(svc.DNS.Domain)
+			p.Protocol = svc.DNS.Protocol
+			p.ResolverURL = svc.DNS.ResolverURL
+			p.InsecureSkipVerify = svc.DNS.InsecureSkipVerify
+			p.CACert = svc.DNS.CACert
+			p.RecordType = strings.ToUpper(svc.DNS.RecordType)
+			p.ExpectedValues = svc.DNS.ExpectedValues
+			if svc.DNS.MaxDuration != "" {
+				if d, err := config.ParseDuration(svc.DNS.MaxDuration); err == nil {
+					p.MaxDuration = d
+				}
+			}
+		}
+	case *monitor.TCPProbe:
+		p.DNSCache = cache
+		if svc.TCP != nil {
+			p.DSCP = svc.TCP.DSCP
+			p.Send, p.Expect, p.ExpectRegex = svc.TCP.Send, svc.TCP.Expect, svc.TCP.ExpectRegex
+			if svc.TCP.Enabled() {
+				p.Resolver = &monitor.TargetResolver{ResolveTo: svc.TCP.ResolveTo, Cache: cache}
+			}
+		}
+	case *monitor.UDPProbe:
+		p.DNSCache = cache
+		if svc.UDP != nil {
+			p.Send, p.Expect, p.ExpectRegex = svc.UDP.Send, svc.UDP.Expect, svc.UDP.ExpectRegex
+			if svc.UDP.Enabled() {
+				p.Resolver = &monitor.TargetResolver{ResolveTo: svc.UDP.ResolveTo, Cache: cache}
+			}
+		}
+	case *monitor.PingProbe:
+		p.DNSCache = dnsCache
+		if svc.Ping != nil {
+			p.PacketSize = svc.Ping.PacketSize
+			p.DontFragment = svc.Ping.DontFragment
+			p.DSCP = svc.Ping.DSCP
+			if svc.Ping.Enabled() {
+				p.Resolver = &monitor.TargetResolver{ResolveTo: svc.Ping.ResolveTo, Cache: dnsCache}
+			}
+		}
+	case *monitor.HostProbe:
+		if svc.Host != nil {
+			p.MaxLoad = svc.Host.MaxLoad
+			p.MinDiskFreePercent = svc.Host.MinDiskFreePercent
+			p.DiskPath = svc.Host.DiskPath
+			p.MaxMemoryPercent = svc.Host.MaxMemoryPercent
+			p.MaxCPUPercent = svc.Host.MaxCPUPercent
+			p.MinDiskFree = svc.Host.MinDiskFree
+			if d, err := config.ParseDuration(svc.Host.CPUSample); err == nil {
+				p.CPUSample = d
+			}
 		}
 	case *monitor.SSHProbe:
 		if svc.SSH != nil {
@@ -76,6 +219,54 @@ func SetupProbe(svc config.Service, cfg *config.Config, registry *tunnels.Regist
 		if svc.Wireguard != nil {
 			p.Config = svc.Wireguard
 		}
+	case *monitor.ExecProbe:
+		if svc.Exec != nil {
+			p.Command = svc.Exec.Command
+			p.Args = svc.Exec.Args
+			p.ExitCode = svc.Exec.ExitCode
+			p.ExitCodes = svc.Exec.ExitCodes
+			p.Expect = svc.Exec.Expect
+			p.ExpectRegex = svc.Exec.ExpectRegex
+		}
+	case *monitor.DatabaseProbe:
+		p.Config = svc.Database
+	case *monitor.SMTPProbe:
+		if svc.SMTP != nil {
+			p.StartTLS = svc.SMTP.StartTLS
+			p.InsecureSkipVerify = svc.SMTP.InsecureSkipVerify
+			p.CACert = svc.SMTP.CACert
+			if svc.SMTP.CertificateExpiry != "" {
+				if d, err := config.ParseDuration(svc.SMTP.CertificateExpiry); err == nil {
+					p.ExpiryThreshold = d
+				}
+			}
+		}
+	case *monitor.NTPProbe:
+		if svc.NTP != nil && svc.NTP.MaxOffset != "" {
+			if d, err := config.ParseDuration(svc.NTP.MaxOffset); err == nil {
+				p.MaxOffset = d
+			}
+		}
+	case *monitor.TracerouteProbe:
+		if svc.Traceroute != nil {
+			p.MaxHops = svc.Traceroute.MaxHops
+		}
+	case *monitor.WebSocketProbe:
+		if svc.WebSocket != nil {
+			p.Headers = svc.WebSocket.Headers
+			p.Send = svc.WebSocket.Send
+			p.MatchData = svc.WebSocket.MatchData
+			p.InsecureSkipVerify = svc.WebSocket.InsecureSkipVerify
+		}
+	case *monitor.MQTTProbe:
+		if svc.MQTT != nil {
+			p.ClientID = svc.MQTT.ClientID
+			p.Username = svc.MQTT.Username
+			p.Password = svc.MQTT.Password
+			p.Topic = svc.MQTT.Topic
+			p.QoS = svc.MQTT.QoS
+			p.Publish = svc.MQTT.Publish
+		}
 	}
 
 	if tlsProbe, ok := probe.(*monitor.TLSProbe); ok && svc.TLS != nil {
@@ -85,22 +276,49 @@ func SetupProbe(svc config.Service, cfg *config.Config, registry *tunnels.Regist
 			}
 		}
 		tlsProbe.InsecureSkipVerify = svc.TLS.InsecureSkipVerify
+		tlsProbe.SkipChain = svc.TLS.VerifyChain != nil && !*svc.TLS.VerifyChain
+		tlsProbe.ExpectedIssuer = svc.TLS.ExpectedIssuer
+		tlsProbe.ExpectedSAN = svc.TLS.ExpectedSAN
+		tlsProbe.ClientCert = svc.TLS.ClientCert
+		tlsProbe.ClientKey = svc.TLS.ClientKey
+		tlsProbe.CACert = svc.TLS.CACert
+		tlsProbe.CheckRevocation = svc.TLS.CheckRevocation
+		tlsProbe.RevocationRequired = svc.TLS.RevocationRequired
+		if tlsProbe.CACert != "" && tlsProbe.InsecureSkipVerify {
+			logging.Warnf(svc.Name, "Both ca_cert and insecure_skip_verify are set: certificate verification is disabled")
+		}
 	}
 
 	if dockerProbe, ok := probe.(*monitor.DockerProbe); ok && svc.Docker != nil {
 		dockerProbe.Sockets = cfg.DockerSockets
 		dockerProbe.SocketName = svc.Docker.Socket
 		dockerProbe.Healthy = svc.Docker.Healthy
+		dockerProbe.Stats = svc.Docker.Stats
+		dockerProbe.MaxRestarts = svc.Docker.MaxRestarts
+		dockerProbe.FailOnOOM = svc.Docker.FailOnOOM
 	}
 
 	// Set universal timeout
-	timeoutStr := svc.Timeout
-	if timeoutStr == "" {
-		timeoutStr = "5s"
-	}
-	if d, err := config.ParseDuration(timeoutStr); err == nil {
+	if d, err := config.ParseDuration(cfg.ServiceTimeout(svc)); err == nil {
 		probe.SetTimeout(d)
 	}
+	if c, ok := probe.(monitor.ConnectTimeoutSetter); ok && svc.ConnectTimeout != "" {
+		if d, err := config.ParseDuration(svc.ConnectTimeout); err == nil {
+			c.SetConnectTimeout(d)
+		}
+	}
+
+	if s, ok := probe.(monitor.SourceAddressSetter); ok {
+		if addr := cfg.ServiceSourceAddress(svc); addr != "" {
+			s.SetSourceAddress(addr)
+		}
+	}
+	if f, ok := probe.(monitor.FamilySetter); ok && svc.Family != "" && svc.Family != monitor.FamilyAuto {
+		f.SetFamily(svc.Family)
+	}
+	if r, ok := probe.(monitor.NameResolverSetter); ok && resolver != nil {
+		r.SetNameResolver(resolver)
+	}
 
 	if svc.Tunnel != "" {
 		if t, ok := registry.Get(svc.Tunnel); ok {
@@ -129,6 +347,18 @@ func SetupProbe(svc config.Service, cfg *config.Config, registry *tunnels.Regist
 				p.DialContext = dialer
 			case *monitor.SSHProbe:
 				p.DialContext = dialer
+			case *monitor.DatabaseProbe:
+				p.DialContext = dialer
+			case *monitor.SMTPProbe:
+				p.DialContext = dialer
+			case *monitor.NTPProbe:
+				p.DialContext = dialer
+			case *monitor.TracerouteProbe:
+				p.DialContext = dialer
+			case *monitor.MQTTProbe:
+				p.DialContext = dialer
+			case *monitor.WebSocketProbe:
+				p.DialContext = dialer
 			case *monitor.DockerProbe:
 				p.DialContext = dialer
 			}
@@ -150,6 +380,12 @@ func SetupProbe(svc config.Service, cfg *config.Config, registry *tunnels.Regist
 		targetMode = svc.TargetMode
 	}
 	probe.SetTargetMode(targetMode)
+	if q, ok := probe.(monitor.QuorumSetter); ok {
+		q.SetQuorum(svc.Quorum)
+	}
+	if c, ok := probe.(monitor.ConcurrencySetter); ok {
+		c.SetConcurrency(svc.Concurrency)
+	}
 
 	return probe, nil
 }
@@ -2,14 +2,20 @@ package agent
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"fmt"
 	"net"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"probixel/pkg/config"
 	"probixel/pkg/monitor"
 	"probixel/pkg/tunnels"
+
+	"golang.org/x/crypto/ssh"
 )
 
 func TestSetupProbe_HTTP(t *testing.T) {
@@ -362,6 +368,198 @@ func TestSetupProbe_TCP(t *testing.T) {
 	}
 }
 
+// startMockSSHServer starts an SSH server that accepts password "secret" and
+// any direct-tcpip channel, recording the requested destination addresses.
+func startMockSSHServer(t *testing.T) (addr string, dialed func() []string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) == "secret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("auth failed")
+		},
+	}
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	signer, _ := ssh.NewSignerFromKey(key)
+	serverConfig.AddHostKey(signer)
+
+	var mu sync.Mutex
+	var destinations []string
+
+	go func() {
+		for {
+			nConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				sConn, chans, reqs, err := ssh.NewServerConn(nConn, serverConfig)
+				if err != nil {
+					return
+				}
+				defer func() { _ = sConn.Close() }()
+				go ssh.DiscardRequests(reqs)
+				for newChan := range chans {
+					if newChan.ChannelType() != "direct-tcpip" {
+						_ = newChan.Reject(ssh.UnknownChannelType, "unknown channel type")
+						continue
+					}
+					var payload struct {
+						Host     string
+						Port     uint32
+						OrigHost string
+						OrigPort uint32
+					}
+					if err := ssh.Unmarshal(newChan.ExtraData(), &payload); err != nil {
+						_ = newChan.Reject(ssh.ConnectionFailed, "bad payload")
+						continue
+					}
+					mu.Lock()
+					destinations = append(destinations, net.JoinHostPort(payload.Host, fmt.Sprint(payload.Port)))
+					mu.Unlock()
+					ch, chReqs, err := newChan.Accept()
+					if err != nil {
+						continue
+					}
+					go ssh.DiscardRequests(chReqs)
+					_ = ch.Close()
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), destinations...)
+	}
+}
+
+func TestSetupProbe_TCPThroughSSHTunnel(t *testing.T) {
+	sshAddr, dialed := startMockSSHServer(t)
+	host, portStr, _ := net.SplitHostPort(sshAddr)
+	var port int
+	_, _ = fmt.Sscanf(portStr, "%d", &port)
+
+	sshCfg := &config.SSHConfig{User: "test", Password: "secret", Port: port}
+	cfg := &config.Config{
+		Tunnels: map[string]config.TunnelConfig{
+			"bastion": {Type: "ssh", Target: host, SSH: sshCfg},
+		},
+	}
+	registry := tunnels.NewRegistry()
+	tun := tunnels.NewSSHTunnel("bastion", host, sshCfg)
+	defer tun.Stop()
+	if err := registry.Register(tun); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	svc := config.Service{
+		Name:    "db-via-bastion",
+		Type:    "tcp",
+		Tunnel:  "bastion",
+		Targets: []string{"db.internal.invalid:5432"},
+		Timeout: "5s",
+	}
+	probe, err := SetupProbe(svc, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+
+	// The target only resolves on the far side of the tunnel, so success
+	// proves the connection went through the SSH server.
+	res, err := probe.Check(context.Background(), "db.internal.invalid:5432")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Success {
+		t.Fatalf("Expected success through tunnel, got: %s", res.Message)
+	}
+	if got := dialed(); len(got) != 1 || got[0] != "db.internal.invalid:5432" {
+		t.Errorf("Expected SSH server to forward to db.internal.invalid:5432, got %v", got)
+	}
+}
+
+func TestSetupProbe_TCPThroughUnstableTunnel(t *testing.T) {
+	registry := tunnels.NewRegistry()
+	_ = registry.Register(&tunnels.MockTunnel{
+		NameFunc:           func() string { return "vpn" },
+		IsStabilizedResult: false,
+	})
+
+	svc := config.Service{Name: "db-via-vpn", Type: "tcp", Tunnel: "vpn", Targets: []string{"db:5432"}}
+	probe, err := SetupProbe(svc, &config.Config{}, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+
+	res, err := probe.Check(context.Background(), "db:5432")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Pending || res.Success {
+		t.Errorf("Expected Pending result, got %+v", res)
+	}
+	if res.Message != `waiting for tunnel "vpn" to stabilize` {
+		t.Errorf("Unexpected message: %s", res.Message)
+	}
+}
+
+func TestSetupProbe_Quorum(t *testing.T) {
+	svc := config.Service{
+		Name:       "cluster",
+		Type:       "tcp",
+		Targets:    []string{"127.0.0.1:1", "127.0.0.1:2", "127.0.0.1:3"},
+		TargetMode: monitor.TargetModeQuorum,
+		Quorum:     2,
+	}
+	probe, err := SetupProbe(svc, &config.Config{}, tunnels.NewRegistry())
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+
+	tcp := probe.(*monitor.TCPProbe)
+	tcp.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == "127.0.0.1:3" {
+			return nil, fmt.Errorf("connection refused")
+		}
+		c1, c2 := net.Pipe()
+		_ = c2.Close()
+		return c1, nil
+	}
+
+	res, _ := probe.Check(context.Background(), "127.0.0.1:1,127.0.0.1:2,127.0.0.1:3")
+	if !res.Success || res.Message != "2/3 up (need 2)" {
+		t.Errorf("expected quorum of 2 to be wired, got %v: %s", res.Success, res.Message)
+	}
+}
+
+func TestSetupProbe_TCPExpect(t *testing.T) {
+	svc := config.Service{
+		Name:    "ssh",
+		Type:    "tcp",
+		Targets: []string{"127.0.0.1:22"},
+		TCP:     &config.TCPConfig{Send: "hello\r\n", Expect: `^SSH-2\.0`, ExpectRegex: true},
+	}
+	probe, err := SetupProbe(svc, &config.Config{}, tunnels.NewRegistry())
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+
+	tcp := probe.(*monitor.TCPProbe)
+	if tcp.Send != "hello\r\n" || tcp.Expect != `^SSH-2\.0` || !tcp.ExpectRegex {
+		t.Errorf("expected tcp.send and tcp.expect to be wired, got %q, %q, %v", tcp.Send, tcp.Expect, tcp.ExpectRegex)
+	}
+}
+
 func TestSetupProbe_UDP(t *testing.T) {
 	cfg := &config.Config{}
 	svc := config.Service{
@@ -370,7 +568,7 @@ func TestSetupProbe_UDP(t *testing.T) {
 		Targets:  []string{"localhost:53"},
 		Interval: "60s",
 		Timeout:  "5s",
-		UDP:      &config.UDPConfig{},
+		UDP:      &config.UDPConfig{Send: "ping", Expect: "^pong", ExpectRegex: true},
 	}
 	registry := tunnels.NewRegistry()
 
@@ -381,9 +579,14 @@ func TestSetupProbe_UDP(t *testing.T) {
 	if probe.Name() != "udp" {
 		t.Errorf("expected name udp, got %s", probe.Name())
 	}
+	udp := probe.(*monitor.UDPProbe)
+	if udp.Send != "ping" || udp.Expect != "^pong" || !udp.ExpectRegex {
+		t.Errorf("expected udp.send and udp.expect to be wired, got %q, %q, %v", udp.Send, udp.Expect, udp.ExpectRegex)
+	}
 }
 
 func TestSetupProbe_TLS(t *testing.T) {
+	verifyChain := false
 	cfg := &config.Config{}
 	svc := config.Service{
 		Name:     "test-tls",
@@ -392,8 +595,11 @@ func TestSetupProbe_TLS(t *testing.T) {
 		Interval: "60s",
 		Timeout:  "10s",
 		TLS: &config.TLSConfig{
-			CertificateExpiry:  "30d",
-			InsecureSkipVerify: true,
+			CertificateExpiry: "30d",
+			VerifyChain:       &verifyChain,
+			ExpectedIssuer:    "Let's Encrypt",
+			ExpectedSAN:       "www.example.com",
+			CheckRevocation:   true,
 		},
 	}
 	registry := tunnels.NewRegistry()
@@ -405,6 +611,9 @@ func TestSetupProbe_TLS(t *testing.T) {
 	if probe.Name() != "tls" {
 		t.Errorf("expected name tls, got %s", probe.Name())
 	}
+	if tp := probe.(*monitor.TLSProbe); !tp.SkipChain || tp.ExpectedIssuer != "Let's Encrypt" || tp.ExpectedSAN != "www.example.com" || !tp.CheckRevocation || tp.RevocationRequired {
+		t.Errorf("expected verification options to be set, got %+v", tp)
+	}
 }
 
 func TestSetupProbe_SSH(t *testing.T) {
@@ -431,6 +640,7 @@ func TestSetupProbe_SSH(t *testing.T) {
 }
 
 func TestSetupProbe_Docker(t *testing.T) {
+	maxRestarts := 3
 	cfg := &config.Config{
 		DockerSockets: map[string]config.DockerSocketConfig{
 			"local": {Socket: "/var/run/docker.sock"},
@@ -442,8 +652,11 @@ func TestSetupProbe_Docker(t *testing.T) {
 		Targets:  []string{"container1"},
 		Interval: "60s",
 		Docker: &config.DockerConfig{
-			Socket:  "local",
-			Healthy: true,
+			Socket:      "local",
+			Healthy:     true,
+			Stats:       true,
+			MaxRestarts: &maxRestarts,
+			FailOnOOM:   true,
 		},
 	}
 	registry := tunnels.NewRegistry()
@@ -455,6 +668,11 @@ func TestSetupProbe_Docker(t *testing.T) {
 	if probe.Name() != "docker" {
 		t.Errorf("expected name docker, got %s", probe.Name())
 	}
+	if dp := probe.(*monitor.DockerProbe); !dp.Healthy || !dp.Stats {
+		t.Errorf("expected healthy and stats to be set, got %v and %v", dp.Healthy, dp.Stats)
+	} else if dp.MaxRestarts == nil || *dp.MaxRestarts != 3 || !dp.FailOnOOM {
+		t.Errorf("expected max_restarts 3 and fail_on_oom, got %v and %v", dp.MaxRestarts, dp.FailOnOOM)
+	}
 }
 
 func TestSetupProbe_Host(t *testing.T) {
@@ -463,6 +681,7 @@ func TestSetupProbe_Host(t *testing.T) {
 		Name:     "test-host",
 		Type:     "host",
 		Interval: "60s",
+		Host:     &config.HostConfig{MaxCPUPercent: 90, CPUSample: "2s", MinDiskFree: map[string]float64{"/var": 20}},
 	}
 	registry := tunnels.NewRegistry()
 
@@ -473,6 +692,10 @@ func TestSetupProbe_Host(t *testing.T) {
 	if probe.Name() != "host" {
 		t.Errorf("expected name host, got %s", probe.Name())
 	}
+	host := probe.(*monitor.HostProbe)
+	if host.MaxCPUPercent != 90 || host.CPUSample != 2*time.Second || host.MinDiskFree["/var"] != 20 {
+		t.Errorf("expected the host thresholds to be wired, got %+v", host)
+	}
 }
 
 func TestSetupProbe_Ping(t *testing.T) {
@@ -494,6 +717,261 @@ func TestSetupProbe_Ping(t *testing.T) {
 	}
 }
 
+func TestSetupProbe_SourceAddress(t *testing.T) {
+	cfg := &config.Config{Global: config.GlobalConfig{SourceAddress: "10.0.0.5"}}
+	registry := tunnels.NewRegistry()
+
+	probe, err := SetupProbe(config.Service{Name: "global", Type: "ping", Targets: []string{"10.0.0.1"}}, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	if got := probe.(*monitor.PingProbe).SourceAddress; got != "10.0.0.5" {
+		t.Errorf("expected global source address, got %q", got)
+	}
+
+	probe, err = SetupProbe(config.Service{Name: "override", Type: "tcp", Targets: []string{"10.0.0.1:22"}, SourceAddress: "10.0.1.5"}, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	if got := probe.(*monitor.TCPProbe).SourceAddress; got != "10.0.1.5" {
+		t.Errorf("expected service source address, got %q", got)
+	}
+}
+
+func TestSetupProbe_DefaultTimeout(t *testing.T) {
+	registry := tunnels.NewRegistry()
+	tests := []struct {
+		name           string
+		defaultTimeout string
+		timeout        string
+		want           time.Duration
+	}{
+		{"service timeout", "10s", "2s", 2 * time.Second},
+		{"global default", "10s", "", 10 * time.Second},
+		{"neither", "", "", 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Global: config.GlobalConfig{DefaultInterval: "1m", DefaultTimeout: tt.defaultTimeout}}
+			probe, err := SetupProbe(config.Service{Name: "db", Type: "tcp", Targets: []string{"10.0.0.1:5432"}, Timeout: tt.timeout}, cfg, registry)
+			if err != nil {
+				t.Fatalf("SetupProbe failed: %v", err)
+			}
+			if got := probe.(*monitor.TCPProbe).Timeout; got != tt.want {
+				t.Errorf("expected timeout %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSetupProbe_Family(t *testing.T) {
+	cfg := &config.Config{}
+	registry := tunnels.NewRegistry()
+
+	probe, err := SetupProbe(config.Service{Name: "v6", Type: "http", URL: "http://app.internal", Family: "ipv6"}, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	if got := probe.(*monitor.HTTPProbe).Family; got != monitor.FamilyIPv6 {
+		t.Errorf("expected family ipv6, got %q", got)
+	}
+
+	probe, err = SetupProbe(config.Service{Name: "auto", Type: "udp", Targets: []string{"10.0.0.1:53"}, Family: "auto"}, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	if got := probe.(*monitor.UDPProbe).Family; got != "" {
+		t.Errorf("expected no forced family, got %q", got)
+	}
+}
+
+func TestSetupProbe_Resolver(t *testing.T) {
+	cfg := &config.Config{Global: config.GlobalConfig{Resolver: "10.0.0.53"}}
+	registry := tunnels.NewRegistry()
+
+	probe, err := SetupProbe(config.Service{Name: "web", Type: "http", URL: "http://app.internal"}, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	web := probe.(*monitor.HTTPProbe)
+	probe, err = SetupProbe(config.Service{Name: "port", Type: "tcp", Targets: []string{"app.internal:22"}, TCP: &config.TCPConfig{ResolveConfig: config.ResolveConfig{Resolve: true}}}, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	tcp := probe.(*monitor.TCPProbe)
+	if web.NameResolver == nil || web.NameResolver != tcp.NameResolver {
+		t.Error("expected the probes to share the global resolver")
+	}
+	if web.DNSCache == dnsCache || web.DNSCache != tcp.DNSCache || tcp.Resolver.Cache != tcp.DNSCache {
+		t.Error("expected the probes to share resolutions apart from the system resolver's")
+	}
+
+	probe, err = SetupProbe(config.Service{Name: "override", Type: "udp", Targets: []string{"app.internal:53"}, Resolver: "10.1.0.53"}, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	if udp := probe.(*monitor.UDPProbe); udp.NameResolver == nil || udp.NameResolver == web.NameResolver {
+		t.Error("expected the service resolver to override the global one")
+	}
+
+	probe, err = SetupProbe(config.Service{Name: "ping", Type: "ping", Targets: []string{"app.internal"}}, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	if probe.(*monitor.PingProbe).DNSCache != dnsCache {
+		t.Error("expected ping to keep the system resolver")
+	}
+}
+
+func TestSetupProbe_Database(t *testing.T) {
+	cfg := &config.Config{}
+	registry := tunnels.NewRegistry()
+	dbCfg := &config.DatabaseConfig{User: "monitor", Query: "SELECT 1"}
+
+	probe, err := SetupProbe(config.Service{Name: "db", Type: "postgres", Targets: []string{"db:5432"}, ConnectTimeout: "1s", Database: dbCfg}, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	p, ok := probe.(*monitor.DatabaseProbe)
+	if !ok {
+		t.Fatalf("expected *monitor.DatabaseProbe, got %T", probe)
+	}
+	if p.Name() != "postgres" || p.Config != dbCfg || p.ConnectTimeout != time.Second {
+		t.Errorf("unexpected probe setup: %+v", p)
+	}
+
+	_, err = SetupProbe(config.Service{Name: "bad", Type: "mysql", Database: &config.DatabaseConfig{DSN: "user@tcp(db"}}, cfg, registry)
+	if err == nil || !strings.Contains(err.Error(), "invalid database.dsn") {
+		t.Errorf("expected invalid database.dsn error, got %v", err)
+	}
+}
+
+func TestSetupProbe_SMTP(t *testing.T) {
+	cfg := &config.Config{}
+	registry := tunnels.NewRegistry()
+	svc := config.Service{
+		Name:           "mail",
+		Type:           "smtp",
+		Targets:        []string{"mx.example.com"},
+		ConnectTimeout: "1s",
+		SMTP:           &config.SMTPConfig{StartTLS: true, CertificateExpiry: "14d", InsecureSkipVerify: true, CACert: "ca.pem"},
+	}
+
+	probe, err := SetupProbe(svc, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	p, ok := probe.(*monitor.SMTPProbe)
+	if !ok {
+		t.Fatalf("expected *monitor.SMTPProbe, got %T", probe)
+	}
+	if !p.StartTLS || p.ExpiryThreshold != 14*24*time.Hour || !p.InsecureSkipVerify || p.CACert != "ca.pem" || p.ConnectTimeout != time.Second {
+		t.Errorf("unexpected probe setup: %+v", p)
+	}
+}
+
+func TestSetupProbe_NTP(t *testing.T) {
+	cfg := &config.Config{}
+	registry := tunnels.NewRegistry()
+	svc := config.Service{Name: "time", Type: "ntp", Targets: []string{"ntp1.internal"}, Timeout: "2s", NTP: &config.NTPConfig{MaxOffset: "250ms"}}
+
+	probe, err := SetupProbe(svc, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	p, ok := probe.(*monitor.NTPProbe)
+	if !ok {
+		t.Fatalf("expected *monitor.NTPProbe, got %T", probe)
+	}
+	if p.MaxOffset != 250*time.Millisecond || p.Timeout != 2*time.Second {
+		t.Errorf("unexpected probe setup: %+v", p)
+	}
+}
+
+func TestSetupProbe_WebSocket(t *testing.T) {
+	cfg := &config.Config{}
+	registry := tunnels.NewRegistry()
+	match := &config.MatchDataConfig{Expectations: []config.Expectation{{Type: "body", Operator: "==", Value: "pong"}}}
+	svc := config.Service{Name: "realtime", Type: "websocket", URL: "wss://realtime.internal/live", Timeout: "2s", WebSocket: &config.WebSocketConfig{
+		Headers: map[string]string{"Authorization": "Bearer token"}, Send: "ping", MatchData: match, InsecureSkipVerify: true,
+	}}
+
+	probe, err := SetupProbe(svc, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	p, ok := probe.(*monitor.WebSocketProbe)
+	if !ok {
+		t.Fatalf("expected *monitor.WebSocketProbe, got %T", probe)
+	}
+	if p.Headers["Authorization"] != "Bearer token" || p.Send != "ping" || p.MatchData != match || !p.InsecureSkipVerify || p.Timeout != 2*time.Second {
+		t.Errorf("unexpected probe setup: %+v", p)
+	}
+}
+
+func TestSetupProbe_MQTT(t *testing.T) {
+	cfg := &config.Config{}
+	registry := tunnels.NewRegistry()
+	svc := config.Service{Name: "broker", Type: "mqtt", Targets: []string{"broker.internal"}, Timeout: "2s", MQTT: &config.MQTTConfig{
+		ClientID: "probixel", Username: "probe", Password: "secret", Topic: "probixel/health", QoS: 1, Publish: "ping",
+	}}
+
+	probe, err := SetupProbe(svc, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	p, ok := probe.(*monitor.MQTTProbe)
+	if !ok {
+		t.Fatalf("expected *monitor.MQTTProbe, got %T", probe)
+	}
+	if p.ClientID != "probixel" || p.Username != "probe" || p.Password != "secret" || p.Topic != "probixel/health" || p.QoS != 1 || p.Publish != "ping" || p.Timeout != 2*time.Second {
+		t.Errorf("unexpected probe setup: %+v", p)
+	}
+}
+
+func TestSetupProbe_Traceroute(t *testing.T) {
+	cfg := &config.Config{Global: config.GlobalConfig{SourceAddress: "10.20.0.5"}}
+	registry := tunnels.NewRegistry()
+	svc := config.Service{Name: "path", Type: "traceroute", Targets: []string{"gw.internal"}, Timeout: "2s", Traceroute: &config.TracerouteConfig{MaxHops: 12}}
+
+	probe, err := SetupProbe(svc, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	p, ok := probe.(*monitor.TracerouteProbe)
+	if !ok {
+		t.Fatalf("expected *monitor.TracerouteProbe, got %T", probe)
+	}
+	if p.MaxHops != 12 || p.Timeout != 2*time.Second || p.SourceAddress != "10.20.0.5" {
+		t.Errorf("unexpected probe setup: %+v", p)
+	}
+}
+
+func TestSetupProbe_DNSCache(t *testing.T) {
+	cfg := &config.Config{Global: config.GlobalConfig{DNSCacheTTL: "5m"}}
+	registry := tunnels.NewRegistry()
+	ConfigureDNSCache(cfg)
+	defer ConfigureDNSCache(&config.Config{})
+
+	tcp, err := SetupProbe(config.Service{Name: "tcp", Type: "tcp", Targets: []string{"db:5432"}, TCP: &config.TCPConfig{ResolveConfig: config.ResolveConfig{Resolve: true}}}, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	ping, err := SetupProbe(config.Service{Name: "ping", Type: "ping", Targets: []string{"db"}}, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+
+	// Every probe shares the same cache
+	tcpProbe := tcp.(*monitor.TCPProbe)
+	if tcpProbe.DNSCache == nil || tcpProbe.DNSCache != ping.(*monitor.PingProbe).DNSCache {
+		t.Error("expected the probes to share the agent's DNS cache")
+	}
+	if tcpProbe.Resolver == nil || tcpProbe.Resolver.Cache != tcpProbe.DNSCache {
+		t.Error("expected the target resolver to use the DNS cache")
+	}
+}
+
 func TestSetupProbe_HTTP_WithConfig(t *testing.T) {
 	cfg := &config.Config{}
 	svc := config.Service{
@@ -508,6 +986,8 @@ func TestSetupProbe_HTTP_WithConfig(t *testing.T) {
 			AcceptedStatusCodes: "200-299",
 			InsecureSkipVerify:  true,
 			CertificateExpiry:   "30d",
+			MaxRedirects:        3,
+			MaxBodyBytes:        1 << 20,
 		},
 	}
 	registry := tunnels.NewRegistry()
@@ -519,6 +999,23 @@ func TestSetupProbe_HTTP_WithConfig(t *testing.T) {
 	if probe.Name() != "http" {
 		t.Errorf("expected name http, got %s", probe.Name())
 	}
+	if p := probe.(*monitor.HTTPProbe); p.DisableRedirects || p.MaxRedirects != 3 {
+		t.Errorf("expected redirects to be followed up to 3 times, got %+v", p)
+	}
+	if p := probe.(*monitor.HTTPProbe); p.MaxBodyBytes != 1<<20 || p.FailOnOversizedBody {
+		t.Errorf("expected bodies truncated to 1 MiB, got %+v", p)
+	}
+
+	noFollow := false
+	svc.HTTP.FollowRedirects = &noFollow
+	svc.HTTP.MaxRedirects = 0
+	probe, err = SetupProbe(svc, cfg, registry)
+	if err != nil {
+		t.Fatalf("SetupProbe failed: %v", err)
+	}
+	if !probe.(*monitor.HTTPProbe).DisableRedirects {
+		t.Error("expected follow_redirects: false to disable redirects")
+	}
 }
 
 func TestSetupProbe_WithCustomTargetMode(t *testing.T) {
@@ -9,10 +9,34 @@ import (
 type ConfigState struct {
 	mu     sync.RWMutex
 	config *config.Config
+
+	// History records recent check outcomes per service, for the uptime ratio
+	// of Status and Metrics. It outlives config reloads, which only resize it
+	// and drop removed services.
+	History *History
+	// Metrics exposes the latest result of each service to Prometheus.
+	Metrics *Metrics
+	// Status exposes the latest result of each service to the status API.
+	Status *Status
+	// StateFile persists the last status of each service across restarts.
+	StateFile *StateFile
+	// Alerts decides which results are pushed in on_change_only mode.
+	Alerts *Alerts
+	// Checks runs out-of-band checks of the running services for the status API.
+	Checks *Checks
 }
 
 func NewConfigState(cfg *config.Config) *ConfigState {
-	return &ConfigState{config: cfg}
+	history := NewHistory(cfg.Global.HistorySize)
+	return &ConfigState{
+		config:    cfg,
+		History:   history,
+		Metrics:   NewMetrics(history),
+		Status:    NewStatus(history),
+		StateFile: NewStateFile(cfg.Global.StateFile),
+		Alerts:    NewAlerts(),
+		Checks:    NewChecks(),
+	}
 }
 
 func (sc *ConfigState) Get() *config.Config {
@@ -25,4 +49,14 @@ func (sc *ConfigState) Set(cfg *config.Config) {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
 	sc.config = cfg
+
+	names := make([]string, 0, len(cfg.Services))
+	for _, svc := range cfg.Services {
+		names = append(names, svc.Name)
+	}
+	sc.History.Update(cfg.Global.HistorySize, names)
+	sc.Metrics.Update(names)
+	sc.Status.Update(names)
+	sc.StateFile.Update(cfg.Global.StateFile, names)
+	sc.Alerts.Update(names)
 }
@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"probixel/pkg/logging"
+	"probixel/pkg/monitor"
+)
+
+// ServiceState is the last status pushed for a service, as persisted in the
+// state file.
+type ServiceState struct {
+	Success   bool      `json:"success"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// StateFile persists the last result pushed for each service to
+// global.state_file, so that a monitor (re)started by an agent restart or a
+// config reload does not push a status that was already pushed. It does
+// nothing without a path.
+type StateFile struct {
+	mu       sync.Mutex
+	path     string
+	services map[string]ServiceState
+	resumed  map[string]bool // Services whose next result is compared to their persisted state
+}
+
+// NewStateFile loads the state persisted at path. A missing or unreadable
+// file starts with no state.
+func NewStateFile(path string) *StateFile {
+	return &StateFile{path: path, services: loadStateFile(path), resumed: make(map[string]bool)}
+}
+
+// Resume makes the next result of service count as a transition only if it
+// differs from the persisted state. It is called when its monitor starts.
+func (f *StateFile) Resume(service string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.path != "" {
+		f.resumed[service] = true
+	}
+}
+
+// Unchanged reports whether res is the first result of service after Resume
+// and has the same status as the persisted state, in which case it does not
+// need to be pushed. Pending results are ignored.
+func (f *StateFile) Unchanged(service string, res monitor.Result) bool {
+	if res.Pending {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.path == "" {
+		return false
+	}
+
+	last, known := f.services[service]
+	unchanged := f.resumed[service] && known && last.Success == res.Success
+	delete(f.resumed, service)
+	return unchanged
+}
+
+// Record persists res as the state of service, once it was pushed. Pending
+// results are ignored.
+func (f *StateFile) Record(service string, res monitor.Result) {
+	if res.Pending {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.path == "" {
+		return
+	}
+
+	f.services[service] = ServiceState{Success: res.Success, Message: res.Message, Timestamp: res.Timestamp}
+	if err := f.save(); err != nil {
+		logging.Warnf("", "Failed to write state file: %v", err)
+	}
+}
+
+// Update switches to the state file at path, loading it if it changed, and
+// drops services that are no longer configured.
+func (f *StateFile) Update(path string, services []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if path != f.path {
+		f.path = path
+		f.services = loadStateFile(path)
+	}
+	configured := make(map[string]bool, len(services))
+	for _, name := range services {
+		configured[name] = true
+	}
+	for name := range f.services {
+		if !configured[name] {
+			delete(f.services, name)
+		}
+	}
+	for name := range f.resumed {
+		if !configured[name] {
+			delete(f.resumed, name)
+		}
+	}
+}
+
+// save writes the state to a temporary file renamed over the state file, so
+// a crash never leaves it half written. Must be called with f.mu held.
+func (f *StateFile) save() error {
+	data, err := json.MarshalIndent(f.services, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), "."+filepath.Base(f.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }() // No-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+func loadStateFile(path string) map[string]ServiceState {
+	services := make(map[string]ServiceState)
+	if path == "" {
+		return services
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // G304: State file path from the config is expected
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logging.Warnf("", "Failed to read state file, starting fresh: %v", err)
+		}
+		return services
+	}
+	if err := json.Unmarshal(data, &services); err != nil {
+		logging.Warnf("", "Ignoring corrupt state file %s, starting fresh: %v", path, err)
+		return make(map[string]ServiceState)
+	}
+	return services
+}
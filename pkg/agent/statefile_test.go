@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"probixel/pkg/monitor"
+)
+
+// push records res as StateFile would see it from CheckAndPush, reporting
+// whether it was pushed.
+func push(f *StateFile, res monitor.Result) bool {
+	if f.Unchanged("svc", res) {
+		return false
+	}
+	f.Record("svc", res)
+	return true
+}
+
+func TestStateFile_Record(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	base := time.Unix(1700000000, 0)
+
+	f := NewStateFile(path)
+	f.Resume("svc")
+	if !push(f, monitor.Result{Success: true, Message: "OK", Timestamp: base}) {
+		t.Error("expected the first result without persisted state to be pushed")
+	}
+
+	// A restart resumes from the file: the same status is not a transition
+	f = NewStateFile(path)
+	f.Resume("svc")
+	if f.Unchanged("svc", monitor.Result{Pending: true}) {
+		t.Error("expected pending results to be ignored")
+	}
+	if push(f, monitor.Result{Success: true, Message: "OK", Timestamp: base.Add(time.Minute)}) {
+		t.Error("expected an unchanged status after a restart not to be pushed")
+	}
+	if !push(f, monitor.Result{Success: true, Timestamp: base.Add(2 * time.Minute)}) {
+		t.Error("expected only the first result after Resume to be compared")
+	}
+
+	// A changed status is pushed
+	f = NewStateFile(path)
+	f.Resume("svc")
+	if !push(f, monitor.Result{Success: false, Message: "connection refused", Timestamp: base.Add(3 * time.Minute)}) {
+		t.Error("expected a status change after a restart to be pushed")
+	}
+
+	got := NewStateFile(path).services["svc"]
+	want := ServiceState{Success: false, Message: "connection refused", Timestamp: base.Add(3 * time.Minute)}
+	if got.Success != want.Success || got.Message != want.Message || !got.Timestamp.Equal(want.Timestamp) {
+		t.Errorf("persisted state = %+v, want %+v", got, want)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only the state file in its directory, got %d entries", len(entries))
+	}
+}
+
+func TestStateFile_CorruptOrMissing(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{corrupt, filepath.Join(dir, "missing.json")} {
+		f := NewStateFile(path)
+		f.Resume("svc")
+		if !push(f, monitor.Result{Success: true}) {
+			t.Errorf("%s: expected a fresh start to push the first result", filepath.Base(path))
+		}
+		if _, ok := NewStateFile(path).services["svc"]; !ok {
+			t.Errorf("%s: expected the state file to be rewritten", filepath.Base(path))
+		}
+	}
+}
+
+func TestStateFile_Disabled(t *testing.T) {
+	f := NewStateFile("")
+	f.Resume("svc")
+	push(f, monitor.Result{Success: true})
+	f.Resume("svc")
+	if !push(f, monitor.Result{Success: true}) {
+		t.Error("expected every result to be pushed without a state file")
+	}
+}
+
+func TestStateFile_Update(t *testing.T) {
+	dir := t.TempDir()
+	f := NewStateFile(filepath.Join(dir, "a.json"))
+	f.Record("kept", monitor.Result{Success: true})
+	f.Record("removed", monitor.Result{Success: true})
+
+	f.Update(filepath.Join(dir, "a.json"), []string{"kept"})
+	if _, ok := f.services["removed"]; ok {
+		t.Error("expected removed services to be dropped")
+	}
+
+	f.Update(filepath.Join(dir, "b.json"), []string{"kept"})
+	if len(f.services) != 0 {
+		t.Errorf("expected the new, missing state file to start fresh, got %v", f.services)
+	}
+}
@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"probixel/pkg/monitor"
+)
+
+// Status keeps the latest result of each service for the status API, and
+// when each service entered its current status. Like Metrics, it outlives
+// config reloads, which only drop removed services.
+type Status struct {
+	mu       sync.Mutex
+	services map[string]ServiceStatus
+	changes  map[string]statusChange // Service -> its last status change, pending results aside
+	history  *History                // Source of the uptime ratio, optional
+}
+
+// statusChange is the status a service changed to and when.
+type statusChange struct {
+	success bool
+	at      time.Time
+}
+
+// ServiceStatus is the latest result of a service as reported by the status API.
+type ServiceStatus struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Success   bool      `json:"success"`
+	Pending   bool      `json:"pending,omitempty"`
+	Message   string    `json:"message"`
+	Target    string    `json:"target,omitempty"`
+	Duration  float64   `json:"duration"` // Milliseconds
+	Timestamp time.Time `json:"timestamp"`
+	Since     time.Time `json:"since,omitzero"` // When the service entered its current status, unset until it has one
+	// Share of the checks in the history that succeeded, unset until one
+	// that isn't pending was recorded
+	UptimeRatio *float64       `json:"uptime_ratio,omitempty"`
+	TLS         *TLSStatus     `json:"tls,omitempty"`     // Certificate the server presented, for probes that inspect it
+	Targets     []TargetStatus `json:"targets,omitempty"` // Outcome of each target, for probes that report them
+}
+
+// TargetStatus is the outcome of one target of a service as reported by the
+// status API.
+type TargetStatus struct {
+	Target   string  `json:"target"`
+	Success  bool    `json:"success"`
+	Duration float64 `json:"duration_ms"`
+	Message  string  `json:"message"`
+}
+
+// TLSStatus is the certificate a service presented as reported by the status API.
+type TLSStatus struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+// NewStatus returns a Status reporting the uptime ratio of each service from
+// history, if not nil.
+func NewStatus(history *History) *Status {
+	return &Status{services: make(map[string]ServiceStatus), changes: make(map[string]statusChange), history: history}
+}
+
+// Record makes res the latest result of service and returns it as reported
+// by the status API. Pending results leave the status, and so Since, as it
+// was. res must already be recorded in the history.
+func (s *Status) Record(service, typ string, res monitor.Result) ServiceStatus {
+	timestamp := res.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	var uptime *float64
+	if s.history != nil {
+		if ratio, ok := s.history.UptimeRatio(service); ok {
+			uptime = &ratio
+		}
+	}
+	var tls *TLSStatus
+	if res.TLS != nil {
+		tls = &TLSStatus{Subject: res.TLS.Subject, Issuer: res.TLS.Issuer, NotAfter: res.TLS.NotAfter}
+	}
+	var targets []TargetStatus
+	for _, tr := range res.TargetResults {
+		targets = append(targets, TargetStatus{
+			Target:   tr.Target,
+			Success:  tr.Success,
+			Duration: float64(tr.Duration) / float64(time.Millisecond),
+			Message:  tr.Message,
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change, known := s.changes[service]
+	if !res.Pending && (!known || change.success != res.Success) {
+		change = statusChange{success: res.Success, at: timestamp}
+		s.changes[service] = change
+	}
+
+	st := ServiceStatus{
+		Name:      service,
+		Type:      typ,
+		Success:   res.Success,
+		Pending:   res.Pending,
+		Message:   res.Message,
+		Target:    res.Target,
+		Duration:  float64(res.Duration) / float64(time.Millisecond),
+		Timestamp: timestamp,
+		Since:     change.at,
+
+		UptimeRatio: uptime,
+		TLS:         tls,
+		Targets:     targets,
+	}
+	s.services[service] = st
+	return st
+}
+
+// InStatusFor returns how long the service had been in its status when st
+// was recorded: 0 for its first result and for a result changing its status.
+func (st ServiceStatus) InStatusFor() time.Duration {
+	if st.Since.IsZero() {
+		return 0
+	}
+	return max(st.Timestamp.Sub(st.Since), 0)
+}
+
+// Update drops services that are no longer configured.
+func (s *Status) Update(services []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name := range s.services {
+		if !slices.Contains(services, name) {
+			delete(s.services, name)
+			delete(s.changes, name)
+		}
+	}
+}
+
+// Get returns the latest result of service, false if it wasn't checked yet.
+func (s *Status) Get(service string) (ServiceStatus, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.services[service]
+	return st, ok
+}
+
+// All returns the latest result of every checked service, sorted by name.
+func (s *Status) All() []ServiceStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := make([]ServiceStatus, 0, len(s.services))
+	for _, st := range s.services {
+		all = append(all, st)
+	}
+	slices.SortFunc(all, func(a, b ServiceStatus) int { return strings.Compare(a.Name, b.Name) })
+	return all
+}
+
+// ServeHTTP answers GET /status with every service, and GET /status/{service}
+// with a single one or 404 if it is unknown or not checked yet.
+func (s *Status) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("service")
+	if name == "" {
+		writeJSON(w, http.StatusOK, map[string][]ServiceStatus{"services": s.All()})
+		return
+	}
+	st, ok := s.Get(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("unknown service %q", name)})
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
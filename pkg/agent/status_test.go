@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"probixel/pkg/monitor"
+)
+
+func TestStatus(t *testing.T) {
+	history := NewHistory(10)
+	s := NewStatus(history)
+	checked := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	expires := checked.Add(90 * 24 * time.Hour)
+	for _, r := range []struct {
+		service, typ string
+		res          monitor.Result
+	}{
+		{"web", "http", monitor.Result{Message: "timeout", Timestamp: checked}},
+		{"db", "tcp", monitor.Result{Message: "connection refused", Timestamp: checked, TargetResults: []monitor.TargetResult{
+			{Target: "db1:5432", Duration: 1500 * time.Millisecond, Message: "connection refused"},
+			{Target: "db2:5432", Message: "timeout"},
+		}}},
+		{"web", "http", monitor.Result{Success: true, Message: "OK", Duration: 120 * time.Millisecond, Target: "https://example.com", Timestamp: checked, TLS: &monitor.TLSInfo{Subject: "example.com", Issuer: "Example CA", NotAfter: expires}}},
+		{"queue", "tcp", monitor.Result{Pending: true, Timestamp: checked}},
+	} {
+		history.Record(r.service, r.res)
+		s.Record(r.service, r.typ, r.res)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /status", s)
+	mux.Handle("GET /status/{service}", s)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	rec := get("/status")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	var all struct {
+		Services []ServiceStatus `json:"services"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &all); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(all.Services) != 3 || all.Services[0].Name != "db" || all.Services[1].Name != "queue" || all.Services[2].Name != "web" {
+		t.Fatalf("expected db, queue and web sorted by name, got %+v", all.Services)
+	}
+	web := all.Services[2]
+	if !web.Success || web.Type != "http" || web.Message != "OK" || web.Duration != 120 || !web.Timestamp.Equal(checked) {
+		t.Errorf("expected the latest web result, got %+v", web)
+	}
+	if web.TLS == nil || web.TLS.Subject != "example.com" || web.TLS.Issuer != "Example CA" || !web.TLS.NotAfter.Equal(expires) {
+		t.Errorf("expected the certificate of web, got %+v", web.TLS)
+	}
+	if !strings.Contains(rec.Body.String(), `"not_after":"2026-04-02T03:04:05Z"`) {
+		t.Errorf("expected the certificate expiry in the response, got %s", rec.Body)
+	}
+	if web.UptimeRatio == nil || *web.UptimeRatio != 0.5 {
+		t.Errorf("expected web uptime ratio 0.5, got %v", web.UptimeRatio)
+	}
+	if all.Services[0].Success || all.Services[0].UptimeRatio == nil || *all.Services[0].UptimeRatio != 0 {
+		t.Errorf("expected db to be down with uptime ratio 0, got %+v", all.Services[0])
+	}
+	if !strings.Contains(rec.Body.String(), `"uptime_ratio":0,`) || all.Services[1].UptimeRatio != nil {
+		t.Errorf("expected an uptime ratio of 0 to be reported but none for a pending service, got %s", rec.Body)
+	}
+
+	rec = get("/status/db")
+	var db ServiceStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &db); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("expected db with 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if db.Name != "db" || db.Message != "connection refused" || db.TLS != nil {
+		t.Errorf("unexpected db status %+v", db)
+	}
+	if len(db.Targets) != 2 || db.Targets[0] != (TargetStatus{Target: "db1:5432", Duration: 1500, Message: "connection refused"}) || db.Targets[1].Target != "db2:5432" {
+		t.Errorf("expected the outcome of each db target, got %+v", db.Targets)
+	}
+	if !strings.Contains(rec.Body.String(), `"targets":[{"target":"db1:5432","success":false,"duration_ms":1500,"message":"connection refused"}`) {
+		t.Errorf("unexpected targets JSON %s", rec.Body)
+	}
+
+	rec = get("/status/missing")
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), `unknown service \"missing\"`) {
+		t.Errorf("expected 404 for an unknown service, got %d: %s", rec.Code, rec.Body)
+	}
+
+	s.Update([]string{"web", "queue"})
+	if _, ok := s.Get("db"); ok {
+		t.Error("expected removed services to be dropped")
+	}
+	if _, ok := s.Get("web"); !ok {
+		t.Error("expected configured services to be kept")
+	}
+}
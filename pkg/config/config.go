@@ -1,28 +1,74 @@
 package config
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
+	_ "time/tzdata" // Maintenance time zones resolve on images without zoneinfo
 
 	"golang.org/x/crypto/ssh"
 	"gopkg.in/yaml.v3"
 )
 
+// DefaultTimeout is the probe timeout applied to services that don't set one
+// when global.default_timeout is not set either, lowered to half the interval
+// for faster services, see ServiceTimeout.
+const DefaultTimeout = "5s"
+
+// DefaultHistorySize is the number of recent checks kept per service when
+// global.history_size is not set.
+const DefaultHistorySize = 100
+
+// IsRegisteredType reports whether a service type without built-in
+// validation has a probe registered for it. The monitor package sets it to
+// monitor.IsRegistered, config can't import monitor itself.
+var IsRegisteredType func(serviceType string) bool
+
 type Config struct {
+	Include       []string                      `yaml:"include,omitempty"` // Glob paths of files merged in by LoadConfig
 	Global        GlobalConfig                  `yaml:"global"`
 	DockerSockets map[string]DockerSocketConfig `yaml:"docker-sockets,omitempty"`
 	Tunnels       map[string]TunnelConfig       `yaml:"tunnels,omitempty"`
 	Services      []Service                     `yaml:"services"`
+
+	sources []string // Files and include patterns the config was loaded from
 }
 
 type TunnelConfig struct {
-	Type      string           `yaml:"type"` // ssh, wireguard
+	Type      string           `yaml:"type"` // ssh, wireguard, socks5
 	Target    string           `yaml:"target,omitempty"`
 	SSH       *SSHConfig       `yaml:"ssh,omitempty"`
 	Wireguard *WireguardConfig `yaml:"wireguard,omitempty"`
+	SOCKS5    *SOCKS5Config    `yaml:"socks5,omitempty"`
+
+	// MaxStabilizationTime is how long the tunnel may go without stabilizing
+	// before the services using it fail instead of staying pending.
+	MaxStabilizationTime string `yaml:"max_stabilization_time,omitempty"`
+}
+
+// SOCKS5Config holds the optional credentials of a socks5 tunnel, whose
+// target is the proxy's address.
+type SOCKS5Config struct {
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
 }
 
 func (c *Config) Validate() error {
@@ -32,6 +78,16 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.Global.DefaultTimeout != "" {
+		d, err := ParseDuration(c.Global.DefaultTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid global default_timeout: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("global default_timeout must be positive")
+		}
+	}
+
 	if c.Global.Notifier.RateLimit != nil {
 		if *c.Global.Notifier.RateLimit == "" {
 			return fmt.Errorf("global notifier.rate_limit cannot be an empty string")
@@ -41,6 +97,115 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.Global.Notifier.ReminderInterval != "" {
+		d, err := ParseDuration(c.Global.Notifier.ReminderInterval)
+		if err != nil {
+			return fmt.Errorf("invalid global notifier.reminder_interval: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("global notifier.reminder_interval must not be negative")
+		}
+	}
+
+	if c.Global.Notifier.MaxBackoff != "" {
+		d, err := ParseDuration(c.Global.Notifier.MaxBackoff)
+		if err != nil {
+			return fmt.Errorf("invalid global notifier.max_backoff: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("global notifier.max_backoff must not be negative")
+		}
+	}
+
+	if c.Global.HistorySize < 0 {
+		return fmt.Errorf("global history_size must not be negative")
+	}
+	if m := c.Global.Metrics; m != nil {
+		if m.Listen == "" {
+			return fmt.Errorf("global metrics.listen is mandatory")
+		}
+		if _, port, err := net.SplitHostPort(m.Listen); err != nil || port == "" {
+			return fmt.Errorf("global metrics.listen %q must be a host:port address", m.Listen)
+		}
+	}
+	if a := c.Global.API; a != nil {
+		if a.Listen == "" {
+			return fmt.Errorf("global api.listen is mandatory")
+		}
+		_, port, err := net.SplitHostPort(a.Listen)
+		if err != nil || port == "" {
+			return fmt.Errorf("global api.listen %q must be a host:port address", a.Listen)
+		}
+		// Port 0 picks a free port for each
+		if m := c.Global.Metrics; m != nil && m.Listen == a.Listen && port != "0" {
+			return fmt.Errorf("global api.listen and metrics.listen must differ, both are %q", a.Listen)
+		}
+	}
+	switch c.Global.Logging.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("global logging.format must be text or json, got %q", c.Global.Logging.Format)
+	}
+	switch c.Global.Logging.Level {
+	case "", "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("global logging.level must be debug, info, warn or error, got %q", c.Global.Logging.Level)
+	}
+	if err := c.Global.Maintenance.validate(); err != nil {
+		return fmt.Errorf("global maintenance: %w", err)
+	}
+	if c.Global.Jitter < 0 || c.Global.Jitter > 100 {
+		return fmt.Errorf("global jitter must be between 0 and 100")
+	}
+	if c.Global.StateFile != "" {
+		if info, err := os.Stat(filepath.Dir(c.Global.StateFile)); err != nil || !info.IsDir() {
+			return fmt.Errorf("global state_file %q must be in an existing directory", c.Global.StateFile)
+		}
+	}
+	if c.Global.SourceAddress != "" && net.ParseIP(c.Global.SourceAddress) == nil {
+		return fmt.Errorf("global source_address %q is not an IP address", c.Global.SourceAddress)
+	}
+	if _, err := resolverServers(c.Global.Resolver); err != nil {
+		return fmt.Errorf("invalid global resolver: %w", err)
+	}
+	if c.Global.DNSCacheTTL != "" {
+		ttl, err := ParseDuration(c.Global.DNSCacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid global dns_cache_ttl: %w", err)
+		}
+		if ttl < 0 {
+			return fmt.Errorf("global dns_cache_ttl must not be negative")
+		}
+	}
+
+	if hb := c.Global.Heartbeat; hb != nil {
+		if hb.URL == "" {
+			return fmt.Errorf("global heartbeat.url is mandatory")
+		}
+		intervalStr := hb.Interval
+		if intervalStr == "" {
+			intervalStr = c.Global.DefaultInterval
+		}
+		if intervalStr == "" {
+			return fmt.Errorf("global heartbeat.interval is mandatory (no global default_interval set)")
+		}
+		interval, err := ParseDuration(intervalStr)
+		if err != nil {
+			return fmt.Errorf("invalid global heartbeat.interval: %w", err)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("global heartbeat.interval must be positive")
+		}
+		if hb.Timeout != "" {
+			if _, err := ParseDuration(hb.Timeout); err != nil {
+				return fmt.Errorf("invalid global heartbeat.timeout: %w", err)
+			}
+		}
+		if err := hb.validateFormat("global heartbeat"); err != nil {
+			return err
+		}
+	}
+
 	for name, socketCfg := range c.DockerSockets {
 		if socketCfg.Socket == "" && (socketCfg.Host == "" || socketCfg.Port == 0) {
 			return fmt.Errorf("docker socket %q is invalid: must provide either socket path or host/port", name)
@@ -51,6 +216,15 @@ func (c *Config) Validate() error {
 		if tunnelCfg.Type == "" {
 			return fmt.Errorf("tunnel %q type is mandatory", name)
 		}
+		if tunnelCfg.MaxStabilizationTime != "" {
+			d, err := ParseDuration(tunnelCfg.MaxStabilizationTime)
+			if err != nil {
+				return fmt.Errorf("tunnel %q max_stabilization_time is invalid: %w", name, err)
+			}
+			if d <= 0 {
+				return fmt.Errorf("tunnel %q max_stabilization_time must be positive", name)
+			}
+		}
 		switch tunnelCfg.Type {
 		case "ssh":
 			if tunnelCfg.SSH == nil {
@@ -63,6 +237,9 @@ func (c *Config) Validate() error {
 			if tunnelCfg.SSH.User == "" {
 				return fmt.Errorf("tunnel %q ssh user is mandatory", name)
 			}
+			if tunnelCfg.SSH.Command != "" || tunnelCfg.SSH.MatchData != nil {
+				return fmt.Errorf("tunnel %q ssh.command and ssh.match_data are only supported by ssh services", name)
+			}
 			// ... other auth checks ...
 		case "wireguard":
 			if tunnelCfg.Wireguard == nil {
@@ -74,20 +251,40 @@ func (c *Config) Validate() error {
 			if err := tunnelCfg.Wireguard.validateAndSetDefaults(); err != nil {
 				return fmt.Errorf("tunnel %q wireguard: %w", name, err)
 			}
+		case "socks5":
+			if tunnelCfg.Target == "" {
+				return fmt.Errorf("tunnel %q of type socks5 requires a target", name)
+			}
+			if _, _, err := ParseTarget(tunnelCfg.Target); err != nil {
+				return fmt.Errorf("tunnel %q socks5 target %q is invalid: %w", name, tunnelCfg.Target, err)
+			}
+			if s := tunnelCfg.SOCKS5; s != nil && s.Password != "" && s.Username == "" {
+				return fmt.Errorf("tunnel %q socks5 username is mandatory when a password is set", name)
+			}
 		default:
 			return fmt.Errorf("unknown tunnel type %q for tunnel %q", tunnelCfg.Type, name)
 		}
 	}
 
+	seenServices := make(map[string]bool, len(c.Services))
 	for i, svc := range c.Services {
 		if svc.Name == "" {
 			return fmt.Errorf("service[%d] name is mandatory", i)
 		}
+		if seenServices[svc.Name] {
+			return fmt.Errorf("service %q is defined more than once", svc.Name)
+		}
+		seenServices[svc.Name] = true
 
 		if svc.Tunnel != "" {
-			if _, ok := c.Tunnels[svc.Tunnel]; !ok {
+			tunCfg, ok := c.Tunnels[svc.Tunnel]
+			if !ok {
 				return fmt.Errorf("service %q references unknown tunnel %q", svc.Name, svc.Tunnel)
 			}
+			// SOCKS5 proxies only carry TCP connections
+			if tunCfg.Type == "socks5" && (svc.Type == "udp" || svc.Type == "ping" || svc.Type == "traceroute" || svc.Type == "ntp" || (svc.Type == "dns" && (svc.DNS == nil || svc.DNS.Protocol == "" || svc.DNS.Protocol == "udp"))) {
+				return fmt.Errorf("service %q of type %q cannot use socks5 tunnel %q, which only carries TCP", svc.Name, svc.Type, svc.Tunnel)
+			}
 		}
 
 		if svc.Interval == "" && c.Global.DefaultInterval == "" {
@@ -99,15 +296,124 @@ func (c *Config) Validate() error {
 			}
 		}
 
-		if svc.MonitorEndpoint.Success.URL == "" {
+		if len(svc.MonitorEndpoint.Success) == 0 {
 			return fmt.Errorf("service %q monitor_endpoint.success.url is mandatory", svc.Name)
 		}
+		for i, e := range svc.MonitorEndpoint.Success {
+			if e.URL == "" {
+				return fmt.Errorf("service %q %s.url is mandatory", svc.Name, svc.MonitorEndpoint.Success.field("monitor_endpoint.success", i))
+			}
+		}
+
+		switch svc.TargetMode {
+		case "", "any", "all":
+			if svc.Quorum != 0 {
+				return fmt.Errorf("service %q quorum requires target_mode \"quorum\"", svc.Name)
+			}
+		case "quorum":
+			switch svc.Type {
+			case "tcp", "udp", "ping", "docker", "dns":
+			default:
+				return fmt.Errorf("service %q of type %q does not support target_mode \"quorum\"", svc.Name, svc.Type)
+			}
+			if svc.Type == "dns" && svc.DNS != nil && svc.DNS.Protocol == "doh" {
+				return fmt.Errorf("service %q dns.protocol doh does not support target_mode \"quorum\"", svc.Name)
+			}
+			if svc.Quorum < 1 {
+				return fmt.Errorf("service %q quorum must be at least 1", svc.Name)
+			}
+			if n := countTargets(svc.Targets); svc.Quorum > n {
+				return fmt.Errorf("service %q quorum %d exceeds the number of targets (%d)", svc.Name, svc.Quorum, n)
+			}
+		default:
+			return fmt.Errorf("service %q has invalid target_mode %q (must be any, all or quorum)", svc.Name, svc.TargetMode)
+		}
+
+		if svc.Concurrency < 0 {
+			return fmt.Errorf("service %q concurrency must not be negative", svc.Name)
+		}
+		if svc.Concurrency > 1 {
+			switch svc.Type {
+			case "tcp", "udp", "ping", "docker":
+			default:
+				return fmt.Errorf("service %q of type %q does not support concurrency", svc.Name, svc.Type)
+			}
+		}
 
 		switch svc.Type {
 		case "http":
 			if svc.URL == "" {
 				return fmt.Errorf("service %q url is mandatory", svc.Name)
 			}
+			templated := strings.Contains(svc.URL, "{%target%}")
+			if len(svc.Targets) > 0 && !templated {
+				return fmt.Errorf("service %q url must contain {%%target%%} when targets are set", svc.Name)
+			}
+			if templated && len(svc.Targets) == 0 {
+				return fmt.Errorf("service %q url contains {%%target%%} but no targets are set", svc.Name)
+			}
+			if svc.HTTP != nil {
+				if err := validateClientCert(svc.HTTP.ClientCert, svc.HTTP.ClientKey); err != nil {
+					return fmt.Errorf("service %q http: %w", svc.Name, err)
+				}
+				if svc.HTTP.CACert != "" {
+					if _, err := LoadCertPool(svc.HTTP.CACert); err != nil {
+						return fmt.Errorf("service %q http: %w", svc.Name, err)
+					}
+				}
+				if svc.HTTP.Proxy != "" {
+					u, err := url.Parse(svc.HTTP.Proxy)
+					if err != nil {
+						return fmt.Errorf("service %q has invalid http.proxy: %w", svc.Name, err)
+					}
+					switch u.Scheme {
+					case "http", "https", "socks5", "socks5h":
+					default:
+						return fmt.Errorf("service %q http.proxy scheme must be http, https or socks5, got %q", svc.Name, u.Scheme)
+					}
+					if u.Host == "" {
+						return fmt.Errorf("service %q http.proxy is missing a host", svc.Name)
+					}
+				}
+				if svc.HTTP.UnixSocket != "" {
+					if svc.Tunnel != "" {
+						return fmt.Errorf("service %q http.unix_socket cannot be used with a tunnel", svc.Name)
+					}
+					if svc.HTTP.Proxy != "" || svc.HTTP.UseEnvProxy {
+						return fmt.Errorf("service %q http.unix_socket cannot be used with a proxy", svc.Name)
+					}
+				}
+				if _, err := ParseIntRanges(svc.HTTP.AcceptedStatusCodes); err != nil {
+					return fmt.Errorf("service %q http.accepted_status_codes is invalid: %w", svc.Name, err)
+				}
+				if svc.HTTP.MaxRedirects < 0 {
+					return fmt.Errorf("service %q http.max_redirects must not be negative", svc.Name)
+				}
+				if svc.HTTP.MaxRedirects > 0 && svc.HTTP.FollowRedirects != nil && !*svc.HTTP.FollowRedirects {
+					return fmt.Errorf("service %q http.max_redirects requires follow_redirects", svc.Name)
+				}
+				if svc.HTTP.MaxBodyBytes < 0 {
+					return fmt.Errorf("service %q http.max_body_bytes must not be negative", svc.Name)
+				}
+				if svc.HTTP.Body != "" {
+					switch strings.ToUpper(svc.HTTP.Method) {
+					case "POST", "PUT", "PATCH":
+					default:
+						return fmt.Errorf("service %q http.body requires http.method POST, PUT or PATCH", svc.Name)
+					}
+				}
+			}
+		case "websocket":
+			if svc.URL == "" {
+				return fmt.Errorf("service %q url is mandatory", svc.Name)
+			}
+			u, err := url.Parse(svc.URL)
+			if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+				return fmt.Errorf("service %q url must be a ws:// or wss:// URL", svc.Name)
+			}
+			if svc.WebSocket != nil && svc.WebSocket.InsecureSkipVerify && u.Scheme != "wss" {
+				return fmt.Errorf("service %q websocket.insecure_skip_verify requires a wss:// url", svc.Name)
+			}
 		case "tls":
 			if svc.TLS == nil {
 				return fmt.Errorf("service %q of type %q requires tls section", svc.Name, svc.Type)
@@ -118,21 +424,160 @@ func (c *Config) Validate() error {
 			if svc.TLS.CertificateExpiry == "" {
 				return fmt.Errorf("service %q tls.certificate_expiry is mandatory", svc.Name)
 			}
+			if svc.TLS.VerifyChain != nil && *svc.TLS.VerifyChain && svc.TLS.InsecureSkipVerify {
+				return fmt.Errorf("service %q tls.verify_chain cannot be combined with insecure_skip_verify, which disables it", svc.Name)
+			}
+			if svc.TLS.RevocationRequired && !svc.TLS.CheckRevocation {
+				return fmt.Errorf("service %q tls.revocation_required requires tls.check_revocation", svc.Name)
+			}
+			if err := validateClientCert(svc.TLS.ClientCert, svc.TLS.ClientKey); err != nil {
+				return fmt.Errorf("service %q tls: %w", svc.Name, err)
+			}
+			if svc.TLS.CACert != "" {
+				if _, err := LoadCertPool(svc.TLS.CACert); err != nil {
+					return fmt.Errorf("service %q tls: %w", svc.Name, err)
+				}
+			}
 		case "tcp":
 			if len(svc.Targets) == 0 {
 				return fmt.Errorf("service %q targets is mandatory", svc.Name)
 			}
+			if err := validateTargets(svc); err != nil {
+				return err
+			}
+			if svc.TCP != nil {
+				if err := svc.TCP.ResolveConfig.validate(); err != nil {
+					return fmt.Errorf("service %q tcp: %w", svc.Name, err)
+				}
+				if svc.TCP.DSCP < 0 || svc.TCP.DSCP > 63 {
+					return fmt.Errorf("service %q tcp.dscp must be between 0 and 63", svc.Name)
+				}
+				if svc.TCP.DSCP > 0 && svc.Tunnel != "" {
+					return fmt.Errorf("service %q tcp.dscp is not supported over a tunnel", svc.Name)
+				}
+				if svc.TCP.ExpectRegex {
+					if svc.TCP.Expect == "" {
+						return fmt.Errorf("service %q tcp.expect_regex requires tcp.expect", svc.Name)
+					}
+					if _, err := regexp.Compile(svc.TCP.Expect); err != nil {
+						return fmt.Errorf("service %q tcp.expect is not a valid regular expression: %w", svc.Name, err)
+					}
+				}
+			}
 		case "dns":
+			protocol := ""
+			if svc.DNS != nil {
+				protocol = svc.DNS.Protocol
+			}
+			switch protocol {
+			case "", "udp":
+				if len(svc.Targets) == 0 {
+					return fmt.Errorf("service %q targets is mandatory", svc.Name)
+				}
+				if err := validateTargets(svc); err != nil {
+					return err
+				}
+				if svc.DNS != nil && (svc.DNS.InsecureSkipVerify || svc.DNS.CACert != "") {
+					return fmt.Errorf("service %q dns.insecure_skip_verify and dns.ca_cert require protocol doh or dot", svc.Name)
+				}
+			case "doh":
+				if !strings.HasPrefix(svc.DNS.ResolverURL, "https://") {
+					return fmt.Errorf("service %q dns.resolver_url must be an https:// URL when protocol is doh", svc.Name)
+				}
+			case "dot":
+				if len(svc.Targets) == 0 {
+					return fmt.Errorf("service %q targets is mandatory", svc.Name)
+				}
+				if err := validateTargets(svc); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("service %q has invalid dns.protocol %q (must be udp, doh or dot)", svc.Name, protocol)
+			}
+			if svc.DNS != nil && svc.DNS.CACert != "" {
+				if _, err := LoadCertPool(svc.DNS.CACert); err != nil {
+					return fmt.Errorf("service %q dns: %w", svc.Name, err)
+				}
+			}
+			if svc.DNS != nil {
+				if err := svc.DNS.validateRecords(); err != nil {
+					return fmt.Errorf("service %q %w", svc.Name, err)
+				}
+			}
+			if svc.DNS != nil && svc.DNS.MaxDuration != "" {
+				d, err := ParseDuration(svc.DNS.MaxDuration)
+				if err != nil {
+					return fmt.Errorf("service %q dns.max_duration is invalid: %w", svc.Name, err)
+				}
+				if d <= 0 {
+					return fmt.Errorf("service %q dns.max_duration must be positive", svc.Name)
+				}
+			}
+		case "ping":
 			if len(svc.Targets) == 0 {
 				return fmt.Errorf("service %q targets is mandatory", svc.Name)
 			}
-		case "ping":
+			if err := validateTargets(svc); err != nil {
+				return err
+			}
+			if svc.Ping != nil {
+				if err := svc.Ping.ResolveConfig.validate(); err != nil {
+					return fmt.Errorf("service %q ping: %w", svc.Name, err)
+				}
+				if svc.Ping.PacketSize < 0 || svc.Ping.PacketSize > 65500 {
+					return fmt.Errorf("service %q ping.packet_size must be between 0 and 65500", svc.Name)
+				}
+				if svc.Ping.DSCP < 0 || svc.Ping.DSCP > 63 {
+					return fmt.Errorf("service %q ping.dscp must be between 0 and 63", svc.Name)
+				}
+			}
+		case "traceroute":
 			if len(svc.Targets) == 0 {
 				return fmt.Errorf("service %q targets is mandatory", svc.Name)
 			}
+			if err := validateTargets(svc); err != nil {
+				return err
+			}
+			if svc.Traceroute != nil && (svc.Traceroute.MaxHops < 0 || svc.Traceroute.MaxHops > 255) {
+				return fmt.Errorf("service %q traceroute.max_hops must be between 1 and 255", svc.Name)
+			}
 		case "host":
 			// host type just uses name and type, targets optional
-			continue
+			if svc.Host != nil {
+				if svc.Host.MaxLoad < 0 {
+					return fmt.Errorf("service %q host.max_load must not be negative", svc.Name)
+				}
+				if svc.Host.MinDiskFreePercent < 0 || svc.Host.MinDiskFreePercent > 100 {
+					return fmt.Errorf("service %q host.min_disk_free_percent must be between 0 and 100", svc.Name)
+				}
+				if svc.Host.MaxMemoryPercent < 0 || svc.Host.MaxMemoryPercent > 100 {
+					return fmt.Errorf("service %q host.max_memory_percent must be between 0 and 100", svc.Name)
+				}
+				if svc.Host.MaxCPUPercent < 0 || svc.Host.MaxCPUPercent > 100 {
+					return fmt.Errorf("service %q host.max_cpu_percent must be between 0 and 100", svc.Name)
+				}
+				if svc.Host.CPUSample != "" {
+					d, err := ParseDuration(svc.Host.CPUSample)
+					if err != nil {
+						return fmt.Errorf("service %q host.cpu_sample is invalid: %w", svc.Name, err)
+					}
+					if d <= 0 {
+						return fmt.Errorf("service %q host.cpu_sample must be positive", svc.Name)
+					}
+				}
+				for _, path := range slices.Sorted(maps.Keys(svc.Host.MinDiskFree)) {
+					if free := svc.Host.MinDiskFree[path]; free < 0 || free > 100 {
+						return fmt.Errorf("service %q host.min_disk_free %q must be between 0 and 100", svc.Name, path)
+					}
+				}
+				// Two ways of saying the same thing, the map covering several paths
+				if len(svc.Host.MinDiskFree) > 0 && (svc.Host.MinDiskFreePercent > 0 || svc.Host.DiskPath != "") {
+					return fmt.Errorf("service %q host.min_disk_free cannot be combined with min_disk_free_percent or disk_path, list the path in min_disk_free instead", svc.Name)
+				}
+				if svc.Host.DiskPath != "" && svc.Host.MinDiskFreePercent == 0 {
+					return fmt.Errorf("service %q host.disk_path requires min_disk_free_percent", svc.Name)
+				}
+			}
 		case "docker":
 			if svc.Docker == nil {
 				return fmt.Errorf("service %q of type %q requires docker section", svc.Name, svc.Type)
@@ -153,6 +598,9 @@ func (c *Config) Validate() error {
 			if len(svc.Targets) == 0 {
 				return fmt.Errorf("service %q targets is mandatory (container name)", svc.Name)
 			}
+			if svc.Docker.MaxRestarts != nil && *svc.Docker.MaxRestarts < 0 {
+				return fmt.Errorf("service %q docker.max_restarts must not be negative", svc.Name)
+			}
 		case "wireguard":
 			hasTunnel := svc.Tunnel != ""
 
@@ -187,6 +635,22 @@ func (c *Config) Validate() error {
 			if len(svc.Targets) == 0 {
 				return fmt.Errorf("service %q targets is mandatory", svc.Name)
 			}
+			if err := validateTargets(svc); err != nil {
+				return err
+			}
+			if svc.UDP != nil {
+				if err := svc.UDP.ResolveConfig.validate(); err != nil {
+					return fmt.Errorf("service %q udp: %w", svc.Name, err)
+				}
+				if svc.UDP.ExpectRegex {
+					if svc.UDP.Expect == "" {
+						return fmt.Errorf("service %q udp.expect_regex requires udp.expect", svc.Name)
+					}
+					if _, err := regexp.Compile(svc.UDP.Expect); err != nil {
+						return fmt.Errorf("service %q udp.expect is not a valid regular expression: %w", svc.Name, err)
+					}
+				}
+			}
 		case "ssh":
 			if len(svc.Targets) > 0 {
 				return fmt.Errorf("service %q ssh must use 'target' (string) instead of 'targets' (list)", svc.Name)
@@ -212,10 +676,131 @@ func (c *Config) Validate() error {
 							return fmt.Errorf("service %q ssh private_key is invalid: %w", svc.Name, err)
 						}
 					}
+				} else if svc.SSH.Command != "" {
+					return fmt.Errorf("service %q ssh.command requires auth_required", svc.Name)
+				}
+				if svc.SSH.MatchData != nil {
+					if svc.SSH.Command == "" {
+						return fmt.Errorf("service %q ssh.match_data requires ssh.command", svc.Name)
+					}
+					for _, exp := range svc.SSH.MatchData.Expectations {
+						if exp.Type == "header" {
+							return fmt.Errorf("service %q ssh.match_data cannot use header expectations, only body and json", svc.Name)
+						}
+					}
+				}
+			}
+		case "exec":
+			if !c.Global.AllowExec {
+				return fmt.Errorf("service %q runs local commands, which requires global.allow_exec: true", svc.Name)
+			}
+			if svc.Exec == nil || svc.Exec.Command == "" {
+				return fmt.Errorf("service %q exec.command is mandatory", svc.Name)
+			}
+			if svc.Tunnel != "" {
+				return fmt.Errorf("service %q exec runs locally and cannot use a tunnel", svc.Name)
+			}
+			if svc.Exec.ExitCode < 0 || svc.Exec.ExitCode > 255 {
+				return fmt.Errorf("service %q exec.exit_code must be between 0 and 255", svc.Name)
+			}
+			if svc.Exec.ExitCode != 0 && svc.Exec.ExitCodes != "" {
+				return fmt.Errorf("service %q exec.exit_code and exec.exit_codes are mutually exclusive", svc.Name)
+			}
+			if _, err := ParseIntRanges(svc.Exec.ExitCodes); err != nil {
+				return fmt.Errorf("service %q exec.exit_codes is invalid: %w", svc.Name, err)
+			}
+			if svc.Exec.ExpectRegex {
+				if svc.Exec.Expect == "" {
+					return fmt.Errorf("service %q exec.expect_regex requires exec.expect", svc.Name)
+				}
+				if _, err := regexp.Compile(svc.Exec.Expect); err != nil {
+					return fmt.Errorf("service %q exec.expect is not a valid regular expression: %w", svc.Name, err)
+				}
+			}
+		case "mysql", "postgres":
+			if svc.Database != nil && svc.Database.DSN != "" {
+				if len(svc.Targets) > 0 {
+					return fmt.Errorf("service %q database.dsn cannot be combined with targets", svc.Name)
+				}
+				if svc.Database.User != "" || svc.Database.Password != "" || svc.Database.DBName != "" {
+					return fmt.Errorf("service %q database.dsn cannot be combined with database.user, password or dbname", svc.Name)
+				}
+			} else {
+				if len(svc.Targets) == 0 {
+					return fmt.Errorf("service %q requires targets or database.dsn", svc.Name)
+				}
+				if svc.Database == nil || svc.Database.User == "" {
+					return fmt.Errorf("service %q database.user is mandatory when using targets", svc.Name)
+				}
+			}
+			if svc.Database != nil && svc.Database.Expect != "" && svc.Database.Query == "" {
+				return fmt.Errorf("service %q database.expect requires database.query", svc.Name)
+			}
+		case "smtp":
+			if len(svc.Targets) == 0 {
+				return fmt.Errorf("service %q targets is mandatory", svc.Name)
+			}
+			if err := validateTargets(svc); err != nil {
+				return err
+			}
+			if svc.SMTP != nil {
+				if !svc.SMTP.StartTLS && (svc.SMTP.CertificateExpiry != "" || svc.SMTP.InsecureSkipVerify || svc.SMTP.CACert != "") {
+					return fmt.Errorf("service %q smtp.certificate_expiry, insecure_skip_verify and ca_cert require smtp.starttls", svc.Name)
+				}
+				if svc.SMTP.CertificateExpiry != "" {
+					if _, err := ParseDuration(svc.SMTP.CertificateExpiry); err != nil {
+						return fmt.Errorf("service %q smtp.certificate_expiry is invalid: %w", svc.Name, err)
+					}
+				}
+				if svc.SMTP.CACert != "" {
+					if _, err := LoadCertPool(svc.SMTP.CACert); err != nil {
+						return fmt.Errorf("service %q smtp: %w", svc.Name, err)
+					}
+				}
+			}
+		case "ntp":
+			if len(svc.Targets) == 0 {
+				return fmt.Errorf("service %q targets is mandatory", svc.Name)
+			}
+			if err := validateTargets(svc); err != nil {
+				return err
+			}
+			if svc.NTP != nil && svc.NTP.MaxOffset != "" {
+				d, err := ParseDuration(svc.NTP.MaxOffset)
+				if err != nil {
+					return fmt.Errorf("service %q ntp.max_offset is invalid: %w", svc.Name, err)
+				}
+				if d <= 0 {
+					return fmt.Errorf("service %q ntp.max_offset must be positive", svc.Name)
+				}
+			}
+		case "mqtt":
+			if len(svc.Targets) == 0 {
+				return fmt.Errorf("service %q targets is mandatory", svc.Name)
+			}
+			if err := validateTargets(svc); err != nil {
+				return err
+			}
+			if svc.MQTT != nil {
+				if svc.MQTT.Password != "" && svc.MQTT.Username == "" {
+					return fmt.Errorf("service %q mqtt.password requires mqtt.username", svc.Name)
+				}
+				if svc.MQTT.QoS < 0 || svc.MQTT.QoS > 1 {
+					return fmt.Errorf("service %q mqtt.qos must be 0 or 1", svc.Name)
+				}
+				if svc.MQTT.Publish != "" {
+					if svc.MQTT.Topic == "" {
+						return fmt.Errorf("service %q mqtt.publish requires mqtt.topic", svc.Name)
+					}
+					if strings.ContainsAny(svc.MQTT.Topic, "+#") {
+						return fmt.Errorf("service %q mqtt.topic can't contain wildcards with mqtt.publish", svc.Name)
+					}
 				}
 			}
 		default:
-			return fmt.Errorf("service %q has unknown type %q", svc.Name, svc.Type)
+			if IsRegisteredType == nil || !IsRegisteredType(svc.Type) {
+				return fmt.Errorf("service %q has unknown type %q", svc.Name, svc.Type)
+			}
 		}
 
 		// Validate service-level timeout against service interval
@@ -225,29 +810,12 @@ func (c *Config) Validate() error {
 		}
 		interval, _ := ParseDuration(intervalStr)
 
-		timeoutStr := svc.Timeout
-		if timeoutStr == "" {
-			timeoutStr = "5s"
-		}
-		timeout, err := ParseDuration(timeoutStr)
+		timeout, err := ParseDuration(c.ServiceTimeout(svc))
 		if err != nil {
 			return fmt.Errorf("service %q timeout is invalid: %w", svc.Name, err)
 		}
 
-		// Determine effective probe retries
-		probeRetries := 3 // Hardcoded default fallback (should be set by Validate globals but safe to have)
-		if c.Global.Monitor.Retries != nil {
-			probeRetries = *c.Global.Monitor.Retries
-		}
-		if svc.Retries != nil {
-			probeRetries = *svc.Retries
-		}
-
-		// Exempt host and wireguard probes from retries
-		if svc.Type == "host" || svc.Type == "wireguard" {
-			probeRetries = 0
-		}
-
+		probeRetries := c.ProbeRetries(svc)
 		if probeRetries < 0 {
 			return fmt.Errorf("service %q: retries cannot be negative", svc.Name)
 		}
@@ -256,66 +824,210 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("service %q timeout (%v) must be less than interval (%v)", svc.Name, timeout, interval)
 		}
 
-		// Validate (retries + 1) * timeout + 1s buffer < interval (exempt host/wireguard and when retries is 0)
-		if probeRetries > 0 {
-			totalProbeTime := time.Duration(probeRetries+1)*timeout + time.Second
-			if totalProbeTime >= interval {
-				return fmt.Errorf("service %q: total probe time (%v) including %d retries and 1s buffer must be less than interval (%v)", svc.Name, totalProbeTime, probeRetries, interval)
+		if svc.ConnectTimeout != "" {
+			switch svc.Type {
+			case "tcp", "http", "tls", "ssh", "mysql", "postgres", "smtp":
+			default:
+				return fmt.Errorf("service %q of type %q does not support connect_timeout", svc.Name, svc.Type)
+			}
+			connectTimeout, err := ParseDuration(svc.ConnectTimeout)
+			if err != nil {
+				return fmt.Errorf("service %q connect_timeout is invalid: %w", svc.Name, err)
+			}
+			if connectTimeout <= 0 {
+				return fmt.Errorf("service %q connect_timeout must be positive", svc.Name)
+			}
+			if connectTimeout > timeout {
+				return fmt.Errorf("service %q connect_timeout (%v) must not exceed timeout (%v)", svc.Name, connectTimeout, timeout)
 			}
 		}
 
-		// Validate monitor endpoint timeouts
-		if svc.MonitorEndpoint.Timeout != "" {
-			if _, err := ParseDuration(svc.MonitorEndpoint.Timeout); err != nil {
-				return fmt.Errorf("service %q monitor_endpoint.timeout is invalid: %w", svc.Name, err)
+		if svc.SourceAddress != "" {
+			switch svc.Type {
+			case "tcp", "udp", "http", "ping", "traceroute":
+			default:
+				return fmt.Errorf("service %q of type %q does not support source_address", svc.Name, svc.Type)
 			}
-		}
-		if svc.MonitorEndpoint.Success.Timeout != "" {
-			if _, err := ParseDuration(svc.MonitorEndpoint.Success.Timeout); err != nil {
-				return fmt.Errorf("service %q monitor_endpoint.success.timeout is invalid: %w", svc.Name, err)
+			if net.ParseIP(svc.SourceAddress) == nil {
+				return fmt.Errorf("service %q source_address %q is not an IP address", svc.Name, svc.SourceAddress)
 			}
-		}
-		if svc.MonitorEndpoint.Failure != nil && svc.MonitorEndpoint.Failure.Timeout != "" {
-			if _, err := ParseDuration(svc.MonitorEndpoint.Failure.Timeout); err != nil {
-				return fmt.Errorf("service %q monitor_endpoint.failure.timeout is invalid: %w", svc.Name, err)
+			if svc.Tunnel != "" {
+				return fmt.Errorf("service %q source_address is not supported over a tunnel", svc.Name)
 			}
 		}
 
-		// Validate notifier retries and effective timeout against service interval
-		// 1. Determine effective timeout for this service's notifier
-		// hierarchy: endpoint > service-shared > global > default (5s)
-		// We'll check Success endpoint specifically as it's mandatory
-		notifierTimeoutStr := svc.MonitorEndpoint.Success.Timeout
-		if notifierTimeoutStr == "" {
-			notifierTimeoutStr = svc.MonitorEndpoint.Timeout
-		}
-		if notifierTimeoutStr == "" {
-			notifierTimeoutStr = c.Global.MonitorEndpoint.Timeout
+		if svc.Family != "" {
+			switch svc.Type {
+			case "tcp", "udp", "http", "ping":
+			default:
+				return fmt.Errorf("service %q of type %q does not support family", svc.Name, svc.Type)
+			}
+			switch svc.Family {
+			case "auto":
+			case "ipv4", "ipv6":
+				if ip := net.ParseIP(svc.SourceAddress); ip != nil && (ip.To4() != nil) != (svc.Family == "ipv4") {
+					return fmt.Errorf("service %q source_address %q is not an %s address", svc.Name, svc.SourceAddress, svc.Family)
+				}
+			default:
+				return fmt.Errorf("service %q has invalid family %q (must be auto, ipv4 or ipv6)", svc.Name, svc.Family)
+			}
 		}
 
-		notifierTimeout := 5 * time.Second // Default
-		if notifierTimeoutStr != "" {
-			if d, err := ParseDuration(notifierTimeoutStr); err == nil && d > 0 {
-				notifierTimeout = d
+		if svc.Resolver != "" {
+			switch svc.Type {
+			case "http", "tcp", "udp", "tls", "smtp":
+			default:
+				return fmt.Errorf("service %q of type %q does not support resolver", svc.Name, svc.Type)
+			}
+			if _, err := resolverServers(svc.Resolver); err != nil {
+				return fmt.Errorf("service %q has invalid resolver: %w", svc.Name, err)
+			}
+			if svc.Tunnel != "" {
+				return fmt.Errorf("service %q resolver is not supported over a tunnel, which resolves on its far side", svc.Name)
 			}
 		}
 
-		// 2. Determine effective retries
-		// Determine effective retries: service-level > global > default (3)
-		retries := 3 // default
-		if c.Global.MonitorEndpoint.Retries != nil {
-			retries = *c.Global.MonitorEndpoint.Retries
-		}
-		if svc.MonitorEndpoint.Retries != nil {
-			retries = *svc.MonitorEndpoint.Retries
+		// A total_timeout bounds each attempt across all targets, so it replaces
+		// the per-target timeout in the interval budget below
+		attemptTimeout := timeout
+		if svc.TotalTimeout != "" {
+			totalTimeout, err := ParseDuration(svc.TotalTimeout)
+			if err != nil {
+				return fmt.Errorf("service %q total_timeout is invalid: %w", svc.Name, err)
+			}
+			if totalTimeout <= 0 {
+				return fmt.Errorf("service %q total_timeout must be positive", svc.Name)
+			}
+			if totalTimeout >= interval {
+				return fmt.Errorf("service %q total_timeout (%v) must be less than interval (%v)", svc.Name, totalTimeout, interval)
+			}
+			attemptTimeout = totalTimeout
 		}
 
-		if retries < 0 {
-			return fmt.Errorf("service %q: monitor_endpoint.retries cannot be negative", svc.Name)
+		var retryInterval time.Duration
+		if svc.RetryInterval != "" {
+			retryInterval, err = ParseDuration(svc.RetryInterval)
+			if err != nil {
+				return fmt.Errorf("service %q retry_interval is invalid: %w", svc.Name, err)
+			}
+			if retryInterval < 0 {
+				return fmt.Errorf("service %q retry_interval must not be negative", svc.Name)
+			}
+			if probeRetries == 0 && retryInterval > 0 {
+				return fmt.Errorf("service %q retry_interval requires retries", svc.Name)
+			}
 		}
 
-		// 3. Validate (retries + 1) * timeout + 1s buffer < interval (skip when retries is 0)
-		if retries > 0 {
+		if svc.MaxResponseTime != "" {
+			maxResponseTime, err := ParseDuration(svc.MaxResponseTime)
+			if err != nil {
+				return fmt.Errorf("service %q max_response_time is invalid: %w", svc.Name, err)
+			}
+			if maxResponseTime <= 0 {
+				return fmt.Errorf("service %q max_response_time must be positive", svc.Name)
+			}
+			if maxResponseTime >= interval {
+				return fmt.Errorf("service %q max_response_time (%v) must be less than interval (%v)", svc.Name, maxResponseTime, interval)
+			}
+		}
+
+		// Validate (retries + 1) * timeout + retries * retry_interval + 1s buffer < interval (exempt host/wireguard and when retries is 0)
+		if probeRetries > 0 {
+			totalProbeTime := time.Duration(probeRetries+1)*attemptTimeout + time.Duration(probeRetries)*retryInterval + time.Second
+			if totalProbeTime >= interval {
+				if retryInterval > 0 {
+					return fmt.Errorf("service %q: total probe time (%v) including %d retries every %v and 1s buffer must be less than interval (%v)", svc.Name, totalProbeTime, probeRetries, retryInterval, interval)
+				}
+				return fmt.Errorf("service %q: total probe time (%v) including %d retries and 1s buffer must be less than interval (%v)", svc.Name, totalProbeTime, probeRetries, interval)
+			}
+		}
+
+		// Validate monitor endpoint timeouts
+		if svc.MonitorEndpoint.Timeout != "" {
+			if _, err := ParseDuration(svc.MonitorEndpoint.Timeout); err != nil {
+				return fmt.Errorf("service %q monitor_endpoint.timeout is invalid: %w", svc.Name, err)
+			}
+		}
+		if err := svc.MonitorEndpoint.Success.validate("monitor_endpoint.success"); err != nil {
+			return fmt.Errorf("service %q %w", svc.Name, err)
+		}
+		if err := svc.MonitorEndpoint.Failure.validate("monitor_endpoint.failure"); err != nil {
+			return fmt.Errorf("service %q %w", svc.Name, err)
+		}
+		if svc.MonitorEndpoint.EscalateAfter != "" {
+			d, err := ParseDuration(svc.MonitorEndpoint.EscalateAfter)
+			if err != nil {
+				return fmt.Errorf("service %q monitor_endpoint.escalate_after is invalid: %w", svc.Name, err)
+			}
+			if d <= 0 {
+				return fmt.Errorf("service %q monitor_endpoint.escalate_after must be positive", svc.Name)
+			}
+			if !slices.ContainsFunc(svc.MonitorEndpoint.Failure, func(e EndpointConfig) bool { return e.URL != "" }) {
+				return fmt.Errorf("service %q monitor_endpoint.escalate_after requires a failure endpoint", svc.Name)
+			}
+		} else if svc.MonitorEndpoint.EscalateRepeat {
+			return fmt.Errorf("service %q monitor_endpoint.escalate_repeat requires escalate_after", svc.Name)
+		}
+		if err := svc.Maintenance.validate(); err != nil {
+			return fmt.Errorf("service %q maintenance: %w", svc.Name, err)
+		}
+		if svc.MonitorEndpoint.ReminderInterval != "" {
+			d, err := ParseDuration(svc.MonitorEndpoint.ReminderInterval)
+			if err != nil {
+				return fmt.Errorf("service %q monitor_endpoint.reminder_interval is invalid: %w", svc.Name, err)
+			}
+			if d < 0 {
+				return fmt.Errorf("service %q monitor_endpoint.reminder_interval must not be negative", svc.Name)
+			}
+			if d > 0 && !c.OnChangeOnly(svc) {
+				return fmt.Errorf("service %q monitor_endpoint.reminder_interval requires on_change_only", svc.Name)
+			}
+		}
+		if svc.MonitorEndpoint.EscalateAfter != "" && c.OnChangeOnly(svc) && c.ReminderInterval(svc) == 0 {
+			// Only the first failure would be pushed, never one to escalate
+			return fmt.Errorf("service %q monitor_endpoint.escalate_after with on_change_only requires a reminder_interval", svc.Name)
+		}
+
+		// Validate notifier retries and effective timeout against service interval
+		// 1. Determine effective timeout for this service's notifier
+		// hierarchy: endpoint > service-shared > global > default (5s)
+		// We'll check Success endpoints specifically as they're mandatory. They
+		// are pushed one after the other, so their timeouts add up.
+		var notifierTimeout time.Duration
+		for _, e := range svc.MonitorEndpoint.Success {
+			notifierTimeoutStr := e.Timeout
+			if notifierTimeoutStr == "" {
+				notifierTimeoutStr = svc.MonitorEndpoint.Timeout
+			}
+			if notifierTimeoutStr == "" {
+				notifierTimeoutStr = c.Global.MonitorEndpoint.Timeout
+			}
+
+			endpointTimeout := 5 * time.Second // Default
+			if notifierTimeoutStr != "" {
+				if d, err := ParseDuration(notifierTimeoutStr); err == nil && d > 0 {
+					endpointTimeout = d
+				}
+			}
+			notifierTimeout += endpointTimeout
+		}
+
+		// 2. Determine effective retries
+		// Determine effective retries: service-level > global > default (3)
+		retries := 3 // default
+		if c.Global.MonitorEndpoint.Retries != nil {
+			retries = *c.Global.MonitorEndpoint.Retries
+		}
+		if svc.MonitorEndpoint.Retries != nil {
+			retries = *svc.MonitorEndpoint.Retries
+		}
+
+		if retries < 0 {
+			return fmt.Errorf("service %q: monitor_endpoint.retries cannot be negative", svc.Name)
+		}
+
+		// 3. Validate (retries + 1) * timeout + 1s buffer < interval (skip when retries is 0)
+		if retries > 0 {
 			totalNotifierTime := time.Duration(retries+1)*notifierTimeout + time.Second
 			if totalNotifierTime >= interval {
 				return fmt.Errorf("service %q: total notifier time (%v) including %d retries and 1s buffer must be less than interval (%v)", svc.Name, totalNotifierTime, retries, interval)
@@ -347,9 +1059,65 @@ func (c *Config) Validate() error {
 
 type GlobalConfig struct {
 	DefaultInterval string                      `yaml:"default_interval,omitempty"`
+	DefaultTimeout  string                      `yaml:"default_timeout,omitempty"` // Probe timeout of services that don't set one, defaults to DefaultTimeout
 	MonitorEndpoint GlobalMonitorEndpointConfig `yaml:"monitor_endpoint,omitempty"`
 	Monitor         MonitorConfig               `yaml:"monitor,omitempty"`
 	Notifier        NotifierConfig              `yaml:"notifier,omitempty"`
+	Heartbeat       *HeartbeatConfig            `yaml:"heartbeat,omitempty"`
+	HistorySize     int                         `yaml:"history_size,omitempty"`   // Recent checks kept per service for the uptime ratio
+	SourceAddress   string                      `yaml:"source_address,omitempty"` // Local IP tcp, udp, http and ping probes connect from
+	DNSCacheTTL     string                      `yaml:"dns_cache_ttl,omitempty"`  // How long tcp, udp, http and ping probes reuse resolved addresses, 0 or unset to resolve every check
+	Resolver        string                      `yaml:"resolver,omitempty"`       // DNS servers http, tcp, udp, tls and smtp probes resolve hostnames with, comma-separated host:port
+	Metrics         *MetricsConfig              `yaml:"metrics,omitempty"`
+	API             *APIConfig                  `yaml:"api,omitempty"`
+	StateFile       string                      `yaml:"state_file,omitempty"`     // Persists the last status of each service across restarts
+	Jitter          int                         `yaml:"jitter,omitempty"`         // Percentage of the interval each service's schedule is randomly offset by
+	JitterSeed      *int64                      `yaml:"jitter_seed,omitempty"`    // Makes the offsets deterministic per service
+	CheckOnStart    *bool                       `yaml:"check_on_start,omitempty"` // Check every service as soon as it starts, defaults to true
+	Maintenance     *MaintenanceConfig          `yaml:"maintenance,omitempty"`    // Applies to every service, on top of their own
+	Logging         LoggingConfig               `yaml:"logging,omitempty"`
+	AllowExec       bool                        `yaml:"allow_exec,omitempty"` // Allows exec services to run local commands, off by default
+}
+
+type LoggingConfig struct {
+	Format string `yaml:"format,omitempty"` // text (default) or json
+	Level  string `yaml:"level,omitempty"`  // debug, info (default), warn or error
+}
+
+// MetricsConfig enables an HTTP server exposing probe results to Prometheus
+// on /metrics.
+type MetricsConfig struct {
+	Listen string `yaml:"listen"` // Address to listen on, e.g. ":9090" or "127.0.0.1:9090"
+}
+
+// APIConfig enables an HTTP API reporting the latest result of each service
+// as JSON on /status, and checking a service on demand on /check.
+type APIConfig struct {
+	Listen string `yaml:"listen"` // Address to listen on, e.g. "127.0.0.1:8080"
+}
+
+// MaintenanceConfig holds windows during which checks still run but their
+// failures, and optionally successes, are not pushed.
+type MaintenanceConfig struct {
+	Timezone        string              `yaml:"timezone,omitempty"`         // IANA name the windows are evaluated in, defaults to local time
+	SuppressSuccess bool                `yaml:"suppress_success,omitempty"` // Don't push successes either
+	Windows         []MaintenanceWindow `yaml:"windows"`
+}
+
+// MaintenanceWindow is a daily time range, restricted to some weekdays.
+// A window ending before it starts spans midnight, its days are those it
+// starts on.
+type MaintenanceWindow struct {
+	Days  []string `yaml:"days,omitempty"` // mon, tue, ... sun, every day when empty
+	Start string   `yaml:"start"`          // HH:MM
+	End   string   `yaml:"end"`            // HH:MM, up to 24:00
+}
+
+// HeartbeatConfig is an endpoint the agent pushes to on a fixed interval,
+// independent of any service, so an external monitor can detect the agent dying.
+type HeartbeatConfig struct {
+	EndpointConfig `yaml:",inline"`
+	Interval       string `yaml:"interval,omitempty"` // Defaults to global default_interval
 }
 
 type MonitorConfig struct {
@@ -357,7 +1125,10 @@ type MonitorConfig struct {
 }
 
 type NotifierConfig struct {
-	RateLimit *string `yaml:"rate_limit,omitempty"` // Global rate limit for notifications
+	RateLimit        *string `yaml:"rate_limit,omitempty"`        // Global rate limit for notifications
+	OnChangeOnly     bool    `yaml:"on_change_only,omitempty"`    // Push only when a service's status changes
+	ReminderInterval string  `yaml:"reminder_interval,omitempty"` // With on_change_only, push again while down this often
+	MaxBackoff       string  `yaml:"max_backoff,omitempty"`       // Caps the backoff of an endpoint failing repeatedly, "0" disables it
 }
 
 type DockerSocketConfig struct {
@@ -376,28 +1147,47 @@ type GlobalMonitorEndpointConfig struct {
 
 type Service struct {
 	Name            string                `yaml:"name"`
-	Type            string                `yaml:"type"` // http, tcp, dns, ping, host, docker, wireguard, tls
+	Type            string                `yaml:"type"` // http, tcp, dns, ping, traceroute, host, docker, wireguard, tls, exec, mysql, postgres
 	URL             string                `yaml:"url,omitempty"`
 	Target          string                `yaml:"target,omitempty"`
 	Targets         []string              `yaml:"targets,omitempty"`
-	TargetMode      string                `yaml:"target_mode,omitempty"` // "any" or "all"
+	TargetMode      string                `yaml:"target_mode,omitempty"` // "any", "all" or "quorum"
+	Quorum          int                   `yaml:"quorum,omitempty"`      // Targets that must succeed in "quorum" mode
+	Concurrency     int                   `yaml:"concurrency,omitempty"` // Targets checked at once, 0 or 1 checks them one by one
 	Tunnel          string                `yaml:"tunnel,omitempty"`
 	Interval        string                `yaml:"interval,omitempty"`
 	Timeout         string                `yaml:"timeout,omitempty"`
+	TotalTimeout    string                `yaml:"total_timeout,omitempty"`   // Bounds a whole check across all targets
+	ConnectTimeout  string                `yaml:"connect_timeout,omitempty"` // Bounds the dial phase, defaults to timeout
+	SourceAddress   string                `yaml:"source_address,omitempty"`  // Overrides global.source_address
+	Family          string                `yaml:"family,omitempty"`          // "auto", "ipv4" or "ipv6", the address family tcp, udp, http and ping probes connect over
+	Resolver        string                `yaml:"resolver,omitempty"`        // Overrides global.resolver
 	MonitorEndpoint MonitorEndpointConfig `yaml:"monitor_endpoint"`
+	Maintenance     *MaintenanceConfig    `yaml:"maintenance,omitempty"` // On top of global.maintenance
 
 	// Type-specific configs
-	HTTP      *HTTPConfig      `yaml:"http,omitempty"`
-	TCP       *TCPConfig       `yaml:"tcp,omitempty"`
-	DNS       *DNSConfig       `yaml:"dns,omitempty"`
-	Ping      *PingConfig      `yaml:"ping,omitempty"`
-	Host      *HostConfig      `yaml:"host,omitempty"`
-	Docker    *DockerConfig    `yaml:"docker,omitempty"`
-	Wireguard *WireguardConfig `yaml:"wireguard,omitempty"`
-	TLS       *TLSConfig       `yaml:"tls,omitempty"`
-	UDP       *UDPConfig       `yaml:"udp,omitempty"`
-	SSH       *SSHConfig       `yaml:"ssh,omitempty"`
-	Retries   *int             `yaml:"retries,omitempty"` // Service-level override
+	HTTP       *HTTPConfig       `yaml:"http,omitempty"`
+	TCP        *TCPConfig        `yaml:"tcp,omitempty"`
+	DNS        *DNSConfig        `yaml:"dns,omitempty"`
+	Ping       *PingConfig       `yaml:"ping,omitempty"`
+	Host       *HostConfig       `yaml:"host,omitempty"`
+	Docker     *DockerConfig     `yaml:"docker,omitempty"`
+	Wireguard  *WireguardConfig  `yaml:"wireguard,omitempty"`
+	TLS        *TLSConfig        `yaml:"tls,omitempty"`
+	UDP        *UDPConfig        `yaml:"udp,omitempty"`
+	SSH        *SSHConfig        `yaml:"ssh,omitempty"`
+	Exec       *ExecConfig       `yaml:"exec,omitempty"`
+	Database   *DatabaseConfig   `yaml:"database,omitempty"` // mysql and postgres
+	SMTP       *SMTPConfig       `yaml:"smtp,omitempty"`
+	NTP        *NTPConfig        `yaml:"ntp,omitempty"`
+	MQTT       *MQTTConfig       `yaml:"mqtt,omitempty"`
+	WebSocket  *WebSocketConfig  `yaml:"websocket,omitempty"`
+	Traceroute *TracerouteConfig `yaml:"traceroute,omitempty"`
+	Retries    *int              `yaml:"retries,omitempty"` // Service-level override
+	// Wait between a failed attempt and its retry, so a transient failure can clear
+	RetryInterval string `yaml:"retry_interval,omitempty"`
+	// Fail otherwise successful checks that take longer than this
+	MaxResponseTime string `yaml:"max_response_time,omitempty"`
 }
 
 type HTTPConfig struct {
@@ -407,47 +1197,205 @@ type HTTPConfig struct {
 	InsecureSkipVerify  bool              `yaml:"insecure_skip_verify,omitempty"`
 	MatchData           *MatchDataConfig  `yaml:"match_data,omitempty"`
 	CertificateExpiry   string            `yaml:"certificate_expiry,omitempty"`
+	ClientCert          string            `yaml:"client_cert,omitempty"`            // mTLS client certificate, file path or inline PEM
+	ClientKey           string            `yaml:"client_key,omitempty"`             // mTLS client key, file path or inline PEM
+	CACert              string            `yaml:"ca_cert,omitempty"`                // CA bundle to verify the server, file path or inline PEM
+	Proxy               string            `yaml:"proxy,omitempty"`                  // http://, https:// or socks5:// proxy URL
+	UseEnvProxy         bool              `yaml:"use_env_proxy,omitempty"`          // Use HTTP_PROXY/HTTPS_PROXY/NO_PROXY when proxy is not set
+	UnixSocket          string            `yaml:"unix_socket,omitempty"`            // Send the request over this Unix domain socket instead of TCP
+	DisableKeepAlive    bool              `yaml:"disable_keepalive,omitempty"`      // Open a new connection for every check
+	Body                string            `yaml:"body,omitempty"`                   // Request body for POST, PUT and PATCH, {%timestamp%} is replaced
+	FollowRedirects     *bool             `yaml:"follow_redirects,omitempty"`       // Default to true, false evaluates 3xx responses as is
+	MaxRedirects        int               `yaml:"max_redirects,omitempty"`          // Redirects followed before failing, defaults to 10
+	MaxBodyBytes        int64             `yaml:"max_body_bytes,omitempty"`         // Decoded body read to match expectations, defaults to 10 MiB
+	FailOnOversizedBody bool              `yaml:"fail_on_oversized_body,omitempty"` // Fail on a larger body instead of matching what was read
 }
 
 type TCPConfig struct {
+	ResolveConfig `yaml:",inline"`
+	DSCP          int    `yaml:"dscp,omitempty"`         // DSCP value (0-63) marked on outgoing packets
+	Send          string `yaml:"send,omitempty"`         // Optional, written once connected, e.g. "PING\r\n"
+	Expect        string `yaml:"expect,omitempty"`       // Optional, the response must contain it
+	ExpectRegex   bool   `yaml:"expect_regex,omitempty"` // Match expect as a regular expression
+}
+
+// ResolveConfig makes probes resolve hostname targets themselves, reporting
+// the address they probed and optionally asserting on it.
+type ResolveConfig struct {
+	Resolve   bool     `yaml:"resolve,omitempty"`
+	ResolveTo []string `yaml:"resolve_to,omitempty"` // Expected addresses, implies resolve
+}
+
+// Enabled reports whether target resolution is requested.
+func (r ResolveConfig) Enabled() bool {
+	return r.Resolve || len(r.ResolveTo) > 0
+}
+
+func (r ResolveConfig) validate() error {
+	for _, addr := range r.ResolveTo {
+		if net.ParseIP(addr) == nil {
+			return fmt.Errorf("resolve_to entry %q is not an IP address", addr)
+		}
+	}
+	return nil
 }
 
 type DNSConfig struct {
-	Domain string `yaml:"domain,omitempty"`
+	Domain      string `yaml:"domain,omitempty"`
+	Protocol    string `yaml:"protocol,omitempty"`     // "udp" (default), "doh" or "dot"
+	ResolverURL string `yaml:"resolver_url,omitempty"` // DoH endpoint, required when protocol is "doh"
+	MaxDuration string `yaml:"max_duration,omitempty"` // Fail resolutions slower than this, even if they succeed
+	RecordType  string `yaml:"record_type,omitempty"`  // A, AAAA, CNAME, MX, TXT or NS; unset only checks the domain resolves
+	// Values that must all be among the records of record_type, e.g. an IP or "10 mail.example.com"
+	ExpectedValues []string `yaml:"expected_values,omitempty"`
+	// TLS settings of DoH and DoT resolvers
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+	CACert             string `yaml:"ca_cert,omitempty"` // File path or inline PEM
 }
 
 type PingConfig struct {
+	ResolveConfig `yaml:",inline"`
+	PacketSize    int  `yaml:"packet_size,omitempty"`   // ICMP payload size in bytes, defaults to the ping default
+	DontFragment  bool `yaml:"dont_fragment,omitempty"` // Set the DF bit, for MTU testing
+	DSCP          int  `yaml:"dscp,omitempty"`          // DSCP value (0-63) marked on echo requests
+}
+
+type TracerouteConfig struct {
+	MaxHops int `yaml:"max_hops,omitempty"` // Hops to try before giving up, defaults to 30
 }
 
 type HostConfig struct {
+	MaxLoad            float64            `yaml:"max_load,omitempty"`              // 1-minute load average
+	MaxCPUPercent      float64            `yaml:"max_cpu_percent,omitempty"`       // CPU in use, measured over cpu_sample
+	CPUSample          string             `yaml:"cpu_sample,omitempty"`            // Defaults to 1s
+	MinDiskFreePercent float64            `yaml:"min_disk_free_percent,omitempty"` // Free space on disk_path
+	DiskPath           string             `yaml:"disk_path,omitempty"`             // Defaults to "/"
+	MinDiskFree        map[string]float64 `yaml:"min_disk_free,omitempty"`         // Free space percentage per mount path
+	MaxMemoryPercent   float64            `yaml:"max_memory_percent,omitempty"`    // Memory in use, excluding reclaimable caches
+}
+
+type ExecConfig struct {
+	Command     string   `yaml:"command"`
+	Args        []string `yaml:"args,omitempty"`
+	ExitCode    int      `yaml:"exit_code,omitempty"`    // Exit code that counts as success, defaults to 0
+	ExitCodes   string   `yaml:"exit_codes,omitempty"`   // Successful exit codes and ranges, e.g. "0-1, 3", instead of exit_code
+	Expect      string   `yaml:"expect,omitempty"`       // Optional, stdout must contain it
+	ExpectRegex bool     `yaml:"expect_regex,omitempty"` // Match expect as a regular expression instead
+}
+
+type DatabaseConfig struct {
+	DSN      string `yaml:"dsn,omitempty"` // Driver connection string, instead of targets and the fields below
+	User     string `yaml:"user,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	DBName   string `yaml:"dbname,omitempty"`
+	Query    string `yaml:"query,omitempty"`  // Optional, e.g. "SELECT 1", must succeed for the check to pass
+	Expect   string `yaml:"expect,omitempty"` // Optional, the first column of the first row must equal it
+}
+
+type SMTPConfig struct {
+	StartTLS bool `yaml:"starttls,omitempty"` // Upgrade the session with STARTTLS and verify the server certificate
+	// Fail when the certificate expires sooner, requires starttls like the settings below
+	CertificateExpiry  string `yaml:"certificate_expiry,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+	CACert             string `yaml:"ca_cert,omitempty"` // CA bundle to verify the server, file path or inline PEM
+}
+
+type NTPConfig struct {
+	MaxOffset string `yaml:"max_offset,omitempty"` // Fail when the server clock is off by more than this
+}
+
+type MQTTConfig struct {
+	ClientID string `yaml:"client_id,omitempty"` // Defaults to a random "probixel-" identifier
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	Topic    string `yaml:"topic,omitempty"`   // Optional, subscribed to
+	QoS      int    `yaml:"qos,omitempty"`     // QoS of the subscription and publication, 0 or 1
+	Publish  string `yaml:"publish,omitempty"` // Optional, published to topic and expected back within the timeout
+}
+
+type WebSocketConfig struct {
+	Headers            map[string]string `yaml:"headers,omitempty"`    // Sent with the handshake request
+	Send               string            `yaml:"send,omitempty"`       // Text message sent once connected
+	MatchData          *MatchDataConfig  `yaml:"match_data,omitempty"` // Matched against the reply, or the first message without send
+	InsecureSkipVerify bool              `yaml:"insecure_skip_verify,omitempty"`
+}
+
+func (d *DNSConfig) validateRecords() error {
+	recordType := strings.ToUpper(d.RecordType)
+	switch recordType {
+	case "":
+		if len(d.ExpectedValues) > 0 {
+			return fmt.Errorf("dns.expected_values requires dns.record_type")
+		}
+		return nil
+	case "A", "AAAA", "CNAME", "MX", "TXT", "NS":
+	default:
+		return fmt.Errorf("has invalid dns.record_type %q (must be A, AAAA, CNAME, MX, TXT or NS)", d.RecordType)
+	}
+	for _, v := range d.ExpectedValues {
+		if recordType != "A" && recordType != "AAAA" {
+			continue
+		}
+		ip := net.ParseIP(strings.TrimSpace(v))
+		if ip == nil || (ip.To4() != nil) != (recordType == "A") {
+			return fmt.Errorf("dns.expected_values entry %q is not an %s address", v, recordType)
+		}
+	}
+	return nil
 }
 
 type DockerConfig struct {
-	Socket  string `yaml:"socket,omitempty"`
-	Healthy bool   `yaml:"healthy,omitempty"`
+	Socket      string `yaml:"socket,omitempty"`
+	Healthy     bool   `yaml:"healthy,omitempty"`
+	Stats       bool   `yaml:"stats,omitempty"`        // Report CPU and memory usage, skipped when slow
+	MaxRestarts *int   `yaml:"max_restarts,omitempty"` // Restarts tolerated before failing, unset to ignore them
+	FailOnOOM   bool   `yaml:"fail_on_oom,omitempty"`  // Fail when the container was OOM killed
 }
 
 type TLSConfig struct {
 	CertificateExpiry  string `yaml:"certificate_expiry"`
 	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+	VerifyChain        *bool  `yaml:"verify_chain,omitempty"`    // Verify the chain against the system roots or ca_cert, defaults to true
+	ExpectedIssuer     string `yaml:"expected_issuer,omitempty"` // Substring of the issuer's common name or organization
+	ExpectedSAN        string `yaml:"expected_san,omitempty"`    // Hostname that must be in the certificate's SANs
+	ClientCert         string `yaml:"client_cert,omitempty"`     // mTLS client certificate, file path or inline PEM
+	ClientKey          string `yaml:"client_key,omitempty"`      // mTLS client key, file path or inline PEM
+	CACert             string `yaml:"ca_cert,omitempty"`         // CA bundle to verify the server, file path or inline PEM
+	// Fail revoked certificates, asking the OCSP responder or the CRL named
+	// by the certificate. An unreachable one only fails the check when
+	// revocation_required is set
+	CheckRevocation    bool `yaml:"check_revocation,omitempty"`
+	RevocationRequired bool `yaml:"revocation_required,omitempty"`
 }
 
 type UDPConfig struct {
+	ResolveConfig `yaml:",inline"`
+	Send          string `yaml:"send,omitempty"`         // Optional, the datagram sent instead of an empty one
+	Expect        string `yaml:"expect,omitempty"`       // Optional, a response datagram must contain it
+	ExpectRegex   bool   `yaml:"expect_regex,omitempty"` // Match expect as a regular expression
 }
 
 type SSHConfig struct {
-	User         string `yaml:"user,omitempty"`
-	Password     string `yaml:"password,omitempty"`
-	PrivateKey   string `yaml:"private_key,omitempty"`
-	AuthRequired *bool  `yaml:"auth_required,omitempty"` // Default to true
-	Port         int    `yaml:"port,omitempty"`          // Default to 22
+	User           string `yaml:"user,omitempty"`
+	Password       string `yaml:"password,omitempty"`
+	PasswordFile   string `yaml:"password_file,omitempty"` // Read into Password at load time
+	PrivateKey     string `yaml:"private_key,omitempty"`
+	PrivateKeyFile string `yaml:"private_key_file,omitempty"` // Read into PrivateKey at load time
+	AuthRequired   *bool  `yaml:"auth_required,omitempty"`    // Default to true
+	Port           int    `yaml:"port,omitempty"`             // Default to 22
+	// Services only: run after logging in, checking its exit status or its
+	// stdout against match_data
+	Command   string           `yaml:"command,omitempty"`
+	MatchData *MatchDataConfig `yaml:"match_data,omitempty"`
 }
 
 type WireguardConfig struct {
 	Endpoint            string `yaml:"endpoint"`
 	PublicKey           string `yaml:"public_key"`
 	PrivateKey          string `yaml:"private_key"`
+	PrivateKeyFile      string `yaml:"private_key_file,omitempty"` // Read into PrivateKey at load time
 	PresharedKey        string `yaml:"preshared_key"`
+	PresharedKeyFile    string `yaml:"preshared_key_file,omitempty"` // Read into PresharedKey at load time
 	Addresses           string `yaml:"addresses"`
 	AllowedIPs          string `yaml:"allowed_ips"`
 	PersistentKeepalive int    `yaml:"persistent_keepalive"`
@@ -465,6 +1413,16 @@ func (w *WireguardConfig) validateAndSetDefaults() error {
 		return fmt.Errorf("restart_threshold must be positive")
 	}
 
+	if w.Endpoint != "" {
+		_, port, err := ParseTarget(w.Endpoint)
+		if err == nil && port == "" {
+			err = errors.New("missing port")
+		}
+		if err != nil {
+			return fmt.Errorf("invalid endpoint %q: %w", w.Endpoint, err)
+		}
+	}
+
 	return nil
 }
 
@@ -476,16 +1434,22 @@ type Expectation struct {
 	Type     string `yaml:"type"`                // json, header, body
 	JSONPath string `yaml:"json_path,omitempty"` // Path for JSON extraction
 	Header   string `yaml:"header,omitempty"`    // Header name
-	Operator string `yaml:"operator"`            // equals, contains, matches, age_less_than, greater_than, less_than
-	Value    string `yaml:"value"`               // Target value to compare against
+	Operator string `yaml:"operator"`            // ==, contains, matches, >, <, in, exists, not_exists
+	Value    string `yaml:"value"`               // Target value to compare against, comma-separated candidates for in
 }
 
 type MonitorEndpointConfig struct {
-	Success EndpointConfig    `yaml:"success"`
-	Failure *EndpointConfig   `yaml:"failure,omitempty"`
+	Success EndpointList      `yaml:"success"`           // Each is pushed successful results
+	Failure EndpointList      `yaml:"failure,omitempty"` // Each is pushed failed results
 	Headers map[string]string `yaml:"headers,omitempty"` // Common headers for both
 	Timeout string            `yaml:"timeout,omitempty"` // Common timeout for both
 	Retries *int              `yaml:"retries,omitempty"` // Service-level override
+
+	EscalateAfter  string `yaml:"escalate_after,omitempty"`  // Annotate failure pushes once down this long
+	EscalateRepeat bool   `yaml:"escalate_repeat,omitempty"` // Escalate every failure past escalate_after, not just the first
+
+	OnChangeOnly     *bool  `yaml:"on_change_only,omitempty"`    // Overrides global notifier.on_change_only
+	ReminderInterval string `yaml:"reminder_interval,omitempty"` // Overrides global notifier.reminder_interval
 }
 
 type EndpointConfig struct {
@@ -494,22 +1458,1248 @@ type EndpointConfig struct {
 	Headers            map[string]string `yaml:"headers"`
 	InsecureSkipVerify bool              `yaml:"insecure_skip_verify,omitempty"`
 	Timeout            string            `yaml:"timeout,omitempty"`
+
+	// AcceptedStatusCodes lists the responses that count as a successful push,
+	// e.g. "200-299, 302". Defaults to any 2xx.
+	AcceptedStatusCodes string `yaml:"accepted_status_codes,omitempty"`
+
+	// Format "slack" or "discord" POSTs a Slack or Discord message rendered
+	// from Template instead of passing the result in the URL. Defaults to the
+	// plain request.
+	Format   string `yaml:"format,omitempty"`
+	Template string `yaml:"template,omitempty"` // Defaults to "{%message%}"
+
+	// BodyTemplate is the path of a Go text/template file rendered with the
+	// result and sent as the request body, with Method defaulting to POST.
+	// A relative path is resolved against the directory of the config file.
+	BodyTemplate string `yaml:"body_template,omitempty"`
+
+	bodyTemplate       *template.Template // BodyTemplate as parsed by Validate
+	bodyTemplateSource string             // Contents BodyTemplate was parsed from
 }
 
-func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path) //nolint:gosec // G304: Config file path from command line flag is expected
+// LoadBodyTemplate reads and parses the body_template file at path.
+func LoadBodyTemplate(path string) (*template.Template, error) {
+	tmpl, _, err := loadBodyTemplate(path)
+	return tmpl, err
+}
+
+func loadBodyTemplate(path string) (*template.Template, string, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // G304: Template path from config file is expected
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	var cfg Config
-	err = yaml.Unmarshal(data, &cfg)
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(data))
+	return tmpl, string(data), err
+}
+
+// ParsedBodyTemplate returns the body_template parsed when the config was
+// validated, so later changes to the file only apply on reload. It reads the
+// file for an endpoint that wasn't validated.
+func (e *EndpointConfig) ParsedBodyTemplate() (*template.Template, error) {
+	if e.bodyTemplate != nil {
+		return e.bodyTemplate, nil
+	}
+	return LoadBodyTemplate(e.BodyTemplate)
+}
+
+// EndpointList is the endpoints a result is pushed to. In YAML it is either a
+// list or, as most services only need one, a single endpoint.
+type EndpointList []EndpointConfig
+
+func (l *EndpointList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		var e EndpointConfig
+		if err := value.Decode(&e); err != nil {
+			return err
+		}
+		*l = EndpointList{e}
+		return nil
+	}
+	var list []EndpointConfig
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// MarshalYAML writes a single endpoint as such, the way it's usually configured.
+func (l EndpointList) MarshalYAML() (any, error) {
+	if len(l) == 1 {
+		return l[0], nil
+	}
+	return []EndpointConfig(l), nil
+}
+
+// field names endpoint i of the list in errors, indexing it only if the list
+// has several.
+func (l EndpointList) field(name string, i int) string {
+	if len(l) == 1 {
+		return name
+	}
+	return fmt.Sprintf("%s[%d]", name, i)
+}
+
+// validate checks the timeout, accepted status codes and message format of
+// each endpoint, the list being named field in errors.
+func (l EndpointList) validate(field string) error {
+	for i := range l {
+		e := &l[i]
+		name := l.field(field, i)
+		if e.Timeout != "" {
+			if _, err := ParseDuration(e.Timeout); err != nil {
+				return fmt.Errorf("%s.timeout is invalid: %w", name, err)
+			}
+		}
+		if _, err := ParseIntRanges(e.AcceptedStatusCodes); err != nil {
+			return fmt.Errorf("%s.accepted_status_codes is invalid: %w", name, err)
+		}
+		if err := e.validateFormat(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateFormat checks the message format of an endpoint, named field in errors.
+func (e *EndpointConfig) validateFormat(field string) error {
+	switch e.Format {
+	case "":
+		if e.Template != "" {
+			return fmt.Errorf("%s.template requires format \"slack\" or \"discord\"", field)
+		}
+		if e.BodyTemplate != "" {
+			tmpl, source, err := loadBodyTemplate(e.BodyTemplate)
+			if err != nil {
+				return fmt.Errorf("%s.body_template is invalid: %w", field, err)
+			}
+			e.bodyTemplate, e.bodyTemplateSource = tmpl, source
+		}
+	case "slack", "discord":
+		if e.BodyTemplate != "" {
+			return fmt.Errorf("%s.body_template cannot be combined with format %q", field, e.Format)
+		}
+		if e.Method != "" && !strings.EqualFold(e.Method, "POST") {
+			return fmt.Errorf("%s.method must be POST with format %q", field, e.Format)
+		}
+	default:
+		return fmt.Errorf("%s.format %q is invalid (must be slack or discord)", field, e.Format)
+	}
+	return nil
+}
+
+// ProbeRetries returns the effective probe retries for svc. Host and wireguard
+// probes are exempt from retries.
+func (c *Config) ProbeRetries(svc Service) int {
+	if svc.Type == "host" || svc.Type == "wireguard" {
+		return 0
+	}
+	retries := 3 // Hardcoded default fallback (should be set by Validate globals but safe to have)
+	if c.Global.Monitor.Retries != nil {
+		retries = *c.Global.Monitor.Retries
+	}
+	if svc.Retries != nil {
+		retries = *svc.Retries
+	}
+	return retries
+}
+
+// CheckTimeout returns the deadline for a single check attempt of svc across
+// all of its targets. It is total_timeout when set, otherwise the share of the
+// interval each attempt gets (minus the 1s buffer and the waits between
+// retries), so checks of long target lists never overrun the interval. It
+// returns 0 if the interval is invalid.
+func (c *Config) CheckTimeout(svc Service) time.Duration {
+	if d, err := ParseDuration(svc.TotalTimeout); err == nil && d > 0 {
+		return d
+	}
+
+	intervalStr := svc.Interval
+	if intervalStr == "" {
+		intervalStr = c.Global.DefaultInterval
+	}
+	interval, err := ParseDuration(intervalStr)
+	if err != nil || interval <= 0 {
+		return 0
+	}
+
+	retries := max(c.ProbeRetries(svc), 0)
+	budget := (interval - time.Second - time.Duration(retries)*c.RetryInterval(svc)) / time.Duration(retries+1)
+	if timeout, err := ParseDuration(c.ServiceTimeout(svc)); err == nil && timeout > budget {
+		return timeout
+	}
+	return budget
+}
+
+// RetryInterval returns how long to wait before retrying a failed check of
+// svc, 0 to retry immediately.
+func (c *Config) RetryInterval(svc Service) time.Duration {
+	d, err := ParseDuration(svc.RetryInterval)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// MaxResponseTime returns the duration beyond which a successful check of svc
+// counts as failed, 0 when there is none.
+func (c *Config) MaxResponseTime(svc Service) time.Duration {
+	d, err := ParseDuration(svc.MaxResponseTime)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// OnChangeOnly reports whether results of svc are only pushed when its status
+// changes, per its monitor_endpoint or the global notifier setting.
+func (c *Config) OnChangeOnly(svc Service) bool {
+	if svc.MonitorEndpoint.OnChangeOnly != nil {
+		return *svc.MonitorEndpoint.OnChangeOnly
+	}
+	return c.Global.Notifier.OnChangeOnly
+}
+
+// ReminderInterval returns how often a service that stays down is pushed
+// again in on_change_only mode, 0 for never.
+func (c *Config) ReminderInterval(svc Service) time.Duration {
+	s := svc.MonitorEndpoint.ReminderInterval
+	if s == "" {
+		s = c.Global.Notifier.ReminderInterval
+	}
+	d, err := ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// InMaintenance reports whether a result of svc at t falls in an active
+// window of the global or the service's maintenance, which suppresses its
+// push. Successes are only suppressed by windows with suppress_success.
+func (c *Config) InMaintenance(svc Service, success bool, t time.Time) bool {
+	for _, m := range []*MaintenanceConfig{c.Global.Maintenance, svc.Maintenance} {
+		if m != nil && (!success || m.SuppressSuccess) && m.Active(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether t falls in one of the windows.
+func (m *MaintenanceConfig) Active(t time.Time) bool {
+	if m == nil {
+		return false
+	}
+	loc, err := m.location()
 	if err != nil {
-		return nil, err
+		return false
 	}
-	if err := cfg.Validate(); err != nil {
-		return nil, err
+	t = t.In(loc)
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range m.Windows {
+		start, _ := parseClock(w.Start)
+		end, _ := parseClock(w.End)
+		if start < end {
+			if w.onDay(t.Weekday()) && minute >= start && minute < end {
+				return true
+			}
+			continue
+		}
+		// Spans midnight: the evening of a listed day, or the morning after
+		if (w.onDay(t.Weekday()) && minute >= start) || (w.onDay((t.Weekday()+6)%7) && minute < end) {
+			return true
+		}
 	}
-	return &cfg, nil
+	return false
+}
+
+// location returns the time zone the windows are evaluated in.
+func (m *MaintenanceConfig) location() (*time.Location, error) {
+	if m.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(m.Timezone)
+}
+
+func (w MaintenanceWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(w.Days, func(d string) bool {
+		wd, ok := weekdays[strings.ToLower(d)]
+		return ok && wd == day
+	})
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func (m *MaintenanceConfig) validate() error {
+	if m == nil {
+		return nil
+	}
+	if _, err := m.location(); err != nil {
+		return fmt.Errorf("invalid timezone %q", m.Timezone)
+	}
+	if len(m.Windows) == 0 {
+		return fmt.Errorf("windows is mandatory")
+	}
+	for i, w := range m.Windows {
+		start, err := parseClock(w.Start)
+		if err != nil || start == 24*60 {
+			return fmt.Errorf("windows[%d] start %q must be HH:MM", i, w.Start)
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return fmt.Errorf("windows[%d] end %q must be HH:MM", i, w.End)
+		}
+		if start == end {
+			return fmt.Errorf("windows[%d] is empty, start and end are both %s", i, w.Start)
+		}
+		for _, d := range w.Days {
+			if _, ok := weekdays[strings.ToLower(d)]; !ok {
+				return fmt.Errorf("windows[%d] has invalid day %q (must be mon, tue, wed, thu, fri, sat or sun)", i, d)
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock parses a HH:MM time of day into minutes since midnight. 24:00
+// is accepted as the end of the day.
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hours, err1 := strconv.Atoi(h)
+	minutes, err2 := strconv.Atoi(m)
+	if !ok || len(h) != 2 || len(m) != 2 || err1 != nil || err2 != nil || hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return hours*60 + minutes, nil
+}
+
+// ServiceTimeout returns the probe timeout of svc: its own timeout if set,
+// otherwise global.default_timeout or else DefaultTimeout, lowered for fast
+// services to half the interval and to what fits (retries + 1) attempts plus
+// the 1s buffer in the interval.
+func (c *Config) ServiceTimeout(svc Service) string {
+	if svc.Timeout != "" {
+		return svc.Timeout
+	}
+	defaultTimeout := DefaultTimeout
+	if c.Global.DefaultTimeout != "" {
+		defaultTimeout = c.Global.DefaultTimeout
+	}
+	intervalStr := svc.Interval
+	if intervalStr == "" {
+		intervalStr = c.Global.DefaultInterval
+	}
+	interval, err := ParseDuration(intervalStr)
+	if err != nil || interval <= 0 {
+		return defaultTimeout
+	}
+	def, err := ParseDuration(defaultTimeout)
+	if err != nil {
+		return defaultTimeout
+	}
+	timeout := min(def, interval/2)
+	if retries := c.ProbeRetries(svc); retries > 0 {
+		// Strictly below the budget, validation rejects a total equal to the interval
+		if budget := (interval - time.Second - 1) / time.Duration(retries+1); budget >= time.Millisecond {
+			timeout = min(timeout, budget.Truncate(time.Millisecond))
+		}
+	}
+	if timeout == def {
+		return defaultTimeout
+	}
+	return timeout.String()
+}
+
+// ServiceSourceAddress returns the local IP the probe of svc connects from:
+// its own source_address, otherwise global.source_address for the types that
+// support it and don't use a tunnel, or "" for the OS default.
+func (c *Config) ServiceSourceAddress(svc Service) string {
+	if svc.SourceAddress != "" || svc.Tunnel != "" {
+		return svc.SourceAddress
+	}
+	switch svc.Type {
+	case "tcp", "udp", "http", "ping", "traceroute":
+		return c.Global.SourceAddress
+	}
+	return ""
+}
+
+// ServiceResolver returns the DNS servers the probe of svc resolves hostnames
+// with: its own resolver, otherwise global.resolver for the types that support
+// it and don't use a tunnel, or nil for the system resolver. Servers are
+// host:port, port 53 unless set.
+func (c *Config) ServiceResolver(svc Service) []string {
+	resolver := svc.Resolver
+	if resolver == "" && svc.Tunnel == "" {
+		switch svc.Type {
+		case "http", "tcp", "udp", "tls", "smtp":
+			resolver = c.Global.Resolver
+		}
+	}
+	servers, _ := resolverServers(resolver)
+	return servers
+}
+
+// resolverServers parses a comma-separated list of DNS servers, IP addresses
+// with an optional port, into host:port addresses.
+func resolverServers(resolver string) ([]string, error) {
+	var servers []string
+	for _, entry := range strings.Split(resolver, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, port, err := ParseTarget(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", entry, err)
+		}
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("%q: %q is not an IP address", entry, host)
+		}
+		if port == "" {
+			port = "53"
+		} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("%q: invalid port %q", entry, port)
+		}
+		servers = append(servers, net.JoinHostPort(host, port))
+	}
+	return servers, nil
+}
+
+// WithDefaults returns a copy of the config with the implicit service defaults
+// (global default_interval fallback and the default timeout) written out.
+// The receiver is expected to have passed Validate, which sets the remaining
+// defaults (probe retries, WireGuard restart_threshold) in place.
+func (c *Config) WithDefaults() *Config {
+	out := *c
+	out.Services = make([]Service, len(c.Services))
+	for i, svc := range c.Services {
+		svc.Timeout = c.ServiceTimeout(svc)
+		svc.SourceAddress = c.ServiceSourceAddress(svc)
+		if svc.Interval == "" {
+			svc.Interval = c.Global.DefaultInterval
+		}
+		out.Services[i] = svc
+	}
+	return &out
+}
+
+// redactedValue is what Redacted shows in place of a secret.
+const redactedValue = "<redacted>"
+
+// Redacted returns a copy of c with its passwords, private keys, inline
+// client keys, credential headers and push URLs masked, for display. Secrets
+// read from a *_file field are left empty instead, the file showing where
+// they come from.
+func (c *Config) Redacted() *Config {
+	out := *c
+	out.Global.MonitorEndpoint.Headers = redactHeaders(c.Global.MonitorEndpoint.Headers)
+	if c.Global.Heartbeat != nil {
+		hb := *c.Global.Heartbeat
+		hb.EndpointConfig = hb.redacted()
+		out.Global.Heartbeat = &hb
+	}
+	out.DockerSockets = make(map[string]DockerSocketConfig, len(c.DockerSockets))
+	for name, socket := range c.DockerSockets {
+		socket.Headers = redactHeaders(socket.Headers)
+		out.DockerSockets[name] = socket
+	}
+	out.Tunnels = make(map[string]TunnelConfig, len(c.Tunnels))
+	for name, t := range c.Tunnels {
+		t.SSH = t.SSH.redacted()
+		t.Wireguard = t.Wireguard.redacted()
+		if t.SOCKS5 != nil {
+			socks := *t.SOCKS5
+			socks.Password = redact(socks.Password)
+			t.SOCKS5 = &socks
+		}
+		out.Tunnels[name] = t
+	}
+	out.Services = make([]Service, len(c.Services))
+	for i, svc := range c.Services {
+		svc.URL = redactURLPassword(svc.URL)
+		svc.SSH = svc.SSH.redacted()
+		svc.Wireguard = svc.Wireguard.redacted()
+		if svc.HTTP != nil {
+			http := *svc.HTTP
+			http.ClientKey = redactPEM(http.ClientKey)
+			http.Headers = redactHeaders(http.Headers)
+			http.Proxy = redactURLPassword(http.Proxy)
+			svc.HTTP = &http
+		}
+		if svc.WebSocket != nil {
+			ws := *svc.WebSocket
+			ws.Headers = redactHeaders(ws.Headers)
+			svc.WebSocket = &ws
+		}
+		if svc.TLS != nil {
+			tls := *svc.TLS
+			tls.ClientKey = redactPEM(tls.ClientKey)
+			svc.TLS = &tls
+		}
+		if svc.Database != nil {
+			db := *svc.Database
+			db.Password = redact(db.Password)
+			db.DSN = redactDSN(db.DSN)
+			svc.Database = &db
+		}
+		if svc.MQTT != nil {
+			mqtt := *svc.MQTT
+			mqtt.Password = redact(mqtt.Password)
+			svc.MQTT = &mqtt
+		}
+		svc.MonitorEndpoint.Headers = redactHeaders(svc.MonitorEndpoint.Headers)
+		svc.MonitorEndpoint.Success = svc.MonitorEndpoint.Success.redacted()
+		svc.MonitorEndpoint.Failure = svc.MonitorEndpoint.Failure.redacted()
+		out.Services[i] = svc
+	}
+	return &out
+}
+
+func (s *SSHConfig) redacted() *SSHConfig {
+	if s == nil {
+		return nil
+	}
+	out := *s
+	out.Password = redactSecret(out.Password, out.PasswordFile)
+	out.PrivateKey = redactSecret(out.PrivateKey, out.PrivateKeyFile)
+	return &out
+}
+
+func (w *WireguardConfig) redacted() *WireguardConfig {
+	if w == nil {
+		return nil
+	}
+	out := *w
+	out.PrivateKey = redactSecret(out.PrivateKey, out.PrivateKeyFile)
+	out.PresharedKey = redactSecret(out.PresharedKey, out.PresharedKeyFile)
+	return &out
+}
+
+// redactSecret masks value, or drops it when it was read from file.
+func redactSecret(value, file string) string {
+	if file != "" {
+		return ""
+	}
+	return redact(value)
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// redactPEM masks an inline PEM key, leaving a file path as is.
+func redactPEM(value string) string {
+	if strings.Contains(value, "-----BEGIN ") {
+		return redactedValue
+	}
+	return value
+}
+
+func (l EndpointList) redacted() EndpointList {
+	if l == nil {
+		return nil
+	}
+	out := make(EndpointList, len(l))
+	for i, e := range l {
+		out[i] = e.redacted()
+	}
+	return out
+}
+
+func (e EndpointConfig) redacted() EndpointConfig {
+	e.URL = redactURL(e.URL)
+	e.Headers = redactHeaders(e.Headers)
+	return e
+}
+
+// redactURL masks the userinfo, path and query values of a push URL, any of
+// which can carry a token (Slack and Discord webhooks, push monitors).
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redactedValue
+	}
+	if u.User != nil {
+		u.User = url.User("xxxxx")
+	}
+	if u.Path != "" && u.Path != "/" {
+		u.Path, u.RawPath = "/xxxxx", ""
+	}
+	// Split by hand, url.Values drops the {%message%} style placeholders
+	if u.RawQuery != "" {
+		params := strings.Split(u.RawQuery, "&")
+		for i, param := range params {
+			if key, _, ok := strings.Cut(param, "="); ok {
+				params[i] = key + "=xxxxx"
+			} else if param != "" {
+				params[i] = "xxxxx"
+			}
+		}
+		u.RawQuery = strings.Join(params, "&")
+	}
+	return u.String()
+}
+
+// redactURLPassword masks the password of a URL's userinfo, as
+// url.URL.Redacted does.
+func redactURLPassword(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return raw
+}
+
+// redactHeaders returns a copy of headers with the values of those that carry
+// credentials masked.
+func redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	out := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if sensitiveHeader(name) {
+			value = redact(value)
+		}
+		out[name] = value
+	}
+	return out
+}
+
+// sensitiveHeader reports whether the header name carries credentials:
+// Authorization, cookies, and *-Token, *-Key, *-Secret or *-Password ones.
+func sensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	switch name {
+	case "authorization", "proxy-authorization", "cookie":
+		return true
+	}
+	for _, suffix := range []string{"token", "key", "secret", "password"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// dsnPassword matches the password of a key=value postgres connection string.
+var dsnPassword = regexp.MustCompile(`\bpassword=('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactDSN masks the password of a database connection string: a URL, a
+// key=value postgres string or the mysql "user:password@tcp(host)/db" form,
+// with xxxxx as url.URL.Redacted does.
+func redactDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		return redactURLPassword(dsn)
+	}
+	if dsnPassword.MatchString(dsn) {
+		return dsnPassword.ReplaceAllString(dsn, "password=xxxxx")
+	}
+	at := strings.LastIndex(dsn, "@")
+	if at < 0 {
+		return dsn
+	}
+	if user, _, ok := strings.Cut(dsn[:at], ":"); ok {
+		return user + ":xxxxx" + dsn[at:]
+	}
+	return dsn
+}
+
+// ServiceFingerprint returns a hash identifying everything a running monitor
+// for svc was built from: the service definition with defaults resolved, plus
+// the tunnel and docker socket it references. Two configs yielding the same
+// fingerprint for a service can share the same running monitor.
+func (c *Config) ServiceFingerprint(svc Service) string {
+	svc.Timeout = c.ServiceTimeout(svc)
+	svc.SourceAddress = c.ServiceSourceAddress(svc)
+	if svc.Interval == "" {
+		svc.Interval = c.Global.DefaultInterval
+	}
+	deps := struct {
+		Service      Service             `yaml:"service"`
+		Tunnel       *TunnelConfig       `yaml:"tunnel,omitempty"`
+		DockerSocket *DockerSocketConfig `yaml:"docker_socket,omitempty"`
+		// The monitor keeps the templates parsed, so it restarts when they change
+		BodyTemplates []string `yaml:"body_templates,omitempty"`
+	}{Service: svc}
+	for _, e := range slices.Concat(svc.MonitorEndpoint.Success, svc.MonitorEndpoint.Failure) {
+		if e.bodyTemplateSource != "" {
+			deps.BodyTemplates = append(deps.BodyTemplates, e.bodyTemplateSource)
+		}
+	}
+	if t, ok := c.Tunnels[svc.Tunnel]; ok {
+		deps.Tunnel = &t
+	}
+	if svc.Docker != nil {
+		if s, ok := c.DockerSockets[svc.Docker.Socket]; ok {
+			deps.DockerSocket = &s
+		}
+	}
+	return fingerprint(deps)
+}
+
+// TunnelFingerprint returns a hash of the named tunnel's definition, or an
+// empty string if the tunnel is not defined.
+func (c *Config) TunnelFingerprint(name string) string {
+	t, ok := c.Tunnels[name]
+	if !ok {
+		return ""
+	}
+	return fingerprint(t)
+}
+
+// HeartbeatFingerprint returns a hash of the heartbeat definition with its
+// interval default resolved, or "" if no heartbeat is configured.
+func (c *Config) HeartbeatFingerprint() string {
+	if c.Global.Heartbeat == nil {
+		return ""
+	}
+	hb := *c.Global.Heartbeat
+	if hb.Interval == "" {
+		hb.Interval = c.Global.DefaultInterval
+	}
+	return fingerprint(struct {
+		Heartbeat    HeartbeatConfig `yaml:"heartbeat"`
+		BodyTemplate string          `yaml:"body_template,omitempty"`
+	}{hb, hb.bodyTemplateSource})
+}
+
+// LoadPEM returns PEM data given either inline PEM or a path to a PEM file.
+func LoadPEM(value string) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN ") {
+		return []byte(value), nil
+	}
+	return os.ReadFile(value) //nolint:gosec // G304: Certificate path from config file is expected
+}
+
+// LoadClientCertificate loads an mTLS client certificate/key pair, each given
+// as a file path or inline PEM.
+func LoadClientCertificate(certValue, keyValue string) (tls.Certificate, error) {
+	certPEM, err := LoadPEM(certValue)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read client_cert: %w", err)
+	}
+	keyPEM, err := LoadPEM(keyValue)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read client_key: %w", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid client certificate pair: %w", err)
+	}
+	return cert, nil
+}
+
+// LoadCertPool builds a certificate pool from a CA bundle given as a file path
+// or inline PEM.
+func LoadCertPool(value string) (*x509.CertPool, error) {
+	caPEM, err := LoadPEM(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca_cert: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("ca_cert contains no valid PEM certificates")
+	}
+	return pool, nil
+}
+
+func validateClientCert(certValue, keyValue string) error {
+	if certValue == "" && keyValue == "" {
+		return nil
+	}
+	if certValue == "" || keyValue == "" {
+		return fmt.Errorf("client_cert and client_key must be set together")
+	}
+	_, err := LoadClientCertificate(certValue, keyValue)
+	return err
+}
+
+// validateTargets checks the syntax of a tcp, udp, ping, dns, smtp, ntp or
+// mqtt service's targets, so that a typo fails at load time rather than on
+// every check: tcp and udp targets are host:port, ping targets a bare host,
+// and dns, smtp, ntp and mqtt targets a server host with an optional port.
+func validateTargets(svc Service) error {
+	for _, entry := range svc.Targets {
+		for _, t := range strings.Split(entry, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" {
+				continue
+			}
+			if err := validateTarget(svc.Type, t); err != nil {
+				return fmt.Errorf("service %q has invalid target %q: %w", svc.Name, t, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateTarget(typ, target string) error {
+	if typ == "dns" {
+		target = strings.TrimPrefix(target, "dns:")
+	}
+	host, port, err := ParseTarget(target)
+	if err != nil {
+		return err
+	}
+	if !validHost(host) {
+		return fmt.Errorf("%q is not a valid host name or IP address", host)
+	}
+	switch {
+	case port == "" && (typ == "tcp" || typ == "udp"):
+		return errors.New("missing port, expected host:port")
+	case port != "" && (typ == "ping" || typ == "traceroute"):
+		return fmt.Errorf("%s targets take no port", typ)
+	case port != "":
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("port %q must be a number between 1 and 65535", port)
+		}
+	}
+	return nil
+}
+
+// ParseTarget splits a target into its host and port. Besides "host:port"
+// and "[ipv6]:port" it accepts targets without a port: hostnames, IPv4
+// addresses and IPv6 literals, bracketed or not. port is empty if the target
+// has none. The returned host never has brackets.
+//
+// A bare IPv6 literal is never split, so "2001:db8::1:80" is an address
+// without a port. Brackets are required to give an IPv6 address a port.
+func ParseTarget(target string) (host, port string, err error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", "", errors.New("empty target")
+	}
+	if host, port, err := net.SplitHostPort(target); err == nil {
+		if host == "" {
+			return "", "", errors.New("missing host")
+		}
+		if port == "" {
+			return "", "", errors.New("missing port")
+		}
+		return host, port, nil
+	}
+	if strings.HasPrefix(target, "[") && strings.HasSuffix(target, "]") {
+		host = target[1 : len(target)-1]
+		if addr, err := netip.ParseAddr(host); err != nil || !addr.Is6() {
+			return "", "", errors.New("brackets must enclose an IPv6 address")
+		}
+		return host, "", nil
+	}
+	if strings.Contains(target, ":") {
+		if _, err := netip.ParseAddr(target); err != nil {
+			return "", "", errors.New("use [host]:port for IPv6 addresses with a port")
+		}
+	}
+	return target, "", nil
+}
+
+// validHost reports whether host is an IP address or a syntactically valid
+// host name. Underscores are accepted, as in some internal DNS names.
+func validHost(host string) bool {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return true
+	}
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// countTargets returns the number of non-empty targets, splitting entries
+// that hold comma-separated lists.
+func countTargets(targets []string) int {
+	n := 0
+	for _, entry := range targets {
+		for _, t := range strings.Split(entry, ",") {
+			if strings.TrimSpace(t) != "" {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func fingerprint(v any) string {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func LoadConfig(path string) (*Config, error) {
+	cfg, err := loadFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Sources returns the files the config was loaded from, the main file first,
+// followed by the include patterns that can add files later. It is empty for
+// a config that was not loaded by LoadConfig.
+func (c *Config) Sources() []string {
+	return c.sources
+}
+
+// loadFile parses the config file at path and merges its includes into it.
+// chain lists the absolute paths of the files including it, to detect cycles.
+func loadFile(path string, chain []string) (*Config, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range chain {
+		if p == abs {
+			return nil, fmt.Errorf("circular include: %s", strings.Join(append(slices.Clone(chain[i:]), abs), " -> "))
+		}
+	}
+
+	cfg, err := parseFile(path)
+	if err != nil {
+		if len(chain) > 0 {
+			return nil, fmt.Errorf("include %s: %w", path, err)
+		}
+		return nil, err
+	}
+
+	cfg.sources = []string{filepath.Clean(path)}
+	chain = append(chain, abs)
+	for _, pattern := range cfg.Include {
+		// Relative includes are resolved against the including file
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include %q: %w", pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("include %q: %w", pattern, os.ErrNotExist)
+		}
+		cfg.sources = append(cfg.sources, filepath.Clean(pattern))
+
+		for _, match := range matches {
+			inc, err := loadFile(match, chain)
+			if err != nil {
+				return nil, err
+			}
+			if err := cfg.merge(inc); err != nil {
+				return nil, fmt.Errorf("include %s: %w", match, err)
+			}
+		}
+	}
+	cfg.Include = nil // Merged, the effective config no longer depends on them
+	return cfg, nil
+}
+
+// parseFile reads and decodes a single config file, environment variables
+// expanded.
+func parseFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // G304: Config file path from command line flag is expected
+	if err != nil {
+		return nil, err
+	}
+	asJSON := isJSON(path, data)
+	if asJSON {
+		if data, err = jsonToYAML(data); err != nil {
+			return nil, err
+		}
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if err := expandEnvNode(&root, os.LookupEnv, !asJSON); err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if root.Kind != 0 { // Empty file
+		if err := root.Decode(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadSecrets(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	cfg.resolveBodyTemplates(filepath.Dir(path))
+	return &cfg, nil
+}
+
+// resolveBodyTemplates resolves relative body_template paths against dir, like
+// the secrets files.
+func (c *Config) resolveBodyTemplates(dir string) {
+	resolve := func(e *EndpointConfig) {
+		if e.BodyTemplate != "" && !filepath.IsAbs(e.BodyTemplate) {
+			e.BodyTemplate = filepath.Join(dir, e.BodyTemplate)
+		}
+	}
+	if hb := c.Global.Heartbeat; hb != nil {
+		resolve(&hb.EndpointConfig)
+	}
+	for _, svc := range c.Services {
+		for _, l := range []EndpointList{svc.MonitorEndpoint.Success, svc.MonitorEndpoint.Failure} {
+			for i := range l {
+				resolve(&l[i])
+			}
+		}
+	}
+}
+
+// loadSecrets reads the SSH and WireGuard secrets given as files, either with
+// a file:// value or a sibling *_file field, relative paths being resolved
+// against dir.
+func (c *Config) loadSecrets(dir string) error {
+	for name, t := range c.Tunnels {
+		if err := t.SSH.loadSecrets(dir); err != nil {
+			return fmt.Errorf("tunnel %q ssh.%w", name, err)
+		}
+		if err := t.Wireguard.loadSecrets(dir); err != nil {
+			return fmt.Errorf("tunnel %q wireguard.%w", name, err)
+		}
+	}
+	for _, svc := range c.Services {
+		if err := svc.SSH.loadSecrets(dir); err != nil {
+			return fmt.Errorf("service %q ssh.%w", svc.Name, err)
+		}
+		if err := svc.Wireguard.loadSecrets(dir); err != nil {
+			return fmt.Errorf("service %q wireguard.%w", svc.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *SSHConfig) loadSecrets(dir string) error {
+	if s == nil {
+		return nil
+	}
+	if err := loadSecret(&s.Password, s.PasswordFile, "password", dir); err != nil {
+		return err
+	}
+	return loadSecret(&s.PrivateKey, s.PrivateKeyFile, "private_key", dir)
+}
+
+func (w *WireguardConfig) loadSecrets(dir string) error {
+	if w == nil {
+		return nil
+	}
+	if err := loadSecret(&w.PrivateKey, w.PrivateKeyFile, "private_key", dir); err != nil {
+		return err
+	}
+	return loadSecret(&w.PresharedKey, w.PresharedKeyFile, "preshared_key", dir)
+}
+
+// loadSecret replaces *value with the contents of file, or of the file its
+// file:// prefix points at, trailing newlines trimmed. The error starts with
+// the name of the field the file came from.
+func loadSecret(value *string, file, field, dir string) error {
+	if path, ok := strings.CutPrefix(*value, "file://"); ok {
+		if file != "" {
+			return fmt.Errorf("%s and %s_file are mutually exclusive", field, field)
+		}
+		file = path
+	} else if file != "" {
+		if *value != "" {
+			return fmt.Errorf("%s and %s_file are mutually exclusive", field, field)
+		}
+		field += "_file"
+	} else {
+		return nil
+	}
+	if file == "" {
+		return fmt.Errorf("%s file path is empty", field)
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	data, err := os.ReadFile(file) //nolint:gosec // G304: Secret path from config file is expected
+	if err != nil {
+		return fmt.Errorf("%s: %w", field, err)
+	}
+	*value = strings.TrimRight(string(data), "\r\n")
+	return nil
+}
+
+// merge adds the services, docker sockets and tunnels of an included config,
+// none of which must already be defined.
+func (c *Config) merge(inc *Config) error {
+	if !reflect.DeepEqual(inc.Global, GlobalConfig{}) {
+		return fmt.Errorf("global can only be set in the main config file")
+	}
+	for name, socket := range inc.DockerSockets {
+		if _, ok := c.DockerSockets[name]; ok {
+			return fmt.Errorf("docker socket %q is already defined", name)
+		}
+		if c.DockerSockets == nil {
+			c.DockerSockets = make(map[string]DockerSocketConfig)
+		}
+		c.DockerSockets[name] = socket
+	}
+	for name, tunnel := range inc.Tunnels {
+		if _, ok := c.Tunnels[name]; ok {
+			return fmt.Errorf("tunnel %q is already defined", name)
+		}
+		if c.Tunnels == nil {
+			c.Tunnels = make(map[string]TunnelConfig)
+		}
+		c.Tunnels[name] = tunnel
+	}
+	for _, svc := range inc.Services {
+		if slices.ContainsFunc(c.Services, func(s Service) bool { return s.Name == svc.Name }) {
+			return fmt.Errorf("service %q is already defined", svc.Name)
+		}
+		c.Services = append(c.Services, svc)
+	}
+	c.sources = append(c.sources, inc.sources...)
+	return nil
+}
+
+// expandEnvNode expands the environment variables in every scalar value of
+// the parsed config, so substituted values can never alter the document
+// structure and comments are left alone. Plain scalars have their tag resolved
+// again, a ${PORT} still decoding as a number. Aliases are skipped, their
+// anchor being expanded where it is defined. Errors are prefixed with the line
+// of the value when lines is set.
+func expandEnvNode(n *yaml.Node, lookup func(string) (string, bool), lines bool) error {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return nil
+	case yaml.ScalarNode:
+		value, err := expandEnv(n.Value, lookup)
+		if err != nil {
+			if lines {
+				return fmt.Errorf("line %d: %w", n.Line, err)
+			}
+			return err
+		}
+		if value != n.Value {
+			n.Value = value
+			if n.Style == 0 {
+				n.Tag = ""
+			}
+		}
+		return nil
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 { // Values only, keys are fixed
+			if err := expandEnvNode(n.Content[i], lookup, lines); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, c := range n.Content {
+		if err := expandEnvNode(c, lookup, lines); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} references in a config value
+// with values from lookup, so secrets can be kept out of the file. The default
+// applies when VAR is unset or empty, and $$ produces a literal $. Any other $
+// is left as is. Undefined variables without a default are an error.
+func expandEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '$' || i+1 == len(s) {
+			out.WriteByte(c)
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			out.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in config")
+			}
+			ref := s[i+2 : i+2+end]
+			name, def, hasDefault := strings.Cut(ref, ":-")
+			if !validEnvName(name) {
+				return "", fmt.Errorf("invalid environment variable reference ${%s}", ref)
+			}
+			value, ok := lookup(name)
+			if hasDefault && value == "" {
+				value, ok = def, true
+			}
+			if !ok {
+				return "", fmt.Errorf("environment variable %q is not set and has no default", name)
+			}
+			out.WriteString(value)
+			i += 2 + end
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String(), nil
+}
+
+func validEnvName(name string) bool {
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// isJSON reports whether the config file should be parsed as JSON, based on a
+// .json extension or a leading '{'.
+func isJSON(path string, data []byte) bool {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return true
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// jsonToYAML converts a JSON config to YAML. The document is decoded
+// generically and re-encoded so the existing yaml struct tags apply to both
+// formats.
+func jsonToYAML(data []byte) ([]byte, error) {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON config: %w", err)
+	}
+	return yaml.Marshal(raw)
 }
 
 // ParseDuration parses a duration string, supporting "d" for days, "h" for hours, "m" for minutes, "s" for seconds.
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// intRange is an inclusive range of integers, a single value when min == max.
+type intRange struct {
+	min, max int
+}
+
+// IntRanges is a parsed list of integers and ranges, see ParseIntRanges.
+type IntRanges []intRange
+
+// ParseIntRanges parses spec, a comma-separated list of integers and
+// inclusive ranges such as "200-299, 404". Entries that aren't integers or
+// whose range is reversed are skipped and reported in the returned error, the
+// valid ones are returned regardless, so callers can choose to ignore them.
+func ParseIntRanges(spec string) (IntRanges, error) {
+	var ranges IntRanges
+	var errs []error
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if lo, hi, ok := strings.Cut(part, "-"); ok {
+			// Range: "200-299"
+			start, err1 := strconv.Atoi(strings.TrimSpace(lo))
+			end, err2 := strconv.Atoi(strings.TrimSpace(hi))
+			switch {
+			case err1 != nil || err2 != nil:
+				errs = append(errs, fmt.Errorf("invalid range %q", part))
+			case start > end:
+				errs = append(errs, fmt.Errorf("reversed range %q", part))
+			default:
+				ranges = append(ranges, intRange{start, end})
+			}
+			continue
+		}
+
+		// Single value: "200"
+		val, err := strconv.Atoi(part)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q", part))
+			continue
+		}
+		ranges = append(ranges, intRange{val, val})
+	}
+	return ranges, errors.Join(errs...)
+}
+
+// Contains reports whether n is in any of the ranges.
+func (r IntRanges) Contains(n int) bool {
+	for _, rng := range r {
+		if n >= rng.min && n <= rng.max {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the ranges back into a spec, e.g. "200-299, 404".
+func (r IntRanges) String() string {
+	parts := make([]string, len(r))
+	for i, rng := range r {
+		if rng.min == rng.max {
+			parts[i] = strconv.Itoa(rng.min)
+		} else {
+			parts[i] = fmt.Sprintf("%d-%d", rng.min, rng.max)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
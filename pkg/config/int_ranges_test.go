@@ -0,0 +1,62 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIntRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    string // Parsed ranges, formatted back
+		wantErr string
+	}{
+		{"single value", "200", "200", ""},
+		{"list and range", "200-202,404", "200-202, 404", ""},
+		{"spaces", "  200 - 202 ,  404  ", "200-202, 404", ""},
+		{"empty entries", "200,,404,", "200, 404", ""},
+		{"empty spec", "", "", ""},
+		{"single value range", "5-5", "5", ""},
+		{"reversed range", "300-200, 404", "404", `reversed range "300-200"`},
+		{"non-integer value", "abc, 200", "200", `invalid value "abc"`},
+		{"non-integer range", "200-abc", "", `invalid range "200-abc"`},
+		{"open range", "200-", "", `invalid range "200-"`},
+		{"negative value", "-1", "", `invalid range "-1"`},
+		{"too many dashes", "1-2-3", "", `invalid range "1-2-3"`},
+		{"several errors", "x, 1, y", "1", `invalid value "x"` + "\n" + `invalid value "y"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranges, err := ParseIntRanges(tt.spec)
+			if got := ranges.String(); got != tt.want {
+				t.Errorf("ParseIntRanges(%q) = %q, want %q", tt.spec, got, tt.want)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestIntRanges_Contains(t *testing.T) {
+	ranges, err := ParseIntRanges("200-299, 404")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, want := range map[int]bool{199: false, 200: true, 250: true, 299: true, 300: false, 404: true, 405: false} {
+		if got := ranges.Contains(n); got != want {
+			t.Errorf("Contains(%d) = %v, want %v", n, got, want)
+		}
+	}
+	if IntRanges(nil).Contains(0) {
+		t.Error("Expected empty ranges to contain nothing")
+	}
+}
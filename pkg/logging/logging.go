@@ -0,0 +1,136 @@
+// Package logging is the agent's logger. In the default text format it writes
+// the same lines as the standard log package; in the JSON format every line
+// is a JSON object with the service, and for check results their status,
+// message and duration, as fields.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	mu         sync.Mutex
+	output     io.Writer = os.Stderr
+	jsonFormat bool
+	jsonLog    *slog.Logger // Set in the JSON format
+	minLevel   slog.LevelVar
+)
+
+// Configure sets the output format, FormatText (also for "") or FormatJSON,
+// and the minimum level logged: "debug", "info" (also for ""), "warn" or
+// "error". In the JSON format, lines of the standard log package, e.g. from
+// the notifier, are written as JSON too.
+func Configure(format, level string) {
+	mu.Lock()
+	defer mu.Unlock()
+	minLevel.Set(parseLevel(level))
+	jsonFormat = format == FormatJSON
+	apply()
+}
+
+// SetOutput redirects the log lines of both formats to w.
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	output = w
+	apply()
+}
+
+// apply points the loggers at output, mu must be held.
+func apply() {
+	if jsonFormat {
+		jsonLog = slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{Level: &minLevel}))
+		slog.SetDefault(jsonLog)
+		return
+	}
+	jsonLog = nil
+	log.SetOutput(output)
+	log.SetFlags(log.LstdFlags)
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// Debugf logs a message about service, "" for the agent itself.
+func Debugf(service, format string, args ...any) {
+	logf(slog.LevelDebug, service, fmt.Sprintf(format, args...))
+}
+
+// Infof logs a message about service, "" for the agent itself.
+func Infof(service, format string, args ...any) {
+	logf(slog.LevelInfo, service, fmt.Sprintf(format, args...))
+}
+
+// Warnf logs a message about service, "" for the agent itself.
+func Warnf(service, format string, args ...any) {
+	logf(slog.LevelWarn, service, fmt.Sprintf(format, args...))
+}
+
+// Errorf logs a message about service, "" for the agent itself.
+func Errorf(service, format string, args ...any) {
+	logf(slog.LevelError, service, fmt.Sprintf(format, args...))
+}
+
+// Check logs the result of a check of service: its status (UP, DOWN or
+// WAITING), message and duration.
+func Check(service, status, message string, duration time.Duration) {
+	if l := logger(); l != nil {
+		l.LogAttrs(context.Background(), slog.LevelInfo, "check",
+			slog.String("service", service),
+			slog.String("status", status),
+			slog.String("message", message),
+			slog.Float64("duration", float64(duration)/float64(time.Millisecond)),
+		)
+		return
+	}
+	if minLevel.Level() <= slog.LevelInfo {
+		log.Printf("[%s] %s (%s) %v", service, status, message, duration)
+	}
+}
+
+func logf(level slog.Level, service, msg string) {
+	if l := logger(); l != nil {
+		var attrs []slog.Attr
+		if service != "" {
+			attrs = append(attrs, slog.String("service", service))
+		}
+		l.LogAttrs(context.Background(), level, msg, attrs...)
+		return
+	}
+	if level < minLevel.Level() {
+		return
+	}
+	if service != "" {
+		msg = "[" + service + "] " + msg
+	}
+	log.Print(msg)
+}
+
+// logger returns the JSON logger, nil in the text format.
+func logger() *slog.Logger {
+	mu.Lock()
+	defer mu.Unlock()
+	return jsonLog
+}
@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// capture configures format and level with the output going to the returned
+// buffer, restoring the defaults at the end of the test.
+func capture(t *testing.T, format, level string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	Configure(format, level)
+	t.Cleanup(func() {
+		Configure("", "")
+		SetOutput(os.Stderr)
+	})
+	return &buf
+}
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestJSON(t *testing.T) {
+	buf := capture(t, FormatJSON, "")
+
+	Check("Web", "DOWN", "HTTP 503 (fail)", 1500*time.Microsecond)
+	Warnf("Web", "Failed to push alert: %v", "timeout")
+	Infof("", "Config reloaded successfully with %d services", 2)
+	Debugf("Web", "not logged at the info level")
+	log.Printf("[Web] Sending notifications to -> %s", "https://uptime.test/down")
+
+	entries := decodeLines(t, buf)
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 lines, got %d: %s", len(entries), buf)
+	}
+
+	check := entries[0]
+	for key, want := range map[string]any{
+		"level":    "INFO",
+		"msg":      "check",
+		"service":  "Web",
+		"status":   "DOWN",
+		"message":  "HTTP 503 (fail)",
+		"duration": 1.5,
+	} {
+		if check[key] != want {
+			t.Errorf("expected %s %v, got %v", key, want, check[key])
+		}
+	}
+	if _, err := time.Parse(time.RFC3339Nano, check["time"].(string)); err != nil {
+		t.Errorf("expected an RFC 3339 time, got %v", check["time"])
+	}
+
+	if entries[1]["level"] != "WARN" || entries[1]["service"] != "Web" || entries[1]["msg"] != "Failed to push alert: timeout" {
+		t.Errorf("unexpected warning: %v", entries[1])
+	}
+	if _, ok := entries[2]["service"]; ok || entries[2]["msg"] != "Config reloaded successfully with 2 services" {
+		t.Errorf("expected an agent message without service, got %v", entries[2])
+	}
+	if entries[3]["msg"] != "[Web] Sending notifications to -> https://uptime.test/down" {
+		t.Errorf("expected the log package to be written as JSON, got %v", entries[3])
+	}
+}
+
+func TestLevel(t *testing.T) {
+	buf := capture(t, FormatJSON, "warn")
+	Infof("Web", "skipped")
+	Check("Web", "UP", "OK", time.Millisecond)
+	Errorf("Web", "kept")
+	if entries := decodeLines(t, buf); len(entries) != 1 || entries[0]["level"] != "ERROR" {
+		t.Errorf("expected only the error, got %s", buf)
+	}
+
+	buf = capture(t, FormatText, "debug")
+	Debugf("Web", "kept")
+	if !strings.HasSuffix(buf.String(), " [Web] kept\n") {
+		t.Errorf("expected the debug line in text, got %q", buf)
+	}
+}
+
+func TestText(t *testing.T) {
+	buf := capture(t, "", "")
+	Check("Web", "UP", "HTTP 200", 120*time.Millisecond)
+	Infof("", "Agent components started with %d services", 3)
+	Debugf("Web", "skipped")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf)
+	}
+	// Same lines as the log package, after its date and time
+	if !strings.HasSuffix(lines[0], " [Web] UP (HTTP 200) 120ms") {
+		t.Errorf("unexpected check line %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], " Agent components started with 3 services") {
+		t.Errorf("unexpected agent line %q", lines[1])
+	}
+	if json.Valid([]byte(lines[0])) {
+		t.Errorf("expected plain text, got %q", lines[0])
+	}
+}
@@ -0,0 +1,358 @@
+package monitor
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"probixel/pkg/config"
+	"probixel/pkg/tunnels"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+func init() {
+	// The mysql driver logs connection errors on its own, they are reported
+	// in the check result instead
+	_ = mysql.SetLogger(log.New(io.Discard, "", 0))
+}
+
+// DatabaseProbe opens a real connection to a MySQL or PostgreSQL server and
+// optionally runs a query on it.
+type DatabaseProbe struct {
+	Driver         string // MonitorTypeMySQL or MonitorTypePostgres
+	Config         *config.DatabaseConfig
+	Timeout        time.Duration
+	ConnectTimeout time.Duration // Bounds the dial, 0 to use Timeout
+	DialContext    func(ctx context.Context, network, address string) (net.Conn, error)
+	targetMode     string
+	tunnel         tunnels.Tunnel
+}
+
+func (p *DatabaseProbe) SetTunnel(t tunnels.Tunnel) {
+	p.tunnel = t
+}
+
+func (p *DatabaseProbe) Name() string {
+	return p.Driver
+}
+
+func (p *DatabaseProbe) Describe() ProbeInfo {
+	name := "MySQL"
+	if p.Driver == MonitorTypePostgres {
+		name = "PostgreSQL"
+	}
+	return ProbeInfo{
+		Type:        p.Driver,
+		Description: fmt.Sprintf("Connects to a %s server, optionally running a query", name),
+		Fields: []string{
+			"targets",
+			"target_mode",
+			"timeout",
+			"connect_timeout",
+			"tunnel",
+			"database.dsn",
+			"database.user",
+			"database.password",
+			"database.dbname",
+			"database.query",
+			"database.expect",
+		},
+	}
+}
+
+// Initialize parses the connection string, so a malformed one is reported
+// before the first check.
+func (p *DatabaseProbe) Initialize() error {
+	if p.Config == nil || p.Config.DSN == "" {
+		return nil
+	}
+	if _, err := p.connector(""); err != nil {
+		return fmt.Errorf("invalid database.dsn: %w", err)
+	}
+	return nil
+}
+
+func (p *DatabaseProbe) SetTargetMode(mode string) {
+	p.targetMode = mode
+}
+
+func (p *DatabaseProbe) Check(ctx context.Context, target string) (Result, error) {
+	startTotal := time.Now()
+
+	// Strict stabilization adherence: always return Pending if tunnel not stabilized
+	if p.tunnel != nil && !p.tunnel.IsStabilized() {
+		return Result{
+			Success:   false,
+			Pending:   true,
+			Duration:  time.Since(startTotal),
+			Message:   fmt.Sprintf("waiting for tunnel %q to stabilize", p.tunnel.Name()),
+			Timestamp: startTotal,
+		}, nil
+	}
+
+	// A connection string names its own server
+	if p.Config != nil && p.Config.DSN != "" {
+		duration, note, err := p.checkOne(ctx, "")
+		if err != nil {
+			return Result{Success: false, Message: err.Error(), Timestamp: startTotal}, nil
+		}
+		return Result{Success: true, Duration: duration, Message: withNote("OK", note), Timestamp: startTotal}, nil
+	}
+
+	targets := strings.Split(target, ",")
+	var lastErr error
+	var lastTarget string
+	var results []TargetResult
+	var totalDuration time.Duration
+	successCount := 0
+
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if err := checkExpired(ctx); err != nil {
+			lastErr, lastTarget = err, t
+			break
+		}
+
+		duration, note, err := p.checkOne(ctx, t)
+		results = append(results, newTargetResult(t, duration, err))
+		if err != nil {
+			if p.targetMode == TargetModeAll {
+				return Result{
+					Success:       false,
+					Message:       fmt.Sprintf("target %s failed: %v", t, err),
+					Target:        t,
+					Timestamp:     startTotal,
+					TargetResults: results,
+				}, nil
+			}
+			lastErr, lastTarget = err, t
+			continue
+		}
+		if p.targetMode != TargetModeAll {
+			return Result{
+				Success:       true,
+				Duration:      duration,
+				Message:       withNote(targetMessage(targets, t, "OK"), note),
+				Target:        t,
+				Timestamp:     startTotal,
+				TargetResults: results,
+			}, nil
+		}
+		totalDuration += duration
+		successCount++
+	}
+
+	if successCount > 0 && lastErr == nil {
+		return Result{
+			Success:       true,
+			Duration:      totalDuration / time.Duration(successCount),
+			Message:       fmt.Sprintf("all %d targets OK", successCount),
+			Timestamp:     startTotal,
+			TargetResults: results,
+		}, nil
+	}
+	if lastErr == nil {
+		return Result{Success: false, Message: "empty target", Timestamp: startTotal}, nil
+	}
+	return Result{
+		Success:       false,
+		Message:       fmt.Sprintf("all targets failed, last error: target %s: %v", lastTarget, lastErr),
+		Target:        lastTarget,
+		Timestamp:     startTotal,
+		TargetResults: results,
+	}, nil
+}
+
+// checkOne connects to target, or to the server of the connection string
+// when target is empty, and runs the query if one is configured. The
+// returned note reports the query latency.
+func (p *DatabaseProbe) checkOne(ctx context.Context, target string) (time.Duration, string, error) {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	connector, err := p.connector(target)
+	if err != nil {
+		return 0, "", err
+	}
+	db := sql.OpenDB(connector)
+	defer func() { _ = db.Close() }()
+	db.SetMaxOpenConns(1)
+
+	start := time.Now()
+	// Opening a connection performs the handshake and authentication
+	conn, err := db.Conn(checkCtx)
+	if err != nil {
+		return 0, "", timeoutError(err, false)
+	}
+	defer func() { _ = conn.Close() }()
+
+	var query, expect string
+	if p.Config != nil {
+		query, expect = p.Config.Query, p.Config.Expect
+	}
+	if query == "" {
+		return time.Since(start), "", nil
+	}
+
+	queryStart := time.Now()
+	first, gotRow, err := queryFirstValue(checkCtx, conn, query)
+	if err != nil {
+		return 0, "", fmt.Errorf("query failed: %w", timeoutError(err, true))
+	}
+	queryDuration := time.Since(queryStart)
+	if expect != "" {
+		if !gotRow {
+			return 0, "", fmt.Errorf("query returned no rows, expected %q", expect)
+		}
+		if first != expect {
+			return 0, "", fmt.Errorf("query returned %q, expected %q", first, expect)
+		}
+	}
+	return time.Since(start), fmt.Sprintf("query %v", queryDuration.Round(time.Microsecond)), nil
+}
+
+// queryFirstValue runs query, reading and discarding every row, and returns
+// the first column of the first row as text, NULL being "NULL".
+func queryFirstValue(ctx context.Context, conn *sql.Conn, query string) (first string, gotRow bool, err error) {
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return "", false, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		if gotRow {
+			continue
+		}
+		gotRow = true
+		cols, err := rows.Columns()
+		if err != nil {
+			return "", false, err
+		}
+		if len(cols) == 0 {
+			continue
+		}
+		values := make([]sql.RawBytes, len(cols))
+		dest := make([]any, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return "", false, err
+		}
+		first = "NULL"
+		if values[0] != nil {
+			first = string(values[0])
+		}
+	}
+	return first, gotRow, rows.Err()
+}
+
+// connector builds the driver connector for target, dialing through dial.
+func (p *DatabaseProbe) connector(target string) (driver.Connector, error) {
+	var cfg config.DatabaseConfig
+	if p.Config != nil {
+		cfg = *p.Config
+	}
+
+	switch p.Driver {
+	case MonitorTypeMySQL:
+		mc := mysql.NewConfig()
+		if cfg.DSN != "" {
+			parsed, err := mysql.ParseDSN(cfg.DSN)
+			if err != nil {
+				return nil, err
+			}
+			mc = parsed
+		} else {
+			addr, _, err := hostPortTarget(target, "3306")
+			if err != nil {
+				return nil, err
+			}
+			mc.Net = "tcp"
+			mc.Addr = addr
+			mc.User = cfg.User
+			mc.Passwd = cfg.Password
+			mc.DBName = cfg.DBName
+		}
+		mc.DialFunc = p.dial
+		return mysql.NewConnector(mc)
+	case MonitorTypePostgres:
+		dsn := cfg.DSN
+		if dsn == "" {
+			addr, _, err := hostPortTarget(target, "5432")
+			if err != nil {
+				return nil, err
+			}
+			// Like libpq, use TLS when the server offers it rather than
+			// requiring it, as lib/pq does by default
+			u := url.URL{Scheme: "postgres", Host: addr, Path: "/" + cfg.DBName, RawQuery: "sslmode=prefer"}
+			if cfg.Password != "" {
+				u.User = url.UserPassword(cfg.User, cfg.Password)
+			} else {
+				u.User = url.User(cfg.User)
+			}
+			dsn = u.String()
+		}
+		c, err := pq.NewConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		c.Dialer(pqDialer{p})
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", p.Driver)
+	}
+}
+
+// dial connects to the database server, giving up after the connect timeout.
+func (p *DatabaseProbe) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	return dialTarget(ctx, network, address, p.DialContext, p.tunnel, nil, connectTimeout(p.ConnectTimeout, timeout))
+}
+
+func (p *DatabaseProbe) SetTimeout(timeout time.Duration) {
+	p.Timeout = timeout
+}
+
+func (p *DatabaseProbe) SetConnectTimeout(timeout time.Duration) {
+	p.ConnectTimeout = timeout
+}
+
+// pqDialer routes lib/pq connections through DatabaseProbe.dial.
+type pqDialer struct {
+	p *DatabaseProbe
+}
+
+func (d pqDialer) Dial(network, address string) (net.Conn, error) {
+	return d.p.dial(context.Background(), network, address)
+}
+
+func (d pqDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.p.dial(ctx, network, address)
+}
+
+func (d pqDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d.p.dial(ctx, network, address)
+}
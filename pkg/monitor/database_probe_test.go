@@ -0,0 +1,277 @@
+package monitor
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"probixel/pkg/config"
+	"probixel/pkg/tunnels"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startMockPostgresServer speaks just enough of the PostgreSQL protocol to
+// accept any login and answer simple queries. Queries containing "fail" get
+// an error response, those with a quoted literal return it as a single row.
+func startMockPostgresServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveMockPostgres(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func serveMockPostgres(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	writeMsg := func(typ byte, body []byte) {
+		msg := []byte{typ, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(msg[1:], uint32(len(body)+4))
+		_, _ = conn.Write(append(msg, body...))
+	}
+	readStartup := func() ([]byte, error) {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, err
+		}
+		body := make([]byte, binary.BigEndian.Uint32(size[:])-4)
+		_, err := io.ReadFull(conn, body)
+		return body, err
+	}
+
+	body, err := readStartup()
+	if err != nil {
+		return
+	}
+	// SSLRequest, decline and wait for the actual startup message
+	if len(body) == 4 && binary.BigEndian.Uint32(body) == 80877103 {
+		_, _ = conn.Write([]byte{'N'})
+		if _, err := readStartup(); err != nil {
+			return
+		}
+	}
+	writeMsg('R', []byte{0, 0, 0, 0}) // AuthenticationOk
+	writeMsg('Z', []byte{'I'})        // ReadyForQuery
+
+	for {
+		var header [5]byte
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			return
+		}
+		body := make([]byte, binary.BigEndian.Uint32(header[1:])-4)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		switch header[0] {
+		case 'Q':
+			query := strings.TrimRight(string(body), "\x00")
+			if strings.Contains(query, "fail") {
+				writeMsg('E', []byte("SERROR\x00C42601\x00Msyntax error\x00\x00"))
+			} else if _, rest, ok := strings.Cut(query, "'"); ok {
+				value, _, _ := strings.Cut(rest, "'")
+				// A single text column, then its row
+				desc := binary.BigEndian.AppendUint16(nil, 1)
+				desc = append(desc, "value\x00"...)
+				desc = binary.BigEndian.AppendUint32(desc, 0)
+				desc = binary.BigEndian.AppendUint16(desc, 0)
+				desc = binary.BigEndian.AppendUint32(desc, 25) // text
+				desc = binary.BigEndian.AppendUint16(desc, 0xffff)
+				desc = binary.BigEndian.AppendUint32(desc, 0xffffffff)
+				desc = binary.BigEndian.AppendUint16(desc, 0)
+				writeMsg('T', desc)
+				row := binary.BigEndian.AppendUint16(nil, 1)
+				row = binary.BigEndian.AppendUint32(row, uint32(len(value)))
+				writeMsg('D', append(row, value...))
+				writeMsg('C', []byte("SELECT 1\x00"))
+			} else {
+				writeMsg('C', []byte("SELECT 1\x00"))
+			}
+			writeMsg('Z', []byte{'I'})
+		case 'X':
+			return
+		}
+	}
+}
+
+func TestDatabaseProbe_Postgres(t *testing.T) {
+	addr := startMockPostgresServer(t)
+
+	tests := []struct {
+		name        string
+		cfg         *config.DatabaseConfig
+		target      string
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{
+			name:        "connect only",
+			cfg:         &config.DatabaseConfig{User: "probixel"},
+			target:      addr,
+			wantSuccess: true,
+			wantMsg:     "OK",
+		},
+		{
+			name:        "query succeeds",
+			cfg:         &config.DatabaseConfig{User: "probixel", Password: "secret", DBName: "app", Query: "SELECT 1"},
+			target:      addr,
+			wantSuccess: true,
+			wantMsg:     "OK (query ",
+		},
+		{
+			name:        "query fails",
+			cfg:         &config.DatabaseConfig{User: "probixel", Query: "SELECT fail"},
+			target:      addr,
+			wantSuccess: false,
+			wantMsg:     "query failed",
+		},
+		{
+			name:        "expected value",
+			cfg:         &config.DatabaseConfig{User: "probixel", Query: "SELECT 'primary'", Expect: "primary"},
+			target:      addr,
+			wantSuccess: true,
+			wantMsg:     "OK (query ",
+		},
+		{
+			name:        "unexpected value",
+			cfg:         &config.DatabaseConfig{User: "probixel", Query: "SELECT 'replica'", Expect: "primary"},
+			target:      addr,
+			wantSuccess: false,
+			wantMsg:     `query returned "replica", expected "primary"`,
+		},
+		{
+			name:        "no rows",
+			cfg:         &config.DatabaseConfig{User: "probixel", Query: "SELECT 1 WHERE false", Expect: "1"},
+			target:      addr,
+			wantSuccess: false,
+			wantMsg:     `query returned no rows, expected "1"`,
+		},
+		{
+			name:        "dsn",
+			cfg:         &config.DatabaseConfig{DSN: "postgres://probixel@" + addr + "/app?sslmode=disable", Query: "SELECT 1"},
+			wantSuccess: true,
+			wantMsg:     "OK (query ",
+		},
+		{
+			name:        "any mode falls back to the next target",
+			cfg:         &config.DatabaseConfig{User: "probixel"},
+			target:      "127.0.0.1:1, " + addr,
+			wantSuccess: true,
+			wantMsg:     "target " + addr + ": OK",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &DatabaseProbe{Driver: MonitorTypePostgres, Config: tt.cfg, Timeout: 2 * time.Second}
+			if err := p.Initialize(); err != nil {
+				t.Fatalf("Initialize() error = %v", err)
+			}
+			res, err := p.Check(context.Background(), tt.target)
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if res.Success != tt.wantSuccess {
+				t.Errorf("Success = %v, want %v (message: %s)", res.Success, tt.wantSuccess, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("Message = %q, want it to contain %q", res.Message, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestDatabaseProbe_ConnectionRefused(t *testing.T) {
+	// Grab a free port and close it, so connecting to it is refused
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	for _, driver := range []string{MonitorTypeMySQL, MonitorTypePostgres} {
+		t.Run(driver, func(t *testing.T) {
+			p := &DatabaseProbe{Driver: driver, Config: &config.DatabaseConfig{User: "probixel"}, Timeout: time.Second}
+			p.SetTargetMode(TargetModeAll)
+			res, _ := p.Check(context.Background(), addr)
+			if res.Success {
+				t.Fatal("expected failure against a closed port")
+			}
+			if !strings.Contains(res.Message, "target "+addr+" failed") {
+				t.Errorf("unexpected message: %s", res.Message)
+			}
+		})
+	}
+}
+
+func TestDatabaseProbe_DefaultPortAndDialContext(t *testing.T) {
+	tests := []struct {
+		driver string
+		want   string
+	}{
+		{MonitorTypeMySQL, "db.example.com:3306"},
+		{MonitorTypePostgres, "db.example.com:5432"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			var dialed string
+			p := &DatabaseProbe{
+				Driver:  tt.driver,
+				Config:  &config.DatabaseConfig{User: "probixel"},
+				Timeout: time.Second,
+				DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+					dialed = address
+					return nil, &net.OpError{Op: "dial", Net: network, Err: io.EOF}
+				},
+			}
+			res, _ := p.Check(context.Background(), "db.example.com")
+			if res.Success {
+				t.Fatal("expected failure")
+			}
+			if dialed != tt.want {
+				t.Errorf("dialed %q, want %q", dialed, tt.want)
+			}
+		})
+	}
+}
+
+func TestDatabaseProbe_InvalidDSN(t *testing.T) {
+	tests := []struct {
+		driver string
+		dsn    string
+	}{
+		{MonitorTypeMySQL, "user@tcp(localhost"},
+		{MonitorTypePostgres, "postgres://%zz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			p := &DatabaseProbe{Driver: tt.driver, Config: &config.DatabaseConfig{DSN: tt.dsn}}
+			err := p.Initialize()
+			if err == nil || !strings.Contains(err.Error(), "invalid database.dsn") {
+				t.Errorf("Initialize() error = %v, want invalid database.dsn", err)
+			}
+		})
+	}
+}
+
+func TestDatabaseProbe_TunnelPending(t *testing.T) {
+	p := &DatabaseProbe{Driver: MonitorTypeMySQL}
+	p.SetTunnel(&tunnels.MockTunnel{IsStabilizedResult: false})
+	res, _ := p.Check(context.Background(), "127.0.0.1:3306")
+	if !res.Pending {
+		t.Errorf("expected a pending result while the tunnel stabilizes, got %+v", res)
+	}
+}
@@ -0,0 +1,131 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// DNSCache reuses hostname resolutions across checks for up to its TTL. It is
+// shared by every probe and safe for concurrent use. A nil cache, or a TTL of
+// 0, resolves every time.
+type DNSCache struct {
+	LookupHost func(ctx context.Context, host string) ([]string, error) // Allows mocking, defaults to net.DefaultResolver
+
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	addrs    []string
+	resolved time.Time
+}
+
+// SetTTL changes how long resolutions are reused. It applies to entries
+// already cached, and 0 disables the cache.
+func (c *DNSCache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	if ttl <= 0 {
+		c.entries = nil
+	}
+}
+
+// lookupHost resolves host with lookup, or with the cache's own lookup when
+// nil, returning the cached addresses if they are younger than the TTL.
+func (c *DNSCache) lookupHost(ctx context.Context, host string, lookup func(ctx context.Context, host string) ([]string, error)) ([]string, error) {
+	if lookup == nil && c != nil {
+		lookup = c.LookupHost
+	}
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupHost
+	}
+	if c == nil {
+		return lookup(ctx, host)
+	}
+
+	c.mu.Lock()
+	ttl := c.ttl
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ttl <= 0 {
+		return lookup(ctx, host)
+	}
+	if ok && time.Since(entry.resolved) < ttl {
+		return entry.addrs, nil
+	}
+
+	// Lookups run unlocked, concurrent misses for a host just resolve it twice
+	addrs, err := lookup(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return addrs, err
+	}
+	c.mu.Lock()
+	if c.ttl > 0 {
+		if c.entries == nil {
+			c.entries = make(map[string]dnsCacheEntry)
+		}
+		c.entries[host] = dnsCacheEntry{addrs: addrs, resolved: time.Now()}
+	}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// resolve returns addr, a "host" or "host:port" target, with a hostname
+// replaced by its first cached address in network's family. IP addresses, and
+// any address while caching is disabled, are returned unchanged for the
+// dialer to resolve.
+func (c *DNSCache) resolve(ctx context.Context, network, addr string) (string, error) {
+	if !c.enabled() {
+		return addr, nil
+	}
+	host, port, err := parseTarget(addr)
+	if err != nil {
+		return "", err
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return addr, nil
+	}
+	addrs, err := c.lookupHost(ctx, host, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	first, err := firstAddress(addrs, network)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	if port == "" {
+		return first, nil
+	}
+	return net.JoinHostPort(first, port), nil
+}
+
+// forget drops the cached resolution of addr's host, so the next check
+// resolves it again. Probes call it when a check fails, in case the host's
+// address changed.
+func (c *DNSCache) forget(addr string) {
+	if c == nil {
+		return
+	}
+	host, _, err := parseTarget(addr)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, host)
+}
+
+func (c *DNSCache) enabled() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ttl > 0
+}
@@ -0,0 +1,163 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func countingLookup(addrs ...string) (*atomic.Int32, func(ctx context.Context, host string) ([]string, error)) {
+	var lookups atomic.Int32
+	return &lookups, func(ctx context.Context, host string) ([]string, error) {
+		lookups.Add(1)
+		if host == "unknown.test" {
+			return nil, errors.New("no such host")
+		}
+		return addrs, nil
+	}
+}
+
+func TestDNSCache_Resolve(t *testing.T) {
+	lookups, lookup := countingLookup("10.0.0.1", "10.0.0.2")
+	c := &DNSCache{LookupHost: lookup}
+	c.SetTTL(time.Minute)
+	ctx := context.Background()
+
+	for range 3 {
+		addr, err := c.resolve(ctx, "tcp", "db.test:5432")
+		if err != nil {
+			t.Fatalf("resolve failed: %v", err)
+		}
+		if addr != "10.0.0.1:5432" {
+			t.Errorf("expected the first address, got %q", addr)
+		}
+	}
+	if got := lookups.Load(); got != 1 {
+		t.Errorf("expected 1 lookup within the TTL, got %d", got)
+	}
+
+	// Bare hosts, as used by ping, are cached too
+	if addr, _ := c.resolve(ctx, "tcp", "db.test"); addr != "10.0.0.1" {
+		t.Errorf("expected a bare address, got %q", addr)
+	}
+
+	// IPs are never looked up
+	if addr, _ := c.resolve(ctx, "tcp", "[2001:db8::1]:443"); addr != "[2001:db8::1]:443" {
+		t.Errorf("expected the IP target unchanged, got %q", addr)
+	}
+	if got := lookups.Load(); got != 1 {
+		t.Errorf("expected no lookup for cached hosts and IPs, got %d", got)
+	}
+
+	// A failed check forgets the host, so the next one resolves it again
+	c.forget("db.test:5432")
+	_, _ = c.resolve(ctx, "tcp", "db.test:5432")
+	if got := lookups.Load(); got != 2 {
+		t.Errorf("expected a new lookup after forget, got %d", got)
+	}
+
+	// Failures are not cached
+	for range 2 {
+		if _, err := c.resolve(ctx, "tcp", "unknown.test:80"); err == nil {
+			t.Error("expected a resolution error")
+		}
+	}
+	if got := lookups.Load(); got != 4 {
+		t.Errorf("expected failed lookups to be retried, got %d lookups", got)
+	}
+}
+
+func TestDNSCache_TTL(t *testing.T) {
+	lookups, lookup := countingLookup("10.0.0.1")
+	c := &DNSCache{LookupHost: lookup}
+	c.SetTTL(50 * time.Millisecond)
+	ctx := context.Background()
+
+	_, _ = c.resolve(ctx, "tcp", "db.test:5432")
+	_, _ = c.resolve(ctx, "tcp", "db.test:5432")
+	time.Sleep(60 * time.Millisecond)
+	_, _ = c.resolve(ctx, "tcp", "db.test:5432")
+	if got := lookups.Load(); got != 2 {
+		t.Errorf("expected an expired entry to be resolved again, got %d lookups", got)
+	}
+
+	// Disabled, targets are left to the dialer to resolve
+	c.SetTTL(0)
+	addr, err := c.resolve(ctx, "tcp", "db.test:5432")
+	if err != nil || addr != "db.test:5432" {
+		t.Errorf("expected the target unchanged, got %q, %v", addr, err)
+	}
+	if got := lookups.Load(); got != 2 {
+		t.Errorf("expected no lookup with caching disabled, got %d", got)
+	}
+
+	var nilCache *DNSCache
+	if addr, _ := nilCache.resolve(ctx, "tcp", "db.test:5432"); addr != "db.test:5432" {
+		t.Errorf("expected a nil cache to leave the target unchanged, got %q", addr)
+	}
+	nilCache.forget("db.test:5432")
+}
+
+func TestDNSCache_Concurrent(t *testing.T) {
+	_, lookup := countingLookup("10.0.0.1")
+	c := &DNSCache{LookupHost: lookup}
+	c.SetTTL(time.Minute)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			for range 50 {
+				if addr, err := c.resolve(context.Background(), "tcp", "db.test:5432"); err != nil || addr != "10.0.0.1:5432" {
+					t.Errorf("unexpected resolution %q, %v", addr, err)
+				}
+				c.forget("db.test:5432")
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestTCPProbe_DNSCache(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	lookups, lookup := countingLookup("127.0.0.1")
+	c := &DNSCache{LookupHost: lookup}
+	c.SetTTL(time.Minute)
+	p := &TCPProbe{Timeout: time.Second, DNSCache: c}
+	target := net.JoinHostPort("service.test", port)
+
+	for range 2 {
+		if res, _ := p.Check(context.Background(), target); !res.Success {
+			t.Fatalf("expected success, got %s", res.Message)
+		}
+	}
+	if got := lookups.Load(); got != 1 {
+		t.Errorf("expected the resolution to be reused, got %d lookups", got)
+	}
+
+	_ = listener.Close()
+	if res, _ := p.Check(context.Background(), target); res.Success {
+		t.Fatal("expected failure once the listener is closed")
+	}
+	_, _ = p.Check(context.Background(), target)
+	if got := lookups.Load(); got != 2 {
+		t.Errorf("expected a failed check to force a new lookup, got %d lookups", got)
+	}
+}
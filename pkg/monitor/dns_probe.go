@@ -1,24 +1,66 @@
 package monitor
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"net/netip"
+	"slices"
 	"strings"
 	"time"
 
 	"probixel/pkg/tunnels"
+
+	"golang.org/x/net/dns/dnsmessage"
 )
 
 const DEFAULT_DOMAIN = "google.com"
 
+// DNS protocols supported by the probe
+const (
+	DNSProtocolUDP = "udp" // Plain DNS over UDP with TCP fallback (default)
+	DNSProtocolDoH = "doh" // DNS-over-HTTPS (RFC 8484)
+	DNSProtocolDoT = "dot" // DNS-over-TLS (RFC 7858)
+)
+
+// dohMediaType is the RFC 8484 content type for DNS wire-format messages
+const dohMediaType = "application/dns-message"
+
+// dnsRecordTypes are the record types that can be checked, by name
+var dnsRecordTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"TXT":   dnsmessage.TypeTXT,
+	"NS":    dnsmessage.TypeNS,
+}
+
 type DNSProbe struct {
 	Resolve     func(ctx context.Context, nameserver, host string) ([]string, error)
 	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
 	Timeout     time.Duration
-	targetMode  string
-	domain      string
-	tunnel      tunnels.Tunnel
+	Protocol    string        // "udp" (default), "doh" or "dot"
+	ResolverURL string        // DoH endpoint, e.g. https://dns.example/dns-query
+	Client      *http.Client  // Allows mocking the DoH client. If nil, one is built from DialContext.
+	MaxDuration time.Duration // Fail resolutions slower than this, 0 to disable
+	// Record checks: RecordType is A, AAAA, CNAME, MX, TXT or NS, empty to only
+	// check the domain resolves, and ExpectedValues must all be among its records
+	RecordType     string
+	ExpectedValues []string
+	LookupRecords  func(ctx context.Context, nameserver, domain, recordType string) ([]string, error) // Allows mocking record lookups
+	// TLS settings of the DoH and DoT resolver connections
+	InsecureSkipVerify bool
+	CACert             string // CA bundle used to verify the resolver, file path or inline PEM
+	targetMode         string
+	quorum             int
+	domain             string
+	tunnel             tunnels.Tunnel
 }
 
 func (p *DNSProbe) SetTunnel(t tunnels.Tunnel) {
@@ -29,19 +71,72 @@ func (p *DNSProbe) Name() string {
 	return MonitorTypeDNS
 }
 
+func (p *DNSProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeDNS,
+		Description: "Resolves a domain against DNS servers, over UDP, DNS-over-HTTPS or DNS-over-TLS",
+		Fields:      []string{"targets", "target_mode", "quorum", "timeout", "dns.domain", "dns.protocol", "dns.resolver_url", "dns.max_duration", "dns.record_type", "dns.expected_values", "dns.insecure_skip_verify", "dns.ca_cert"},
+	}
+}
+
 func (p *DNSProbe) SetTargetMode(mode string) {
 	p.targetMode = mode
 }
 
+func (p *DNSProbe) SetQuorum(n int) {
+	p.quorum = n
+}
+
 func (p *DNSProbe) SetDomain(domain string) {
 	p.domain = domain
 }
 
 func (p *DNSProbe) Check(ctx context.Context, target string) (Result, error) {
+	res, err := p.check(ctx, target)
+	if err != nil || p.targetMode == TargetModeQuorum {
+		// In quorum mode slow nameservers count as down, see checkQuorum
+		return res, err
+	}
+	return p.checkMaxDuration(res, strings.Split(target, ",")), nil
+}
+
+// checkMaxDuration fails a successful result when a resolution took longer
+// than MaxDuration. In "all" mode the slowest nameserver is reported.
+func (p *DNSProbe) checkMaxDuration(res Result, targets []string) Result {
+	if p.MaxDuration <= 0 || !res.Success {
+		return res
+	}
+
+	slowest, target := -1, res.Target
+	duration := res.Duration
+	for i, tr := range res.TargetResults {
+		if tr.Success && (slowest < 0 || tr.Duration > duration) {
+			slowest, target, duration = i, tr.Target, tr.Duration
+		}
+	}
+	if duration <= p.MaxDuration {
+		return res
+	}
+
+	msg := fmt.Sprintf("resolved in %v, above max_duration %v", duration, p.MaxDuration)
+	if slowest >= 0 {
+		res.TargetResults[slowest].Success = false
+		res.TargetResults[slowest].Message = msg
+	}
+	res.Success = false
+	res.Duration = duration
+	res.Target = target
+	res.Message = targetMessage(targets, target, msg)
+	return res
+}
+
+func (p *DNSProbe) check(ctx context.Context, target string) (Result, error) {
 	// Target might start with "dns:"
 	target = strings.TrimPrefix(target, "dns:")
 	targets := strings.Split(target, ",")
 	var lastErr error
+	var lastTarget string
+	var results []TargetResult
 
 	startTotal := time.Now()
 
@@ -56,9 +151,29 @@ func (p *DNSProbe) Check(ctx context.Context, target string) (Result, error) {
 		}, nil
 	}
 
+	if p.Protocol == DNSProtocolDoH {
+		resolverURL := p.ResolverURL
+		if resolverURL == "" {
+			resolverURL = strings.TrimSpace(target)
+		}
+		return p.checkDoH(ctx, resolverURL, startTotal), nil
+	}
+	if p.targetMode == TargetModeQuorum {
+		return p.checkQuorum(ctx, targets, startTotal), nil
+	}
+	if p.Protocol == DNSProtocolDoT {
+		return p.checkDoT(ctx, targets, startTotal), nil
+	}
+
+	domainToResolve := p.domain
+	if domainToResolve == "" {
+		domainToResolve = DEFAULT_DOMAIN
+	}
+
 	// For "all" mode, track successes
 	if p.targetMode == TargetModeAll {
 		var totalDuration time.Duration
+		var found []string
 		successCount := 0
 
 		for _, t := range targets {
@@ -67,72 +182,45 @@ func (p *DNSProbe) Check(ctx context.Context, target string) (Result, error) {
 				continue
 			}
 
-			// Handle host:port
-			host, port, err := net.SplitHostPort(t)
+			nameserver, _, err := hostPortTarget(t, "53")
 			if err != nil {
-				host = t
-				port = "53"
+				return Result{
+					Success:       false,
+					Duration:      0,
+					Message:       fmt.Sprintf("target %s failed: %v", t, err),
+					Target:        t,
+					Timestamp:     startTotal,
+					TargetResults: append(results, newTargetResult(t, 0, err)),
+				}, nil
 			}
-			nameserver := net.JoinHostPort(host, port)
 			start := time.Now()
 
-			domainToResolve := p.domain
-			if domainToResolve == "" {
-				domainToResolve = DEFAULT_DOMAIN
-			}
-
-			var ips []string
-			if p.Resolve != nil {
-				ips, err = p.Resolve(ctx, nameserver, domainToResolve)
-			} else {
-				dialer := p.DialContext
-				if dialer == nil {
-					timeout := p.Timeout
-					if timeout == 0 {
-						timeout = 5 * time.Second
-					}
-					d := net.Dialer{Timeout: timeout}
-					dialer = d.DialContext
-				}
-
-				r := &net.Resolver{
-					PreferGo: true,
-					Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-						return dialer(ctx, "udp", nameserver)
-					},
-				}
-				ips, err = r.LookupHost(ctx, domainToResolve)
-				if err != nil {
-					// Retry with TCP
-					rTCP := &net.Resolver{
-						PreferGo: true,
-						Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-							return dialer(ctx, "tcp", nameserver)
-						},
-					}
-					ips, err = rTCP.LookupHost(ctx, domainToResolve)
-				}
-			}
-
-			if err != nil || len(ips) == 0 {
+			records, _, err := p.resolve(ctx, nameserver, domainToResolve)
+			if err != nil {
 				return Result{
-					Success:   false,
-					Duration:  0,
-					Message:   fmt.Sprintf("target %s failed: %v", t, err),
-					Timestamp: startTotal,
+					Success:       false,
+					Duration:      0,
+					Message:       fmt.Sprintf("target %s failed: %v", t, err),
+					Target:        nameserver,
+					Timestamp:     startTotal,
+					TargetResults: append(results, newTargetResult(nameserver, 0, err)),
 				}, nil
 			}
 
-			totalDuration += time.Since(start)
+			duration := time.Since(start)
+			results = append(results, p.recordsResult(nameserver, duration, records))
+			totalDuration += duration
+			found = appendMissing(found, records)
 			successCount++
 		}
 
 		if successCount > 0 {
 			return Result{
-				Success:   true,
-				Duration:  totalDuration / time.Duration(successCount),
-				Message:   fmt.Sprintf("all %d targets OK", successCount),
-				Timestamp: startTotal,
+				Success:       true,
+				Duration:      totalDuration / time.Duration(successCount),
+				Message:       fmt.Sprintf("all %d targets %s", successCount, p.okMessage("", found)),
+				Timestamp:     startTotal,
+				TargetResults: results,
 			}, nil
 		}
 	}
@@ -143,94 +231,576 @@ func (p *DNSProbe) Check(ctx context.Context, target string) (Result, error) {
 		if t == "" {
 			continue
 		}
+		if err := checkExpired(ctx); err != nil {
+			lastErr, lastTarget = err, t
+			break
+		}
 
-		// Handle host:port
-		host, port, err := net.SplitHostPort(t)
+		nameserver, _, err := hostPortTarget(t, "53")
 		if err != nil {
-			host = t
-			port = "53"
+			results = append(results, newTargetResult(t, 0, err))
+			lastErr, lastTarget = err, t
+			continue
 		}
-		nameserver := net.JoinHostPort(host, port)
 		start := time.Now()
 
-		domainToResolve := p.domain
-		if domainToResolve == "" {
-			domainToResolve = DEFAULT_DOMAIN
+		records, overTCP, err := p.resolve(ctx, nameserver, domainToResolve)
+		if err == nil {
+			duration := time.Since(start)
+			transport := ""
+			if overTCP {
+				transport = "TCP"
+			}
+			return Result{
+				Success:       true,
+				Duration:      duration,
+				Message:       targetMessage(targets, nameserver, p.okMessage(transport, records)),
+				Target:        nameserver,
+				Timestamp:     startTotal,
+				TargetResults: append(results, p.recordsResult(nameserver, duration, records)),
+			}, nil
 		}
 
-		var ips []string
-		if p.Resolve != nil {
-			ips, err = p.Resolve(ctx, nameserver, domainToResolve)
-		} else {
-			dialer := p.DialContext
-			if dialer == nil {
-				timeout := p.Timeout
-				if timeout == 0 {
-					timeout = 5 * time.Second
+		results = append(results, newTargetResult(nameserver, 0, err))
+		lastErr = err
+		lastTarget = nameserver
+	}
+
+	return Result{
+		Success:       false,
+		Duration:      0,
+		Message:       fmt.Sprintf("all dns targets failed, last error: target %s: %v", lastTarget, lastErr),
+		Target:        lastTarget,
+		Timestamp:     startTotal,
+		TargetResults: results,
+	}, nil
+}
+
+// checkQuorum resolves the domain on every nameserver, over UDP or DoT,
+// succeeding when at least the quorum of them answer as expected within
+// MaxDuration.
+func (p *DNSProbe) checkQuorum(ctx context.Context, targets []string, startTotal time.Time) Result {
+	domain := p.domain
+	if domain == "" {
+		domain = DEFAULT_DOMAIN
+	}
+	return checkTargets(ctx, targets, targetCheck{
+		mode:   TargetModeQuorum,
+		quorum: p.quorum,
+		check: func(ctx context.Context, t string) (time.Duration, string, error) {
+			var duration time.Duration
+			var err error
+			if p.Protocol == DNSProtocolDoT {
+				duration, _, err = p.queryDoT(ctx, t)
+			} else {
+				var nameserver string
+				if nameserver, _, err = hostPortTarget(t, "53"); err == nil {
+					start := time.Now()
+					_, _, err = p.resolve(ctx, nameserver, domain)
+					duration = time.Since(start)
 				}
-				d := net.Dialer{Timeout: timeout}
-				dialer = d.DialContext
 			}
-
-			r := &net.Resolver{
-				PreferGo: true,
-				Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-					return dialer(ctx, "udp", nameserver)
-				},
+			if err != nil {
+				return 0, "", err
+			}
+			if p.MaxDuration > 0 && duration > p.MaxDuration {
+				return 0, "", fmt.Errorf("resolved in %v, above max_duration %v", duration, p.MaxDuration)
 			}
-			ips, err = r.LookupHost(ctx, domainToResolve)
+			return duration, "", nil
+		},
+	}, startTotal)
+}
+
+// resolve looks domain up on nameserver over UDP, retrying over TCP when
+// that fails, and checks the records against the expected values. overTCP
+// reports whether the TCP retry answered.
+func (p *DNSProbe) resolve(ctx context.Context, nameserver, domain string) (records []string, overTCP bool, err error) {
+	if p.RecordType == "" && p.Resolve != nil {
+		records, err = p.Resolve(ctx, nameserver, domain)
+		if err == nil && len(records) == 0 {
+			err = noRecordsErr(nil, domain)
+		}
+		return records, false, err
+	}
+	if p.RecordType != "" && p.LookupRecords != nil {
+		records, err = p.LookupRecords(ctx, nameserver, domain, p.RecordType)
+		if err == nil {
+			err = p.checkRecords(domain, records)
 		}
+		return records, false, err
+	}
 
-		if err == nil && len(ips) > 0 {
-			return Result{
-				Success:   true,
-				Duration:  time.Since(start),
-				Message:   "OK",
-				Target:    nameserver,
-				Timestamp: startTotal,
-			}, nil
+	records, err = p.lookup(ctx, "udp", nameserver, domain)
+	if err != nil || len(records) == 0 {
+		records, err = p.lookup(ctx, "tcp", nameserver, domain)
+		overTCP = true
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if p.RecordType == "" && len(records) == 0 {
+		return nil, false, noRecordsErr(nil, domain)
+	}
+	return records, overTCP, p.checkRecords(domain, records)
+}
+
+// lookup queries nameserver over network for the records of domain of the
+// configured type, or its addresses when no type is configured.
+func (p *DNSProbe) lookup(ctx context.Context, network, nameserver, domain string) ([]string, error) {
+	dialer := p.DialContext
+	if dialer == nil {
+		timeout := p.Timeout
+		if timeout == 0 {
+			timeout = 5 * time.Second
 		}
+		d := net.Dialer{Timeout: timeout}
+		dialer = d.DialContext
+	}
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer(ctx, network, nameserver)
+		},
+	}
 
-		// Retry DNS resolution with TCP if UDP failed
-		if p.Resolve == nil {
-			dialer := p.DialContext
-			if dialer == nil {
-				timeout := p.Timeout
-				if timeout == 0 {
-					timeout = 5 * time.Second
-				}
-				d := net.Dialer{Timeout: timeout}
-				dialer = d.DialContext
-			}
+	var records []string
+	switch p.RecordType {
+	case "":
+		return r.LookupHost(ctx, domain)
+	case "A", "AAAA":
+		family := "ip4"
+		if p.RecordType == "AAAA" {
+			family = "ip6"
+		}
+		ips, err := r.LookupIP(ctx, family, domain)
+		for _, ip := range ips {
+			records = append(records, ip.String())
+		}
+		return records, err
+	case "CNAME":
+		cname, err := r.LookupCNAME(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+		// Without an alias the canonical name is the domain itself
+		if normalizeName(cname) != normalizeName(domain) {
+			records = append(records, normalizeName(cname))
+		}
+		return records, nil
+	case "MX":
+		mxs, err := r.LookupMX(ctx, domain)
+		for _, mx := range mxs {
+			records = append(records, fmt.Sprintf("%d %s", mx.Pref, normalizeName(mx.Host)))
+		}
+		return records, err
+	case "TXT":
+		return r.LookupTXT(ctx, domain)
+	case "NS":
+		nss, err := r.LookupNS(ctx, domain)
+		for _, ns := range nss {
+			records = append(records, normalizeName(ns.Host))
+		}
+		return records, err
+	}
+	return nil, fmt.Errorf("unsupported record type %q", p.RecordType)
+}
 
-			rTCP := &net.Resolver{
-				PreferGo: true,
-				Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-					return dialer(ctx, "tcp", nameserver)
-				},
-			}
-			ips, err = rTCP.LookupHost(ctx, domainToResolve)
-			if err == nil && len(ips) > 0 {
-				return Result{
-					Success:   true,
-					Duration:  time.Since(start),
-					Message:   "OK (TCP)",
-					Target:    nameserver,
-					Timestamp: startTotal,
-				}, nil
-			}
+// checkRecords fails when a record type is configured and domain has no
+// record of that type, or lacks one of the expected values.
+func (p *DNSProbe) checkRecords(domain string, records []string) error {
+	if p.RecordType == "" {
+		return nil
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no %s records for %s", p.RecordType, domain)
+	}
+	for _, want := range p.ExpectedValues {
+		if !slices.ContainsFunc(records, func(got string) bool { return p.recordMatches(got, want) }) {
+			return fmt.Errorf("expected %s record %q not found for %s, got %s", p.RecordType, want, domain, strings.Join(records, ", "))
 		}
+	}
+	return nil
+}
 
-		lastErr = err
+// recordMatches compares a record with an expected value: addresses in any
+// notation, names ignoring case and the trailing dot, and MX records with or
+// without their preference.
+func (p *DNSProbe) recordMatches(got, want string) bool {
+	switch p.RecordType {
+	case "A", "AAAA":
+		g, err1 := netip.ParseAddr(got)
+		w, err2 := netip.ParseAddr(strings.TrimSpace(want))
+		return err1 == nil && err2 == nil && g == w
+	case "CNAME", "NS":
+		return normalizeName(got) == normalizeName(want)
+	case "MX":
+		want = normalizeName(want)
+		_, host, _ := strings.Cut(got, " ")
+		return got == want || host == want
+	}
+	return got == want
+}
+
+// okMessage describes a successful resolution, e.g. "OK (TCP, A: 10.0.0.1)".
+// Records are only listed when a record type is configured.
+func (p *DNSProbe) okMessage(transport string, records []string) string {
+	var notes []string
+	if transport != "" {
+		notes = append(notes, transport)
+	}
+	if p.RecordType != "" {
+		notes = append(notes, fmt.Sprintf("%s: %s", p.RecordType, strings.Join(records, ", ")))
+	}
+	if len(notes) == 0 {
+		return "OK"
+	}
+	return fmt.Sprintf("OK (%s)", strings.Join(notes, ", "))
+}
+
+// recordsResult is the successful result of one resolver, listing the
+// records it returned.
+func (p *DNSProbe) recordsResult(nameserver string, duration time.Duration, records []string) TargetResult {
+	tr := newTargetResult(nameserver, duration, nil)
+	tr.Message = p.okMessage("", records)
+	return tr
+}
+
+// normalizeName lowercases a domain name and removes its trailing dot.
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
+
+// appendMissing appends the values of add that are not in list yet.
+func appendMissing(list, add []string) []string {
+	for _, v := range add {
+		if !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// noRecordsErr returns err, or an error naming domain when a lookup returned
+// no error but no addresses either.
+func noRecordsErr(err error, domain string) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("no records for %s", domain)
+}
+
+// checkDoH sends an A query for the configured domain to resolverURL as an
+// RFC 8484 POST request and succeeds if the resolver returns at least one answer.
+func (p *DNSProbe) checkDoH(ctx context.Context, resolverURL string, startTotal time.Time) Result {
+	fail := func(format string, args ...any) Result {
+		return Result{
+			Success:   false,
+			Message:   fmt.Sprintf(format, args...),
+			Target:    resolverURL,
+			Timestamp: startTotal,
+		}
+	}
+
+	packed, err := p.query()
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, resolverURL, bytes.NewReader(packed))
+	if err != nil {
+		return fail("failed to create doh request: %v", err)
+	}
+	req.Header.Set("Content-Type", dohMediaType)
+	req.Header.Set("Accept", dohMediaType)
+
+	client := p.Client
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if p.DialContext != nil {
+			transport.DialContext = p.DialContext
+		}
+		roots, err := rootCAs(p.CACert)
+		if err != nil {
+			return fail("%v", err)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: p.InsecureSkipVerify, // nolint:gosec // deliberate feature
+			RootCAs:            roots,
+		}
+		client = &http.Client{Transport: transport}
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return fail("doh request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fail("doh resolver returned status %d", resp.StatusCode)
+	}
+
+	// DNS messages are limited to 64KiB
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return fail("failed to read doh response: %v", err)
+	}
+	duration := time.Since(start)
+
+	records, err := p.checkAnswer(body, DNSProtocolDoH)
+	if err != nil {
+		return fail("%v", err)
 	}
 
 	return Result{
-		Success:   false,
-		Duration:  0,
-		Message:   fmt.Sprintf("all dns targets failed, last error: %v", lastErr),
+		Success:   true,
+		Duration:  duration,
+		Message:   p.okMessage("DoH", records),
+		Target:    resolverURL,
 		Timestamp: startTotal,
-	}, nil
+	}
+}
+
+// checkDoT queries each resolver in targets over TLS, evaluated per the
+// target mode. Resolvers default to port 853.
+func (p *DNSProbe) checkDoT(ctx context.Context, targets []string, startTotal time.Time) Result {
+	all := p.targetMode == TargetModeAll
+	var results []TargetResult
+	var lastErr error
+	var lastTarget string
+	var totalDuration time.Duration
+	var found []string
+	successCount := 0
+
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+
+		var duration time.Duration
+		var records []string
+		err := checkExpired(ctx)
+		if err == nil {
+			duration, records, err = p.queryDoT(ctx, t)
+		}
+		if err == nil {
+			results = append(results, p.recordsResult(t, duration, records))
+		} else {
+			results = append(results, newTargetResult(t, duration, err))
+		}
+
+		if err == nil && !all {
+			return Result{
+				Success:       true,
+				Duration:      duration,
+				Message:       targetMessage(targets, t, p.okMessage("DoT", records)),
+				Target:        t,
+				Timestamp:     startTotal,
+				TargetResults: results,
+			}
+		}
+		if err != nil && (all || ctx.Err() != nil) {
+			return Result{
+				Success:       false,
+				Message:       fmt.Sprintf("target %s failed: %v", t, err),
+				Target:        t,
+				Timestamp:     startTotal,
+				TargetResults: results,
+			}
+		}
+		if err == nil {
+			totalDuration += duration
+			found = appendMissing(found, records)
+			successCount++
+		} else {
+			lastErr, lastTarget = err, t
+		}
+	}
+
+	if all && successCount > 0 {
+		return Result{
+			Success:       true,
+			Duration:      totalDuration / time.Duration(successCount),
+			Message:       fmt.Sprintf("all %d targets %s", successCount, p.okMessage("DoT", found)),
+			Timestamp:     startTotal,
+			TargetResults: results,
+		}
+	}
+	return Result{
+		Success:       false,
+		Message:       fmt.Sprintf("all dns targets failed, last error: target %s: %v", lastTarget, lastErr),
+		Target:        lastTarget,
+		Timestamp:     startTotal,
+		TargetResults: results,
+	}
 }
+
+// queryDoT sends the query to resolver over a TLS connection, length
+// prefixed as on TCP, and returns how long the resolution took and the
+// records of the configured type.
+func (p *DNSProbe) queryDoT(ctx context.Context, resolver string) (time.Duration, []string, error) {
+	addr, host, err := hostPortTarget(resolver, "853")
+	if err != nil {
+		return 0, nil, err
+	}
+	packed, err := p.query()
+	if err != nil {
+		return 0, nil, err
+	}
+	roots, err := rootCAs(p.CACert)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	dialer := p.DialContext
+	if dialer == nil {
+		var d net.Dialer
+		dialer = d.DialContext
+	}
+	rawConn, err := dialer(ctx, "tcp", addr)
+	if err != nil {
+		return 0, nil, err
+	}
+	conn := tls.Client(rawConn, &tls.Config{
+		InsecureSkipVerify: p.InsecureSkipVerify, // nolint:gosec // deliberate feature
+		ServerName:         host,
+		RootCAs:            roots,
+	})
+	defer func() { _ = conn.Close() }()
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return 0, nil, fmt.Errorf("tls handshake failed: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	msg := make([]byte, 2+len(packed))
+	binary.BigEndian.PutUint16(msg, uint16(len(packed)))
+	copy(msg[2:], packed)
+	if _, err := conn.Write(msg); err != nil {
+		return 0, nil, fmt.Errorf("failed to send dot query: %w", err)
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return 0, nil, fmt.Errorf("failed to read dot response: %w", err)
+	}
+	body := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, body); err != nil {
+		return 0, nil, fmt.Errorf("failed to read dot response: %w", err)
+	}
+	duration := time.Since(start)
+
+	records, err := p.checkAnswer(body, DNSProtocolDoT)
+	if err != nil {
+		return 0, nil, err
+	}
+	return duration, records, nil
+}
+
+// query builds the wire-format query for the configured domain and record
+// type, A by default, sent to the DoH and DoT resolvers.
+func (p *DNSProbe) query() ([]byte, error) {
+	domain := p.domain
+	if domain == "" {
+		domain = DEFAULT_DOMAIN
+	}
+	fqdn := domain
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid domain %q: %v", domain, err)
+	}
+
+	qtype := dnsmessage.TypeA
+	if p.RecordType != "" {
+		t, ok := dnsRecordTypes[p.RecordType]
+		if !ok {
+			return nil, fmt.Errorf("unsupported record type %q", p.RecordType)
+		}
+		qtype = t
+	}
+
+	// RFC 8484 recommends an ID of 0 so responses are cache friendly
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: name, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build dns query: %v", err)
+	}
+	return packed, nil
+}
+
+// checkAnswer verifies that body is a successful answer with at least one
+// record, and returns the records of the configured type checked against the
+// expected values. protocol names the resolver in errors.
+func (p *DNSProbe) checkAnswer(body []byte, protocol string) ([]string, error) {
+	domain := p.domain
+	if domain == "" {
+		domain = DEFAULT_DOMAIN
+	}
+
+	var answer dnsmessage.Message
+	if err := answer.Unpack(body); err != nil {
+		return nil, fmt.Errorf("invalid %s response: %v", protocol, err)
+	}
+	if answer.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("%s resolver answered %s for %s", protocol, strings.TrimPrefix(answer.RCode.String(), "RCode"), domain)
+	}
+	if len(answer.Answers) == 0 {
+		return nil, fmt.Errorf("%s resolver returned no answers for %s", protocol, domain)
+	}
+	if p.RecordType == "" {
+		return nil, nil
+	}
+
+	// Answers may include the CNAME chain leading to the requested records
+	var records []string
+	for _, rr := range answer.Answers {
+		if rr.Header.Type != dnsRecordTypes[p.RecordType] {
+			continue
+		}
+		switch r := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			records = append(records, netip.AddrFrom4(r.A).String())
+		case *dnsmessage.AAAAResource:
+			records = append(records, netip.AddrFrom16(r.AAAA).String())
+		case *dnsmessage.CNAMEResource:
+			records = append(records, normalizeName(r.CNAME.String()))
+		case *dnsmessage.MXResource:
+			records = append(records, fmt.Sprintf("%d %s", r.Pref, normalizeName(r.MX.String())))
+		case *dnsmessage.TXTResource:
+			records = append(records, strings.Join(r.TXT, ""))
+		case *dnsmessage.NSResource:
+			records = append(records, normalizeName(r.NS.String()))
+		}
+	}
+	return records, p.checkRecords(domain, records)
+}
+
 func (p *DNSProbe) SetTimeout(timeout time.Duration) {
 	p.Timeout = timeout
 }
@@ -2,12 +2,23 @@ package monitor
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"probixel/pkg/tunnels"
 	"strings"
 	"testing"
 	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
 )
 
 func TestDNSProbe_Check(t *testing.T) {
@@ -111,6 +122,45 @@ func TestDNSProbe_Check_AllMode_Failure(t *testing.T) {
 	}
 }
 
+func TestDNSProbe_Check_QuorumMode(t *testing.T) {
+	probe := &DNSProbe{
+		Resolve: func(ctx context.Context, ns, host string) ([]string, error) {
+			switch {
+			case strings.HasPrefix(ns, "bad"):
+				return nil, errors.New("dns fail")
+			case strings.HasPrefix(ns, "slow"):
+				time.Sleep(20 * time.Millisecond)
+			}
+			return []string{"1.2.3.4"}, nil
+		},
+	}
+	probe.SetTargetMode(TargetModeQuorum)
+
+	probe.SetQuorum(2)
+	res, _ := probe.Check(context.Background(), "good1,bad,good2")
+	if !res.Success || res.Message != "2/3 up (need 2)" {
+		t.Errorf("expected quorum success, got %v: %s", res.Success, res.Message)
+	}
+
+	probe.SetQuorum(3)
+	res, _ = probe.Check(context.Background(), "good1,bad,good2")
+	if res.Success || res.Target != "bad" || !strings.Contains(res.Message, "dns fail") {
+		t.Errorf("expected quorum failure on bad, got %v (%s): %s", res.Success, res.Target, res.Message)
+	}
+
+	// Nameservers slower than max_duration count as down
+	probe.MaxDuration = 10 * time.Millisecond
+	probe.SetQuorum(2)
+	res, _ = probe.Check(context.Background(), "good1,slow,good2")
+	if !res.Success || res.Message != "2/3 up (need 2)" {
+		t.Errorf("expected quorum success without the slow nameserver, got %v: %s", res.Success, res.Message)
+	}
+	res, _ = probe.Check(context.Background(), "good1,slow,bad")
+	if res.Success || res.Target != "slow" || !strings.Contains(res.Message, "above max_duration 10ms") {
+		t.Errorf("expected quorum failure on slow, got %v (%s): %s", res.Success, res.Target, res.Message)
+	}
+}
+
 func TestDNSProbe_Check_RealLogic(t *testing.T) {
 	// Trigger the real net.Resolver path by not setting Resolve field
 	probe := &DNSProbe{}
@@ -212,3 +262,392 @@ func TestDNSProbe_SetTimeout(t *testing.T) {
 		t.Errorf("Expected timeout 10s, got %v", p.Timeout)
 	}
 }
+
+// newDoHServer returns a TLS server answering RFC 8484 POST queries with the
+// given rcode and, on success, a single A record.
+func newDoHServer(t *testing.T, rcode dnsmessage.RCode) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		packed := dnsAnswer(t, body, rcode)
+		if packed == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(packed)
+	}))
+}
+
+// dnsAnswer answers the wire-format query with the given rcode and, on
+// success, a single A record. It returns nil for malformed queries.
+func dnsAnswer(t *testing.T, body []byte, rcode dnsmessage.RCode) []byte {
+	var query dnsmessage.Message
+	if err := query.Unpack(body); err != nil || len(query.Questions) != 1 {
+		return nil
+	}
+	q := query.Questions[0]
+	if q.Name.String() != "probixel.test." || q.Type != dnsmessage.TypeA {
+		t.Errorf("unexpected question %v", q)
+	}
+
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: query.ID, Response: true, RCode: rcode},
+		Questions: query.Questions,
+	}
+	if rcode == dnsmessage.RCodeSuccess {
+		resp.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+		}}
+	}
+	packed, _ := resp.Pack()
+	return packed
+}
+
+// newDoTServer starts a DNS-over-TLS resolver answering with the given rcode.
+// It returns its address and a PEM bundle trusting its certificate.
+func newDoTServer(t *testing.T, rcode dnsmessage.RCode) (addr, caPEM string) {
+	t.Helper()
+	cert := generateTestCert(t, x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				var length [2]byte
+				if _, err := io.ReadFull(conn, length[:]); err != nil {
+					return
+				}
+				body := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, body); err != nil {
+					return
+				}
+				packed := dnsAnswer(t, body, rcode)
+				msg := binary.BigEndian.AppendUint16(nil, uint16(len(packed)))
+				_, _ = conn.Write(append(msg, packed...))
+			}()
+		}
+	}()
+
+	caPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}))
+	return l.Addr().String(), caPEM
+}
+
+func TestDNSProbe_DoT(t *testing.T) {
+	addr, caPEM := newDoTServer(t, dnsmessage.RCodeSuccess)
+	nxdomain, _ := newDoTServer(t, dnsmessage.RCodeNameError)
+
+	tests := []struct {
+		name       string
+		probe      DNSProbe
+		mode       string
+		targets    string
+		wantOK     bool
+		wantMsg    string
+		wantTarget string
+	}{
+		{"trusted ca", DNSProbe{CACert: caPEM}, TargetModeAny, addr, true, "OK (DoT)", addr},
+		{"insecure", DNSProbe{InsecureSkipVerify: true}, TargetModeAny, addr, true, "OK (DoT)", addr},
+		{"untrusted certificate", DNSProbe{}, TargetModeAny, addr, false, "tls handshake failed", addr},
+		{"nxdomain", DNSProbe{InsecureSkipVerify: true}, TargetModeAny, nxdomain, false, "dot resolver answered NameError", nxdomain},
+		{"any falls back", DNSProbe{InsecureSkipVerify: true}, TargetModeAny, nxdomain + "," + addr, true, "target " + addr + ": OK (DoT)", addr},
+		{"all fails on first failure", DNSProbe{InsecureSkipVerify: true}, TargetModeAll, addr + "," + nxdomain, false, "target " + nxdomain + " failed", nxdomain},
+		{"all OK", DNSProbe{CACert: caPEM}, TargetModeAll, addr + "," + addr, true, "all 2 targets OK", ""},
+		{"quorum met", DNSProbe{InsecureSkipVerify: true, quorum: 1}, TargetModeQuorum, nxdomain + "," + addr, true, "1/2 up (need 1)", ""},
+		{"quorum missed", DNSProbe{InsecureSkipVerify: true, quorum: 2}, TargetModeQuorum, nxdomain + "," + addr, false, "first failure: target " + nxdomain + ": dot resolver answered NameError", nxdomain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := tt.probe
+			probe.Protocol = DNSProtocolDoT
+			probe.SetDomain("probixel.test")
+			probe.SetTargetMode(tt.mode)
+
+			res, err := probe.Check(context.Background(), tt.targets)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantOK || !strings.Contains(res.Message, tt.wantMsg) || res.Target != tt.wantTarget {
+				t.Errorf("got success=%v target=%q message=%q, want %v %q containing %q", res.Success, res.Target, res.Message, tt.wantOK, tt.wantTarget, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestDNSProbe_DoH(t *testing.T) {
+	server := newDoHServer(t, dnsmessage.RCodeSuccess)
+	defer server.Close()
+
+	probe := &DNSProbe{
+		Protocol:    DNSProtocolDoH,
+		ResolverURL: server.URL + "/dns-query",
+		Client:      server.Client(),
+	}
+	probe.SetDomain("probixel.test")
+
+	res, err := probe.Check(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Success {
+		t.Fatalf("Expected success, got: %s", res.Message)
+	}
+	if res.Target != server.URL+"/dns-query" {
+		t.Errorf("Expected resolver URL as target, got %q", res.Target)
+	}
+}
+
+func TestDNSProbe_DoH_Failures(t *testing.T) {
+	nxdomain := newDoHServer(t, dnsmessage.RCodeNameError)
+	defer nxdomain.Close()
+
+	broken := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	garbage := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html>not dns</html>"))
+	}))
+	defer garbage.Close()
+
+	tests := []struct {
+		name    string
+		server  *httptest.Server
+		wantMsg string
+	}{
+		{"nxdomain", nxdomain, "answered NameError"},
+		{"http error", broken, "status 502"},
+		{"invalid body", garbage, "invalid doh response"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := &DNSProbe{Protocol: DNSProtocolDoH, Client: tt.server.Client()}
+			probe.SetDomain("probixel.test")
+
+			// Without resolver_url the target is used as the endpoint
+			res, _ := probe.Check(context.Background(), tt.server.URL)
+			if res.Success {
+				t.Fatal("Expected failure")
+			}
+			if !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("Expected message containing %q, got %q", tt.wantMsg, res.Message)
+			}
+		})
+	}
+}
+
+func TestDNSProbe_MaxDuration(t *testing.T) {
+	// ns2 answers slowly
+	resolve := func(ctx context.Context, nameserver, host string) ([]string, error) {
+		if strings.HasPrefix(nameserver, "ns2") {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return []string{"10.0.0.1"}, nil
+	}
+
+	tests := []struct {
+		name        string
+		mode        string
+		targets     string
+		maxDuration time.Duration
+		wantSuccess bool
+		wantTarget  string
+	}{
+		{"unset", TargetModeAny, "ns2", 0, true, "ns2:53"},
+		{"fast enough", TargetModeAny, "ns1", 20 * time.Millisecond, true, "ns1:53"},
+		{"too slow", TargetModeAny, "ns2", 20 * time.Millisecond, false, "ns2:53"},
+		{"all mode reports the slowest", TargetModeAll, "ns1,ns2", 20 * time.Millisecond, false, "ns2:53"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &DNSProbe{Resolve: resolve, MaxDuration: tt.maxDuration}
+			p.SetTargetMode(tt.mode)
+			res, err := p.Check(context.Background(), tt.targets)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess || res.Target != tt.wantTarget {
+				t.Fatalf("got success=%v target=%q (%s), want success=%v target=%q", res.Success, res.Target, res.Message, tt.wantSuccess, tt.wantTarget)
+			}
+			if !tt.wantSuccess {
+				if !strings.Contains(res.Message, "above max_duration 20ms") {
+					t.Errorf("unexpected message: %s", res.Message)
+				}
+				if res.Duration <= tt.maxDuration {
+					t.Errorf("Duration = %v, want the measured time above %v", res.Duration, tt.maxDuration)
+				}
+			}
+		})
+	}
+}
+
+func TestDNSProbe_MaxDurationRecords(t *testing.T) {
+	// ns2 answers slowly, ns3 quickly but with another address
+	lookup := func(ctx context.Context, nameserver, domain, recordType string) ([]string, error) {
+		switch {
+		case strings.HasPrefix(nameserver, "ns2"):
+			time.Sleep(50 * time.Millisecond)
+		case strings.HasPrefix(nameserver, "ns3"):
+			return []string{"10.0.0.9"}, nil
+		}
+		return []string{"10.0.0.1"}, nil
+	}
+
+	tests := []struct {
+		name        string
+		targets     string
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{"fast and expected", "ns1", true, "OK (A: 10.0.0.1)"},
+		{"slow and expected", "ns2", false, "above max_duration 20ms"},
+		{"fast and unexpected", "ns3", false, `expected A record "10.0.0.1" not found`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &DNSProbe{LookupRecords: lookup, RecordType: "A", ExpectedValues: []string{"10.0.0.1"}, MaxDuration: 20 * time.Millisecond}
+			p.SetDomain("probixel.test")
+			res, err := p.Check(context.Background(), tt.targets)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess || !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("got success=%v message=%q, want success=%v message containing %q", res.Success, res.Message, tt.wantSuccess, tt.wantMsg)
+			}
+			if tt.wantSuccess && res.Duration > p.MaxDuration {
+				t.Errorf("Duration = %v, want the query time below %v", res.Duration, p.MaxDuration)
+			}
+			if tt.targets == "ns2" && res.Duration < 50*time.Millisecond {
+				t.Errorf("Duration = %v, want the measured query time", res.Duration)
+			}
+		})
+	}
+}
+
+func TestDNSProbe_RecordTypes(t *testing.T) {
+	zone := map[string]map[string][]string{
+		"A":    {"10.0.0.53:53": {"10.0.0.1", "10.0.0.2"}, "10.0.0.54:53": {"10.0.0.9"}},
+		"AAAA": {"10.0.0.53:53": {"2001:db8::1"}},
+		"MX":   {"10.0.0.53:53": {"10 mail.probixel.test", "20 backup.probixel.test"}},
+		"TXT":  {"10.0.0.53:53": {"v=spf1 -all"}},
+	}
+
+	tests := []struct {
+		name       string
+		recordType string
+		expected   []string
+		mode       string
+		targets    string
+		wantOK     bool
+		wantMsg    string
+	}{
+		{"records listed", "A", nil, TargetModeAny, "10.0.0.53", true, "OK (A: 10.0.0.1, 10.0.0.2)"},
+		{"expected found", "A", []string{"10.0.0.2"}, TargetModeAny, "10.0.0.53", true, "OK (A: 10.0.0.1, 10.0.0.2)"},
+		{"expected missing", "A", []string{"10.0.0.1", "10.0.0.3"}, TargetModeAny, "10.0.0.53", false, `expected A record "10.0.0.3" not found for probixel.test, got 10.0.0.1, 10.0.0.2`},
+		{"any falls back", "A", []string{"10.0.0.1"}, TargetModeAny, "10.0.0.54,10.0.0.53", true, "target 10.0.0.53:53: OK (A: 10.0.0.1, 10.0.0.2)"},
+		{"all requires every resolver", "A", []string{"10.0.0.1"}, TargetModeAll, "10.0.0.53,10.0.0.54", false, "target 10.0.0.54 failed: expected A record \"10.0.0.1\" not found"},
+		{"all OK", "A", nil, TargetModeAll, "10.0.0.53,10.0.0.54", true, "all 2 targets OK (A: 10.0.0.1, 10.0.0.2, 10.0.0.9)"},
+		{"ipv6 notation", "AAAA", []string{"2001:0db8:0::1"}, TargetModeAny, "10.0.0.53", true, "OK (AAAA: 2001:db8::1)"},
+		{"mx host", "MX", []string{"MAIL.probixel.test."}, TargetModeAny, "10.0.0.53", true, "OK (MX: 10 mail.probixel.test, 20 backup.probixel.test)"},
+		{"mx preference", "MX", []string{"10 backup.probixel.test"}, TargetModeAny, "10.0.0.53", false, `expected MX record "10 backup.probixel.test" not found`},
+		{"txt", "TXT", []string{"v=spf1 -all"}, TargetModeAny, "10.0.0.53", true, "OK (TXT: v=spf1 -all)"},
+		{"no records", "NS", nil, TargetModeAny, "10.0.0.53", false, "no NS records for probixel.test"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := &DNSProbe{
+				RecordType:     tt.recordType,
+				ExpectedValues: tt.expected,
+				LookupRecords: func(ctx context.Context, nameserver, domain, recordType string) ([]string, error) {
+					if domain != "probixel.test" || recordType != tt.recordType {
+						t.Errorf("unexpected lookup of %s %s", recordType, domain)
+					}
+					return zone[recordType][nameserver], nil
+				},
+			}
+			probe.SetDomain("probixel.test")
+			probe.SetTargetMode(tt.mode)
+
+			res, err := probe.Check(context.Background(), tt.targets)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantOK || !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("got success=%v message=%q, want %v containing %q", res.Success, res.Message, tt.wantOK, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestDNSProbe_DoH_RecordType(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var query dnsmessage.Message
+		if err := query.Unpack(body); err != nil || len(query.Questions) != 1 || query.Questions[0].Type != dnsmessage.TypeMX {
+			t.Errorf("expected an MX query, got %v", query.Questions)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		q := query.Questions[0]
+		target := dnsmessage.MustNewName("mail.probixel.test.")
+		resp := dnsmessage.Message{
+			Header:    dnsmessage.Header{Response: true},
+			Questions: query.Questions,
+			Answers: []dnsmessage.Resource{
+				// Records of other types, like a CNAME chain, are not listed
+				{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET},
+					Body:   &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("alias.probixel.test.")},
+				},
+				{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeMX, Class: dnsmessage.ClassINET},
+					Body:   &dnsmessage.MXResource{Pref: 10, MX: target},
+				},
+			},
+		}
+		packed, _ := resp.Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(packed)
+	}))
+	defer server.Close()
+
+	probe := &DNSProbe{Protocol: DNSProtocolDoH, Client: server.Client(), RecordType: "MX"}
+	probe.SetDomain("probixel.test")
+
+	res, _ := probe.Check(context.Background(), server.URL)
+	if !res.Success || res.Message != "OK (DoH, MX: 10 mail.probixel.test)" {
+		t.Errorf("expected the MX record to be listed, got %v: %s", res.Success, res.Message)
+	}
+
+	probe.ExpectedValues = []string{"mx.probixel.test"}
+	res, _ = probe.Check(context.Background(), server.URL)
+	if res.Success || !strings.Contains(res.Message, `expected MX record "mx.probixel.test" not found`) {
+		t.Errorf("expected a missing record failure, got %v: %s", res.Success, res.Message)
+	}
+}
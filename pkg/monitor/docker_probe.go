@@ -3,9 +3,11 @@ package monitor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,7 +19,12 @@ type DockerProbe struct {
 	Sockets     map[string]config.DockerSocketConfig
 	SocketName  string
 	Healthy     bool
+	Stats       bool // Also report CPU and memory usage, within half of Timeout
+	MaxRestarts *int // Fail once the container restarted more often, nil to ignore restarts
+	FailOnOOM   bool // Fail when the container was last killed for running out of memory
 	targetMode  string
+	quorum      int
+	concurrency int
 	Timeout     time.Duration
 	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
 	tunnel      tunnels.Tunnel
@@ -31,10 +38,26 @@ func (p *DockerProbe) Name() string {
 	return MonitorTypeDocker
 }
 
+func (p *DockerProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeDocker,
+		Description: "Checks that containers are running, and optionally healthy",
+		Fields:      []string{"targets", "target_mode", "quorum", "concurrency", "tunnel", "docker.socket", "docker.healthy", "docker.stats", "docker.max_restarts", "docker.fail_on_oom"},
+	}
+}
+
 func (p *DockerProbe) SetTargetMode(mode string) {
 	p.targetMode = mode
 }
 
+func (p *DockerProbe) SetQuorum(n int) {
+	p.quorum = n
+}
+
+func (p *DockerProbe) SetConcurrency(n int) {
+	p.concurrency = n
+}
+
 func (p *DockerProbe) Check(ctx context.Context, target string) (Result, error) {
 	start := time.Now()
 	targets := strings.Split(target, ",")
@@ -70,74 +93,46 @@ func (p *DockerProbe) Check(ctx context.Context, target string) (Result, error)
 		}, nil
 	}
 
-	if p.targetMode == TargetModeAll {
-		var totalDuration time.Duration
-		successCount := 0
-		for _, t := range targets {
-			t = strings.TrimSpace(t)
-			if t == "" {
-				continue
+	res := checkTargets(ctx, targets, targetCheck{
+		mode:        p.targetMode,
+		quorum:      p.quorum,
+		concurrency: p.concurrency,
+		okMessage:   "all %d containers OK",
+		failMessage: func(t string, err error) string {
+			if len(targets) == 1 {
+				return err.Error()
 			}
+			return fmt.Sprintf("all %d docker targets failed, last error: target %s: %v", len(targets), t, err)
+		},
+		check: func(ctx context.Context, t string) (time.Duration, string, error) {
 			res := p.checkOne(ctx, client, apiURL, cfg, t)
 			if !res.Success {
-				res.Duration = time.Since(start)
-				res.Timestamp = start
-				return res, nil
+				return 0, "", errors.New(res.Message)
 			}
-			totalDuration += res.Duration
-			successCount++
-		}
-		avgDuration := time.Duration(0)
-		if successCount > 0 {
-			avgDuration = totalDuration / time.Duration(successCount)
-		}
-		return Result{
-			Success:   true,
-			Duration:  avgDuration,
-			Message:   fmt.Sprintf("all %d containers OK", successCount),
-			Timestamp: start,
-		}, nil
-	}
-
-	// Default "any" mode
-	var lastRes Result
-	for _, t := range targets {
-		t = strings.TrimSpace(t)
-		if t == "" {
-			continue
-		}
-		res := p.checkOne(ctx, client, apiURL, cfg, t)
-		if res.Success {
-			res.Duration = time.Since(start)
-			res.Timestamp = start
-			return res, nil
-		}
-		lastRes = res
-	}
-
-	lastRes.Duration = time.Since(start)
-	lastRes.Timestamp = start
-	if len(targets) > 1 && !lastRes.Success {
-		lastRes.Message = fmt.Sprintf("all %d docker targets failed, last error: %s", len(targets), lastRes.Message)
-	}
-	return lastRes, nil
+			return res.Duration, res.Message, nil
+		},
+	}, start)
+	res.Duration = time.Since(start)
+	return res, nil
 }
 
+// checkOne checks a single container's status and, when enabled, its
+// resource usage. The two calls have independent deadlines splitting Timeout,
+// so a slow stats endpoint can neither fail the check nor starve the status
+// call: the container is reported up with its stats unavailable.
 func (p *DockerProbe) checkOne(ctx context.Context, client *http.Client, apiURL string, cfg config.DockerSocketConfig, target string) Result {
 	start := time.Now()
-	url := fmt.Sprintf("%s/containers/%s/json", apiURL, target)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return Result{Success: false, Message: fmt.Sprintf("failed to create request: %v", err)}
+	statusTimeout, statsTimeout := p.timeout(), time.Duration(0)
+	if p.Stats {
+		statsTimeout = statusTimeout / 2
+		statusTimeout -= statsTimeout
 	}
 
-	for k, v := range cfg.Headers {
-		req.Header.Set(k, v)
-	}
-
-	resp, err := client.Do(req)
+	statusCtx, cancel := context.WithTimeout(ctx, statusTimeout)
+	defer cancel()
+	resp, err := p.get(statusCtx, client, fmt.Sprintf("%s/containers/%s/json", apiURL, target), cfg, target)
 	if err != nil {
-		return Result{Success: false, Message: fmt.Sprintf("docker api request failed: %v", err)}
+		return Result{Success: false, Message: err.Error(), Target: target}
 	}
 	defer resp.Body.Close()
 
@@ -150,9 +145,11 @@ func (p *DockerProbe) checkOne(ctx context.Context, client *http.Client, apiURL
 	}
 
 	var containerInfo struct {
-		State struct {
-			Status string `json:"Status"`
-			Health struct {
+		RestartCount int `json:"RestartCount"`
+		State        struct {
+			Status    string `json:"Status"`
+			OOMKilled bool   `json:"OOMKilled"`
+			Health    struct {
 				Status string `json:"Status"`
 			} `json:"Health"`
 		} `json:"State"`
@@ -173,19 +170,116 @@ func (p *DockerProbe) checkOne(ctx context.Context, client *http.Client, apiURL
 		return Result{Success: false, Message: fmt.Sprintf("container is running but health is %s", healthStatus), Target: target}
 	}
 
+	// A container restarted by its restart policy is running again, these
+	// catch it crash looping or being killed for lack of memory
+	if p.FailOnOOM && containerInfo.State.OOMKilled {
+		return Result{Success: false, Message: fmt.Sprintf("container was OOM killed (%d restarts)", containerInfo.RestartCount), Target: target}
+	}
+	if p.MaxRestarts != nil && containerInfo.RestartCount > *p.MaxRestarts {
+		return Result{Success: false, Message: fmt.Sprintf("container restarted %d times (max %d)", containerInfo.RestartCount, *p.MaxRestarts), Target: target}
+	}
+
 	msg := "OK"
 	if healthStatus != "" {
 		msg = fmt.Sprintf("running (%s)", healthStatus)
 	}
+	duration := time.Since(start)
+
+	if p.Stats {
+		statsCtx, cancel := context.WithTimeout(ctx, statsTimeout)
+		defer cancel()
+		usage, err := p.stats(statsCtx, client, apiURL, cfg, target)
+		switch {
+		case errors.Is(err, context.DeadlineExceeded) || errors.Is(statsCtx.Err(), context.DeadlineExceeded):
+			msg += fmt.Sprintf(", stats unavailable (skipped after %v)", statsTimeout)
+		case err != nil:
+			msg += fmt.Sprintf(", stats unavailable (%v)", err)
+		default:
+			msg += ", " + usage
+		}
+	}
 
 	return Result{
 		Success:  true,
-		Duration: time.Since(start),
+		Duration: duration,
 		Message:  msg,
 		Target:   target,
 	}
 }
 
+// get sends a GET request for a container to the docker api.
+func (p *DockerProbe) get(ctx context.Context, client *http.Client, url string, cfg config.DockerSocketConfig, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	// Proxies may expect per-container credentials, so {%target%} is replaced with the container name
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, strings.ReplaceAll(v, "{%target%}", target))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker api request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// stats fetches a container's resource usage, formatted as "cpu 1.2%, mem
+// 45.3 MiB". The daemon samples CPU twice, a second apart, before answering.
+func (p *DockerProbe) stats(ctx context.Context, client *http.Client, apiURL string, cfg config.DockerSocketConfig, target string) (string, error) {
+	resp, err := p.get(ctx, client, fmt.Sprintf("%s/containers/%s/stats?stream=false", apiURL, target), cfg, target)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("docker api returned status %d", resp.StatusCode)
+	}
+
+	type cpuStats struct {
+		CPUUsage struct {
+			TotalUsage uint64 `json:"total_usage"`
+		} `json:"cpu_usage"`
+		SystemUsage uint64 `json:"system_cpu_usage"`
+		OnlineCPUs  uint64 `json:"online_cpus"`
+	}
+	var s struct {
+		CPUStats    cpuStats `json:"cpu_stats"`
+		PreCPUStats cpuStats `json:"precpu_stats"`
+		MemoryStats struct {
+			Usage uint64            `json:"usage"`
+			Stats map[string]uint64 `json:"stats"`
+		} `json:"memory_stats"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return "", fmt.Errorf("failed to decode docker stats: %w", err)
+	}
+
+	// Same computation as docker stats: the container's share of the host's
+	// CPU time between the two samples, scaled to the number of CPUs
+	cpu := 0.0
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	if cpuDelta > 0 && systemDelta > 0 {
+		cpu = cpuDelta / systemDelta * float64(max(s.CPUStats.OnlineCPUs, 1)) * 100
+	}
+
+	// Page cache is reclaimable and excluded, as docker stats does
+	mem := s.MemoryStats.Usage
+	cache := s.MemoryStats.Stats["inactive_file"] // cgroup v2
+	if cache == 0 {
+		cache = s.MemoryStats.Stats["total_inactive_file"] // cgroup v1
+	}
+	if cache < mem {
+		mem -= cache
+	}
+
+	return fmt.Sprintf("cpu %.1f%%, mem %.1f MiB", cpu, float64(mem)/(1<<20)), nil
+}
+
 func (p *DockerProbe) getClient(cfg config.DockerSocketConfig) (*http.Client, string, error) {
 	if cfg.Socket != "" {
 		tr := &http.Transport{
@@ -194,11 +288,7 @@ func (p *DockerProbe) getClient(cfg config.DockerSocketConfig) (*http.Client, st
 			},
 		}
 		// When using unix socket, the host in the URL is ignored but must be present
-		timeout := p.Timeout
-		if timeout == 0 {
-			timeout = 5 * time.Second
-		}
-		return &http.Client{Transport: tr, Timeout: timeout}, "http://localhost", nil
+		return &http.Client{Transport: tr, Timeout: p.timeout()}, "http://localhost", nil
 	}
 
 	if cfg.Host != "" && cfg.Port != 0 {
@@ -206,18 +296,15 @@ func (p *DockerProbe) getClient(cfg config.DockerSocketConfig) (*http.Client, st
 		if protocol == "" {
 			protocol = "http"
 		}
-		apiURL := fmt.Sprintf("%s://%s:%d", protocol, cfg.Host, cfg.Port)
+		host := strings.TrimSuffix(strings.TrimPrefix(cfg.Host, "["), "]")
+		apiURL := fmt.Sprintf("%s://%s", protocol, net.JoinHostPort(host, strconv.Itoa(cfg.Port)))
 
 		tr := &http.Transport{}
 		if p.DialContext != nil {
 			tr.DialContext = p.DialContext
 		}
 
-		timeout := p.Timeout
-		if timeout == 0 {
-			timeout = 5 * time.Second
-		}
-		return &http.Client{Transport: tr, Timeout: timeout}, apiURL, nil
+		return &http.Client{Transport: tr, Timeout: p.timeout()}, apiURL, nil
 	}
 
 	return nil, "", fmt.Errorf("invalid docker socket configuration: must provide either socket path or host/port")
@@ -225,3 +312,10 @@ func (p *DockerProbe) getClient(cfg config.DockerSocketConfig) (*http.Client, st
 func (p *DockerProbe) SetTimeout(timeout time.Duration) {
 	p.Timeout = timeout
 }
+
+func (p *DockerProbe) timeout() time.Duration {
+	if p.Timeout == 0 {
+		return 5 * time.Second
+	}
+	return p.Timeout
+}
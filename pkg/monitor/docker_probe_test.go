@@ -73,6 +73,49 @@ func TestDockerProbe_Check_Proxy(t *testing.T) {
 	}
 }
 
+func TestDockerProbe_Check_TargetHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		container := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/containers/"), "/json")
+		if got, want := r.Header.Get("Authorization"), "Bearer token-"+container; got != want {
+			t.Errorf("expected Authorization %q, got %q", want, got)
+		}
+		if got := r.Header.Get("X-Static"); got != "unchanged" {
+			t.Errorf("expected static header to be unchanged, got %q", got)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"State": map[string]interface{}{"Status": "running"},
+		})
+	}))
+	defer server.Close()
+
+	host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	port := 0
+	fmt.Sscanf(portStr, "%d", &port)
+
+	probe := &DockerProbe{
+		Sockets: map[string]config.DockerSocketConfig{
+			"proxy": {
+				Host: host,
+				Port: port,
+				Headers: map[string]string{
+					"Authorization": "Bearer token-{%target%}",
+					"X-Static":      "unchanged",
+				},
+			},
+		},
+		SocketName: "proxy",
+	}
+	probe.SetTargetMode(TargetModeAll)
+
+	result, err := probe.Check(context.Background(), "web,db")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Success {
+		t.Errorf("expected success, got failure: %s", result.Message)
+	}
+}
+
 func TestDockerProbe_Check_UnixSocket(t *testing.T) {
 	// Create a temporary unix socket file
 	socketFile := "/tmp/probixel-docker-test.sock"
@@ -120,6 +163,7 @@ func TestDockerProbe_Check_UnixSocket(t *testing.T) {
 }
 
 func TestDockerProbe_Check_ContainerStatuses(t *testing.T) {
+	two := 2
 	tests := []struct {
 		name         string
 		status       string
@@ -127,12 +171,22 @@ func TestDockerProbe_Check_ContainerStatuses(t *testing.T) {
 		waitHealthy  bool
 		wantSuccess  bool
 		wantMsg      string
+		restarts     int
+		oomKilled    bool
+		maxRestarts  *int
+		failOnOOM    bool
 	}{
-		{"running and healthy", "running", "healthy", true, true, "running (healthy)"},
-		{"running but unhealthy", "running", "unhealthy", true, false, "container is running but health is unhealthy"},
-		{"running no healthcheck", "running", "", true, true, "OK"},
-		{"stopped", "exited", "", false, false, "container is exited"},
-		{"starting", "running", "starting", true, false, "container is running but health is starting"},
+		{name: "running and healthy", status: "running", healthStatus: "healthy", waitHealthy: true, wantSuccess: true, wantMsg: "running (healthy)"},
+		{name: "running but unhealthy", status: "running", healthStatus: "unhealthy", waitHealthy: true, wantMsg: "container is running but health is unhealthy"},
+		{name: "running no healthcheck", status: "running", waitHealthy: true, wantSuccess: true, wantMsg: "OK"},
+		{name: "stopped", status: "exited", wantMsg: "container is exited"},
+		{name: "starting", status: "running", healthStatus: "starting", waitHealthy: true, wantMsg: "container is running but health is starting"},
+		{name: "restarts within max", status: "running", restarts: 2, maxRestarts: &two, wantSuccess: true, wantMsg: "OK"},
+		{name: "restarts exceed max", status: "running", restarts: 5, maxRestarts: &two, wantMsg: "container restarted 5 times (max 2)"},
+		{name: "restarts ignored without max", status: "running", restarts: 5, wantSuccess: true, wantMsg: "OK"},
+		{name: "oom killed", status: "running", restarts: 1, oomKilled: true, failOnOOM: true, wantMsg: "container was OOM killed (1 restarts)"},
+		{name: "oom killed ignored", status: "running", oomKilled: true, wantSuccess: true, wantMsg: "OK"},
+		{name: "stopped and oom killed", status: "exited", oomKilled: true, failOnOOM: true, wantMsg: "container is exited"},
 	}
 
 	for _, tt := range tests {
@@ -140,8 +194,10 @@ func TestDockerProbe_Check_ContainerStatuses(t *testing.T) {
 			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				w.Header().Set("Content-Type", "application/json")
 				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"RestartCount": tt.restarts,
 					"State": map[string]interface{}{
-						"Status": tt.status,
+						"Status":    tt.status,
+						"OOMKilled": tt.oomKilled,
 						"Health": map[string]interface{}{
 							"Status": tt.healthStatus,
 						},
@@ -162,8 +218,10 @@ func TestDockerProbe_Check_ContainerStatuses(t *testing.T) {
 						Port: port,
 					},
 				},
-				SocketName: "test",
-				Healthy:    tt.waitHealthy,
+				SocketName:  "test",
+				Healthy:     tt.waitHealthy,
+				MaxRestarts: tt.maxRestarts,
+				FailOnOOM:   tt.failOnOOM,
 			}
 
 			result, err := probe.Check(context.Background(), "test")
@@ -554,3 +612,108 @@ func TestDockerProbe_SetTimeout(t *testing.T) {
 		t.Errorf("Expected timeout 10s, got %v", p.Timeout)
 	}
 }
+
+func TestDockerProbe_Check_QuorumMode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := "running"
+		if strings.Contains(r.URL.Path, "/web2/") {
+			status = "exited"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"State": map[string]interface{}{"Status": status},
+		})
+	}))
+	defer server.Close()
+
+	host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	port := 0
+	fmt.Sscanf(portStr, "%d", &port)
+
+	probe := &DockerProbe{
+		Sockets:    map[string]config.DockerSocketConfig{"proxy": {Host: host, Port: port, Protocol: "http"}},
+		SocketName: "proxy",
+	}
+	probe.SetTargetMode(TargetModeQuorum)
+
+	probe.SetQuorum(2)
+	res, _ := probe.Check(context.Background(), "web1,web2,web3")
+	if !res.Success || res.Message != "2/3 up (need 2)" {
+		t.Errorf("expected quorum success, got %v: %s", res.Success, res.Message)
+	}
+
+	probe.SetQuorum(3)
+	res, _ = probe.Check(context.Background(), "web1,web2,web3")
+	if res.Success || res.Target != "web2" {
+		t.Errorf("expected quorum failure on web2, got %v (%s): %s", res.Success, res.Target, res.Message)
+	}
+}
+
+func TestDockerProbe_Check_Stats(t *testing.T) {
+	startServer := func(t *testing.T, statsDelay time.Duration, statsStatus int) config.DockerSocketConfig {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasSuffix(r.URL.Path, "/stats") {
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"State": map[string]interface{}{"Status": "running"},
+				})
+				return
+			}
+			if r.URL.Query().Get("stream") != "false" {
+				t.Errorf("expected a single stats sample, got query %q", r.URL.RawQuery)
+			}
+			select {
+			case <-time.After(statsDelay):
+			case <-r.Context().Done():
+				return
+			}
+			if statsStatus != 0 {
+				w.WriteHeader(statsStatus)
+				return
+			}
+			_, _ = w.Write([]byte(`{
+				"cpu_stats": {"cpu_usage": {"total_usage": 300000000}, "system_cpu_usage": 20000000000, "online_cpus": 4},
+				"precpu_stats": {"cpu_usage": {"total_usage": 100000000}, "system_cpu_usage": 10000000000},
+				"memory_stats": {"usage": 73400320, "stats": {"inactive_file": 20971520}}
+			}`))
+		}))
+		t.Cleanup(server.Close)
+
+		host, portStr, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+		port := 0
+		fmt.Sscanf(portStr, "%d", &port)
+		return config.DockerSocketConfig{Host: host, Port: port, Protocol: "http"}
+	}
+
+	tests := []struct {
+		name       string
+		stats      bool
+		delay      time.Duration
+		status     int
+		wantMsg    string
+		maxElapsed time.Duration
+	}{
+		{name: "disabled", wantMsg: "OK"},
+		{name: "usage", stats: true, wantMsg: "OK, cpu 8.0%, mem 50.0 MiB"},
+		{name: "slow stats", stats: true, delay: time.Second, wantMsg: "OK, stats unavailable (skipped after 100ms)", maxElapsed: 150 * time.Millisecond},
+		{name: "stats error", stats: true, status: http.StatusInternalServerError, wantMsg: "OK, stats unavailable (docker api returned status 500)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := &DockerProbe{
+				Sockets:    map[string]config.DockerSocketConfig{"proxy": startServer(t, tt.delay, tt.status)},
+				SocketName: "proxy",
+				Stats:      tt.stats,
+				Timeout:    200 * time.Millisecond,
+			}
+
+			start := time.Now()
+			res, _ := probe.Check(context.Background(), "web")
+			if !res.Success || res.Message != tt.wantMsg {
+				t.Errorf("expected success with %q, got %v: %s", tt.wantMsg, res.Success, res.Message)
+			}
+			if tt.maxElapsed > 0 && time.Since(start) > tt.maxElapsed {
+				t.Errorf("expected slow stats to be skipped within %v, took %v", tt.maxElapsed, time.Since(start))
+			}
+		})
+	}
+}
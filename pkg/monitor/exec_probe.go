@@ -0,0 +1,179 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"probixel/pkg/config"
+)
+
+// execOutputLimit caps how much of a command's output is quoted in a message
+const execOutputLimit = 200
+
+type ExecProbe struct {
+	Command     string
+	Args        []string
+	ExitCode    int    // Exit code that counts as success, 0 by default
+	ExitCodes   string // Optional list/ranges of successful exit codes, e.g. "0-1, 3", overrides ExitCode
+	Expect      string // Optional, stdout must contain it
+	ExpectRegex bool   // Match Expect as a regular expression instead
+	Timeout     time.Duration
+
+	exitCodes config.IntRanges
+	match     func([]byte) bool
+}
+
+func (p *ExecProbe) Name() string {
+	return MonitorTypeExec
+}
+
+func (p *ExecProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeExec,
+		Description: "Runs a local command and checks its exit code and output",
+		Fields:      []string{"timeout", "exec.command", "exec.args", "exec.exit_code", "exec.exit_codes", "exec.expect", "exec.expect_regex"},
+	}
+}
+
+// Initialize parses ExitCodes and compiles Expect, so a malformed list or
+// expression is reported before the first check rather than silently never
+// matching.
+func (p *ExecProbe) Initialize() error {
+	if p.ExitCodes != "" {
+		ranges, err := config.ParseIntRanges(p.ExitCodes)
+		if err != nil {
+			return fmt.Errorf("invalid exit_codes: %w", err)
+		}
+		p.exitCodes = ranges
+	}
+	if p.Expect != "" {
+		match, err := expectMatcher(p.Expect, p.ExpectRegex)
+		if err != nil {
+			return fmt.Errorf("invalid expect: %w", err)
+		}
+		p.match = match
+	}
+	return nil
+}
+
+func (p *ExecProbe) SetTargetMode(mode string) {
+	// Not used for Exec probe, but kept for consistency with other probes
+	_ = mode
+}
+
+func (p *ExecProbe) Check(ctx context.Context, target string) (Result, error) {
+	start := time.Now()
+	fail := func(format string, args ...any) (Result, error) {
+		return Result{
+			Success:   false,
+			Message:   fmt.Sprintf(format, args...),
+			Target:    p.Command,
+			Timestamp: start,
+		}, nil
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctxCmd, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := execCommand(ctxCmd, p.Command, p.Args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Don't wait forever on children that inherited the output pipes
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	duration := time.Since(start)
+	if ctxCmd.Err() != nil {
+		return fail("command timed out after %v", timeout)
+	}
+
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return fail("failed to run command: %v", err)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+	if !p.exitCodeOK(exitCode) {
+		// Scripts often report the failure on stdout only
+		out := lastLine(stderr.String())
+		if out == "" {
+			out = lastLine(stdout.String())
+		}
+		return fail("%s", withOutput(fmt.Sprintf("exit code %d, expected %s", exitCode, p.expectedExitCodes()), out))
+	}
+
+	if p.Expect != "" {
+		match := p.match
+		if match == nil {
+			if match, err = expectMatcher(p.Expect, p.ExpectRegex); err != nil {
+				return fail("invalid expect: %v", err)
+			}
+		}
+		if !match(stdout.Bytes()) {
+			verb := "contain"
+			if p.ExpectRegex {
+				verb = "match"
+			}
+			return fail("%s", withOutput(fmt.Sprintf("output does not %s %q", verb, p.Expect), lastLine(stdout.String())))
+		}
+	}
+
+	return Result{
+		Success:   true,
+		Duration:  duration,
+		Message:   withOutput(fmt.Sprintf("OK (exit code %d)", exitCode), lastLine(stdout.String())),
+		Target:    p.Command,
+		Timestamp: start,
+	}, nil
+}
+
+// exitCodeOK reports whether code counts as success.
+func (p *ExecProbe) exitCodeOK(code int) bool {
+	if p.exitCodes != nil {
+		return p.exitCodes.Contains(code)
+	}
+	return code == p.ExitCode
+}
+
+// expectedExitCodes describes the successful exit codes for failure messages.
+func (p *ExecProbe) expectedExitCodes() string {
+	if p.exitCodes != nil {
+		return p.exitCodes.String()
+	}
+	return strconv.Itoa(p.ExitCode)
+}
+
+// lastLine returns the last non-empty line of s, truncated to execOutputLimit.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	line := strings.TrimSpace(lines[len(lines)-1])
+	if len(line) > execOutputLimit {
+		line = line[:execOutputLimit] + "..."
+	}
+	return line
+}
+
+// withOutput appends a line of the command's output to msg, if there is one.
+func withOutput(msg, out string) string {
+	if out == "" {
+		return msg
+	}
+	return msg + ": " + out
+}
+
+func (p *ExecProbe) SetTimeout(timeout time.Duration) {
+	p.Timeout = timeout
+}
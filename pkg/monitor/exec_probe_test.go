@@ -0,0 +1,189 @@
+package monitor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecProbe_Check(t *testing.T) {
+	tests := []struct {
+		name        string
+		probe       *ExecProbe
+		wantSuccess bool
+		wantMessage string
+	}{
+		{
+			name:        "exit zero",
+			probe:       &ExecProbe{Command: "sh", Args: []string{"-c", "exit 0"}},
+			wantSuccess: true,
+			wantMessage: "OK (exit code 0)",
+		},
+		{
+			name:        "non-zero exit",
+			probe:       &ExecProbe{Command: "sh", Args: []string{"-c", "echo first >&2; echo vendor check failed >&2; exit 3"}},
+			wantSuccess: false,
+			wantMessage: "exit code 3, expected 0: vendor check failed",
+		},
+		{
+			name:        "configured exit code",
+			probe:       &ExecProbe{Command: "sh", Args: []string{"-c", "exit 2"}, ExitCode: 2},
+			wantSuccess: true,
+			wantMessage: "OK (exit code 2)",
+		},
+		{
+			name:        "expected output",
+			probe:       &ExecProbe{Command: "echo", Args: []string{"status: healthy"}, Expect: "healthy"},
+			wantSuccess: true,
+			wantMessage: "OK (exit code 0)",
+		},
+		{
+			name:        "missing expected output",
+			probe:       &ExecProbe{Command: "echo", Args: []string{"status: degraded"}, Expect: "healthy"},
+			wantSuccess: false,
+			wantMessage: `output does not contain "healthy"`,
+		},
+		{
+			name:        "expect ignores stderr",
+			probe:       &ExecProbe{Command: "sh", Args: []string{"-c", "echo healthy >&2"}, Expect: "healthy"},
+			wantSuccess: false,
+			wantMessage: `output does not contain "healthy"`,
+		},
+		{
+			name:        "unknown command",
+			probe:       &ExecProbe{Command: "probixel-no-such-command"},
+			wantSuccess: false,
+			wantMessage: "failed to run command",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.probe.Check(context.Background(), "")
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess || !strings.HasPrefix(res.Message, tt.wantMessage) {
+				t.Errorf("got success=%v message=%q, want success=%v message %q", res.Success, res.Message, tt.wantSuccess, tt.wantMessage)
+			}
+			if res.Target != tt.probe.Command {
+				t.Errorf("Target = %q, want %q", res.Target, tt.probe.Command)
+			}
+		})
+	}
+}
+
+func TestExecProbe_ExitCodes(t *testing.T) {
+	p := &ExecProbe{Command: "sh", Args: []string{"-c", "exit 3"}, ExitCodes: "0-1, 3"}
+	if err := p.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	res, _ := p.Check(context.Background(), "")
+	if !res.Success || res.Message != "OK (exit code 3)" {
+		t.Errorf("Expected exit code 3 to succeed, got %q", res.Message)
+	}
+
+	p.Args = []string{"-c", "exit 2"}
+	res, _ = p.Check(context.Background(), "")
+	if res.Success || res.Message != "exit code 2, expected 0-1, 3" {
+		t.Errorf("Expected exit code 2 to fail, got %q", res.Message)
+	}
+
+	p = &ExecProbe{Command: "true", ExitCodes: "0, ok"}
+	if err := p.Initialize(); err == nil || !strings.Contains(err.Error(), `invalid exit_codes: invalid value "ok"`) {
+		t.Errorf("Expected invalid exit_codes error, got %v", err)
+	}
+}
+
+func TestExecProbe_HelperProcess(t *testing.T) {
+	oldExec := execCommand
+	execCommand = fakeExecCommand
+	defer func() { execCommand = oldExec }()
+
+	tests := []struct {
+		name        string
+		probe       ExecProbe
+		wantSuccess bool
+		wantMessage string
+	}{
+		{
+			name:        "stdout in message",
+			probe:       ExecProbe{Args: []string{"fresh"}},
+			wantSuccess: true,
+			wantMessage: "OK (exit code 0): backup ok, 3h old",
+		},
+		{
+			name:        "failure reported on stdout",
+			probe:       ExecProbe{Args: []string{"stale"}},
+			wantMessage: "exit code 1, expected 0: backup is 30h old",
+		},
+		{
+			name:        "truncated output",
+			probe:       ExecProbe{Args: []string{"verbose"}},
+			wantSuccess: true,
+			wantMessage: "OK (exit code 0): " + strings.Repeat("x", execOutputLimit) + "...",
+		},
+		{
+			name:        "regex matched",
+			probe:       ExecProbe{Args: []string{"fresh"}, Expect: `ok, \d+h old`, ExpectRegex: true},
+			wantSuccess: true,
+			wantMessage: "OK (exit code 0): backup ok, 3h old",
+		},
+		{
+			name:        "regex not matched",
+			probe:       ExecProbe{Args: []string{"fresh"}, Expect: `^backup ok`, ExpectRegex: true},
+			wantMessage: `output does not match "^backup ok": backup ok, 3h old`,
+		},
+		{
+			name:        "regex is matched as such",
+			probe:       ExecProbe{Args: []string{"fresh"}, Expect: `(?m)^backup ok`, ExpectRegex: true},
+			wantSuccess: true,
+			wantMessage: "OK (exit code 0): backup ok, 3h old",
+		},
+		{
+			name:        "substring with regex characters",
+			probe:       ExecProbe{Args: []string{"fresh"}, Expect: `\d+h`},
+			wantMessage: `output does not contain "\\d+h": backup ok, 3h old`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.probe
+			p.Command = "check-backup"
+			if err := p.Initialize(); err != nil {
+				t.Fatalf("Initialize failed: %v", err)
+			}
+			res, err := p.Check(context.Background(), "")
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess || res.Message != tt.wantMessage {
+				t.Errorf("got success=%v message=%q, want success=%v message %q", res.Success, res.Message, tt.wantSuccess, tt.wantMessage)
+			}
+		})
+	}
+
+	p := &ExecProbe{Command: "check-backup", Expect: "(", ExpectRegex: true}
+	if err := p.Initialize(); err == nil || !strings.Contains(err.Error(), "invalid expect") {
+		t.Errorf("Expected invalid expect error, got %v", err)
+	}
+}
+
+func TestExecProbe_Timeout(t *testing.T) {
+	p := &ExecProbe{Command: "sleep", Args: []string{"10"}}
+	p.SetTimeout(100 * time.Millisecond)
+
+	start := time.Now()
+	res, err := p.Check(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if res.Success || res.Message != "command timed out after 100ms" {
+		t.Errorf("expected a timeout failure, got %+v", res)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("command was not killed at the timeout, took %v", elapsed)
+	}
+}
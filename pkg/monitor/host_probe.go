@@ -2,17 +2,65 @@ package monitor
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// errHostMetricUnsupported is returned by the host metric readers on
+// platforms where the metric can't be read. The threshold is then skipped.
+var errHostMetricUnsupported = errors.New("not supported on " + runtime.GOOS)
+
+// defaultCPUSample is how long CPU usage is measured over by default.
+const defaultCPUSample = time.Second
+
 type HostProbe struct {
+	MaxLoad            float64            // Maximum 1-minute load average, 0 to disable
+	MaxCPUPercent      float64            // Maximum CPU in use over CPUSample, 0 to disable
+	CPUSample          time.Duration      // Defaults to 1s
+	MinDiskFreePercent float64            // Minimum free space on DiskPath, 0 to disable
+	DiskPath           string             // Defaults to "/"
+	MinDiskFree        map[string]float64 // Minimum free space per mount path
+	MaxMemoryPercent   float64            // Maximum memory in use, 0 to disable
+	Timeout            time.Duration
+
+	// Metric readers, they allow mocking the local system in tests. If nil,
+	// the OS is queried.
+	Load       func(ctx context.Context) (float64, error)
+	CPUUsed    func(ctx context.Context, sample time.Duration) (float64, error)
+	DiskFree   func(ctx context.Context, path string) (float64, error)
+	MemoryUsed func(ctx context.Context) (float64, error)
 }
 
 func (p *HostProbe) Name() string {
 	return MonitorTypeHost
 }
 
+func (p *HostProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeHost,
+		Description: "Reports that the agent is running, and optionally checks local load, disk and memory",
+		Fields: []string{
+			"targets",
+			"target_mode",
+			"host.max_load",
+			"host.max_cpu_percent",
+			"host.cpu_sample",
+			"host.min_disk_free_percent",
+			"host.disk_path",
+			"host.min_disk_free",
+			"host.max_memory_percent",
+		},
+	}
+}
+
 func (p *HostProbe) SetTargetMode(mode string) {
 	// Not used for Host probe, but kept for consistency with other probes
 	_ = mode
@@ -20,15 +68,268 @@ func (p *HostProbe) SetTargetMode(mode string) {
 
 func (p *HostProbe) Check(ctx context.Context, target string) (Result, error) {
 	target = strings.TrimSpace(target)
-	return Result{
-		Success:   true,
-		Duration:  time.Millisecond,
-		Message:   "Host heartbeat",
+	start := time.Now()
+
+	if p.MaxLoad == 0 && p.MaxCPUPercent == 0 && p.MinDiskFreePercent == 0 && len(p.MinDiskFree) == 0 && p.MaxMemoryPercent == 0 {
+		return Result{
+			Success:   true,
+			Duration:  time.Millisecond,
+			Message:   "Host heartbeat",
+			Target:    target,
+			Timestamp: start,
+		}, nil
+	}
+
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	var metrics, breaches, skipped []string
+	measure := func(name string, err error, metric string, breached bool, threshold string) {
+		switch {
+		case errors.Is(err, errHostMetricUnsupported):
+			skipped = append(skipped, fmt.Sprintf("%s %v", name, err))
+		case err != nil:
+			breaches = append(breaches, fmt.Sprintf("failed to read %s: %v", name, err))
+		case breached:
+			breaches = append(breaches, metric+", "+threshold)
+		default:
+			metrics = append(metrics, metric)
+		}
+	}
+
+	if p.MaxLoad > 0 {
+		load, err := p.readLoad(ctx)
+		measure("load", err, fmt.Sprintf("load %.2f", load), load > p.MaxLoad, fmt.Sprintf("above max_load %.2f", p.MaxLoad))
+	}
+	if p.MaxCPUPercent > 0 {
+		sample := p.CPUSample
+		if sample <= 0 {
+			sample = defaultCPUSample
+		}
+		used, err := p.readCPUUsed(ctx, sample)
+		measure("cpu", err, fmt.Sprintf("cpu %.1f%%", used), used > p.MaxCPUPercent, fmt.Sprintf("above max_cpu_percent %.1f%%", p.MaxCPUPercent))
+	}
+	if p.MinDiskFreePercent > 0 {
+		path := p.DiskPath
+		if path == "" {
+			path = "/"
+		}
+		free, err := p.readDiskFree(ctx, path)
+		measure("disk free", err, fmt.Sprintf("%s free %.1f%%", path, free), free < p.MinDiskFreePercent, fmt.Sprintf("below min_disk_free_percent %.1f%%", p.MinDiskFreePercent))
+	}
+	for _, path := range slices.Sorted(maps.Keys(p.MinDiskFree)) {
+		minFree := p.MinDiskFree[path]
+		free, err := p.readDiskFree(ctx, path)
+		measure(path+" disk free", err, fmt.Sprintf("%s free %.1f%%", path, free), free < minFree, fmt.Sprintf("below min_disk_free %.1f%%", minFree))
+	}
+	if p.MaxMemoryPercent > 0 {
+		used, err := p.readMemoryUsed(ctx)
+		measure("memory", err, fmt.Sprintf("memory %.1f%%", used), used > p.MaxMemoryPercent, fmt.Sprintf("above max_memory_percent %.1f%%", p.MaxMemoryPercent))
+	}
+
+	res := Result{
+		Success:   len(breaches) == 0,
+		Duration:  time.Since(start),
 		Target:    target,
-		Timestamp: time.Now(),
-	}, nil
+		Timestamp: start,
+	}
+	if !res.Success {
+		res.Duration = 0
+		res.Message = strings.Join(breaches, "; ")
+		return res, nil
+	}
+	res.Message = "Host OK"
+	if len(metrics) > 0 {
+		res.Message = fmt.Sprintf("Host OK (%s)", strings.Join(metrics, ", "))
+	}
+	if len(skipped) > 0 {
+		res.Message = withNote(res.Message, strings.Join(skipped, ", "))
+	}
+	return res, nil
+}
+
+func (p *HostProbe) readLoad(ctx context.Context) (float64, error) {
+	if p.Load != nil {
+		return p.Load(ctx)
+	}
+	switch runtime.GOOS {
+	case "linux":
+		data, err := os.ReadFile("/proc/loadavg")
+		if err != nil {
+			return 0, err
+		}
+		return parseLoadAvg(string(data))
+	case "darwin", "freebsd":
+		out, err := exec.CommandContext(ctx, "sysctl", "-n", "vm.loadavg").Output()
+		if err != nil {
+			return 0, err
+		}
+		return parseLoadAvg(strings.Trim(strings.TrimSpace(string(out)), "{}"))
+	default:
+		return 0, errHostMetricUnsupported
+	}
+}
+
+func (p *HostProbe) readCPUUsed(ctx context.Context, sample time.Duration) (float64, error) {
+	if p.CPUUsed != nil {
+		return p.CPUUsed(ctx, sample)
+	}
+	if runtime.GOOS != "linux" {
+		return 0, errHostMetricUnsupported
+	}
+	read := func() (cpuTimes, error) {
+		data, err := os.ReadFile("/proc/stat")
+		if err != nil {
+			return cpuTimes{}, err
+		}
+		return parseProcStat(string(data))
+	}
+	before, err := read()
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-time.After(sample):
+	}
+	after, err := read()
+	if err != nil {
+		return 0, err
+	}
+	return before.usedPercent(after), nil
+}
+
+// cpuTimes are the cumulative CPU times of all CPUs, in clock ticks.
+type cpuTimes struct {
+	total, idle float64
+}
+
+// usedPercent returns the share of the time between t and later the CPUs
+// were busy.
+func (t cpuTimes) usedPercent(later cpuTimes) float64 {
+	total := later.total - t.total
+	if total <= 0 {
+		return 0
+	}
+	return (total - (later.idle - t.idle)) / total * 100
+}
+
+// parseProcStat returns the CPU times of the aggregate "cpu" line of
+// /proc/stat, counting iowait as idle. Guest time is already part of user.
+func parseProcStat(s string) (cpuTimes, error) {
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		var t cpuTimes
+		// user nice system idle iowait irq softirq steal
+		for i, field := range fields[1:min(len(fields), 9)] {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return cpuTimes{}, fmt.Errorf("unexpected /proc/stat cpu line %q", line)
+			}
+			t.total += v
+			if i == 3 || i == 4 {
+				t.idle += v
+			}
+		}
+		return t, nil
+	}
+	return cpuTimes{}, errors.New("cpu line missing from /proc/stat")
+}
+
+func (p *HostProbe) readDiskFree(ctx context.Context, path string) (float64, error) {
+	if p.DiskFree != nil {
+		return p.DiskFree(ctx, path)
+	}
+	if runtime.GOOS == "windows" {
+		return 0, errHostMetricUnsupported
+	}
+	out, err := exec.CommandContext(ctx, "df", "-Pk", path).Output()
+	if err != nil {
+		return 0, fmt.Errorf("df %s: %w", path, err)
+	}
+	return parseDiskFree(string(out))
+}
+
+func (p *HostProbe) readMemoryUsed(ctx context.Context) (float64, error) {
+	if p.MemoryUsed != nil {
+		return p.MemoryUsed(ctx)
+	}
+	if runtime.GOOS != "linux" {
+		return 0, errHostMetricUnsupported
+	}
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	return parseMemInfo(string(data))
+}
+
+// parseLoadAvg returns the 1-minute load average from /proc/loadavg or
+// "sysctl vm.loadavg" output.
+func parseLoadAvg(s string) (float64, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected load average %q", s)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// parseDiskFree returns the percentage of free space from POSIX "df -Pk"
+// output, computed like df's capacity column.
+func parseDiskFree(s string) (float64, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(lines) < 2 || len(fields) < 6 {
+		return 0, fmt.Errorf("unexpected df output %q", s)
+	}
+	used, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected df output %q", s)
+	}
+	avail, err := strconv.ParseFloat(fields[3], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected df output %q", s)
+	}
+	if used+avail == 0 {
+		return 0, fmt.Errorf("unexpected df output %q", s)
+	}
+	return avail / (used + avail) * 100, nil
+}
+
+// parseMemInfo returns the percentage of memory in use from /proc/meminfo,
+// counting MemAvailable as free.
+func parseMemInfo(s string) (float64, error) {
+	var total, available float64
+	var haveTotal, haveAvailable bool
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total, haveTotal = v, true
+		case "MemAvailable:":
+			available, haveAvailable = v, true
+		}
+	}
+	if !haveTotal || !haveAvailable || total == 0 {
+		return 0, errors.New("MemTotal or MemAvailable missing from /proc/meminfo")
+	}
+	return (total - available) / total * 100, nil
 }
 
 func (p *HostProbe) SetTimeout(timeout time.Duration) {
-	// Not used for Host probe
+	p.Timeout = timeout
 }
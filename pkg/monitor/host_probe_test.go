@@ -2,6 +2,8 @@ package monitor
 
 import (
 	"context"
+	"errors"
+	"runtime"
 	"testing"
 	"time"
 )
@@ -31,5 +33,160 @@ func TestHostProbe_SetTargetMode(t *testing.T) {
 func TestHostProbe_SetTimeout(t *testing.T) {
 	p := &HostProbe{}
 	p.SetTimeout(10 * time.Second)
-	// HostProbe's SetTimeout is a no-op, but we test it for coverage
+	if p.Timeout != 10*time.Second {
+		t.Errorf("Timeout = %v, want 10s", p.Timeout)
+	}
+}
+
+func TestHostProbe_Thresholds(t *testing.T) {
+	metric := func(v float64, err error) func(ctx context.Context) (float64, error) {
+		return func(ctx context.Context) (float64, error) { return v, err }
+	}
+
+	tests := []struct {
+		name        string
+		probe       *HostProbe
+		wantSuccess bool
+		wantMessage string
+	}{
+		{
+			name:        "within thresholds",
+			probe:       &HostProbe{MaxLoad: 4, MaxMemoryPercent: 90, Load: metric(0.5, nil), MemoryUsed: metric(42, nil)},
+			wantSuccess: true,
+			wantMessage: "Host OK (load 0.50, memory 42.0%)",
+		},
+		{
+			name: "disk free breached",
+			probe: &HostProbe{MinDiskFreePercent: 10, DiskPath: "/data", DiskFree: func(ctx context.Context, path string) (float64, error) {
+				if path != "/data" {
+					t.Errorf("DiskFree path = %q, want /data", path)
+				}
+				return 3.5, nil
+			}},
+			wantSuccess: false,
+			wantMessage: "/data free 3.5%, below min_disk_free_percent 10.0%",
+		},
+		{
+			name:        "several breaches",
+			probe:       &HostProbe{MaxLoad: 2, MaxMemoryPercent: 80, Load: metric(3.25, nil), MemoryUsed: metric(95, nil)},
+			wantSuccess: false,
+			wantMessage: "load 3.25, above max_load 2.00; memory 95.0%, above max_memory_percent 80.0%",
+		},
+		{
+			name: "cpu sampled",
+			probe: &HostProbe{MaxCPUPercent: 90, CPUSample: 200 * time.Millisecond, CPUUsed: func(ctx context.Context, sample time.Duration) (float64, error) {
+				if sample != 200*time.Millisecond {
+					t.Errorf("CPUUsed sample = %v, want 200ms", sample)
+				}
+				return 12.5, nil
+			}},
+			wantSuccess: true,
+			wantMessage: "Host OK (cpu 12.5%)",
+		},
+		{
+			name: "cpu breached with the default sample",
+			probe: &HostProbe{MaxCPUPercent: 90, CPUUsed: func(ctx context.Context, sample time.Duration) (float64, error) {
+				if sample != defaultCPUSample {
+					t.Errorf("CPUUsed sample = %v, want %v", sample, defaultCPUSample)
+				}
+				return 97, nil
+			}},
+			wantSuccess: false,
+			wantMessage: "cpu 97.0%, above max_cpu_percent 90.0%",
+		},
+		{
+			name: "disk free per mount",
+			probe: &HostProbe{MinDiskFree: map[string]float64{"/var": 20, "/": 10}, DiskFree: func(ctx context.Context, path string) (float64, error) {
+				return map[string]float64{"/": 50, "/var": 15}[path], nil
+			}},
+			wantSuccess: false,
+			wantMessage: "/var free 15.0%, below min_disk_free 20.0%",
+		},
+		{
+			name: "disk free per mount within thresholds",
+			probe: &HostProbe{MinDiskFree: map[string]float64{"/var": 20, "/": 10}, DiskFree: func(ctx context.Context, path string) (float64, error) {
+				return map[string]float64{"/": 50, "/var": 25}[path], nil
+			}},
+			wantSuccess: true,
+			wantMessage: "Host OK (/ free 50.0%, /var free 25.0%)",
+		},
+		{
+			name:        "read error",
+			probe:       &HostProbe{MaxLoad: 2, Load: metric(0, errors.New("permission denied"))},
+			wantSuccess: false,
+			wantMessage: "failed to read load: permission denied",
+		},
+		{
+			name:        "unsupported metric is skipped",
+			probe:       &HostProbe{MaxLoad: 2, MaxMemoryPercent: 80, Load: metric(1, nil), MemoryUsed: metric(0, errHostMetricUnsupported)},
+			wantSuccess: true,
+			wantMessage: "Host OK (load 1.00) (memory " + errHostMetricUnsupported.Error() + ")",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.probe.Check(context.Background(), "")
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess || res.Message != tt.wantMessage {
+				t.Errorf("got success=%v message=%q, want success=%v message=%q", res.Success, res.Message, tt.wantSuccess, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestHostProbe_Parsers(t *testing.T) {
+	load, err := parseLoadAvg("0.52 0.58 0.59 1/123 4567\n")
+	if err != nil || load != 0.52 {
+		t.Errorf("parseLoadAvg = %v, %v, want 0.52", load, err)
+	}
+	if _, err := parseLoadAvg(""); err == nil {
+		t.Error("expected error for empty load average")
+	}
+
+	df := "Filesystem     1024-blocks    Used Available Capacity Mounted on\n/dev/sda1         1000000  750000    250000      75% /\n"
+	free, err := parseDiskFree(df)
+	if err != nil || free != 25 {
+		t.Errorf("parseDiskFree = %v, %v, want 25", free, err)
+	}
+	if _, err := parseDiskFree("garbage"); err == nil {
+		t.Error("expected error for unexpected df output")
+	}
+
+	meminfo := "MemTotal:       16000000 kB\nMemFree:         1000000 kB\nMemAvailable:    4000000 kB\n"
+	used, err := parseMemInfo(meminfo)
+	if err != nil || used != 75 {
+		t.Errorf("parseMemInfo = %v, %v, want 75", used, err)
+	}
+	if _, err := parseMemInfo("MemTotal: 100 kB\n"); err == nil {
+		t.Error("expected error when MemAvailable is missing")
+	}
+
+	before, err := parseProcStat("cpu  100 0 100 700 100 0 0 0 0 0\ncpu0 50 0 50 350 50 0 0 0 0 0\n")
+	if err != nil {
+		t.Fatalf("parseProcStat failed: %v", err)
+	}
+	after, _ := parseProcStat("cpu  250 0 150 800 200 0 0 0 0 0\n")
+	if used := before.usedPercent(after); used != 50 {
+		t.Errorf("usedPercent = %v, want 50", used)
+	}
+	if _, err := parseProcStat("intr 1 2 3\n"); err == nil {
+		t.Error("expected error when the cpu line is missing")
+	}
+}
+
+func TestHostProbe_LocalSystem(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads /proc")
+	}
+	p := &HostProbe{MaxLoad: 1e6, MaxCPUPercent: 100, CPUSample: 50 * time.Millisecond, MinDiskFreePercent: 0.001, MaxMemoryPercent: 100}
+	res, err := p.Check(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Success {
+		t.Errorf("expected the local system to be within thresholds, got %s", res.Message)
+	}
 }
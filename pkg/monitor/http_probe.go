@@ -1,15 +1,23 @@
 package monitor
 
 import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"probixel/pkg/config"
@@ -18,16 +26,71 @@ import (
 	"github.com/tidwall/gjson"
 )
 
+// defaultMaxRedirects is how many redirects are followed without
+// http.max_redirects, as with Go's default client.
+const defaultMaxRedirects = 10
+
+// defaultMaxBodyBytes bounds the decoded response body read to match
+// expectations without http.max_body_bytes.
+const defaultMaxBodyBytes = 10 << 20
+
+// UserAgent is sent by the HTTP probe unless http.headers sets User-Agent,
+// instead of Go's generic default that some WAFs block as a bot.
+const UserAgent = "probixel"
+
 type HTTPProbe struct {
 	AcceptedStatusCodes string // Configured range/list, e.g. "200-299, 404"
 	InsecureSkipVerify  bool   // Skip TLS verification
+	ClientCert          string // mTLS client certificate, file path or inline PEM
+	ClientKey           string // mTLS client key, file path or inline PEM
+	CACert              string // CA bundle used to verify the server, file path or inline PEM
+	Proxy               string // Proxy URL (http, https or socks5); empty for a direct connection
+	UseEnvProxy         bool   // Use the environment proxy settings when Proxy is empty
+	UnixSocket          string // If set, requests are sent over this Unix domain socket
+	DisableKeepAlive    bool   // Open a new connection for every check instead of reusing one
+	DisableRedirects    bool   // Evaluate 3xx responses instead of following them
+	MaxRedirects        int    // Redirects followed before failing, 0 for defaultMaxRedirects
+	MaxBodyBytes        int64  // Decoded body read to match expectations, 0 for defaultMaxBodyBytes
+	FailOnOversizedBody bool   // Fail when the body exceeds MaxBodyBytes instead of matching what was read
 	MatchData           *config.MatchDataConfig
 	Method              string            // HTTP method
+	Body                string            // Request body sent with POST, PUT and PATCH, {%timestamp%} is replaced
 	Headers             map[string]string // HTTP headers for the probe itself
 	ExpiryThreshold     time.Duration     // Threshold for TLS expiry check
 	Timeout             time.Duration     // Timeout for HTTP requests
+	ConnectTimeout      time.Duration     // Bounds the dial, 0 to use Timeout
+	SourceAddress       string            // Local IP direct connections are made from, empty for the OS default
+	Family              string            // FamilyIPv4 or FamilyIPv6 to connect over that family only, empty for either
+	DNSCache            *DNSCache         // Optional, reuses resolutions of direct connections within its TTL
+	NameResolver        *net.Resolver     // Optional, resolves hostnames instead of the system resolver
 	DialContext         func(ctx context.Context, network, address string) (net.Conn, error)
+	URLTemplate         string // If set, each target is substituted for {%target%} in it and requested in turn
+	targetMode          string
 	tunnel              tunnels.Tunnel
+
+	// The client is kept across checks so connections are reused. It is
+	// rebuilt whenever the settings it was built from change.
+	clientMu  sync.Mutex
+	client    *http.Client
+	clientKey httpClientKey
+}
+
+// httpClientKey holds the settings an HTTPProbe client is built from.
+type httpClientKey struct {
+	insecureSkipVerify bool
+	clientCert         string
+	clientKey          string
+	caCert             string
+	proxy              string
+	useEnvProxy        bool
+	unixSocket         string
+	disableKeepAlive   bool
+	disableRedirects   bool
+	maxRedirects       int
+	timeout            time.Duration
+	connectTimeout     time.Duration
+	sourceAddress      string
+	family             string
 }
 
 func (p *HTTPProbe) SetTunnel(t tunnels.Tunnel) {
@@ -38,9 +101,44 @@ func (p *HTTPProbe) Name() string {
 	return MonitorTypeHTTP
 }
 
+func (p *HTTPProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeHTTP,
+		Description: "Requests a URL and checks the status code, body and certificate",
+		Fields: []string{
+			"url",
+			"targets",
+			"target_mode",
+			"timeout",
+			"connect_timeout",
+			"tunnel",
+			"source_address",
+			"family",
+			"resolver",
+			"http.method",
+			"http.headers",
+			"http.accepted_status_codes",
+			"http.insecure_skip_verify",
+			"http.match_data",
+			"http.certificate_expiry",
+			"http.client_cert",
+			"http.client_key",
+			"http.ca_cert",
+			"http.proxy",
+			"http.use_env_proxy",
+			"http.unix_socket",
+			"http.disable_keepalive",
+			"http.body",
+			"http.follow_redirects",
+			"http.max_redirects",
+			"http.max_body_bytes",
+			"http.fail_on_oversized_body",
+		},
+	}
+}
+
 func (p *HTTPProbe) SetTargetMode(mode string) {
-	// Not used for HTTP probe, but kept for consistency with other probes
-	_ = mode
+	p.targetMode = mode
 }
 
 func (p *HTTPProbe) Check(ctx context.Context, target string) (Result, error) {
@@ -58,24 +156,87 @@ func (p *HTTPProbe) Check(ctx context.Context, target string) (Result, error) {
 		}, nil
 	}
 
-	// Create a custom client to handle timeouts and insecure skip verify if needed
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: p.InsecureSkipVerify}, //nolint:gosec // G402: Optional skip for untrusted endpoints
-		DialContext:     p.DialContext,
+	if p.URLTemplate != "" {
+		return p.checkTemplated(ctx, strings.Split(target, ","), start), nil
 	}
+	return p.checkURL(ctx, target, start), nil
+}
 
-	// Use configured timeout, default to 5 seconds
-	timeout := p.Timeout
-	if timeout == 0 {
-		timeout = 5 * time.Second
+// checkTemplated requests URLTemplate once per target and evaluates the
+// outcomes per the target mode. The deciding target, not the URL, is
+// reported as the result's target.
+func (p *HTTPProbe) checkTemplated(ctx context.Context, targets []string, startTotal time.Time) Result {
+	all := p.targetMode == TargetModeAll
+	var results []TargetResult
+	var last Result
+	var totalDuration time.Duration
+	successCount := 0
+
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+
+		var res Result
+		if err := checkExpired(ctx); err != nil {
+			res.Message = err.Error()
+		} else {
+			res = p.checkURL(ctx, strings.ReplaceAll(p.URLTemplate, "{%target%}", url.PathEscape(t)), time.Now())
+		}
+		results = append(results, TargetResult{Target: t, Success: res.Success, Duration: res.Duration, Message: res.Message})
+		res.Target = t
+		res.Timestamp = startTotal
+		res.TargetResults = results
+
+		// The first success decides in "any" mode, the first failure in "all"
+		// mode, and running out of time decides in both
+		if res.Success && !all {
+			res.Message = targetMessage(targets, t, res.Message)
+			return res
+		}
+		if !res.Success && (all || ctx.Err() != nil) {
+			res.Duration = 0
+			res.Message = targetMessage(targets, t, res.Message)
+			return res
+		}
+		if res.Success {
+			totalDuration += res.Duration
+			successCount++
+		}
+		last = res
 	}
 
-	client := &http.Client{
-		Transport: tr,
-		Timeout:   timeout,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return nil // Follow redirects by default
-		},
+	if all && successCount > 0 {
+		return Result{
+			Success:       true,
+			Duration:      totalDuration / time.Duration(successCount),
+			Message:       withNote(fmt.Sprintf("all %d targets OK", successCount), familyNote(p.Family)),
+			Timestamp:     startTotal,
+			TargetResults: results,
+		}
+	}
+	return Result{
+		Success:       false,
+		Duration:      0,
+		Message:       fmt.Sprintf("all targets failed, last error: target %s: %s", last.Target, last.Message),
+		Target:        last.Target,
+		Timestamp:     startTotal,
+		TargetResults: results,
+	}
+}
+
+// checkURL requests target and evaluates the response.
+func (p *HTTPProbe) checkURL(ctx context.Context, target string, start time.Time) Result {
+	client, err := p.httpClient()
+	if err != nil {
+		return Result{
+			Success:   false,
+			Duration:  time.Since(start),
+			Message:   err.Error(),
+			Target:    target,
+			Timestamp: start,
+		}
 	}
 
 	method := p.Method
@@ -83,70 +244,113 @@ func (p *HTTPProbe) Check(ctx context.Context, target string) (Result, error) {
 		method = "GET"
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	body := p.requestBody(method, start)
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
 	if err != nil {
 		return Result{
 			Success:   false,
 			Duration:  time.Since(start),
 			Message:   fmt.Sprintf("failed to create request: %v", err),
 			Timestamp: start,
-		}, nil
+		}
 	}
 
 	// Add headers
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json") // Unless http.headers sets it
+	}
 	for k, v := range p.Headers {
 		req.Header.Set(k, v)
 	}
 
+	// Whether a connection was obtained tells a connect timeout from a read timeout
+	connected := false
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { connected = true },
+	}))
+
 	resp, err := client.Do(req)
 	if err != nil {
 		return Result{
 			Success:   false,
 			Duration:  time.Since(start),
-			Message:   fmt.Sprintf("request failed: %v", err),
+			Message:   withNote(fmt.Sprintf("request failed: %v", timeoutError(err, connected)), familyNote(p.Family)),
 			Timestamp: start,
-		}, nil
+		}
 	}
-	defer func() { _ = resp.Body.Close() }()
+	defer func() {
+		// Drain what's left of the body so the connection can be reused
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+		_ = resp.Body.Close()
+	}()
 
 	duration := time.Since(start)
 
 	// Check status code against configuration
-	success := p.checkStatusCode(resp.StatusCode)
+	success, err := p.checkStatusCode(resp.StatusCode)
+	if err != nil {
+		return Result{
+			Success:   false,
+			Duration:  duration,
+			Message:   fmt.Sprintf("invalid http.accepted_status_codes: %v", err),
+			Target:    target,
+			Timestamp: start,
+		}
+	}
 	msg := fmt.Sprintf("HTTP %d", resp.StatusCode)
 
 	// If status code check passed and there are expectations, check them,
 	if success && p.MatchData != nil && len(p.MatchData.Expectations) > 0 {
-		body, err := io.ReadAll(resp.Body)
+		limit := p.MaxBodyBytes
+		if limit <= 0 {
+			limit = defaultMaxBodyBytes
+		}
+		body, truncated, err := readBody(resp, limit)
+		if err == nil && truncated && p.FailOnOversizedBody {
+			err = fmt.Errorf("body exceeds %d bytes", limit)
+		}
 		if err != nil {
 			return Result{
 				Success:   false,
 				Duration:  duration,
-				Message:   fmt.Sprintf("failed to read response body: %v", err),
+				Message:   fmt.Sprintf("failed to read response body: %v", timeoutError(err, true)),
 				Target:    target,
 				Timestamp: start,
-			}, nil
+			}
 		}
 
 		success, msg = p.evaluateExpectations(body, resp.Header)
+		if truncated {
+			msg += fmt.Sprintf(" (body truncated to %d bytes)", limit)
+		}
+	}
+
+	// Report where a followed redirect chain ended
+	if final := resp.Request.URL.String(); final != req.URL.String() {
+		msg += fmt.Sprintf(" (redirected to %s)", final)
+	}
+
+	var tlsInfo *TLSInfo
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		tlsInfo = newTLSInfo(resp.TLS.PeerCertificates[0])
 	}
 
 	// Check TLS expiry if HTTPS and threshold is set
-	if success && resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 && p.ExpiryThreshold > 0 {
-		cert := resp.TLS.PeerCertificates[0]
-		remaining := time.Until(cert.NotAfter)
+	if success && tlsInfo != nil && p.ExpiryThreshold > 0 {
+		remaining := time.Until(tlsInfo.NotAfter)
 
 		threshold := p.ExpiryThreshold
 		daysRemaining := int(remaining.Hours() / 24)
-		tlsMsg := fmt.Sprintf(" (TLS expires in %d days)", daysRemaining)
 		if remaining < 0 {
 			success = false
-			msg += fmt.Sprintf(" (TLS EXPIRED on %s)", cert.NotAfter.Format("2006-01-02"))
+			msg += fmt.Sprintf(" (TLS EXPIRED on %s: %s by %s)", tlsInfo.NotAfter.Format("2006-01-02"), tlsInfo.Subject, tlsInfo.Issuer)
 		} else if remaining < threshold {
 			success = false
-			msg += fmt.Sprintf(" (TLS expires soon: %d days remaining)", daysRemaining)
+			msg += fmt.Sprintf(" (TLS expires soon: %d days remaining: %s)", daysRemaining, tlsInfo)
 		} else {
-			msg += tlsMsg
+			msg += fmt.Sprintf(" (TLS expires in %d days: %s)", daysRemaining, tlsInfo)
 		}
 	}
 
@@ -159,10 +363,188 @@ func (p *HTTPProbe) Check(ctx context.Context, target string) (Result, error) {
 	return Result{
 		Success:   success,
 		Duration:  duration,
-		Message:   msg,
+		Message:   withNote(msg, familyNote(p.Family)),
 		Target:    target,
 		Timestamp: start,
-	}, nil
+		TLS:       tlsInfo,
+	}
+}
+
+// requestBody returns the body to send with method, nil if there is none.
+// Its length is known, so the request carries a Content-Length.
+func (p *HTTPProbe) requestBody(method string, start time.Time) io.Reader {
+	if p.Body == "" {
+		return nil
+	}
+	switch strings.ToUpper(method) {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return strings.NewReader(strings.ReplaceAll(p.Body, "{%timestamp%}", strconv.FormatInt(start.Unix(), 10)))
+	}
+	return nil
+}
+
+// maxDrainBytes bounds how much of an unread response body is discarded to
+// keep the connection reusable. Larger bodies just close the connection.
+const maxDrainBytes = 64 << 10
+
+// httpClient returns the client for the probe's current settings, building
+// it on first use or when the settings changed since it was built.
+func (p *HTTPProbe) httpClient() (*http.Client, error) {
+	// Use configured timeout, default to 5 seconds
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	key := httpClientKey{
+		insecureSkipVerify: p.InsecureSkipVerify,
+		clientCert:         p.ClientCert,
+		clientKey:          p.ClientKey,
+		caCert:             p.CACert,
+		proxy:              p.Proxy,
+		useEnvProxy:        p.UseEnvProxy,
+		unixSocket:         p.UnixSocket,
+		disableKeepAlive:   p.DisableKeepAlive,
+		disableRedirects:   p.DisableRedirects,
+		maxRedirects:       p.MaxRedirects,
+		timeout:            timeout,
+		connectTimeout:     connectTimeout(p.ConnectTimeout, timeout),
+		sourceAddress:      p.SourceAddress,
+		family:             p.Family,
+	}
+
+	p.clientMu.Lock()
+	defer p.clientMu.Unlock()
+	if p.client != nil && p.clientKey == key {
+		return p.client, nil
+	}
+
+	certificates, err := clientCertificates(p.ClientCert, p.ClientKey)
+	var roots *x509.CertPool
+	if err == nil {
+		roots, err = rootCAs(p.CACert)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a custom client to handle timeouts and insecure skip verify if needed
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: p.InsecureSkipVerify, //nolint:gosec // G402: Optional skip for untrusted endpoints
+			Certificates:       certificates,
+			RootCAs:            roots,
+		},
+		DisableKeepAlives: p.DisableKeepAlive,
+		// Bodies are decoded by readBody, which also handles deflate and a
+		// user-set Accept-Encoding, rather than by the transport
+		DisableCompression: true,
+	}
+	dial := p.DialContext
+	if dial == nil {
+		d := net.Dialer{LocalAddr: localAddr("tcp", key.sourceAddress), Resolver: p.NameResolver}
+		cache := p.DNSCache
+		dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialAddr, err := cache.resolve(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			conn, err := d.DialContext(ctx, network, dialAddr)
+			if err != nil {
+				cache.forget(address)
+			}
+			return conn, sourceError(err, key.sourceAddress)
+		}
+	}
+	if p.UnixSocket != "" {
+		// The URL host is only used for the Host header, every request goes to the socket
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", p.UnixSocket)
+		}
+	}
+	tr.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		ctx, cancel := context.WithTimeout(ctx, key.connectTimeout)
+		defer cancel()
+		return dial(ctx, familyNetwork(network, key.family), address)
+	}
+
+	switch {
+	case p.Proxy != "":
+		proxyURL, err := url.Parse(p.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+		tr.Proxy = http.ProxyURL(proxyURL)
+	case p.UseEnvProxy:
+		tr.Proxy = http.ProxyFromEnvironment
+	}
+
+	if p.client != nil {
+		p.client.CloseIdleConnections()
+	}
+	p.client = &http.Client{
+		Transport: tr,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if key.disableRedirects {
+				return http.ErrUseLastResponse
+			}
+			maxRedirects := key.maxRedirects
+			if maxRedirects == 0 {
+				maxRedirects = defaultMaxRedirects
+			}
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+	p.clientKey = key
+	return p.client, nil
+}
+
+// readBody reads up to limit bytes of the response body, decompressing it
+// according to its Content-Encoding so expectations match against the decoded
+// content. It reports whether the decoded body was longer than limit.
+func readBody(resp *http.Response, limit int64) ([]byte, bool, error) {
+	var r io.Reader = resp.Body
+	switch encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		r = gz
+	case "deflate":
+		// Deflate should be zlib-wrapped, but some servers send raw deflate
+		br := bufio.NewReader(resp.Body)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid deflate body: %w", err)
+			}
+			defer func() { _ = zr.Close() }()
+			r = zr
+		} else {
+			r = flate.NewReader(br)
+		}
+	default:
+		return nil, false, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+	}
+	// One byte past the limit tells a body of exactly limit bytes from a longer one
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if int64(len(body)) > limit {
+		return body[:limit], true, nil
+	}
+	return body, false, err
+}
+
+// isZlibHeader reports whether header, the first two bytes of a deflate
+// body, is a zlib header (RFC 1950) rather than the start of raw deflate.
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
 }
 
 func (p *HTTPProbe) evaluateExpectations(body []byte, headers http.Header) (bool, string) {
@@ -170,6 +552,20 @@ func (p *HTTPProbe) evaluateExpectations(body []byte, headers http.Header) (bool
 		var actualValue string
 		var found bool
 
+		// Presence is checked whatever the value, null and empty ones included
+		switch exp.Operator {
+		case "exists":
+			if !expectationFieldExists(exp, body, headers) {
+				return false, fmt.Sprintf("field not found: %s", exp.JSONPath+exp.Header)
+			}
+			continue
+		case "not_exists":
+			if expectationFieldExists(exp, body, headers) {
+				return false, fmt.Sprintf("expectation failed: %s not_exists (actual: present)", exp.JSONPath+exp.Header)
+			}
+			continue
+		}
+
 		switch exp.Type {
 		case "header":
 			actualValue = headers.Get(exp.Header)
@@ -230,10 +626,31 @@ func (p *HTTPProbe) evaluateExpectations(body []byte, headers http.Header) (bool
 	return true, "Expectations met"
 }
 
+// expectationFieldExists reports whether the header, JSON path or body of exp
+// is present in the response.
+func expectationFieldExists(exp config.Expectation, body []byte, headers http.Header) bool {
+	switch exp.Type {
+	case "header":
+		_, ok := headers[http.CanonicalHeaderKey(exp.Header)]
+		return ok
+	case "json":
+		return gjson.GetBytes(body, exp.JSONPath).Exists()
+	}
+	return len(body) > 0
+}
+
 func (p *HTTPProbe) evaluateOperator(op, actual, target string) (bool, error) {
 	switch op {
 	case "==":
 		return actual == target, nil
+	case "in":
+		// target is a comma-separated list of candidates
+		for candidate := range strings.SplitSeq(target, ",") {
+			if actual == strings.TrimSpace(candidate) {
+				return true, nil
+			}
+		}
+		return false, nil
 	case "contains":
 		return strings.Contains(actual, target), nil
 	case "matches":
@@ -263,6 +680,17 @@ func (p *HTTPProbe) compare(actual, target string, isGreater bool) (bool, error)
 		return age < dur, nil
 	}
 
+	// Try size comparison when either side has a unit, e.g. "2GB" against
+	// "1.5GB", "500Mi" or a plain number of bytes
+	if actSize, actUnit, err1 := parseSize(actual); err1 == nil {
+		if tarSize, tarUnit, err2 := parseSize(target); err2 == nil && (actUnit || tarUnit) {
+			if isGreater {
+				return actSize > tarSize, nil
+			}
+			return actSize < tarSize, nil
+		}
+	}
+
 	// Try numeric comparison
 	if actNum, err1 := strconv.ParseFloat(actual, 64); err1 == nil {
 		if tarNum, err2 := strconv.ParseFloat(target, 64); err2 == nil {
@@ -286,6 +714,41 @@ func (p *HTTPProbe) compare(actual, target string, isGreater bool) (bool, error)
 	return false, fmt.Errorf("unsupported comparison between %q and %q", actual, target)
 }
 
+// sizeUnits are the byte multipliers of the units parseSize accepts, in
+// lower case: decimal (KB) and binary (Ki, KiB) prefixes.
+var sizeUnits = map[string]float64{
+	"b":  1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12, "pb": 1e15,
+	"ki": 1 << 10, "mi": 1 << 20, "gi": 1 << 30, "ti": 1 << 40, "pi": 1 << 50,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40, "pib": 1 << 50,
+}
+
+// sizePattern splits a size into its number and unit, e.g. "1.5 GB".
+var sizePattern = regexp.MustCompile(`^([+-]?[0-9]*\.?[0-9]+)\s*([A-Za-z]+)$`)
+
+// parseSize returns the number of bytes of a human-readable size such as
+// "1.5GB" or "500Mi", units being case-insensitive. A plain number is taken
+// as bytes, hasUnit tells the two apart.
+func parseSize(s string) (bytes float64, hasUnit bool, err error) {
+	s = strings.TrimSpace(s)
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, false, nil
+	}
+	m := sizePattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false, fmt.Errorf("invalid size %q", s)
+	}
+	multiplier, ok := sizeUnits[strings.ToLower(m[2])]
+	if !ok {
+		return 0, false, fmt.Errorf("unknown size unit %q", m[2])
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return v * multiplier, true, nil
+}
+
 func (p *HTTPProbe) parseTimestamp(s string) (time.Time, error) {
 	formats := []string{
 		time.RFC3339,
@@ -300,44 +763,41 @@ func (p *HTTPProbe) parseTimestamp(s string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("unrecognized time format")
 }
 
-func (p *HTTPProbe) checkStatusCode(code int) bool {
+func (p *HTTPProbe) checkStatusCode(code int) (bool, error) {
 	if p.AcceptedStatusCodes == "" {
 		// Default behavior: 200-399 is considered success (including redirects if not followed, but usually 2xx)
-		return code >= 200 && code < 400
+		return code >= 200 && code < 400, nil
 	}
+	return MatchStatusCode(p.AcceptedStatusCodes, code)
+}
 
-	parts := strings.Split(p.AcceptedStatusCodes, ",")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
-		}
-
-		if strings.Contains(part, "-") {
-			// Range: "200-299"
-			ranges := strings.Split(part, "-")
-			if len(ranges) == 2 {
-				start, err1 := strconv.Atoi(strings.TrimSpace(ranges[0]))
-				end, err2 := strconv.Atoi(strings.TrimSpace(ranges[1]))
-				if err1 == nil && err2 == nil {
-					if code >= start && code <= end {
-						return true
-					}
-				}
-			}
-		} else {
-			// Single value: "200"
-			val, err := strconv.Atoi(part)
-			if err == nil {
-				if code == val {
-					return true
-				}
-			}
-		}
+// MatchStatusCode reports whether code is listed in spec, a comma-separated
+// list of codes and ranges such as "200-299, 404". A malformed spec is an
+// error, config.Validate rejects it at load time.
+func MatchStatusCode(spec string, code int) (bool, error) {
+	ranges, err := config.ParseIntRanges(spec)
+	if err != nil {
+		return false, err
 	}
-
-	return false
+	return ranges.Contains(code), nil
 }
+
 func (p *HTTPProbe) SetTimeout(timeout time.Duration) {
 	p.Timeout = timeout
 }
+
+func (p *HTTPProbe) SetConnectTimeout(timeout time.Duration) {
+	p.ConnectTimeout = timeout
+}
+
+func (p *HTTPProbe) SetSourceAddress(addr string) {
+	p.SourceAddress = addr
+}
+
+func (p *HTTPProbe) SetFamily(family string) {
+	p.Family = family
+}
+
+func (p *HTTPProbe) SetNameResolver(r *net.Resolver) {
+	p.NameResolver = r
+}
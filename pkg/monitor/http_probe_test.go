@@ -1,11 +1,21 @@
 package monitor
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"fmt"
+	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -114,13 +124,14 @@ func TestHTTPProbe_CheckStatusCode_EdgeCases(t *testing.T) {
 		acceptedCodes string
 		actualStatus  int
 		shouldSucceed bool
+		wantErr       bool
 	}{
-		{"Empty accepted codes with 200", "", 200, true},
-		{"Single code mismatch", "201", 200, false},
-		{"Range outside", "200-299", 300, false},
-		{"Invalid range (start > end)", "300-200", 250, false},
-		{"Malformed codes (text)", "abc, 200", 200, true},
-		{"Non-integer input", "200-abc", 200, false},
+		{"Empty accepted codes with 200", "", 200, true, false},
+		{"Single code mismatch", "201", 200, false, false},
+		{"Range outside", "200-299", 300, false, false},
+		{"Invalid range (start > end)", "300-200", 250, false, true},
+		{"Malformed codes (text)", "abc, 200", 200, false, true},
+		{"Non-integer input", "200-abc", 200, false, true},
 	}
 
 	for _, tt := range tests {
@@ -128,8 +139,9 @@ func TestHTTPProbe_CheckStatusCode_EdgeCases(t *testing.T) {
 			probe := &HTTPProbe{
 				AcceptedStatusCodes: tt.acceptedCodes,
 			}
-			if probe.checkStatusCode(tt.actualStatus) != tt.shouldSucceed {
-				t.Errorf("%s failed", tt.name)
+			ok, err := probe.checkStatusCode(tt.actualStatus)
+			if ok != tt.shouldSucceed || (err != nil) != tt.wantErr {
+				t.Errorf("%s failed: got %v, %v", tt.name, ok, err)
 			}
 		})
 	}
@@ -193,6 +205,17 @@ func TestHTTPProbe_InsecureSkipVerify(t *testing.T) {
 		if !strings.Contains(res.Message, "TLS expires in") {
 			t.Errorf("Message missing TLS expiry info: %s", res.Message)
 		}
+		// httptest certs are issued by Acme Co for example.com
+		if res.TLS == nil {
+			t.Fatal("Expected certificate details in the result")
+		}
+		cert := ts.Certificate()
+		if res.TLS.Issuer != "Acme Co" || !res.TLS.NotAfter.Equal(cert.NotAfter) {
+			t.Errorf("Unexpected certificate details: %+v", res.TLS)
+		}
+		if !strings.Contains(res.Message, res.TLS.String()) {
+			t.Errorf("Message missing certificate details: %s", res.Message)
+		}
 	})
 
 	t.Run("TLS expiry threshold fail", func(t *testing.T) {
@@ -243,6 +266,218 @@ func TestHTTPProbe_AdvancedOptions(t *testing.T) {
 	}
 }
 
+func TestHTTPProbe_UserAgent(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"default", nil, UserAgent},
+		{"header override", map[string]string{"User-Agent": "custom/1.0"}, "custom/1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := &HTTPProbe{Headers: tt.headers}
+			res, err := probe.Check(context.Background(), ts.URL)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if !res.Success {
+				t.Fatalf("Expected success, got failure: %s", res.Message)
+			}
+			if got != tt.want {
+				t.Errorf("Expected User-Agent %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHTTPProbe_RequestBody(t *testing.T) {
+	type received struct {
+		method        string
+		contentType   string
+		contentLength int64
+		body          string
+	}
+	var got received
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = received{r.Method, r.Header.Get("Content-Type"), r.ContentLength, string(body)}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"size": %d}`, len(body))
+	}))
+	defer ts.Close()
+
+	large := `{"data": "` + strings.Repeat("x", 4<<20) + `"}`
+
+	tests := []struct {
+		name            string
+		method          string
+		body            string
+		headers         map[string]string
+		wantBody        string
+		wantContentType string
+	}{
+		{"post", "POST", `{"query": "status"}`, nil, `{"query": "status"}`, "application/json"},
+		{"put with content type", "PUT", "a=1", map[string]string{"content-type": "application/x-www-form-urlencoded"}, "a=1", "application/x-www-form-urlencoded"},
+		{"patch", "PATCH", "{}", nil, "{}", "application/json"},
+		{"large", "POST", large, nil, large, "application/json"},
+		{"get ignores body", "GET", `{"query": "status"}`, nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = received{}
+			probe := &HTTPProbe{
+				Method:  tt.method,
+				Body:    tt.body,
+				Headers: tt.headers,
+				MatchData: &config.MatchDataConfig{Expectations: []config.Expectation{
+					{Type: "json", JSONPath: "size", Operator: "==", Value: fmt.Sprint(len(tt.wantBody))},
+				}},
+			}
+			res, err := probe.Check(context.Background(), ts.URL)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if !res.Success {
+				t.Fatalf("Expected success, got failure: %s", res.Message)
+			}
+			if got.method != tt.method || got.body != tt.wantBody || got.contentType != tt.wantContentType {
+				t.Errorf("Expected %s with %d bytes of %q, got %s with %d bytes of %q", tt.method, len(tt.wantBody), tt.wantContentType, got.method, len(got.body), got.contentType)
+			}
+			if tt.wantBody != "" && got.contentLength != int64(len(tt.wantBody)) {
+				t.Errorf("Expected Content-Length %d, got %d", len(tt.wantBody), got.contentLength)
+			}
+		})
+	}
+}
+
+func TestHTTPProbe_RequestBody_Timestamp(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = string(body)
+	}))
+	defer ts.Close()
+
+	probe := &HTTPProbe{Method: "POST", Body: `{"ts": {%timestamp%}}`}
+	res, err := probe.Check(context.Background(), ts.URL)
+	if err != nil || !res.Success {
+		t.Fatalf("Expected success, got %v: %s", err, res.Message)
+	}
+	if want := fmt.Sprintf(`{"ts": %d}`, res.Timestamp.Unix()); got != want {
+		t.Errorf("Expected body %q, got %q", want, got)
+	}
+}
+
+func TestHTTPProbe_CompressedBody(t *testing.T) {
+	const payload = `{"status": "ok"}`
+	compress := map[string]func(w io.Writer) io.WriteCloser{
+		"gzip":        func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate":     func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+		"raw deflate": func(w io.Writer) io.WriteCloser { fw, _ := flate.NewWriter(w, flate.DefaultCompression); return fw },
+	}
+
+	for name, newWriter := range compress {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := newWriter(&buf)
+			_, _ = zw.Write([]byte(payload))
+			_ = zw.Close()
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+					t.Errorf("Expected Accept-Encoding to offer gzip, got %q", r.Header.Get("Accept-Encoding"))
+				}
+				w.Header().Set("Content-Encoding", strings.TrimPrefix(name, "raw "))
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write(buf.Bytes())
+			}))
+			defer ts.Close()
+
+			probe := &HTTPProbe{
+				MatchData: &config.MatchDataConfig{
+					Expectations: []config.Expectation{
+						{Type: "json", JSONPath: "status", Operator: "==", Value: "ok"},
+						{Type: "body", Operator: "contains", Value: `"status"`},
+					},
+				},
+			}
+			res, err := probe.Check(context.Background(), ts.URL)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if !res.Success {
+				t.Errorf("Expected success, got failure: %s", res.Message)
+			}
+		})
+	}
+
+	t.Run("unsupported encoding", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = w.Write([]byte("compressed"))
+		}))
+		defer ts.Close()
+
+		probe := &HTTPProbe{
+			MatchData: &config.MatchDataConfig{
+				Expectations: []config.Expectation{{Type: "body", Operator: "contains", Value: "x"}},
+			},
+		}
+		res, _ := probe.Check(context.Background(), ts.URL)
+		if res.Success || !strings.Contains(res.Message, `unsupported Content-Encoding "br"`) {
+			t.Errorf("Expected unsupported encoding failure, got %q", res.Message)
+		}
+	})
+}
+
+func TestHTTPProbe_MaxBodyBytes(t *testing.T) {
+	// 4 KiB of JSON compresses to a few bytes, the limit applies to the decoded body
+	payload := `{"status": "ok", "padding": "` + strings.Repeat("x", 4096) + `"}`
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write([]byte(payload))
+	_ = zw.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer ts.Close()
+
+	matchData := &config.MatchDataConfig{Expectations: []config.Expectation{
+		{Type: "body", Operator: "contains", Value: `"status": "ok"`},
+	}}
+
+	probe := &HTTPProbe{MatchData: matchData, MaxBodyBytes: 1024}
+	res, _ := probe.Check(context.Background(), ts.URL)
+	if !res.Success || !strings.HasSuffix(res.Message, "(body truncated to 1024 bytes)") {
+		t.Errorf("Expected the truncated body to match, got %q", res.Message)
+	}
+
+	probe = &HTTPProbe{MatchData: matchData, MaxBodyBytes: 1024, FailOnOversizedBody: true}
+	res, _ = probe.Check(context.Background(), ts.URL)
+	if res.Success || !strings.Contains(res.Message, "body exceeds 1024 bytes") {
+		t.Errorf("Expected an oversized body failure, got %q", res.Message)
+	}
+
+	probe = &HTTPProbe{MatchData: matchData, MaxBodyBytes: int64(len(payload)), FailOnOversizedBody: true}
+	res, _ = probe.Check(context.Background(), ts.URL)
+	if !res.Success || res.Message != "Expectations met" {
+		t.Errorf("Expected a body of exactly the limit to match, got %q", res.Message)
+	}
+}
+
 func TestHTTPProbe_Expectations(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -438,6 +673,99 @@ func TestHTTPProbe_Expectations(t *testing.T) {
 	}
 }
 
+func TestHTTPProbe_CompareSizes(t *testing.T) {
+	tests := []struct {
+		actual, op, target string
+		want               bool
+		wantErr            string
+	}{
+		{"1.5GB", "<", "2GB", true, ""},
+		{"1.5GB", ">", "2GB", false, ""},
+		{"2KB", ">", "1999", true, ""},
+		{"1500000", "<", "2MB", true, ""},
+		{"1Gi", ">", "1GB", true, ""},
+		{"500Mi", "<", "0.5GB", false, ""},
+		{"1024Ki", "<", "1MiB", false, ""},
+		{"1024Ki", ">", "1MB", true, ""},
+		{"3 gb", ">", "2GB", true, ""},
+		{"1.5GB", "<", "2 apples", false, "unsupported comparison"},
+		{"1.5XB", "<", "2GB", false, "unsupported comparison"},
+	}
+
+	p := &HTTPProbe{}
+	for _, tt := range tests {
+		t.Run(tt.actual+" "+tt.op+" "+tt.target, func(t *testing.T) {
+			got, err := p.evaluateOperator(tt.op, tt.actual, tt.target)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	// Durations and plain numbers are compared as before
+	if ok, err := p.compare(time.Now().Add(-time.Minute).Format(time.RFC3339), "10m", false); err != nil || !ok {
+		t.Errorf("expected the age check to pass, got %v, %v", ok, err)
+	}
+	if ok, err := p.compare("10", "9.5", true); err != nil || !ok {
+		t.Errorf("expected the numeric comparison to pass, got %v, %v", ok, err)
+	}
+
+	probe := &HTTPProbe{MatchData: &config.MatchDataConfig{Expectations: []config.Expectation{
+		{Type: "json", JSONPath: "memory.used", Operator: "<", Value: "2GB"},
+		{Type: "json", JSONPath: "disk.free", Operator: ">", Value: "100Mi"},
+	}}}
+	if passed, msg := probe.evaluateExpectations([]byte(`{"memory": {"used": "1.5GB"}, "disk": {"free": 209715200}}`), http.Header{}); !passed {
+		t.Errorf("expected size expectations to pass, got %s", msg)
+	}
+}
+
+func TestHTTPProbe_ExistsAndInOperators(t *testing.T) {
+	body := []byte(`{"status": "degraded", "version": null, "regions": ["eu", "us"], "replicas": 3}`)
+	headers := http.Header{"X-Request-Id": {"abc"}, "X-Empty": {""}}
+
+	tests := []struct {
+		name    string
+		exp     config.Expectation
+		want    bool
+		wantMsg string
+	}{
+		{"json exists", config.Expectation{Type: "json", JSONPath: "status", Operator: "exists"}, true, ""},
+		{"json null exists", config.Expectation{Type: "json", JSONPath: "version", Operator: "exists"}, true, ""},
+		{"json missing exists", config.Expectation{Type: "json", JSONPath: "uptime", Operator: "exists"}, false, "field not found: uptime"},
+		{"json missing not_exists", config.Expectation{Type: "json", JSONPath: "error", Operator: "not_exists"}, true, ""},
+		{"json present not_exists", config.Expectation{Type: "json", JSONPath: "status", Operator: "not_exists"}, false, "status not_exists (actual: present)"},
+		{"header exists", config.Expectation{Type: "header", Header: "x-request-id", Operator: "exists"}, true, ""},
+		{"empty header exists", config.Expectation{Type: "header", Header: "X-Empty", Operator: "exists"}, true, ""},
+		{"header missing", config.Expectation{Type: "header", Header: "X-Trace", Operator: "exists"}, false, "field not found: X-Trace"},
+		{"header not_exists", config.Expectation{Type: "header", Header: "X-Debug", Operator: "not_exists"}, true, ""},
+		{"in", config.Expectation{Type: "json", JSONPath: "status", Operator: "in", Value: "healthy, degraded"}, true, ""},
+		{"not in", config.Expectation{Type: "json", JSONPath: "status", Operator: "in", Value: "healthy,ok"}, false, "status in healthy,ok"},
+		{"number in", config.Expectation{Type: "json", JSONPath: "replicas", Operator: "in", Value: "3,5"}, true, ""},
+		{"array element in", config.Expectation{Type: "json", JSONPath: "regions", Operator: "in", Value: "us,ap"}, true, ""},
+		{"header in", config.Expectation{Type: "header", Header: "X-Request-Id", Operator: "in", Value: "abc,def"}, true, ""},
+		{"missing in", config.Expectation{Type: "json", JSONPath: "uptime", Operator: "in", Value: "1,2"}, false, "field not found: uptime"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &HTTPProbe{MatchData: &config.MatchDataConfig{Expectations: []config.Expectation{tt.exp}}}
+			passed, msg := p.evaluateExpectations(body, headers)
+			if passed != tt.want {
+				t.Fatalf("expected %v, got %v: %s", tt.want, passed, msg)
+			}
+			if !strings.Contains(msg, tt.wantMsg) {
+				t.Errorf("expected message containing %q, got %q", tt.wantMsg, msg)
+			}
+		})
+	}
+}
+
 func TestHTTPProbe_EdgeCases_Extended(t *testing.T) {
 	t.Run("Unknown expectation type", func(t *testing.T) {
 		probe := &HTTPProbe{
@@ -617,7 +945,7 @@ func TestHTTPProbe_EdgeCases_Extended(t *testing.T) {
 
 	t.Run("Status codes with spaces", func(t *testing.T) {
 		p := &HTTPProbe{AcceptedStatusCodes: "200, , 201"}
-		if !p.checkStatusCode(201) {
+		if ok, err := p.checkStatusCode(201); !ok || err != nil {
 			t.Error("expected 201 to be accepted")
 		}
 	})
@@ -753,3 +1081,373 @@ func TestHTTPProbe_SetTimeout(t *testing.T) {
 		t.Errorf("Expected timeout 10s, got %v", p.Timeout)
 	}
 }
+
+func TestHTTPProbe_ClientCertificate(t *testing.T) {
+	certPEM, keyPEM, pool := generateTestClientCert(t)
+	server := newMTLSServer(t, pool)
+	defer server.Close()
+
+	t.Run("with client cert", func(t *testing.T) {
+		probe := &HTTPProbe{InsecureSkipVerify: true, ClientCert: certPEM, ClientKey: keyPEM}
+		res, err := probe.Check(context.Background(), server.URL)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		if !res.Success {
+			t.Errorf("Expected success with client cert, got: %s", res.Message)
+		}
+	})
+
+	t.Run("without client cert", func(t *testing.T) {
+		probe := &HTTPProbe{InsecureSkipVerify: true}
+		res, _ := probe.Check(context.Background(), server.URL)
+		if res.Success {
+			t.Error("Expected failure without client cert")
+		}
+	})
+
+	t.Run("missing key file", func(t *testing.T) {
+		probe := &HTTPProbe{InsecureSkipVerify: true, ClientCert: certPEM, ClientKey: "/nonexistent/client.key"}
+		res, _ := probe.Check(context.Background(), server.URL)
+		if res.Success || !strings.Contains(res.Message, "failed to read client_key") {
+			t.Errorf("Expected client_key read error, got: %s", res.Message)
+		}
+	})
+
+	t.Run("mismatched key", func(t *testing.T) {
+		_, otherKeyPEM, _ := generateTestClientCert(t)
+		probe := &HTTPProbe{InsecureSkipVerify: true, ClientCert: certPEM, ClientKey: otherKeyPEM}
+		res, _ := probe.Check(context.Background(), server.URL)
+		if res.Success || !strings.Contains(res.Message, "invalid client certificate pair") {
+			t.Errorf("Expected invalid pair error, got: %s", res.Message)
+		}
+	})
+
+	t.Run("with certificate expiry", func(t *testing.T) {
+		probe := &HTTPProbe{InsecureSkipVerify: true, ClientCert: certPEM, ClientKey: keyPEM, ExpiryThreshold: time.Hour}
+		res, _ := probe.Check(context.Background(), server.URL)
+		if !res.Success || !strings.Contains(res.Message, "TLS expires in") {
+			t.Errorf("Expected success with client cert and expiry check, got: %s", res.Message)
+		}
+	})
+}
+
+func TestHTTPProbe_CACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	defer server.Close()
+
+	// Any other self-signed certificate works as an untrusted CA
+	otherCA, _, _ := generateTestClientCert(t)
+
+	tests := []struct {
+		name        string
+		probe       *HTTPProbe
+		wantSuccess bool
+	}{
+		{"trusted ca", &HTTPProbe{CACert: serverCAPEM(server)}, true},
+		{"system roots", &HTTPProbe{}, false},
+		{"wrong ca", &HTTPProbe{CACert: otherCA}, false},
+		{"skip verify wins", &HTTPProbe{CACert: otherCA, InsecureSkipVerify: true}, true},
+		{"invalid ca", &HTTPProbe{CACert: "-----BEGIN CERTIFICATE-----\nnope\n-----END CERTIFICATE-----"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.probe.Check(context.Background(), server.URL)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess {
+				t.Errorf("Success = %v, want %v (%s)", res.Success, tt.wantSuccess, res.Message)
+			}
+		})
+	}
+}
+
+func TestHTTPProbe_ConnectionReuse(t *testing.T) {
+	var mu sync.Mutex
+	newConns := 0
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("unread body ", 100)))
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			newConns++
+			mu.Unlock()
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	connections := func(p *HTTPProbe, checks int) int {
+		t.Helper()
+		mu.Lock()
+		newConns = 0
+		mu.Unlock()
+		for range checks {
+			res, err := p.Check(context.Background(), ts.URL)
+			if err != nil || !res.Success {
+				t.Fatalf("check failed: %v %s", err, res.Message)
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return newConns
+	}
+
+	p := &HTTPProbe{}
+	if n := connections(p, 3); n != 1 {
+		t.Errorf("expected checks to reuse 1 connection, got %d", n)
+	}
+
+	// A settings change rebuilds the client and its connections
+	p.InsecureSkipVerify = true
+	if n := connections(p, 2); n != 1 {
+		t.Errorf("expected 1 new connection after a settings change, got %d", n)
+	}
+
+	p.DisableKeepAlive = true
+	if n := connections(p, 3); n != 3 {
+		t.Errorf("expected a new connection per check with disable_keepalive, got %d", n)
+	}
+}
+
+func TestHTTPProbe_TransportReuse(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	p := &HTTPProbe{InsecureSkipVerify: true}
+	check := func() http.RoundTripper {
+		t.Helper()
+		if res, err := p.Check(context.Background(), ts.URL); err != nil || !res.Success {
+			t.Fatalf("check failed: %v %s", err, res.Message)
+		}
+		return p.client.Transport
+	}
+
+	transport := check()
+	for range 3 {
+		if check() != transport {
+			t.Fatal("expected checks with unchanged settings to reuse the transport")
+		}
+	}
+	if tr := transport.(*http.Transport); tr.DisableKeepAlives {
+		t.Error("expected keep-alives to be enabled")
+	}
+
+	// A reload changing the TLS settings rebuilds it
+	p.CACert = serverCAPEM(ts)
+	p.InsecureSkipVerify = false
+	if check() == transport {
+		t.Error("expected a TLS settings change to rebuild the transport")
+	}
+}
+
+func BenchmarkHTTPProbe_Check(b *testing.B) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	for _, keepAlive := range []bool{true, false} {
+		b.Run(fmt.Sprintf("keepalive=%v", keepAlive), func(b *testing.B) {
+			p := &HTTPProbe{InsecureSkipVerify: true, DisableKeepAlive: !keepAlive}
+			for b.Loop() {
+				if res, _ := p.Check(context.Background(), ts.URL); !res.Success {
+					b.Fatalf("check failed: %s", res.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPProbe_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets not supported: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("healthy"))
+	}))
+	ts.Listener = listener
+	ts.Start()
+	defer ts.Close()
+
+	probe := &HTTPProbe{
+		UnixSocket: socket,
+		MatchData: &config.MatchDataConfig{
+			Expectations: []config.Expectation{{Type: "body", Operator: "contains", Value: "healthy"}},
+		},
+	}
+	res, err := probe.Check(context.Background(), "http://localhost/health")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Success {
+		t.Errorf("Expected success, got failure: %s", res.Message)
+	}
+
+	probe.UnixSocket = filepath.Join(t.TempDir(), "missing.sock")
+	res, _ = probe.Check(context.Background(), "http://localhost/health")
+	if res.Success {
+		t.Error("Expected failure for a missing socket")
+	}
+}
+
+func TestHTTPProbe_Proxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Requests through a forward proxy carry the absolute target URL
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	t.Run("via proxy", func(t *testing.T) {
+		probe := &HTTPProbe{Proxy: proxy.URL}
+		res, err := probe.Check(context.Background(), "http://only-via-proxy.invalid/health")
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		if !res.Success {
+			t.Fatalf("Expected success through proxy, got: %s", res.Message)
+		}
+		if len(proxied) != 1 || proxied[0] != "http://only-via-proxy.invalid/health" {
+			t.Errorf("Expected request to go through proxy, got %v", proxied)
+		}
+	})
+
+	t.Run("invalid proxy", func(t *testing.T) {
+		probe := &HTTPProbe{Proxy: "://bad"}
+		res, _ := probe.Check(context.Background(), "http://example.test")
+		if res.Success || !strings.Contains(res.Message, "invalid proxy") {
+			t.Errorf("Expected invalid proxy error, got: %s", res.Message)
+		}
+	})
+
+	t.Run("no proxy by default", func(t *testing.T) {
+		t.Setenv("HTTP_PROXY", proxy.URL)
+		before := len(proxied)
+		probe := &HTTPProbe{Timeout: time.Second}
+		res, _ := probe.Check(context.Background(), "http://only-via-proxy.invalid/health")
+		if res.Success || len(proxied) != before {
+			t.Error("Expected a direct connection when no proxy is configured")
+		}
+	})
+}
+
+func TestHTTPProbe_TemplatedTargets(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.EscapedPath())
+		mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		mode        string
+		targets     string
+		wantSuccess bool
+		wantTarget  string
+		wantMsg     string
+		wantPaths   []string
+	}{
+		{"any stops at first success", TargetModeAny, "missing,2,3", true, "2", "target 2: HTTP 200", []string{"/items/missing", "/items/2"}},
+		{"any all failed", TargetModeAny, "missing", false, "missing", "all targets failed, last error: target missing: HTTP 404 (fail)", []string{"/items/missing"}},
+		{"all OK", TargetModeAll, "1,2", true, "", "all 2 targets OK", []string{"/items/1", "/items/2"}},
+		{"all stops at first failure", TargetModeAll, "1,missing,3", false, "missing", "target missing: HTTP 404 (fail)", []string{"/items/1", "/items/missing"}},
+		{"escapes targets", TargetModeAll, "a b", true, "", "all 1 targets OK", []string{"/items/a%20b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths = nil
+			p := &HTTPProbe{URLTemplate: ts.URL + "/items/{%target%}"}
+			p.SetTargetMode(tt.mode)
+
+			res, err := p.Check(context.Background(), tt.targets)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Success != tt.wantSuccess || res.Target != tt.wantTarget || res.Message != tt.wantMsg {
+				t.Errorf("got success=%v target=%q message=%q, want %v %q %q", res.Success, res.Target, res.Message, tt.wantSuccess, tt.wantTarget, tt.wantMsg)
+			}
+			if len(res.TargetResults) != len(tt.wantPaths) {
+				t.Errorf("expected %d target results, got %d", len(tt.wantPaths), len(res.TargetResults))
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(paths, " ") != strings.Join(tt.wantPaths, " ") {
+				t.Errorf("requested %v, want %v", paths, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestHTTPProbe_Redirects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, "/hops/"):
+			// /hops/N redirects N times before landing on /ok
+			n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hops/"))
+			if n <= 1 {
+				http.Redirect(w, r, "/ok", http.StatusFound)
+				return
+			}
+			http.Redirect(w, r, fmt.Sprintf("/hops/%d", n-1), http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		probe       *HTTPProbe
+		path        string
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{"follows", &HTTPProbe{}, "/hops/1", true, "HTTP 200 (redirected to " + ts.URL + "/ok)"},
+		{"no redirect", &HTTPProbe{}, "/ok", true, "HTTP 200"},
+		{"no follow", &HTTPProbe{DisableRedirects: true}, "/hops/1", true, "HTTP 302"},
+		{"no follow not accepted", &HTTPProbe{DisableRedirects: true, AcceptedStatusCodes: "200"}, "/hops/1", false, "HTTP 302 (fail)"},
+		{"within max", &HTTPProbe{MaxRedirects: 3}, "/hops/2", true, "HTTP 200 (redirected to " + ts.URL + "/ok)"},
+		{"exceeds max", &HTTPProbe{MaxRedirects: 2}, "/hops/3", false, "stopped after 2 redirects"},
+		{"loop", &HTTPProbe{}, "/loop", false, "stopped after 10 redirects"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.probe.Check(context.Background(), ts.URL+tt.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Success != tt.wantSuccess {
+				t.Errorf("expected success %v, got %v: %s", tt.wantSuccess, res.Success, res.Message)
+			}
+			if tt.wantSuccess && res.Message != tt.wantMsg || !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("expected message %q, got %q", tt.wantMsg, res.Message)
+			}
+		})
+	}
+}
@@ -2,8 +2,17 @@ package monitor
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
+	"net/netip"
+	"probixel/pkg/config"
 	"probixel/pkg/tunnels"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -12,10 +21,55 @@ type Result struct {
 	Success          bool
 	Duration         time.Duration
 	Message          string
-	Target           string // The specific target that succeeded (relevant for CSV/list checks)
+	Target           string // The target that decided the outcome: first success in "any" mode, first failure in "all" mode
 	Timestamp        time.Time
 	SkipNotification bool
 	Pending          bool
+	TargetResults    []TargetResult // Outcome of each target a multi-target probe tried, in order
+	TLS              *TLSInfo       // Certificate presented by the server, for probes that inspect it
+	Since            time.Duration  // How long the service has been in its current status, set by the agent
+}
+
+// TLSInfo describes the leaf certificate a server presented
+type TLSInfo struct {
+	Subject  string // Subject common name, or the first DNS name when it has none
+	Issuer   string // Issuer common name, or organization when it has none
+	NotAfter time.Time
+}
+
+// newTLSInfo extracts the TLSInfo of cert.
+func newTLSInfo(cert *x509.Certificate) *TLSInfo {
+	info := &TLSInfo{Subject: cert.Subject.CommonName, Issuer: cert.Issuer.CommonName, NotAfter: cert.NotAfter}
+	if info.Subject == "" && len(cert.DNSNames) > 0 {
+		info.Subject = cert.DNSNames[0]
+	}
+	if info.Issuer == "" && len(cert.Issuer.Organization) > 0 {
+		info.Issuer = cert.Issuer.Organization[0]
+	}
+	return info
+}
+
+// String returns a concise form for result messages, e.g.
+// "example.com by R3, until 2026-01-02".
+func (i *TLSInfo) String() string {
+	return fmt.Sprintf("%s by %s, until %s", i.Subject, i.Issuer, i.NotAfter.Format("2006-01-02"))
+}
+
+// TargetResult is the outcome of checking a single target of a multi-target probe
+type TargetResult struct {
+	Target   string
+	Success  bool
+	Duration time.Duration
+	Message  string
+}
+
+// newTargetResult builds the TargetResult for target from its check duration
+// and error.
+func newTargetResult(target string, duration time.Duration, err error) TargetResult {
+	if err != nil {
+		return TargetResult{Target: target, Message: err.Error()}
+	}
+	return TargetResult{Target: target, Success: true, Duration: duration, Message: "OK"}
 }
 
 // Tunneler is an optional interface for probes that use tunnels
@@ -29,6 +83,14 @@ type Probe interface {
 	Name() string
 	SetTargetMode(mode string)
 	SetTimeout(timeout time.Duration)
+	Describe() ProbeInfo
+}
+
+// ProbeInfo describes a probe type and the configuration it honors
+type ProbeInfo struct {
+	Type        string
+	Description string
+	Fields      []string // Service fields the probe reads, type-specific ones prefixed with their block (e.g. "http.method")
 }
 
 // Initializer is an optional interface for probes that need setup before the first check
@@ -36,50 +98,574 @@ type Initializer interface {
 	Initialize() error
 }
 
+// QuorumSetter is an optional interface for probes that support the "quorum" target mode
+type QuorumSetter interface {
+	SetQuorum(n int)
+}
+
+// ConcurrencySetter is an optional interface for probes that can check several targets at once
+type ConcurrencySetter interface {
+	SetConcurrency(n int)
+}
+
+// ConnectTimeoutSetter is an optional interface for probes that bound the dial phase separately from the whole check
+type ConnectTimeoutSetter interface {
+	SetConnectTimeout(timeout time.Duration)
+}
+
+// SourceAddressSetter is an optional interface for probes whose connections
+// can be bound to a local source address
+type SourceAddressSetter interface {
+	SetSourceAddress(addr string)
+}
+
+// FamilySetter is an optional interface for probes whose connections can be
+// forced over one address family, FamilyIPv4 or FamilyIPv6
+type FamilySetter interface {
+	SetFamily(family string)
+}
+
+// NameResolverSetter is an optional interface for probes that can resolve
+// target hostnames with a resolver other than the system's
+type NameResolverSetter interface {
+	SetNameResolver(r *net.Resolver)
+}
+
 // MonitorType defines the supported monitor types
 const (
-	MonitorTypeHTTP      = "http"
-	MonitorTypeTCP       = "tcp"
-	MonitorTypeDNS       = "dns"
-	MonitorTypePing      = "ping"
-	MonitorTypeUDP       = "udp"
-	MonitorTypeHost      = "host"
-	MonitorTypeDocker    = "docker"
-	MonitorTypeWireguard = "wireguard"
-	MonitorTypeTLS       = "tls"
-	MonitorTypeSSH       = "ssh"
+	MonitorTypeHTTP       = "http"
+	MonitorTypeTCP        = "tcp"
+	MonitorTypeDNS        = "dns"
+	MonitorTypePing       = "ping"
+	MonitorTypeUDP        = "udp"
+	MonitorTypeHost       = "host"
+	MonitorTypeDocker     = "docker"
+	MonitorTypeWireguard  = "wireguard"
+	MonitorTypeTLS        = "tls"
+	MonitorTypeSSH        = "ssh"
+	MonitorTypeExec       = "exec"
+	MonitorTypeMySQL      = "mysql"
+	MonitorTypePostgres   = "postgres"
+	MonitorTypeSMTP       = "smtp"
+	MonitorTypeNTP        = "ntp"
+	MonitorTypeTraceroute = "traceroute"
+	MonitorTypeMQTT       = "mqtt"
+	MonitorTypeWebSocket  = "websocket"
 )
 
+// ProbeTypes lists the built-in monitor types, see RegisteredTypes for
+// every type GetProbe can construct
+var ProbeTypes = []string{
+	MonitorTypeHTTP,
+	MonitorTypeTCP,
+	MonitorTypeDNS,
+	MonitorTypePing,
+	MonitorTypeUDP,
+	MonitorTypeHost,
+	MonitorTypeDocker,
+	MonitorTypeWireguard,
+	MonitorTypeTLS,
+	MonitorTypeSSH,
+	MonitorTypeExec,
+	MonitorTypeMySQL,
+	MonitorTypePostgres,
+	MonitorTypeSMTP,
+	MonitorTypeNTP,
+	MonitorTypeTraceroute,
+	MonitorTypeMQTT,
+	MonitorTypeWebSocket,
+}
+
 // TargetMode defines how multiple targets are evaluated
 const (
-	TargetModeAny = "any" // Success if any target succeeds (default)
-	TargetModeAll = "all" // Success only if all targets succeed
+	TargetModeAny    = "any"    // Success if any target succeeds (default)
+	TargetModeAll    = "all"    // Success only if all targets succeed
+	TargetModeQuorum = "quorum" // Success if at least the configured quorum of targets succeed
 )
 
-// Factory returns a Probe based on the type
-func GetProbe(monitorType string) (Probe, error) {
-	switch monitorType {
-	case MonitorTypeHTTP:
-		return &HTTPProbe{}, nil
-	case MonitorTypeTCP:
-		return &TCPProbe{}, nil
-	case MonitorTypeDNS:
-		return &DNSProbe{}, nil
-	case MonitorTypePing:
-		return &PingProbe{}, nil
-	case MonitorTypeUDP:
-		return &UDPProbe{}, nil
-	case MonitorTypeHost:
-		return &HostProbe{}, nil
-	case MonitorTypeDocker:
-		return &DockerProbe{}, nil
-	case MonitorTypeWireguard:
-		return &WireguardProbe{}, nil
-	case MonitorTypeTLS:
-		return &TLSProbe{}, nil
-	case MonitorTypeSSH:
-		return &SSHProbe{}, nil
+// Address families a service's connections can be forced over
+const (
+	FamilyAuto = "auto" // Whatever the target resolves to (default)
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// familyNetwork restricts network, e.g. "tcp" or "ping", to family: "tcp4"
+// for FamilyIPv4, "tcp6" for FamilyIPv6 and network itself otherwise.
+func familyNetwork(network, family string) string {
+	switch family {
+	case FamilyIPv4:
+		return network + "4"
+	case FamilyIPv6:
+		return network + "6"
+	}
+	return network
+}
+
+// familyNote returns the note naming a forced family in result messages,
+// empty when the family isn't forced.
+func familyNote(family string) string {
+	if family == FamilyIPv4 || family == FamilyIPv6 {
+		return family
+	}
+	return ""
+}
+
+// joinNotes joins the non-empty notes of a result message.
+func joinNotes(notes ...string) string {
+	return strings.Join(slices.DeleteFunc(notes, func(n string) bool { return n == "" }), ", ")
+}
+
+// firstAddress returns the first of addrs in network's family, any of them
+// when network isn't restricted to one, e.g. "tcp" rather than "tcp4".
+func firstAddress(addrs []string, network string) (string, error) {
+	v4, v6 := strings.HasSuffix(network, "4"), strings.HasSuffix(network, "6")
+	for _, addr := range addrs {
+		ip, err := netip.ParseAddr(addr)
+		if (v4 || v6) && (err != nil || ip.Unmap().Is4() != v4) {
+			continue
+		}
+		return addr, nil
+	}
+	if len(addrs) == 0 {
+		return "", errors.New("no addresses")
+	}
+	return "", fmt.Errorf("no ipv%s address", network[len(network)-1:])
+}
+
+// targetMessage prefixes msg with the target that decided a check's outcome
+// when more than one target was configured, so alerts can name the endpoint.
+func targetMessage(targets []string, target, msg string) string {
+	count := 0
+	for _, t := range targets {
+		if strings.TrimSpace(t) != "" {
+			count++
+		}
+	}
+	if count <= 1 {
+		return msg
+	}
+	return fmt.Sprintf("target %s: %s", target, msg)
+}
+
+// checkExpired returns an error once ctx is done, so multi-target checks stop
+// trying further targets when the check's total timeout has passed.
+func checkExpired(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("check timed out: %w", err)
+	}
+	return nil
+}
+
+// connectTimeout returns how long a probe may spend dialing: connect if it
+// is set and shorter than the probe's timeout, the timeout otherwise.
+func connectTimeout(connect, timeout time.Duration) time.Duration {
+	if connect > 0 && connect < timeout {
+		return connect
+	}
+	return timeout
+}
+
+// dialTarget connects to address, preferring dial, a probe's injected
+// DialContext, then the probe's tunnel and finally a direct connection
+// through resolver, nil for the system one. A timeout above zero bounds the
+// attempt, and a timed out one is reported as a connect timeout.
+func dialTarget(ctx context.Context, network, address string, dial func(ctx context.Context, network, address string) (net.Conn, error), tunnel tunnels.Tunnel, resolver *net.Resolver, timeout time.Duration) (net.Conn, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var conn net.Conn
+	var err error
+	switch {
+	case dial != nil:
+		conn, err = dial(ctx, network, address)
+	case tunnel != nil:
+		conn, err = tunnel.DialContext(ctx, network, address)
+		if err != nil {
+			err = fmt.Errorf("via tunnel %q: %w", tunnel.Name(), err)
+		}
 	default:
+		d := net.Dialer{Resolver: resolver}
+		conn, err = d.DialContext(ctx, network, address)
+	}
+	if err != nil {
+		return nil, timeoutError(err, false)
+	}
+	return conn, nil
+}
+
+// localAddr returns the local address binding network connections to the
+// source IP, nil when source is empty.
+func localAddr(network, source string) net.Addr {
+	if source == "" {
+		return nil
+	}
+	ip := net.ParseIP(source)
+	if strings.HasPrefix(network, "udp") {
+		return &net.UDPAddr{IP: ip}
+	}
+	return &net.TCPAddr{IP: ip}
+}
+
+// sourceError explains a dial error caused by the source address not being
+// assigned to this host. Other errors are returned as is.
+func sourceError(err error, source string) error {
+	if source != "" && errors.Is(err, syscall.EADDRNOTAVAIL) {
+		return fmt.Errorf("source address %s is not available on this host: %w", source, err)
+	}
+	return err
+}
+
+// timeoutError names the phase a timed out err happened in, "connect
+// timeout" before a connection was established and "read timeout" after, so
+// an unreachable server can be told apart from a slow one. Other errors are
+// returned as is.
+func timeoutError(err error, connected bool) error {
+	var netErr net.Error
+	if !errors.Is(err, context.DeadlineExceeded) && !(errors.As(err, &netErr) && netErr.Timeout()) {
+		return err
+	}
+	if connected {
+		return fmt.Errorf("read timeout: %w", err)
+	}
+	return fmt.Errorf("connect timeout: %w", err)
+}
+
+// targetCheck describes a multi-target check run by checkTargets.
+type targetCheck struct {
+	mode        string
+	quorum      int
+	concurrency int
+	okMessage   string                                // "all" mode success, formatted with the number of targets
+	failMessage func(target string, err error) string // "any" mode message when every target failed
+	check       func(ctx context.Context, target string) (time.Duration, string, error)
+}
+
+// checkTargets checks targets with up to c.concurrency checks in flight, one
+// at a time below 2, and evaluates them per c.mode. Once the outcome of an
+// "any" or "all" check is decided the remaining checks are cancelled or never
+// started, while "quorum" checks every target. The deciding target is picked
+// in configuration order among the targets that completed, so the result is
+// the same whatever the concurrency.
+func checkTargets(ctx context.Context, targets []string, c targetCheck, startTotal time.Time) Result {
+	var active []string
+	for _, t := range targets {
+		if t = strings.TrimSpace(t); t != "" {
+			active = append(active, t)
+		}
+	}
+	quorum := c.quorum
+	if quorum < 1 {
+		quorum = len(active)
+	}
+
+	type outcome struct {
+		done     bool
+		duration time.Duration
+		msg      string
+		err      error
+	}
+	outcomes := make([]outcome, len(active))
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	up, down := 0, 0
+	decided := func() bool {
+		switch c.mode {
+		case TargetModeAll:
+			return down > 0
+		case TargetModeQuorum:
+			return false // Every target counts in the reported tally
+		default:
+			return up > 0
+		}
+	}
+
+	sem := make(chan struct{}, max(c.concurrency, 1))
+	for i, t := range active {
+		select {
+		case sem <- struct{}{}:
+		case <-runCtx.Done():
+		}
+		if runCtx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			duration, msg, err := c.check(runCtx, t)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && runCtx.Err() != nil && ctx.Err() == nil {
+				return // Cancelled because the outcome was already decided
+			}
+			outcomes[i] = outcome{done: true, duration: duration, msg: msg, err: err}
+			if err != nil {
+				down++
+			} else {
+				up++
+			}
+			if decided() {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Targets never started because the check's own deadline passed count as failed
+	if err := checkExpired(ctx); err != nil {
+		for i := range outcomes {
+			if !outcomes[i].done {
+				outcomes[i] = outcome{done: true, err: err}
+				down++
+			}
+		}
+	}
+
+	var results []TargetResult
+	var totalDuration time.Duration
+	firstOK, firstFailed, lastFailed := -1, -1, -1
+	for i, o := range outcomes {
+		if !o.done {
+			continue
+		}
+		tr := newTargetResult(active[i], o.duration, o.err)
+		if o.err == nil && o.msg != "" {
+			tr.Message = o.msg
+		}
+		results = append(results, tr)
+		if o.err != nil {
+			if firstFailed < 0 {
+				firstFailed = i
+			}
+			lastFailed = i
+			continue
+		}
+		if firstOK < 0 {
+			firstOK = i
+		}
+		totalDuration += o.duration
+	}
+
+	res := Result{Timestamp: startTotal, TargetResults: results}
+	switch c.mode {
+	case TargetModeAll:
+		if firstFailed >= 0 {
+			res.Message = fmt.Sprintf("target %s failed: %v", active[firstFailed], outcomes[firstFailed].err)
+			res.Target = active[firstFailed]
+			return res
+		}
+		if up > 0 {
+			res.Success = true
+			res.Duration = totalDuration / time.Duration(up)
+			res.Message = fmt.Sprintf(c.okMessage, up)
+		}
+		return res
+	case TargetModeQuorum:
+		res.Message = fmt.Sprintf("%d/%d up (need %d)", up, len(active), quorum)
+		if up >= quorum && up > 0 {
+			res.Success = true
+			res.Duration = totalDuration / time.Duration(up)
+			return res
+		}
+		if firstFailed >= 0 {
+			res.Message = fmt.Sprintf("%s, first failure: target %s: %v", res.Message, active[firstFailed], outcomes[firstFailed].err)
+			res.Target = active[firstFailed]
+		}
+		return res
+	default:
+		if firstOK >= 0 {
+			res.Success = true
+			res.Duration = outcomes[firstOK].duration
+			res.Message = targetMessage(targets, active[firstOK], outcomes[firstOK].msg)
+			res.Target = active[firstOK]
+			return res
+		}
+		if lastFailed >= 0 {
+			res.Message = c.failMessage(active[lastFailed], outcomes[lastFailed].err)
+			res.Target = active[lastFailed]
+		}
+		return res
+	}
+}
+
+// parseTarget splits a target into its host and port with
+// config.ParseTarget, naming the target in the error.
+func parseTarget(target string) (host, port string, err error) {
+	host, port, err = config.ParseTarget(target)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid target %q: %w", strings.TrimSpace(target), err)
+	}
+	return host, port, nil
+}
+
+// hostPortTarget parses a target that needs a port, using defaultPort when
+// the target has none, and returns it as a dialable address. An empty
+// defaultPort makes the port mandatory.
+func hostPortTarget(target, defaultPort string) (addr, host string, err error) {
+	host, port, err := parseTarget(target)
+	if err != nil {
+		return "", "", err
+	}
+	if port == "" {
+		if defaultPort == "" {
+			return "", "", fmt.Errorf("invalid target %q: missing port", target)
+		}
+		port = defaultPort
+	}
+	return net.JoinHostPort(host, port), host, nil
+}
+
+// TargetResolver resolves hostname targets before they are probed so results
+// show which address was checked, and optionally asserts on that address.
+type TargetResolver struct {
+	ResolveTo  []string                                                 // If set, every resolved address must be in this list
+	LookupHost func(ctx context.Context, host string) ([]string, error) // Allows mocking, defaults to net.DefaultResolver
+	Cache      *DNSCache                                                // Optional, reuses resolutions within its TTL
+}
+
+// resolve returns target with its host replaced by the first resolved address
+// in network's family, and a note describing the resolution for result
+// messages. A nil resolver or a target that is already an IP is returned
+// unchanged with an empty note.
+func (r *TargetResolver) resolve(ctx context.Context, network, target string) (string, string, error) {
+	if r == nil {
+		return target, "", nil
+	}
+
+	host, port, err := parseTarget(target)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return target, "", nil
+	}
+
+	addrs, err := r.Cache.lookupHost(ctx, host, r.LookupHost)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+
+	if len(r.ResolveTo) > 0 {
+		for _, addr := range addrs {
+			if !slices.Contains(r.ResolveTo, addr) {
+				return "", "", fmt.Errorf("%s resolved to unexpected address %s (expected %s)", host, addr, strings.Join(r.ResolveTo, ", "))
+			}
+		}
+	}
+
+	addr, err := firstAddress(addrs, network)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	note := fmt.Sprintf("%s resolved to %s", host, addr)
+	if port == "" {
+		return addr, note, nil
+	}
+	return net.JoinHostPort(addr, port), note, nil
+}
+
+// withNote appends a resolution note to a result message.
+func withNote(msg, note string) string {
+	if note == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s (%s)", msg, note)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]func() Probe)
+)
+
+func init() {
+	RegisterProbe(MonitorTypeHTTP, func() Probe { return &HTTPProbe{} })
+	RegisterProbe(MonitorTypeTCP, func() Probe { return &TCPProbe{} })
+	RegisterProbe(MonitorTypeDNS, func() Probe { return &DNSProbe{} })
+	RegisterProbe(MonitorTypePing, func() Probe { return &PingProbe{} })
+	RegisterProbe(MonitorTypeUDP, func() Probe { return &UDPProbe{} })
+	RegisterProbe(MonitorTypeHost, func() Probe { return &HostProbe{} })
+	RegisterProbe(MonitorTypeDocker, func() Probe { return &DockerProbe{} })
+	RegisterProbe(MonitorTypeWireguard, func() Probe { return &WireguardProbe{} })
+	RegisterProbe(MonitorTypeTLS, func() Probe { return &TLSProbe{} })
+	RegisterProbe(MonitorTypeSSH, func() Probe { return &SSHProbe{} })
+	RegisterProbe(MonitorTypeExec, func() Probe { return &ExecProbe{} })
+	RegisterProbe(MonitorTypeMySQL, func() Probe { return &DatabaseProbe{Driver: MonitorTypeMySQL} })
+	RegisterProbe(MonitorTypePostgres, func() Probe { return &DatabaseProbe{Driver: MonitorTypePostgres} })
+	RegisterProbe(MonitorTypeSMTP, func() Probe { return &SMTPProbe{} })
+	RegisterProbe(MonitorTypeNTP, func() Probe { return &NTPProbe{} })
+	RegisterProbe(MonitorTypeTraceroute, func() Probe { return &TracerouteProbe{} })
+	RegisterProbe(MonitorTypeMQTT, func() Probe { return &MQTTProbe{} })
+	RegisterProbe(MonitorTypeWebSocket, func() Probe { return &WebSocketProbe{} })
+	config.IsRegisteredType = IsRegistered
+}
+
+// RegisterProbe makes a probe type available to GetProbe and to config
+// validation under name. factory must return a new probe on every call. It
+// panics if name is empty, factory is nil or name is already registered.
+func RegisterProbe(name string, factory func() Probe) {
+	if name == "" || factory == nil {
+		panic("monitor: RegisterProbe requires a name and a factory")
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("monitor: RegisterProbe called twice for type %q", name))
+	}
+	registry[name] = factory
+}
+
+// IsRegistered reports whether a probe is registered for monitorType.
+func IsRegistered(monitorType string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registry[monitorType]
+	return ok
+}
+
+// RegisteredTypes returns the name of every registered probe type, sorted.
+func RegisteredTypes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// GetProbe returns a new probe of the registered type monitorType
+func GetProbe(monitorType string) (Probe, error) {
+	registryMu.RLock()
+	factory, ok := registry[monitorType]
+	registryMu.RUnlock()
+	if !ok {
 		return nil, fmt.Errorf("unknown monitor type: %s", monitorType)
 	}
+	return factory(), nil
+}
+
+// DescribeProbes returns the description of every available probe type,
+// sorted by type name.
+func DescribeProbes() []ProbeInfo {
+	types := RegisteredTypes()
+	infos := make([]ProbeInfo, 0, len(types))
+	for _, t := range types {
+		p, err := GetProbe(t)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, p.Describe())
+	}
+	slices.SortFunc(infos, func(a, b ProbeInfo) int { return strings.Compare(a.Type, b.Type) })
+	return infos
 }
@@ -1,10 +1,24 @@
 package monitor
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"probixel/pkg/config"
+	"probixel/pkg/tunnels"
 )
 
 func TestProbeName(t *testing.T) {
+
 	tests := []struct {
 		name     string
 		probe    Probe
@@ -18,6 +32,7 @@ func TestProbeName(t *testing.T) {
 		{"Host Probe", &HostProbe{}, MonitorTypeHost},
 		{"Docker Probe", &DockerProbe{}, MonitorTypeDocker},
 		{"WireGuard Probe", &WireguardProbe{}, MonitorTypeWireguard},
+		{"Exec Probe", &ExecProbe{}, MonitorTypeExec},
 	}
 
 	for _, tt := range tests {
@@ -61,6 +76,88 @@ func TestSetTargetMode(t *testing.T) {
 	}
 }
 
+type customProbe struct {
+	TCPProbe
+}
+
+func (p *customProbe) Name() string {
+	return "custom"
+}
+
+func (p *customProbe) Describe() ProbeInfo {
+	return ProbeInfo{Type: "custom", Description: "Custom probe", Fields: []string{"targets"}}
+}
+
+func TestRegisterProbe(t *testing.T) {
+	RegisterProbe("custom", func() Probe { return &customProbe{} })
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "custom")
+		registryMu.Unlock()
+	}()
+
+	if !IsRegistered("custom") || !config.IsRegisteredType("custom") {
+		t.Error("Expected custom to be registered")
+	}
+	if IsRegistered("alien-tech") {
+		t.Error("Expected alien-tech not to be registered")
+	}
+	probe, err := GetProbe("custom")
+	if err != nil {
+		t.Fatalf("GetProbe failed: %v", err)
+	}
+	if probe.Name() != "custom" {
+		t.Errorf("Expected custom probe, got %q", probe.Name())
+	}
+	if other, _ := GetProbe("custom"); other == probe {
+		t.Error("Expected a new probe on every GetProbe call")
+	}
+	if !slices.Contains(RegisteredTypes(), "custom") {
+		t.Errorf("Expected custom in %v", RegisteredTypes())
+	}
+
+	for _, tt := range []struct {
+		name    string
+		factory func() Probe
+	}{
+		{"custom", func() Probe { return &customProbe{} }},
+		{MonitorTypeHTTP, func() Probe { return &HTTPProbe{} }},
+		{"", func() Probe { return &customProbe{} }},
+		{"nil-factory", nil},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterProbe(%q) did not panic", tt.name)
+				}
+			}()
+			RegisterProbe(tt.name, tt.factory)
+		}()
+	}
+}
+
+func TestDescribeProbes(t *testing.T) {
+	infos := DescribeProbes()
+	if len(infos) != len(ProbeTypes) {
+		t.Fatalf("Expected %d probe descriptions, got %d", len(ProbeTypes), len(infos))
+	}
+	for i, info := range infos {
+		if i > 0 && infos[i-1].Type >= info.Type {
+			t.Errorf("Expected descriptions sorted by type, got %q before %q", infos[i-1].Type, info.Type)
+		}
+		if info.Description == "" || len(info.Fields) == 0 {
+			t.Errorf("Probe %q has an empty description", info.Type)
+		}
+		probe, err := GetProbe(info.Type)
+		if err != nil {
+			t.Fatalf("GetProbe(%q) failed: %v", info.Type, err)
+		}
+		if probe.Name() != info.Type {
+			t.Errorf("Describe().Type = %q, want %q", info.Type, probe.Name())
+		}
+	}
+}
+
 func TestGetProbe(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -78,6 +175,7 @@ func TestGetProbe(t *testing.T) {
 		{"Wireguard probe", MonitorTypeWireguard, MonitorTypeWireguard, false},
 		{"TLS probe", MonitorTypeTLS, MonitorTypeTLS, false},
 		{"SSH probe", MonitorTypeSSH, MonitorTypeSSH, false},
+		{"Exec probe", MonitorTypeExec, MonitorTypeExec, false},
 		{"Invalid probe", "invalid", "", true},
 		{"Empty probe", "", "", true},
 	}
@@ -110,3 +208,760 @@ func TestGetProbe(t *testing.T) {
 		})
 	}
 }
+
+func TestTargetMessage(t *testing.T) {
+	if got := targetMessage([]string{"db1"}, "db1", "OK"); got != "OK" {
+		t.Errorf("single target should keep message, got %q", got)
+	}
+	if got := targetMessage([]string{"db1", " ", "db2"}, "db2", "OK"); got != "target db2: OK" {
+		t.Errorf("unexpected message %q", got)
+	}
+}
+
+// Multi-target probes must report the target that decided the outcome: the
+// first failure in "all" mode and the first success in "any" mode.
+func TestMultiTargetProbes_DecidingTarget(t *testing.T) {
+	// db2 is down, db1 and db3 are up
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if strings.HasPrefix(address, "db2") {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return &mockUDPConn{}, nil
+	}
+	resolve := func(ctx context.Context, nameserver, host string) ([]string, error) {
+		if strings.HasPrefix(nameserver, "db2") {
+			return nil, fmt.Errorf("timeout")
+		}
+		return []string{"10.0.0.1"}, nil
+	}
+
+	newProbes := func() map[string]Probe {
+		return map[string]Probe{
+			"tcp": &TCPProbe{DialContext: dial},
+			"udp": &UDPProbe{DialContext: dial},
+			"dns": &DNSProbe{Resolve: resolve},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		mode        string
+		targets     string
+		wantSuccess bool
+		wantTarget  string
+	}{
+		{"all mode first failure", TargetModeAll, "db1:53,db2:53,db3:53", false, "db2:53"},
+		{"any mode first success", TargetModeAny, "db2:53,db3:53,db1:53", true, "db3:53"},
+		{"any mode all failed", TargetModeAny, "db2:53", false, "db2:53"},
+	}
+
+	for _, tt := range tests {
+		for name, p := range newProbes() {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				p.SetTargetMode(tt.mode)
+				res, err := p.Check(context.Background(), tt.targets)
+				if err != nil {
+					t.Fatalf("Check failed: %v", err)
+				}
+				if res.Success != tt.wantSuccess {
+					t.Fatalf("Success = %v, want %v (%s)", res.Success, tt.wantSuccess, res.Message)
+				}
+				if res.Target != tt.wantTarget {
+					t.Errorf("Target = %q, want %q", res.Target, tt.wantTarget)
+				}
+				if !strings.Contains(res.Message, tt.wantTarget) {
+					t.Errorf("Message %q should name target %q", res.Message, tt.wantTarget)
+				}
+			})
+		}
+	}
+}
+
+func TestMultiTargetProbes_TargetResults(t *testing.T) {
+	// db2 is down, db1 and db3 are up
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if strings.HasPrefix(address, "db2") {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return &mockUDPConn{}, nil
+	}
+	resolve := func(ctx context.Context, nameserver, host string) ([]string, error) {
+		if strings.HasPrefix(nameserver, "db2") {
+			return nil, fmt.Errorf("timeout")
+		}
+		return []string{"10.0.0.1"}, nil
+	}
+
+	newProbes := func(mode string) map[string]Probe {
+		return map[string]Probe{
+			"tcp": &TCPProbe{DialContext: dial},
+			"udp": &UDPProbe{DialContext: dial},
+			"dns": &DNSProbe{Resolve: resolve},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		mode    string
+		targets string
+		want    []bool // Success of each target tried, in order
+	}{
+		{"all mode stops at first failure", TargetModeAll, "db1:53,db2:53,db3:53", []bool{true, false}},
+		{"any mode stops at first success", TargetModeAny, "db2:53,db3:53,db1:53", []bool{false, true}},
+		{"any mode all failed", TargetModeAny, "db2:53", []bool{false}},
+		{"quorum mode tries every target", TargetModeQuorum, "db1:53,db2:53,db3:53", []bool{true, false, true}},
+	}
+
+	for _, tt := range tests {
+		for name, p := range newProbes(tt.mode) {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				p.SetTargetMode(tt.mode)
+				if qs, ok := p.(QuorumSetter); ok {
+					qs.SetQuorum(2)
+				}
+				res, err := p.Check(context.Background(), tt.targets)
+				if err != nil {
+					t.Fatalf("Check failed: %v", err)
+				}
+				if len(res.TargetResults) != len(tt.want) {
+					t.Fatalf("TargetResults = %+v, want %d entries", res.TargetResults, len(tt.want))
+				}
+				targets := strings.Split(tt.targets, ",")
+				for i, tr := range res.TargetResults {
+					if tr.Target != targets[i] {
+						t.Errorf("TargetResults[%d].Target = %q, want %q", i, tr.Target, targets[i])
+					}
+					if tr.Success != tt.want[i] {
+						t.Errorf("TargetResults[%d].Success = %v, want %v (%s)", i, tr.Success, tt.want[i], tr.Message)
+					}
+					if !tr.Success && (tr.Message == "" || tr.Duration != 0) {
+						t.Errorf("TargetResults[%d] failure should have a message and no duration, got %+v", i, tr)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestQuorumMode(t *testing.T) {
+	// db2 and db4 are down, db1, db3 and db5 are up
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if strings.HasPrefix(address, "db2") || strings.HasPrefix(address, "db4") {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return &mockUDPConn{}, nil
+	}
+	targets := "db1:9000,db2:9000,db3:9000,db4:9000,db5:9000"
+
+	tests := []struct {
+		quorum      int
+		wantSuccess bool
+		wantMessage string
+	}{
+		{3, true, "3/5 up (need 3)"},
+		{4, false, "3/5 up (need 4), first failure: target db2:9000: connection refused"},
+		{0, false, "3/5 up (need 5)"}, // unset quorum requires every target
+	}
+
+	for _, tt := range tests {
+		probes := map[string]Probe{
+			"tcp": &TCPProbe{DialContext: dial},
+			"udp": &UDPProbe{DialContext: dial},
+		}
+		for name, p := range probes {
+			t.Run(fmt.Sprintf("%s/quorum=%d", name, tt.quorum), func(t *testing.T) {
+				p.SetTargetMode(TargetModeQuorum)
+				p.(QuorumSetter).SetQuorum(tt.quorum)
+
+				res, err := p.Check(context.Background(), targets)
+				if err != nil {
+					t.Fatalf("Check failed: %v", err)
+				}
+				if res.Success != tt.wantSuccess {
+					t.Errorf("Success = %v, want %v", res.Success, tt.wantSuccess)
+				}
+				if !strings.HasPrefix(res.Message, tt.wantMessage) {
+					t.Errorf("Message = %q, want prefix %q", res.Message, tt.wantMessage)
+				}
+				if !res.Success && res.Target != "db2:9000" {
+					t.Errorf("Target = %q, want first failure db2:9000", res.Target)
+				}
+			})
+		}
+	}
+}
+
+func TestTargetResolver(t *testing.T) {
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		switch host {
+		case "db.test":
+			return []string{"10.0.0.5"}, nil
+		case "rr.test":
+			return []string{"10.0.0.5", "10.0.0.6"}, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+
+	tests := []struct {
+		name     string
+		resolver *TargetResolver
+		target   string
+		wantAddr string
+		wantNote string
+		wantErr  string
+	}{
+		{"nil resolver", nil, "db.test:5432", "db.test:5432", "", ""},
+		{"ip target", &TargetResolver{LookupHost: lookup}, "10.0.0.1:5432", "10.0.0.1:5432", "", ""},
+		{"host with port", &TargetResolver{LookupHost: lookup}, "db.test:5432", "10.0.0.5:5432", "db.test resolved to 10.0.0.5", ""},
+		{"host without port", &TargetResolver{LookupHost: lookup}, "db.test", "10.0.0.5", "db.test resolved to 10.0.0.5", ""},
+		{"expected address", &TargetResolver{LookupHost: lookup, ResolveTo: []string{"10.0.0.5", "10.0.0.6"}}, "rr.test:80", "10.0.0.5:80", "rr.test resolved to 10.0.0.5", ""},
+		{"unexpected address", &TargetResolver{LookupHost: lookup, ResolveTo: []string{"10.0.0.5"}}, "rr.test:80", "", "", "resolved to unexpected address 10.0.0.6"},
+		{"lookup failure", &TargetResolver{LookupHost: lookup}, "missing.test:80", "", "", "failed to resolve missing.test"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, note, err := tt.resolver.resolve(context.Background(), "tcp", tt.target)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if addr != tt.wantAddr || note != tt.wantNote {
+				t.Errorf("got (%q, %q), want (%q, %q)", addr, note, tt.wantAddr, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestResolvingProbes(t *testing.T) {
+	resolver := &TargetResolver{
+		LookupHost: func(ctx context.Context, host string) ([]string, error) {
+			return []string{"10.0.0.5"}, nil
+		},
+	}
+
+	var dialed []string
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return &mockUDPConn{}, nil
+	}
+
+	probes := map[string]Probe{
+		"tcp": &TCPProbe{DialContext: dial, Resolver: resolver},
+		"udp": &UDPProbe{DialContext: dial, Resolver: resolver},
+	}
+	for name, p := range probes {
+		t.Run(name, func(t *testing.T) {
+			dialed = nil
+			res, err := p.Check(context.Background(), "db.test:5432")
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if !res.Success || res.Message != "OK (db.test resolved to 10.0.0.5)" {
+				t.Errorf("unexpected result %v: %s", res.Success, res.Message)
+			}
+			if len(dialed) != 1 || dialed[0] != "10.0.0.5:5432" {
+				t.Errorf("expected resolved address to be dialed, got %v", dialed)
+			}
+		})
+	}
+
+	t.Run("unexpected address fails", func(t *testing.T) {
+		p := &TCPProbe{DialContext: dial, Resolver: &TargetResolver{LookupHost: resolver.LookupHost, ResolveTo: []string{"10.0.0.9"}}}
+		res, _ := p.Check(context.Background(), "db.test:5432")
+		if res.Success || !strings.Contains(res.Message, "unexpected address 10.0.0.5") {
+			t.Errorf("expected resolve_to failure, got: %s", res.Message)
+		}
+	})
+}
+
+func TestConcurrentTargets(t *testing.T) {
+	// db2 is down, db1, db3 and db4 are up
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		if strings.HasPrefix(address, "db2") {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return &mockUDPConn{}, nil
+	}
+
+	tests := []struct {
+		name        string
+		mode        string
+		targets     string
+		wantSuccess bool
+		wantTarget  string
+		wantMessage string
+	}{
+		{"all mode", TargetModeAll, "db1:53,db3:53,db4:53", true, "", "all 3 targets OK"},
+		{"all mode failure", TargetModeAll, "db1:53,db2:53,db3:53", false, "db2:53", "target db2:53 failed: connection refused"},
+		{"any mode", TargetModeAny, "db2:53,db3:53,db1:53", true, "db3:53", "target db3:53: OK"},
+		{"any mode all failed", TargetModeAny, "db2:53", false, "db2:53", "all targets failed, last error: target db2:53: connection refused"},
+		{"quorum mode", TargetModeQuorum, "db1:53,db2:53,db3:53,db4:53", true, "", "3/4 up (need 3)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			maxInFlight = 0
+			mu.Unlock()
+
+			p := &TCPProbe{DialContext: dial}
+			p.SetTargetMode(tt.mode)
+			if tt.mode == TargetModeQuorum {
+				p.SetQuorum(3)
+			}
+			p.SetConcurrency(2)
+			res, err := p.Check(context.Background(), tt.targets)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess || res.Target != tt.wantTarget || res.Message != tt.wantMessage {
+				t.Errorf("got success=%v target=%q message=%q, want success=%v target=%q message=%q",
+					res.Success, res.Target, res.Message, tt.wantSuccess, tt.wantTarget, tt.wantMessage)
+			}
+			for i := 1; i < len(res.TargetResults); i++ {
+				if strings.Index(tt.targets, res.TargetResults[i-1].Target) > strings.Index(tt.targets, res.TargetResults[i].Target) {
+					t.Errorf("TargetResults not in target order: %+v", res.TargetResults)
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if maxInFlight > 2 {
+				t.Errorf("%d targets checked at once, want at most 2", maxInFlight)
+			}
+		})
+	}
+}
+
+func TestConcurrentTargets_CancelsOnceDecided(t *testing.T) {
+	// db1 hangs until cancelled, db2 answers right away
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if strings.HasPrefix(address, "db1") {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return &mockUDPConn{}, nil
+	}
+
+	p := &TCPProbe{DialContext: dial}
+	p.SetTargetMode(TargetModeAny)
+	p.SetConcurrency(2)
+
+	done := make(chan Result, 1)
+	go func() {
+		res, _ := p.Check(context.Background(), "db1:53,db2:53")
+		done <- res
+	}()
+
+	select {
+	case res := <-done:
+		if !res.Success || res.Target != "db2:53" {
+			t.Errorf("expected success from db2:53, got %+v", res)
+		}
+		if len(res.TargetResults) != 1 {
+			t.Errorf("cancelled target should not be reported, got %+v", res.TargetResults)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("check did not cancel the pending target once the outcome was decided")
+	}
+}
+
+func TestCheckTargets_SequentialMatchesConcurrent(t *testing.T) {
+	// Targets named "down*" fail. Earlier targets answer first, as a
+	// sequential check would see them.
+	check := func(ctx context.Context, target string) (time.Duration, string, error) {
+		i, _ := strconv.Atoi(target[len(target)-1:])
+		time.Sleep(time.Duration(i) * 5 * time.Millisecond)
+		if strings.HasPrefix(target, "down") {
+			return 0, "", fmt.Errorf("connection refused")
+		}
+		return time.Millisecond, "OK", nil
+	}
+	failMessage := func(t string, err error) string {
+		return fmt.Sprintf("all targets failed, last error: target %s: %v", t, err)
+	}
+
+	tests := []struct {
+		name        string
+		mode        string
+		quorum      int
+		targets     string
+		wantSuccess bool
+		wantMessage string
+	}{
+		{"all", TargetModeAll, 0, "up1,up2,up3", true, "all 3 targets OK"},
+		{"all failure", TargetModeAll, 0, "up1,down2,down3,up4", false, "target down2 failed: connection refused"},
+		{"any", TargetModeAny, 0, "down1,up2,up3", true, "target up2: OK"},
+		{"any single", TargetModeAny, 0, "up1", true, "OK"},
+		{"any failure", TargetModeAny, 0, "down1,down2,down3", false, "all targets failed, last error: target down3: connection refused"},
+		{"quorum", TargetModeQuorum, 2, "down1,up2,up3,down4", true, "2/4 up (need 2)"},
+		{"quorum failure", TargetModeQuorum, 3, "down1,up2,up3,down4", false, "2/4 up (need 3), first failure: target down1: connection refused"},
+		{"quorum unset", TargetModeQuorum, 0, "up1,down2,up3", false, "2/3 up (need 3), first failure: target down2: connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []Result
+			for _, concurrency := range []int{0, 1, 2, 4} {
+				res := checkTargets(context.Background(), strings.Split(tt.targets, ","), targetCheck{
+					mode:        tt.mode,
+					quorum:      tt.quorum,
+					concurrency: concurrency,
+					okMessage:   "all %d targets OK",
+					failMessage: failMessage,
+					check:       check,
+				}, time.Now())
+				if res.Success != tt.wantSuccess || res.Message != tt.wantMessage {
+					t.Errorf("concurrency %d: got success=%v message=%q, want success=%v message=%q",
+						concurrency, res.Success, res.Message, tt.wantSuccess, tt.wantMessage)
+				}
+				results = append(results, res)
+			}
+			for _, res := range results[1:] {
+				if res.Target != results[0].Target {
+					t.Errorf("got target %q, want %q as when sequential", res.Target, results[0].Target)
+				}
+			}
+		})
+	}
+}
+
+func TestDialTarget(t *testing.T) {
+	hang := func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	tunnel := &tunnels.MockTunnel{
+		NameFunc: func() string { return "bastion" },
+		DialContextFunc: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, fmt.Errorf("connection refused")
+		},
+	}
+
+	// An injected dialer takes precedence over the tunnel
+	conn, err := dialTarget(context.Background(), "tcp", "db:5432", func(ctx context.Context, network, address string) (net.Conn, error) {
+		return &mockUDPConn{}, nil
+	}, tunnel, nil, 0)
+	if err != nil || conn == nil {
+		t.Errorf("expected the injected dialer's connection, got %v", err)
+	}
+
+	if _, err := dialTarget(context.Background(), "tcp", "db:5432", nil, tunnel, nil, 0); err == nil || err.Error() != `via tunnel "bastion": connection refused` {
+		t.Errorf("expected the tunnel named in the error, got %v", err)
+	}
+
+	start := time.Now()
+	_, err = dialTarget(context.Background(), "tcp", "db:5432", hang, nil, nil, 20*time.Millisecond)
+	if err == nil || !strings.HasPrefix(err.Error(), "connect timeout") {
+		t.Errorf("expected a connect timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the timeout to bound the attempt, took %v", elapsed)
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		target   string
+		wantHost string
+		wantPort string
+		wantErr  bool
+	}{
+		{"example.test:80", "example.test", "80", false},
+		{"example.test", "example.test", "", false},
+		{"10.0.0.1:53", "10.0.0.1", "53", false},
+		{"10.0.0.1", "10.0.0.1", "", false},
+		{"[::1]:80", "::1", "80", false},
+		{"[2001:db8::1]:443", "2001:db8::1", "443", false},
+		{"[fe80::1%eth0]:22", "fe80::1%eth0", "22", false},
+		{"::1", "::1", "", false},
+		{"2001:db8::1", "2001:db8::1", "", false},
+		{"[2001:db8::1]", "2001:db8::1", "", false},
+		{" [::1]:80 ", "::1", "80", false},
+		{"", "", "", true},
+		{":80", "", "", true},
+		{"[example.test]", "", "", true},
+		{"host:80:90", "", "", true},
+		{"[::1]:", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			host, port, err := parseTarget(tt.target)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got host=%q port=%q", host, port)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if host != tt.wantHost || port != tt.wantPort {
+				t.Errorf("got host=%q port=%q, want host=%q port=%q", host, port, tt.wantHost, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestProbes_IPv6Targets(t *testing.T) {
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	t.Run("tcp", func(t *testing.T) {
+		p := &TCPProbe{Timeout: time.Second}
+		res, _ := p.Check(context.Background(), fmt.Sprintf("[::1]:%d", port))
+		if !res.Success {
+			t.Errorf("expected success, got %s", res.Message)
+		}
+		res, _ = p.Check(context.Background(), "::1")
+		if res.Success || !strings.Contains(res.Message, "missing port") {
+			t.Errorf("expected a missing port failure, got %+v", res)
+		}
+	})
+
+	t.Run("udp", func(t *testing.T) {
+		p := &UDPProbe{Timeout: time.Second}
+		res, _ := p.Check(context.Background(), fmt.Sprintf("[::1]:%d", port))
+		if !res.Success {
+			t.Errorf("expected success, got %s", res.Message)
+		}
+	})
+
+	t.Run("dns", func(t *testing.T) {
+		var got []string
+		p := &DNSProbe{Resolve: func(ctx context.Context, nameserver, host string) ([]string, error) {
+			got = append(got, nameserver)
+			return nil, fmt.Errorf("timeout")
+		}}
+		_, _ = p.Check(context.Background(), "::1,[2001:db8::1],[2001:db8::2]:5353")
+		want := []string{"[::1]:53", "[2001:db8::1]:53", "[2001:db8::2]:5353"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("queried nameservers %v, want %v", got, want)
+		}
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		var got string
+		p := &TLSProbe{DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			got = address
+			return nil, fmt.Errorf("refused")
+		}}
+		_, _ = p.Check(context.Background(), "[2001:db8::1]")
+		if got != "[2001:db8::1]:443" {
+			t.Errorf("dialed %q, want [2001:db8::1]:443", got)
+		}
+	})
+
+	t.Run("docker", func(t *testing.T) {
+		p := &DockerProbe{}
+		for _, host := range []string{"::1", "[::1]"} {
+			_, apiURL, err := p.getClient(config.DockerSocketConfig{Host: host, Port: 2375})
+			if err != nil || apiURL != "http://[::1]:2375" {
+				t.Errorf("host %q: got %q, %v, want http://[::1]:2375", host, apiURL, err)
+			}
+		}
+	})
+}
+
+func TestProbes_ConnectTimeout(t *testing.T) {
+	// hang never connects, so only the connect timeout can end the dial
+	hang := func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	authRequired := false
+
+	probes := []struct {
+		name  string
+		probe interface {
+			Probe
+			ConnectTimeoutSetter
+		}
+		target string
+	}{
+		{"tcp", &TCPProbe{DialContext: hang}, "10.0.0.1:80"},
+		{"http", &HTTPProbe{DialContext: hang}, "http://10.0.0.1"},
+		{"tls", &TLSProbe{DialContext: hang}, "10.0.0.1"},
+		{"ssh", &SSHProbe{DialContext: hang, Config: &config.SSHConfig{AuthRequired: &authRequired}}, "10.0.0.1"},
+	}
+	for _, tt := range probes {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.probe.SetTimeout(5 * time.Second)
+			tt.probe.SetConnectTimeout(50 * time.Millisecond)
+
+			start := time.Now()
+			res, _ := tt.probe.Check(context.Background(), tt.target)
+			if res.Success || !strings.Contains(res.Message, "connect timeout") {
+				t.Errorf("expected a connect timeout, got %q", res.Message)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("dial took %v, expected it to stop at the connect timeout", elapsed)
+			}
+		})
+	}
+
+	t.Run("http read timeout", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		defer ts.Close()
+
+		p := &HTTPProbe{Timeout: 100 * time.Millisecond, ConnectTimeout: 50 * time.Millisecond}
+		res, _ := p.Check(context.Background(), ts.URL)
+		if res.Success || !strings.Contains(res.Message, "read timeout") {
+			t.Errorf("expected a read timeout, got %q", res.Message)
+		}
+	})
+}
+
+func TestProbes_SourceAddress(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	probes := []struct {
+		name   string
+		probe  func() Probe
+		target string
+	}{
+		{"tcp", func() Probe { return &TCPProbe{} }, ln.Addr().String()},
+		{"udp", func() Probe { return &UDPProbe{} }, "127.0.0.1:9"},
+		{"http", func() Probe { return &HTTPProbe{} }, ts.URL},
+	}
+	for _, tt := range probes {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.probe()
+			p.(SourceAddressSetter).SetSourceAddress("127.0.0.1")
+			res, _ := p.Check(context.Background(), tt.target)
+			if !res.Success {
+				t.Errorf("expected success from 127.0.0.1, got %q", res.Message)
+			}
+
+			// 192.0.2.0/24 is reserved for documentation, never assigned locally
+			p = tt.probe()
+			p.(SourceAddressSetter).SetSourceAddress("192.0.2.1")
+			res, _ = p.Check(context.Background(), tt.target)
+			if res.Success || !strings.Contains(res.Message, "source address 192.0.2.1 is not available on this host") {
+				t.Errorf("expected an unavailable source address failure, got %q", res.Message)
+			}
+		})
+	}
+}
+
+func TestProbes_Family(t *testing.T) {
+	// The listeners are IPv4 only
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	probes := []struct {
+		name   string
+		probe  func() Probe
+		target string
+		wantOK string
+	}{
+		{"tcp", func() Probe { return &TCPProbe{} }, ln.Addr().String(), "OK (ipv4)"},
+		{"udp", func() Probe { return &UDPProbe{} }, "127.0.0.1:9", "OK (ipv4)"},
+		{"http", func() Probe { return &HTTPProbe{} }, ts.URL, "HTTP 200 (ipv4)"},
+	}
+	for _, tt := range probes {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.probe()
+			p.(FamilySetter).SetFamily(FamilyIPv4)
+			res, _ := p.Check(context.Background(), tt.target)
+			if !res.Success || res.Message != tt.wantOK {
+				t.Errorf("expected %q over ipv4, got %q", tt.wantOK, res.Message)
+			}
+
+			p = tt.probe()
+			p.(FamilySetter).SetFamily(FamilyIPv6)
+			res, _ = p.Check(context.Background(), tt.target)
+			if res.Success || !strings.HasSuffix(res.Message, "(ipv6)") {
+				t.Errorf("expected an ipv6 failure against an ipv4 address, got %q", res.Message)
+			}
+		})
+	}
+
+	t.Run("dual stack name", func(t *testing.T) {
+		_, port, _ := net.SplitHostPort(ln.Addr().String())
+		resolver := &TargetResolver{LookupHost: func(ctx context.Context, host string) ([]string, error) {
+			return []string{"::1", "127.0.0.1"}, nil
+		}}
+		p := &TCPProbe{Resolver: resolver, Family: FamilyIPv4}
+		res, _ := p.Check(context.Background(), "db.test:"+port)
+		if !res.Success || res.Message != "OK (ipv4, db.test resolved to 127.0.0.1)" {
+			t.Errorf("expected the ipv4 address to be dialed, got %q", res.Message)
+		}
+
+		resolver.LookupHost = func(ctx context.Context, host string) ([]string, error) {
+			return []string{"127.0.0.1"}, nil
+		}
+		p.Family = FamilyIPv6
+		res, _ = p.Check(context.Background(), "db.test:"+port)
+		if res.Success || !strings.Contains(res.Message, "failed to resolve db.test: no ipv6 address") {
+			t.Errorf("expected no ipv6 address, got %q", res.Message)
+		}
+	})
+}
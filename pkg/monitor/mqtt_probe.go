@@ -0,0 +1,433 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"probixel/pkg/tunnels"
+)
+
+// MQTT 3.1.1 control packet types, in the high nibble of the fixed header.
+const (
+	mqttConnect    = 1
+	mqttConnAck    = 2
+	mqttPublish    = 3
+	mqttPubAck     = 4
+	mqttSubscribe  = 8
+	mqttSubAck     = 9
+	mqttDisconnect = 14
+)
+
+// mqttMaxPacket bounds the packets read from the broker, which may send large
+// retained messages on a subscribed topic.
+const mqttMaxPacket = 1 << 20
+
+// mqttConnAckErrors describes the CONNACK return codes refusing a connection.
+var mqttConnAckErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// MQTTProbe connects to an MQTT broker with CONNECT, optionally subscribes to
+// a topic and publishes a message to it, which must come back from the broker
+// before the timeout, then disconnects.
+type MQTTProbe struct {
+	ClientID    string // Defaults to a random "probixel-" identifier
+	Username    string
+	Password    string
+	Topic       string // Subscribed to when set
+	QoS         int    // QoS of the subscription and publication, 0 or 1
+	Publish     string // Payload published to Topic and expected back, empty to only subscribe
+	Timeout     time.Duration
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+	targetMode  string
+	tunnel      tunnels.Tunnel
+}
+
+func (p *MQTTProbe) SetTunnel(t tunnels.Tunnel) {
+	p.tunnel = t
+}
+
+func (p *MQTTProbe) Name() string {
+	return MonitorTypeMQTT
+}
+
+func (p *MQTTProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeMQTT,
+		Description: "Connects to an MQTT broker, optionally subscribing to a topic and receiving a message published to it",
+		Fields: []string{
+			"targets",
+			"target_mode",
+			"timeout",
+			"tunnel",
+			"mqtt.client_id",
+			"mqtt.username",
+			"mqtt.password",
+			"mqtt.topic",
+			"mqtt.qos",
+			"mqtt.publish",
+		},
+	}
+}
+
+func (p *MQTTProbe) SetTargetMode(mode string) {
+	p.targetMode = mode
+}
+
+func (p *MQTTProbe) Check(ctx context.Context, target string) (Result, error) {
+	startTotal := time.Now()
+
+	// Strict stabilization adherence: always return Pending if tunnel not stabilized
+	if p.tunnel != nil && !p.tunnel.IsStabilized() {
+		return Result{
+			Success:   false,
+			Pending:   true,
+			Duration:  time.Since(startTotal),
+			Message:   fmt.Sprintf("waiting for tunnel %q to stabilize", p.tunnel.Name()),
+			Timestamp: startTotal,
+		}, nil
+	}
+
+	targets := strings.Split(target, ",")
+	var lastErr error
+	var lastTarget string
+	var results []TargetResult
+	var totalDuration time.Duration
+	successCount := 0
+
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if err := checkExpired(ctx); err != nil {
+			lastErr, lastTarget = err, t
+			break
+		}
+
+		duration, err := p.checkOne(ctx, t)
+		results = append(results, newTargetResult(t, duration, err))
+		if err != nil {
+			if p.targetMode == TargetModeAll {
+				return Result{
+					Success:       false,
+					Message:       fmt.Sprintf("target %s failed: %v", t, err),
+					Target:        t,
+					Timestamp:     startTotal,
+					TargetResults: results,
+				}, nil
+			}
+			lastErr, lastTarget = err, t
+			continue
+		}
+		if p.targetMode != TargetModeAll {
+			return Result{
+				Success:       true,
+				Duration:      duration,
+				Message:       withNote(targetMessage(targets, t, "OK"), p.note()),
+				Target:        t,
+				Timestamp:     startTotal,
+				TargetResults: results,
+			}, nil
+		}
+		totalDuration += duration
+		successCount++
+	}
+
+	if successCount > 0 && lastErr == nil {
+		return Result{
+			Success:       true,
+			Duration:      totalDuration / time.Duration(successCount),
+			Message:       fmt.Sprintf("all %d targets OK", successCount),
+			Timestamp:     startTotal,
+			TargetResults: results,
+		}, nil
+	}
+	if lastErr == nil {
+		return Result{Success: false, Message: "empty target", Timestamp: startTotal}, nil
+	}
+	return Result{
+		Success:       false,
+		Message:       fmt.Sprintf("all targets failed, last error: target %s: %v", lastTarget, lastErr),
+		Target:        lastTarget,
+		Timestamp:     startTotal,
+		TargetResults: results,
+	}, nil
+}
+
+// note describes what the session checked beyond the connection.
+func (p *MQTTProbe) note() string {
+	switch {
+	case p.Topic != "" && p.Publish != "":
+		return fmt.Sprintf("message received on %s", p.Topic)
+	case p.Topic != "":
+		return fmt.Sprintf("subscribed to %s", p.Topic)
+	}
+	return ""
+}
+
+// checkOne runs an MQTT session with target.
+func (p *MQTTProbe) checkOne(ctx context.Context, target string) (time.Duration, error) {
+	addr, _, err := hostPortTarget(target, "1883")
+	if err != nil {
+		return 0, err
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := dialTarget(ctx, "tcp", addr, p.DialContext, p.tunnel, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	// The session is read with plain deadlines, abort it by closing the
+	// connection once ctx is done
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	if err := p.session(conn); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("mqtt session aborted: %w", ctx.Err())
+		}
+		return 0, timeoutError(err, true)
+	}
+	return time.Since(start), nil
+}
+
+// session speaks MQTT 3.1.1 over conn up to DISCONNECT.
+func (p *MQTTProbe) session(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+
+	clientID := p.ClientID
+	if clientID == "" {
+		id := make([]byte, 6)
+		_, _ = rand.Read(id)
+		clientID = "probixel-" + hex.EncodeToString(id)
+	}
+	if _, err := conn.Write(mqttConnectPacket(clientID, p.Username, p.Password)); err != nil {
+		return fmt.Errorf("CONNECT failed: %w", err)
+	}
+	header, body, err := mqttReadPacket(r)
+	if err != nil {
+		return fmt.Errorf("no CONNACK: %w", err)
+	}
+	if header>>4 != mqttConnAck || len(body) != 2 {
+		return fmt.Errorf("expected CONNACK, got packet type %d", header>>4)
+	}
+	if code := body[1]; code != 0 {
+		if msg, ok := mqttConnAckErrors[code]; ok {
+			return fmt.Errorf("connection refused: %s", msg)
+		}
+		return fmt.Errorf("connection refused with code %d", code)
+	}
+
+	if p.Topic != "" {
+		if err := p.subscribe(conn, r); err != nil {
+			return err
+		}
+	}
+	if p.Topic != "" && p.Publish != "" {
+		if err := p.publish(conn, r); err != nil {
+			return err
+		}
+	}
+
+	_, _ = conn.Write([]byte{mqttDisconnect << 4, 0})
+	return nil
+}
+
+// subscribe subscribes to p.Topic and waits for the broker's SUBACK.
+func (p *MQTTProbe) subscribe(conn net.Conn, r *bufio.Reader) error {
+	const packetID = 1
+	var body bytes.Buffer
+	_ = binary.Write(&body, binary.BigEndian, uint16(packetID))
+	mqttWriteString(&body, p.Topic)
+	body.WriteByte(byte(p.QoS))
+	if _, err := conn.Write(mqttPacket(mqttSubscribe<<4|0x02, body.Bytes())); err != nil {
+		return fmt.Errorf("SUBSCRIBE failed: %w", err)
+	}
+
+	for {
+		header, body, err := mqttReadPacket(r)
+		if err != nil {
+			return fmt.Errorf("no SUBACK: %w", err)
+		}
+		if header>>4 != mqttSubAck {
+			continue // Retained messages may arrive first
+		}
+		if len(body) < 3 || binary.BigEndian.Uint16(body) != packetID {
+			return errors.New("malformed SUBACK")
+		}
+		if body[2] == 0x80 {
+			return fmt.Errorf("subscription to %s refused", p.Topic)
+		}
+		return nil
+	}
+}
+
+// publish publishes p.Publish to p.Topic and waits for the broker to deliver
+// it back on the subscription, and to acknowledge it with QoS 1.
+func (p *MQTTProbe) publish(conn net.Conn, r *bufio.Reader) error {
+	const packetID = 2
+	var body bytes.Buffer
+	mqttWriteString(&body, p.Topic)
+	if p.QoS > 0 {
+		_ = binary.Write(&body, binary.BigEndian, uint16(packetID))
+	}
+	body.WriteString(p.Publish)
+	if _, err := conn.Write(mqttPacket(byte(mqttPublish<<4|p.QoS<<1), body.Bytes())); err != nil {
+		return fmt.Errorf("PUBLISH failed: %w", err)
+	}
+
+	acked, received := p.QoS == 0, false
+	for !acked || !received {
+		header, body, err := mqttReadPacket(r)
+		if err != nil {
+			if !acked {
+				return fmt.Errorf("no PUBACK: %w", err)
+			}
+			return fmt.Errorf("published message not received: %w", err)
+		}
+		switch header >> 4 {
+		case mqttPubAck:
+			acked = acked || (len(body) == 2 && binary.BigEndian.Uint16(body) == packetID)
+		case mqttPublish:
+			topic, payload, id, err := mqttParsePublish(header, body)
+			if err != nil {
+				return err
+			}
+			if id != 0 {
+				_, _ = conn.Write(mqttPacket(mqttPubAck<<4, binary.BigEndian.AppendUint16(nil, id)))
+			}
+			received = received || (topic == p.Topic && string(payload) == p.Publish)
+		}
+	}
+	return nil
+}
+
+// mqttConnectPacket builds a CONNECT packet starting a clean session.
+func mqttConnectPacket(clientID, username, password string) []byte {
+	var body bytes.Buffer
+	mqttWriteString(&body, "MQTT")
+	body.WriteByte(4) // Protocol level 3.1.1
+	flags := byte(0x02)
+	if username != "" {
+		flags |= 0x80
+	}
+	if password != "" {
+		flags |= 0x40
+	}
+	body.WriteByte(flags)
+	_ = binary.Write(&body, binary.BigEndian, uint16(60)) // Keep alive
+	mqttWriteString(&body, clientID)
+	if username != "" {
+		mqttWriteString(&body, username)
+	}
+	if password != "" {
+		mqttWriteString(&body, password)
+	}
+	return mqttPacket(mqttConnect<<4, body.Bytes())
+}
+
+// mqttPacket frames body with the fixed header and its remaining length.
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+// mqttReadPacket reads a control packet, returning its fixed header byte,
+// with the packet type in the high nibble and its flags in the low one, and
+// its body.
+func mqttReadPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		multiplier *= 128
+	}
+	if length > mqttMaxPacket {
+		return 0, nil, fmt.Errorf("packet of %d bytes exceeds %d", length, mqttMaxPacket)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// mqttParsePublish returns the topic, payload and packet identifier, 0 for
+// QoS 0, of a PUBLISH packet.
+func mqttParsePublish(header byte, body []byte) (string, []byte, uint16, error) {
+	if len(body) < 2 {
+		return "", nil, 0, errors.New("malformed PUBLISH")
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+n {
+		return "", nil, 0, errors.New("malformed PUBLISH")
+	}
+	topic, rest := string(body[2:2+n]), body[2+n:]
+	var id uint16
+	if (header>>1)&0x03 > 0 {
+		if len(rest) < 2 {
+			return "", nil, 0, errors.New("malformed PUBLISH")
+		}
+		id, rest = binary.BigEndian.Uint16(rest), rest[2:]
+	}
+	return topic, rest, id, nil
+}
+
+func mqttWriteString(b *bytes.Buffer, s string) {
+	_ = binary.Write(b, binary.BigEndian, uint16(len(s)))
+	b.WriteString(s)
+}
+
+func (p *MQTTProbe) SetTimeout(timeout time.Duration) {
+	p.Timeout = timeout
+}
@@ -0,0 +1,265 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"probixel/pkg/tunnels"
+)
+
+// mockBroker is a minimal MQTT broker: it accepts the CONNECT of username and
+// password, acknowledges subscriptions and QoS 1 publications, and delivers
+// publications back to the client when it subscribed to their topic.
+type mockBroker struct {
+	username, password string
+	dropPublish        bool // Never deliver publications back
+	refuseSubscribe    bool
+}
+
+func startMockBroker(t *testing.T, b *mockBroker) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (b *mockBroker) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+
+	header, body, err := mqttReadPacket(r)
+	if err != nil || header>>4 != mqttConnect {
+		return
+	}
+	code := byte(0)
+	if username, password := mqttConnectCredentials(body); username != b.username || password != b.password {
+		code = 4
+	}
+	_, _ = conn.Write(mqttPacket(mqttConnAck<<4, []byte{0, code}))
+	if code != 0 {
+		return
+	}
+
+	subscribed := map[string]byte{}
+	for {
+		header, body, err := mqttReadPacket(r)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case mqttSubscribe:
+			topic := string(body[4 : 4+binary.BigEndian.Uint16(body[2:])])
+			qos := body[len(body)-1]
+			if b.refuseSubscribe {
+				qos = 0x80
+			} else {
+				subscribed[topic] = qos
+			}
+			_, _ = conn.Write(mqttPacket(mqttSubAck<<4, []byte{body[0], body[1], qos}))
+		case mqttPublish:
+			topic, payload, id, err := mqttParsePublish(header, body)
+			if err != nil {
+				return
+			}
+			if id != 0 {
+				_, _ = conn.Write(mqttPacket(mqttPubAck<<4, binary.BigEndian.AppendUint16(nil, id)))
+			}
+			qos, ok := subscribed[topic]
+			if !ok || b.dropPublish {
+				continue
+			}
+			var out bytes.Buffer
+			mqttWriteString(&out, topic)
+			if qos > 0 {
+				_ = binary.Write(&out, binary.BigEndian, uint16(7))
+			}
+			out.Write(payload)
+			_, _ = conn.Write(mqttPacket(mqttPublish<<4|qos<<1, out.Bytes()))
+		case mqttDisconnect:
+			return
+		}
+	}
+}
+
+// mqttConnectCredentials returns the username and password of a CONNECT body.
+func mqttConnectCredentials(body []byte) (string, string) {
+	flags := body[7]
+	rest := body[10:]
+	next := func() string {
+		n := int(binary.BigEndian.Uint16(rest))
+		s := string(rest[2 : 2+n])
+		rest = rest[2+n:]
+		return s
+	}
+	next() // Client identifier
+	var username, password string
+	if flags&0x80 != 0 {
+		username = next()
+	}
+	if flags&0x40 != 0 {
+		password = next()
+	}
+	return username, password
+}
+
+func TestMQTTProbe_Name(t *testing.T) {
+	p := &MQTTProbe{}
+	if p.Name() != MonitorTypeMQTT {
+		t.Errorf("Expected name %s, got %s", MonitorTypeMQTT, p.Name())
+	}
+}
+
+func TestMQTTProbe_Check(t *testing.T) {
+	tests := []struct {
+		name        string
+		broker      mockBroker
+		probe       MQTTProbe
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{
+			name:        "connect",
+			wantSuccess: true,
+			wantMsg:     "OK",
+		},
+		{
+			name:        "authenticated",
+			broker:      mockBroker{username: "probe", password: "secret"},
+			probe:       MQTTProbe{Username: "probe", Password: "secret"},
+			wantSuccess: true,
+			wantMsg:     "OK",
+		},
+		{
+			name:    "bad credentials",
+			broker:  mockBroker{username: "probe", password: "secret"},
+			probe:   MQTTProbe{Username: "probe", Password: "wrong"},
+			wantMsg: "connection refused: bad user name or password",
+		},
+		{
+			name:        "subscribe",
+			probe:       MQTTProbe{Topic: "probixel/health"},
+			wantSuccess: true,
+			wantMsg:     "OK (subscribed to probixel/health)",
+		},
+		{
+			name:    "subscription refused",
+			broker:  mockBroker{refuseSubscribe: true},
+			probe:   MQTTProbe{Topic: "probixel/health"},
+			wantMsg: "subscription to probixel/health refused",
+		},
+		{
+			name:        "publish QoS 0",
+			probe:       MQTTProbe{Topic: "probixel/health", Publish: "ping"},
+			wantSuccess: true,
+			wantMsg:     "OK (message received on probixel/health)",
+		},
+		{
+			name:        "publish QoS 1",
+			probe:       MQTTProbe{Topic: "probixel/health", Publish: "ping", QoS: 1},
+			wantSuccess: true,
+			wantMsg:     "OK (message received on probixel/health)",
+		},
+		{
+			name:    "publication never delivered",
+			broker:  mockBroker{dropPublish: true},
+			probe:   MQTTProbe{Topic: "probixel/health", Publish: "ping", QoS: 1},
+			wantMsg: "published message not received",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startMockBroker(t, &tt.broker)
+			p := tt.probe
+			p.SetTimeout(500 * time.Millisecond)
+
+			res, err := p.Check(context.Background(), addr)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess {
+				t.Errorf("Expected success %v, got %v: %s", tt.wantSuccess, res.Success, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("Expected message containing %q, got %q", tt.wantMsg, res.Message)
+			}
+		})
+	}
+}
+
+func TestMQTTProbe_NotABroker(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+		_ = conn.Close()
+	}()
+
+	p := &MQTTProbe{Timeout: 500 * time.Millisecond}
+	res, _ := p.Check(context.Background(), ln.Addr().String())
+	if res.Success {
+		t.Fatalf("Expected failure against a non-MQTT server, got: %s", res.Message)
+	}
+}
+
+func TestMQTTProbe_TargetModeAll(t *testing.T) {
+	up := startMockBroker(t, &mockBroker{})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	down := ln.Addr().String()
+	_ = ln.Close()
+
+	p := &MQTTProbe{Timeout: 500 * time.Millisecond}
+	p.SetTargetMode(TargetModeAll)
+	res, _ := p.Check(context.Background(), up+","+down)
+	if res.Success || !strings.Contains(res.Message, "target "+down+" failed") {
+		t.Errorf("Expected failure of %s, got: %s", down, res.Message)
+	}
+
+	p.SetTargetMode(TargetModeAny)
+	res, _ = p.Check(context.Background(), down+","+up)
+	if !res.Success || res.Target != up {
+		t.Errorf("Expected success on %s, got: %s", up, res.Message)
+	}
+}
+
+func TestMQTTProbe_Stabilization(t *testing.T) {
+	mt := &tunnels.MockTunnel{IsStabilizedResult: false}
+	p := &MQTTProbe{}
+	p.SetTunnel(mt)
+
+	res, err := p.Check(context.Background(), "127.0.0.1:1883")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Pending {
+		t.Error("Expected Pending: true")
+	}
+}
@@ -0,0 +1,226 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"probixel/pkg/tunnels"
+)
+
+// NTPProbe sends an SNTP client request (RFC 4330) to each target and
+// reports the round-trip and the offset of the server clock from ours.
+type NTPProbe struct {
+	MaxOffset   time.Duration // Fail when the server clock is off by more than this, 0 to only report it
+	Timeout     time.Duration
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+	targetMode  string
+	tunnel      tunnels.Tunnel
+}
+
+const (
+	ntpPacketSize = 48
+	// Seconds between the NTP epoch (1900) and the Unix epoch (1970)
+	ntpEpochOffset = 2208988800
+)
+
+func (p *NTPProbe) SetTunnel(t tunnels.Tunnel) {
+	p.tunnel = t
+}
+
+func (p *NTPProbe) Name() string {
+	return MonitorTypeNTP
+}
+
+func (p *NTPProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeNTP,
+		Description: "Queries NTP servers, checking the offset of their clock",
+		Fields:      []string{"targets", "target_mode", "timeout", "tunnel", "ntp.max_offset"},
+	}
+}
+
+func (p *NTPProbe) SetTargetMode(mode string) {
+	p.targetMode = mode
+}
+
+func (p *NTPProbe) Check(ctx context.Context, target string) (Result, error) {
+	startTotal := time.Now()
+
+	// Strict stabilization adherence: always return Pending if tunnel not stabilized
+	if p.tunnel != nil && !p.tunnel.IsStabilized() {
+		return Result{
+			Success:   false,
+			Pending:   true,
+			Duration:  time.Since(startTotal),
+			Message:   fmt.Sprintf("waiting for tunnel %q to stabilize", p.tunnel.Name()),
+			Timestamp: startTotal,
+		}, nil
+	}
+
+	targets := strings.Split(target, ",")
+	var lastErr error
+	var lastTarget string
+	var results []TargetResult
+	var totalDuration time.Duration
+	successCount := 0
+
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if err := checkExpired(ctx); err != nil {
+			lastErr, lastTarget = err, t
+			break
+		}
+
+		duration, note, err := p.checkOne(ctx, t)
+		results = append(results, newTargetResult(t, duration, err))
+		if err != nil {
+			if p.targetMode == TargetModeAll {
+				return Result{
+					Success:       false,
+					Message:       fmt.Sprintf("target %s failed: %v", t, err),
+					Target:        t,
+					Timestamp:     startTotal,
+					TargetResults: results,
+				}, nil
+			}
+			lastErr, lastTarget = err, t
+			continue
+		}
+		if p.targetMode != TargetModeAll {
+			return Result{
+				Success:       true,
+				Duration:      duration,
+				Message:       withNote(targetMessage(targets, t, "OK"), note),
+				Target:        t,
+				Timestamp:     startTotal,
+				TargetResults: results,
+			}, nil
+		}
+		totalDuration += duration
+		successCount++
+	}
+
+	if successCount > 0 && lastErr == nil {
+		return Result{
+			Success:       true,
+			Duration:      totalDuration / time.Duration(successCount),
+			Message:       fmt.Sprintf("all %d targets OK", successCount),
+			Timestamp:     startTotal,
+			TargetResults: results,
+		}, nil
+	}
+	if lastErr == nil {
+		return Result{Success: false, Message: "empty target", Timestamp: startTotal}, nil
+	}
+	return Result{
+		Success:       false,
+		Message:       fmt.Sprintf("all targets failed, last error: target %s: %v", lastTarget, lastErr),
+		Target:        lastTarget,
+		Timestamp:     startTotal,
+		TargetResults: results,
+	}, nil
+}
+
+// checkOne queries target, returning the round-trip, which excludes the
+// server's processing time, and a note reporting the clock offset.
+func (p *NTPProbe) checkOne(ctx context.Context, target string) (time.Duration, string, error) {
+	addr, _, err := hostPortTarget(target, "123")
+	if err != nil {
+		return 0, "", err
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := dialTarget(ctx, "udp", addr, p.DialContext, p.tunnel, nil, 0)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() { _ = conn.Close() }()
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	req := make([]byte, ntpPacketSize)
+	req[0] = 4<<3 | 3 // Version 4, client mode
+	sent := time.Now()
+	binary.BigEndian.PutUint64(req[40:], toNTPTime(sent))
+	if _, err := conn.Write(req); err != nil {
+		return 0, "", fmt.Errorf("write failed: %w", err)
+	}
+
+	resp := make([]byte, 512)
+	n, err := conn.Read(resp)
+	received := time.Now()
+	if err != nil {
+		return 0, "", timeoutError(fmt.Errorf("no response: %w", err), true)
+	}
+	if n < ntpPacketSize {
+		return 0, "", fmt.Errorf("short response of %d bytes", n)
+	}
+	resp = resp[:n]
+
+	if mode := resp[0] & 0x7; mode != 4 {
+		return 0, "", fmt.Errorf("unexpected response mode %d", mode)
+	}
+	if !bytes.Equal(resp[24:32], req[40:48]) {
+		return 0, "", errors.New("response does not match the request")
+	}
+	stratum := resp[1]
+	if stratum == 0 {
+		// Kiss-o'-Death, the reference ID holds the code
+		return 0, "", fmt.Errorf("kiss-o'-death from server: %s", strings.TrimRight(string(resp[12:16]), "\x00"))
+	}
+	if resp[0]>>6 == 3 || stratum > 15 {
+		return 0, "", errors.New("server clock is not synchronized")
+	}
+
+	serverReceive := fromNTPTime(binary.BigEndian.Uint64(resp[32:]))
+	serverTransmit := fromNTPTime(binary.BigEndian.Uint64(resp[40:]))
+	offset := (serverReceive.Sub(sent) + serverTransmit.Sub(received)) / 2
+	rtt := received.Sub(sent) - serverTransmit.Sub(serverReceive)
+	if rtt < 0 {
+		rtt = 0
+	}
+
+	if p.MaxOffset > 0 && (offset > p.MaxOffset || offset < -p.MaxOffset) {
+		return 0, "", fmt.Errorf("clock offset %v exceeds %v", offset.Round(time.Microsecond), p.MaxOffset)
+	}
+	return rtt, fmt.Sprintf("offset %v, stratum %d", offset.Round(time.Microsecond), stratum), nil
+}
+
+// toNTPTime converts t to a 64-bit NTP timestamp, seconds since 1900 and
+// their fraction.
+func toNTPTime(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := (uint64(t.Nanosecond()) << 32) / uint64(time.Second)
+	return secs<<32 | frac
+}
+
+// fromNTPTime converts a 64-bit NTP timestamp to a time. As RFC 4330
+// suggests, timestamps without the high bit set are taken to be past 2036,
+// when the seconds wrap around.
+func fromNTPTime(v uint64) time.Time {
+	secs := int64(v >> 32)
+	if secs&0x80000000 == 0 {
+		secs += 1 << 32
+	}
+	nanos := (int64(v&0xffffffff) * int64(time.Second)) >> 32
+	return time.Unix(secs-ntpEpochOffset, nanos)
+}
+
+func (p *NTPProbe) SetTimeout(timeout time.Duration) {
+	p.Timeout = timeout
+}
@@ -0,0 +1,205 @@
+package monitor
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"probixel/pkg/tunnels"
+)
+
+// startMockNTPServer answers each request with a server packet built by
+// reply from the request and a clock skewed by skew. A nil reply never
+// answers.
+func startMockNTPServer(t *testing.T, skew time.Duration, reply func(req, resp []byte)) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if reply == nil || n < ntpPacketSize {
+				continue
+			}
+			now := time.Now().Add(skew)
+			resp := make([]byte, ntpPacketSize)
+			resp[0] = 4<<3 | 4 // Version 4, server mode
+			resp[1] = 2        // Stratum
+			copy(resp[12:16], "GPS\x00")
+			copy(resp[24:32], buf[40:48]) // Origin timestamp
+			binary.BigEndian.PutUint64(resp[32:], toNTPTime(now))
+			binary.BigEndian.PutUint64(resp[40:], toNTPTime(now))
+			reply(buf[:n], resp)
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestNTPProbe_Name(t *testing.T) {
+	p := &NTPProbe{}
+	if p.Name() != MonitorTypeNTP {
+		t.Errorf("Expected name %s, got %s", MonitorTypeNTP, p.Name())
+	}
+}
+
+func TestNTPProbe_Check(t *testing.T) {
+	valid := func(req, resp []byte) {}
+
+	tests := []struct {
+		name        string
+		skew        time.Duration
+		reply       func(req, resp []byte)
+		maxOffset   time.Duration
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{
+			name:        "in sync",
+			reply:       valid,
+			maxOffset:   time.Second,
+			wantSuccess: true,
+			wantMsg:     "stratum 2",
+		},
+		{
+			name:        "offset reported without max_offset",
+			skew:        -3 * time.Second,
+			reply:       valid,
+			wantSuccess: true,
+			wantMsg:     "OK (offset -",
+		},
+		{
+			name:      "offset exceeds max_offset",
+			skew:      3 * time.Second,
+			reply:     valid,
+			maxOffset: time.Second,
+			wantMsg:   "exceeds 1s",
+		},
+		{
+			name:      "negative offset exceeds max_offset",
+			skew:      -3 * time.Second,
+			reply:     valid,
+			maxOffset: time.Second,
+			wantMsg:   "clock offset -",
+		},
+		{
+			name:    "kiss-o'-death",
+			reply:   func(req, resp []byte) { resp[1] = 0; copy(resp[12:16], "RATE") },
+			wantMsg: "kiss-o'-death from server: RATE",
+		},
+		{
+			name:    "unsynchronized",
+			reply:   func(req, resp []byte) { resp[0] |= 3 << 6 },
+			wantMsg: "server clock is not synchronized",
+		},
+		{
+			name:    "origin mismatch",
+			reply:   func(req, resp []byte) { resp[31]++ },
+			wantMsg: "response does not match the request",
+		},
+		{
+			name:    "wrong mode",
+			reply:   func(req, resp []byte) { resp[0] = 4<<3 | 3 },
+			wantMsg: "unexpected response mode 3",
+		},
+		{
+			name:    "no response",
+			wantMsg: "read timeout: no response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startMockNTPServer(t, tt.skew, tt.reply)
+			p := &NTPProbe{MaxOffset: tt.maxOffset}
+			p.SetTimeout(200 * time.Millisecond)
+			res, err := p.Check(context.Background(), addr)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess {
+				t.Errorf("Expected success %v, got %v: %s", tt.wantSuccess, res.Success, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("Expected message containing %q, got %q", tt.wantMsg, res.Message)
+			}
+		})
+	}
+}
+
+func TestNTPProbe_RequestPacket(t *testing.T) {
+	requests := make(chan []byte, 1)
+	addr := startMockNTPServer(t, 0, func(req, resp []byte) {
+		requests <- append([]byte(nil), req...)
+	})
+
+	p := &NTPProbe{}
+	if res, _ := p.Check(context.Background(), addr); !res.Success {
+		t.Fatalf("Expected success, got: %s", res.Message)
+	}
+	req := <-requests
+	if len(req) != ntpPacketSize {
+		t.Fatalf("Expected a %d byte request, got %d", ntpPacketSize, len(req))
+	}
+	if version, mode := req[0]>>3&0x7, req[0]&0x7; version != 4 || mode != 3 {
+		t.Errorf("Expected a version 4 client request, got version %d mode %d", version, mode)
+	}
+	if sent := fromNTPTime(binary.BigEndian.Uint64(req[40:])); time.Since(sent).Abs() > time.Second {
+		t.Errorf("Expected the transmit timestamp to be now, got %v", sent)
+	}
+}
+
+func TestNTPProbe_TargetModeAll(t *testing.T) {
+	up := startMockNTPServer(t, 0, func(req, resp []byte) {})
+	down := startMockNTPServer(t, 0, nil)
+
+	p := &NTPProbe{}
+	p.SetTimeout(100 * time.Millisecond)
+	p.SetTargetMode(TargetModeAll)
+	res, _ := p.Check(context.Background(), up+","+down)
+	if res.Success || !strings.Contains(res.Message, "target "+down+" failed") {
+		t.Errorf("Expected failure of %s, got: %s", down, res.Message)
+	}
+
+	p.SetTargetMode(TargetModeAny)
+	res, _ = p.Check(context.Background(), down+","+up)
+	if !res.Success || res.Target != up {
+		t.Errorf("Expected success on %s, got: %s", up, res.Message)
+	}
+}
+
+func TestNTPTime(t *testing.T) {
+	for _, want := range []time.Time{
+		time.Date(2026, 10, 15, 12, 30, 0, 500000000, time.UTC),
+		time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC), // After the 2036 wrap around
+	} {
+		got := fromNTPTime(toNTPTime(want))
+		if d := got.Sub(want).Abs(); d > time.Microsecond {
+			t.Errorf("Expected %v after a round trip, got %v", want, got)
+		}
+	}
+}
+
+func TestNTPProbe_Stabilization(t *testing.T) {
+	mt := &tunnels.MockTunnel{IsStabilizedResult: false}
+	p := &NTPProbe{}
+	p.SetTunnel(mt)
+
+	res, err := p.Check(context.Background(), "pool.ntp.org")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Pending {
+		t.Error("Expected Pending: true")
+	}
+}
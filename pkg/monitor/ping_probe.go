@@ -2,6 +2,7 @@ package monitor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -16,16 +17,74 @@ import (
 
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 // execCommand is a variable to allow mocking in tests
 var execCommand = exec.CommandContext
 
+// fragmentationPattern matches ping output reporting that a packet was too
+// large to send with the DF bit set, across Linux, macOS and Windows.
+var fragmentationPattern = regexp.MustCompile(`(?i)message too long|frag(mentation)? needed|needs to be fragmented`)
+
+var mtuPattern = regexp.MustCompile(`(?i)mtu\s*=\s*(\d+)`)
+
+// sourcePattern matches ping output reporting that the source address can't
+// be bound, across Linux and macOS.
+var sourcePattern = regexp.MustCompile(`(?i)can(not|'t) assign requested address|invalid source`)
+
+// ttlExceededPattern matches ping output reporting that a router dropped the
+// packet as its TTL ran out, capturing the router's address, across Linux,
+// macOS and Windows.
+var ttlExceededPattern = regexp.MustCompile(`(?i)from ([0-9a-f.:]*[0-9a-f])[: ].*(time to live exceeded|ttl expired)`)
+
+// pingOptions are the packet options passed to the ping executable.
+type pingOptions struct {
+	PacketSize   int
+	DontFragment bool
+	DSCP         int
+	Source       string
+	TTL          int    // Hops the echo request may take, 0 for the default
+	Family       string // FamilyIPv4 or FamilyIPv6 to ping over that family only, empty for either
+}
+
+// ttlExceededError is returned by a ping with a TTL when a router on the way
+// answered in place of the target.
+type ttlExceededError struct {
+	hop string // Address of the router, empty if unknown
+}
+
+func (e *ttlExceededError) Error() string {
+	if e.hop == "" {
+		return "time to live exceeded"
+	}
+	return fmt.Sprintf("time to live exceeded at %s", e.hop)
+}
+
+// ttlSetter is implemented by sockets that set the TTL of outgoing packets
+// themselves, without a file descriptor for ipv4.Conn to configure.
+type ttlSetter interface {
+	SetTTL(ttl int) error
+}
+
+// errTTLUnsupported is returned by pingBuiltin when the socket can't limit
+// the TTL of echo requests.
+var errTTLUnsupported = errors.New("ttl is not supported for built-in ICMP over a tunnel")
+
 type PingProbe struct {
-	targetMode  string
-	Timeout     time.Duration
-	tunnel      tunnels.Tunnel
-	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+	targetMode    string
+	quorum        int
+	concurrency   int
+	Timeout       time.Duration
+	tunnel        tunnels.Tunnel
+	DialContext   func(ctx context.Context, network, address string) (net.Conn, error)
+	Resolver      *TargetResolver // Optional, resolves hostname targets before pinging
+	PacketSize    int             // ICMP payload size in bytes, 0 for the default
+	DontFragment  bool            // Set the DF bit so oversized packets fail instead of fragmenting
+	DSCP          int             // DSCP value marked on echo requests, 0 to leave unmarked
+	SourceAddress string          // Local IP echo requests are sent from, empty for the OS default
+	Family        string          // FamilyIPv4 or FamilyIPv6 to ping over that family only, empty for either
+	DNSCache      *DNSCache       // Optional, reuses resolutions of direct pings within its TTL
 }
 
 func (p *PingProbe) SetTunnel(t tunnels.Tunnel) {
@@ -36,14 +95,42 @@ func (p *PingProbe) Name() string {
 	return MonitorTypePing
 }
 
+func (p *PingProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypePing,
+		Description: "Sends an ICMP echo request to each target",
+		Fields: []string{
+			"targets",
+			"target_mode",
+			"quorum",
+			"concurrency",
+			"timeout",
+			"tunnel",
+			"source_address",
+			"family",
+			"ping.resolve",
+			"ping.resolve_to",
+			"ping.packet_size",
+			"ping.dont_fragment",
+			"ping.dscp",
+		},
+	}
+}
+
 func (p *PingProbe) SetTargetMode(mode string) {
 	p.targetMode = mode
 }
 
+func (p *PingProbe) SetQuorum(n int) {
+	p.quorum = n
+}
+
+func (p *PingProbe) SetConcurrency(n int) {
+	p.concurrency = n
+}
+
 func (p *PingProbe) Check(ctx context.Context, target string) (Result, error) {
 	targets := strings.Split(target, ",")
-	var lastErr error
-
 	startTotal := time.Now()
 
 	// Strict stabilization adherence: always return Pending if tunnel not stabilized
@@ -57,98 +144,80 @@ func (p *PingProbe) Check(ctx context.Context, target string) (Result, error) {
 		}, nil
 	}
 
-	// For "all" mode, track successes
-	if p.targetMode == TargetModeAll {
-		var totalDuration time.Duration
-		successCount := 0
-
-		for _, t := range targets {
-			t = strings.TrimSpace(t)
-			if t == "" {
-				continue
-			}
-
+	return checkTargets(ctx, targets, targetCheck{
+		mode:        p.targetMode,
+		quorum:      p.quorum,
+		concurrency: p.concurrency,
+		okMessage:   "all %d targets OK",
+		failMessage: func(t string, err error) string {
+			return fmt.Sprintf("all ping targets failed, last error: target %s: %v", t, err)
+		},
+		check: func(ctx context.Context, t string) (time.Duration, string, error) {
 			start := time.Now()
-			duration, _, err := p.pingTarget(ctx, t)
-
+			duration, msg, err := p.pingTarget(ctx, t)
 			if err != nil {
-				return Result{
-					Success:   false,
-					Duration:  0,
-					Message:   fmt.Sprintf("target %s failed: %v", t, err),
-					Timestamp: startTotal,
-				}, nil
+				return 0, "", err
 			}
-
-			totalDuration += duration
 			if duration == 0 {
-				totalDuration += time.Since(start)
+				duration = time.Since(start)
 			}
-			successCount++
-		}
+			return duration, msg, nil
+		},
+	}, startTotal), nil
+}
 
-		if successCount > 0 {
-			return Result{
-				Success:   true,
-				Duration:  totalDuration / time.Duration(successCount),
-				Message:   fmt.Sprintf("all %d targets OK", successCount),
-				Timestamp: startTotal,
-			}, nil
-		}
+// pingTarget resolves target if a resolver is configured and pings it,
+// adding the forced family and resolution note to the returned message or
+// error.
+func (p *PingProbe) pingTarget(ctx context.Context, target string) (time.Duration, string, error) {
+	host, port, err := parseTarget(target)
+	if err != nil {
+		return 0, "", err
 	}
-
-	// Default "any" mode
-	for _, t := range targets {
-		t = strings.TrimSpace(t)
-		if t == "" {
-			continue
-		}
-
-		start := time.Now()
-		duration, msg, err := p.pingTarget(ctx, t)
-
-		if err == nil {
-			if duration == 0 {
-				duration = time.Since(start)
-			}
-			return Result{
-				Success:   true,
-				Duration:  duration,
-				Message:   msg,
-				Target:    t,
-				Timestamp: startTotal,
-			}, nil
-		}
-		lastErr = err
+	if port != "" {
+		return 0, "", fmt.Errorf("invalid target %q: ping targets take no port", target)
 	}
-
-	return Result{
-		Success:   false,
-		Duration:  0,
-		Message:   fmt.Sprintf("all ping targets failed, last error: %v", lastErr),
-		Timestamp: startTotal,
-	}, nil
+	network := familyNetwork("ip", p.Family)
+	addr, note, err := p.Resolver.resolve(ctx, network, host)
+	if err == nil && p.DialContext == nil {
+		addr, err = p.DNSCache.resolve(ctx, network, addr)
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	note = joinNotes(familyNote(p.Family), note)
+	duration, msg, err := p.ping(ctx, addr, p.options())
+	if err != nil {
+		p.DNSCache.forget(host)
+	}
+	if err != nil && note != "" {
+		err = fmt.Errorf("%w (%s)", err, note)
+	}
+	return duration, withNote(msg, note), err
 }
 
-func (p *PingProbe) pingTarget(ctx context.Context, target string) (time.Duration, string, error) {
+// ping sends a single echo request with opts to target, over the tunnel's
+// ICMP socket if it has one, running ping on the SSH server or locally
+// otherwise.
+func (p *PingProbe) ping(ctx context.Context, target string, opts pingOptions) (time.Duration, string, error) {
 	if p.DialContext != nil {
-		duration, msg, err := p.pingBuiltin(ctx, target)
+		duration, msg, err := p.pingBuiltin(ctx, target, opts)
 		if err != nil && strings.Contains(err.Error(), "unsupported protocol") {
 			// SSH tunnels don't support ICMP - try remote ping execution
 			if p.tunnel != nil {
 				if sshTunnel, ok := p.tunnel.(*tunnels.SSHTunnel); ok {
-					return p.pingRemoteSSH(ctx, sshTunnel, target)
+					return p.pingRemoteSSH(ctx, sshTunnel, target, opts)
 				}
 			}
 			// Fallback to local executable ping
-			return p.pingExecutable(ctx, target)
+			return p.pingExecutable(ctx, target, opts)
 		}
 		return duration, msg, err
 	}
-	return p.pingExecutable(ctx, target)
+	return p.pingExecutable(ctx, target, opts)
 }
 
-func (p *PingProbe) pingExecutable(ctx context.Context, target string) (time.Duration, string, error) {
+func (p *PingProbe) pingExecutable(ctx context.Context, target string, opts pingOptions) (time.Duration, string, error) {
 	timeout := p.Timeout
 	if timeout == 0 {
 		timeout = 5 * time.Second
@@ -156,11 +225,20 @@ func (p *PingProbe) pingExecutable(ctx context.Context, target string) (time.Dur
 	ctxCmd, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	name, args := getPingArgs(runtime.GOOS, target, timeout)
+	name, args := getPingArgs(runtime.GOOS, target, timeout, opts)
 	cmd := execCommand(ctxCmd, name, args...)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
+		if ttlErr := ttlExceeded(opts, string(output)); ttlErr != nil {
+			return 0, "", ttlErr
+		}
+		if fragErr := p.fragmentationError(string(output)); fragErr != nil {
+			return 0, "", fragErr
+		}
+		if p.SourceAddress != "" && sourcePattern.MatchString(string(output)) {
+			return 0, "", fmt.Errorf("source address %s is not available on this host: %w", p.SourceAddress, err)
+		}
 		return 0, "", err
 	}
 
@@ -171,7 +249,7 @@ func (p *PingProbe) pingExecutable(ctx context.Context, target string) (time.Dur
 	return rtt, "OK", nil
 }
 
-func (p *PingProbe) pingRemoteSSH(ctx context.Context, sshTunnel *tunnels.SSHTunnel, target string) (time.Duration, string, error) {
+func (p *PingProbe) pingRemoteSSH(ctx context.Context, sshTunnel *tunnels.SSHTunnel, target string, opts pingOptions) (time.Duration, string, error) {
 	start := time.Now()
 
 	// Get SSH client from tunnel
@@ -192,12 +270,23 @@ func (p *PingProbe) pingRemoteSSH(ctx context.Context, sshTunnel *tunnels.SSHTun
 	if timeout == 0 {
 		timeout = 5 * time.Second
 	}
-	name, args := getPingArgs("linux", target, timeout) // SSH usually targets Linux/Unix
+	name, args := getPingArgs("linux", target, timeout, opts) // SSH usually targets Linux/Unix
 	cmd := fmt.Sprintf("%s %s", name, strings.Join(args, " "))
 
-	// Execute remote ping
+	// Execute remote ping, closing the session to abort it once ctx is done
+	stop := context.AfterFunc(ctx, func() { _ = session.Close() })
+	defer stop()
 	output, err := session.CombinedOutput(cmd)
+	if ctx.Err() != nil {
+		return 0, "", fmt.Errorf("remote ping aborted: %w", ctx.Err())
+	}
 	if err != nil {
+		if ttlErr := ttlExceeded(opts, string(output)); ttlErr != nil {
+			return 0, "", ttlErr
+		}
+		if fragErr := p.fragmentationError(string(output)); fragErr != nil {
+			return 0, "", fragErr
+		}
 		return 0, "", fmt.Errorf("remote ping failed: %w", err)
 	}
 
@@ -211,15 +300,51 @@ func (p *PingProbe) pingRemoteSSH(ctx context.Context, sshTunnel *tunnels.SSHTun
 	return rtt, "OK", nil
 }
 
-func (p *PingProbe) pingBuiltin(ctx context.Context, target string) (time.Duration, string, error) {
+func (p *PingProbe) pingBuiltin(ctx context.Context, target string, opts pingOptions) (time.Duration, string, error) {
+	// Tunnel sockets don't expose IP-level options
+	if opts.DontFragment {
+		return 0, "", fmt.Errorf("dont_fragment is not supported for built-in ICMP over a tunnel")
+	}
+
+	// Without a forced family the echo request goes over IPv4
+	v6 := opts.Family == FamilyIPv6
+	network, echo, proto := "ping4", icmp.Type(ipv4.ICMPTypeEcho), 1 // 1 for ICMPv4
+	if v6 {
+		network, echo, proto = "ping6", ipv6.ICMPTypeEchoRequest, 58 // 58 for ICMPv6
+	}
 
 	start := time.Now()
-	socket, err := p.DialContext(ctx, "ping4", target)
+	socket, err := p.DialContext(ctx, network, target)
 	if err != nil {
-		return 0, "", fmt.Errorf("dial ping4 failed: %w", err)
+		return 0, "", fmt.Errorf("dial %s failed: %w", network, err)
 	}
 	defer func() { _ = socket.Close() }()
 
+	if opts.DSCP > 0 {
+		if v6 {
+			err = ipv6.NewConn(socket).SetTrafficClass(opts.DSCP << 2)
+		} else {
+			err = ipv4.NewConn(socket).SetTOS(opts.DSCP << 2)
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("dscp is not supported for built-in ICMP over a tunnel: %w", err)
+		}
+	}
+	if opts.TTL > 0 {
+		var err error
+		switch s, ok := socket.(ttlSetter); {
+		case ok:
+			err = s.SetTTL(opts.TTL)
+		case v6:
+			err = ipv6.NewConn(socket).SetHopLimit(opts.TTL)
+		default:
+			err = ipv4.NewConn(socket).SetTTL(opts.TTL)
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("%w: %w", errTTLUnsupported, err)
+		}
+	}
+
 	if deadline, ok := ctx.Deadline(); ok {
 		_ = socket.SetReadDeadline(deadline)
 	} else {
@@ -231,12 +356,12 @@ func (p *PingProbe) pingBuiltin(ctx context.Context, target string) (time.Durati
 	}
 
 	msg := icmp.Message{
-		Type: ipv4.ICMPTypeEcho,
+		Type: echo,
 		Code: 0,
 		Body: &icmp.Echo{
 			ID:   os.Getpid() & 0xffff,
 			Seq:  1,
-			Data: []byte("PROBIXEL"),
+			Data: p.payload(),
 		},
 	}
 
@@ -249,37 +374,191 @@ func (p *PingProbe) pingBuiltin(ctx context.Context, target string) (time.Durati
 		return 0, "", fmt.Errorf("ping write: %w", err)
 	}
 
-	reply := make([]byte, 1500)
-	n, err := socket.Read(reply)
+	// Abort the read once ctx is done, the read deadline only covers timeouts
+	stop := context.AfterFunc(ctx, func() { _ = socket.Close() })
+	defer stop()
+
+	// Time exceeded replies come from the router rather than the target,
+	// only a packet socket tells which one
+	reply := make([]byte, max(1500, len(icmpBytes)+64))
+	var from net.Addr
+	var n int
+	if pc, ok := socket.(net.PacketConn); ok && opts.TTL > 0 {
+		n, from, err = pc.ReadFrom(reply)
+	} else {
+		n, err = socket.Read(reply)
+	}
+	if ctx.Err() != nil {
+		return 0, "", fmt.Errorf("ping aborted: %w", ctx.Err())
+	}
 	if err != nil {
 		return 0, "", fmt.Errorf("ping read: %w", err)
 	}
 
 	duration := time.Since(start)
 
-	rm, err := icmp.ParseMessage(1, reply[:n]) // 1 for ICMPv4
+	rm, err := icmp.ParseMessage(proto, reply[:n])
 	if err != nil {
 		return duration, "OK (parse failed)", nil
 	}
 
 	switch rm.Type {
-	case ipv4.ICMPTypeEchoReply:
+	case ipv4.ICMPTypeEchoReply, ipv6.ICMPTypeEchoReply:
 		return duration, "OK", nil
+	case ipv4.ICMPTypeTimeExceeded, ipv6.ICMPTypeTimeExceeded:
+		if opts.TTL == 0 {
+			return 0, "", fmt.Errorf("unexpected ICMP type: %v", rm.Type)
+		}
+		hop := ""
+		if from != nil {
+			hop = from.String()
+		}
+		return 0, "", &ttlExceededError{hop: hop}
 	default:
+		// Destination unreachable, code 4: fragmentation needed and DF set,
+		// IPv6 routers never fragment and report the packet as too big
+		if (rm.Type == ipv4.ICMPTypeDestinationUnreachable && rm.Code == 4) || rm.Type == ipv6.ICMPTypePacketTooBig {
+			return 0, "", fmt.Errorf("packet of %d bytes needs fragmentation", len(icmpBytes))
+		}
 		return 0, "", fmt.Errorf("unexpected ICMP type: %v", rm.Type)
 	}
 }
 
-func getPingArgs(goos, target string, timeout time.Duration) (string, []string) {
+func (p *PingProbe) options() pingOptions {
+	return pingOptions{PacketSize: p.PacketSize, DontFragment: p.DontFragment, DSCP: p.DSCP, Source: p.SourceAddress, Family: p.Family}
+}
+
+// payload returns the ICMP echo data, padded to PacketSize if configured.
+func (p *PingProbe) payload() []byte {
+	data := []byte("PROBIXEL")
+	if p.PacketSize <= 0 {
+		return data
+	}
+	padded := make([]byte, p.PacketSize)
+	copy(padded, data)
+	return padded
+}
+
+// ttlExceeded returns a ttlExceededError when ping output with a TTL set
+// shows a router dropped the echo request, or nil otherwise.
+func ttlExceeded(opts pingOptions, output string) error {
+	if opts.TTL == 0 {
+		return nil
+	}
+	m := ttlExceededPattern.FindStringSubmatch(output)
+	if m == nil {
+		return nil
+	}
+	return &ttlExceededError{hop: m[1]}
+}
+
+// fragmentationError returns a distinct error when ping output shows the packet
+// could not be sent without fragmentation, or nil otherwise.
+func (p *PingProbe) fragmentationError(output string) error {
+	if !fragmentationPattern.MatchString(output) {
+		return nil
+	}
+	msg := "packet needs fragmentation but DF is set"
+	if p.PacketSize > 0 {
+		msg = fmt.Sprintf("packet of %d bytes needs fragmentation but DF is set", p.PacketSize)
+	}
+	if m := mtuPattern.FindStringSubmatch(output); len(m) > 1 {
+		msg = fmt.Sprintf("%s (path mtu %s)", msg, m[1])
+	}
+	return errors.New(msg)
+}
+
+func getPingArgs(goos, target string, timeout time.Duration, opts pingOptions) (string, []string) {
 	timeoutSec := int(timeout.Seconds())
 	if timeoutSec == 0 {
 		timeoutSec = 5
 	}
 
-	if goos == "windows" {
-		return "ping", []string{"-n", "1", "-w", strconv.Itoa(timeoutSec * 1000), target}
+	var args []string
+	switch goos {
+	case "windows":
+		args = []string{"-n", "1", "-w", strconv.Itoa(timeoutSec * 1000)}
+		if opts.PacketSize > 0 {
+			args = append(args, "-l", strconv.Itoa(opts.PacketSize))
+		}
+		if opts.DontFragment {
+			args = append(args, "-f")
+		}
+		if opts.DSCP > 0 {
+			args = append(args, "-v", strconv.Itoa(opts.DSCP<<2))
+		}
+		if opts.Source != "" {
+			args = append(args, "-S", opts.Source)
+		}
+		if opts.TTL > 0 {
+			args = append(args, "-i", strconv.Itoa(opts.TTL))
+		}
+		args = append(args, familyFlag(opts.Family)...)
+	case "darwin":
+		if opts.Family == FamilyIPv6 {
+			// macOS pings IPv6 with a separate executable, the check's
+			// context bounds its wait
+			args = []string{"-c", "1"}
+			if opts.PacketSize > 0 {
+				args = append(args, "-s", strconv.Itoa(opts.PacketSize))
+			}
+			if opts.Source != "" {
+				args = append(args, "-S", opts.Source)
+			}
+			if opts.TTL > 0 {
+				args = append(args, "-n", "-h", strconv.Itoa(opts.TTL))
+			}
+			return "ping6", append(args, target)
+		}
+		args = []string{"-c", "1", "-W", strconv.Itoa(timeoutSec)}
+		if opts.PacketSize > 0 {
+			args = append(args, "-s", strconv.Itoa(opts.PacketSize))
+		}
+		if opts.DontFragment {
+			args = append(args, "-D")
+		}
+		if opts.DSCP > 0 {
+			args = append(args, "-z", strconv.Itoa(opts.DSCP<<2))
+		}
+		if opts.Source != "" {
+			args = append(args, "-S", opts.Source)
+		}
+		if opts.TTL > 0 {
+			// Numeric output, so the router answering can be parsed
+			args = append(args, "-n", "-m", strconv.Itoa(opts.TTL))
+		}
+	default:
+		args = []string{"-c", "1", "-W", strconv.Itoa(timeoutSec)}
+		if opts.PacketSize > 0 {
+			args = append(args, "-s", strconv.Itoa(opts.PacketSize))
+		}
+		if opts.DontFragment {
+			args = append(args, "-M", "do")
+		}
+		if opts.DSCP > 0 {
+			args = append(args, "-Q", strconv.Itoa(opts.DSCP<<2))
+		}
+		if opts.Source != "" {
+			args = append(args, "-I", opts.Source)
+		}
+		if opts.TTL > 0 {
+			args = append(args, "-n", "-t", strconv.Itoa(opts.TTL))
+		}
+		args = append(args, familyFlag(opts.Family)...)
+	}
+	return "ping", append(args, target)
+}
+
+// familyFlag returns the ping flag restricting it to family, none when the
+// family isn't forced.
+func familyFlag(family string) []string {
+	switch family {
+	case FamilyIPv4:
+		return []string{"-4"}
+	case FamilyIPv6:
+		return []string{"-6"}
 	}
-	return "ping", []string{"-c", "1", "-W", strconv.Itoa(timeoutSec), target}
+	return nil
 }
 
 func parsePingTime(output string) (time.Duration, error) {
@@ -300,3 +579,11 @@ func parsePingTime(output string) (time.Duration, error) {
 func (p *PingProbe) SetTimeout(timeout time.Duration) {
 	p.Timeout = timeout
 }
+
+func (p *PingProbe) SetSourceAddress(addr string) {
+	p.SourceAddress = addr
+}
+
+func (p *PingProbe) SetFamily(family string) {
+	p.Family = family
+}
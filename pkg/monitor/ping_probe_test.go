@@ -10,7 +10,9 @@ import (
 	"os/exec"
 	"probixel/pkg/config"
 	"probixel/pkg/tunnels"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -26,6 +28,25 @@ func fakeExecCommand(ctx context.Context, command string, args ...string) *exec.
 	return cmd
 }
 
+// helperCheckBackup mocks a script run by the exec probe, its first
+// argument picks the outcome.
+func helperCheckBackup(args []string) {
+	switch args[0] {
+	case "fresh":
+		fmt.Println("checking /srv/backup")
+		fmt.Println("backup ok, 3h old")
+		os.Exit(0)
+	case "stale":
+		// Reported on stdout only
+		fmt.Println("backup is 30h old")
+		os.Exit(1)
+	case "verbose":
+		fmt.Println(strings.Repeat("x", 300))
+		os.Exit(0)
+	}
+	os.Exit(2)
+}
+
 // TestHelperProcess isn't a real test. It's used as a mock process.
 func TestHelperProcess(t *testing.T) {
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
@@ -50,6 +71,9 @@ func TestHelperProcess(t *testing.T) {
 
 	cmd, cmdArgs := args[0], args[1:]
 
+	if cmd == "check-backup" {
+		helperCheckBackup(cmdArgs)
+	}
 	if cmd != "ping" {
 		fmt.Fprintf(os.Stderr, "Unknown command %q\n", cmd)
 		os.Exit(2)
@@ -57,12 +81,32 @@ func TestHelperProcess(t *testing.T) {
 
 	// Simple argument check
 	target := cmdArgs[len(cmdArgs)-1]
+	ttl := 64
+	for i, arg := range cmdArgs[:len(cmdArgs)-1] {
+		if arg == "-t" {
+			ttl, _ = strconv.Atoi(cmdArgs[i+1])
+		}
+	}
 
 	switch target {
 	case "localhost.test":
 		// Success
 		fmt.Printf("time=10.5 ms\n")
 		os.Exit(0)
+	case "hops.test":
+		// Three hops away, the routers answer
+		if ttl < 3 {
+			fmt.Printf("From 10.0.0.%d icmp_seq=1 Time to live exceeded\n", ttl)
+			os.Exit(1)
+		}
+		fmt.Printf("time=20.5 ms\n")
+		os.Exit(0)
+	case "blackhole.test":
+		// Dropped after the second hop
+		if ttl <= 2 {
+			fmt.Printf("From 10.0.0.%d icmp_seq=1 Time to live exceeded\n", ttl)
+		}
+		os.Exit(1)
 	case "unreachable.test":
 		// Failure (timeout or unreachable)
 		// Simulate delay?
@@ -146,6 +190,30 @@ func TestPingProbe_AllMode_FailFast(t *testing.T) {
 	}
 }
 
+func TestPingProbe_QuorumMode(t *testing.T) {
+	oldExec := execCommand
+	defer func() { execCommand = oldExec }()
+	execCommand = fakeExecCommand
+
+	probe := &PingProbe{}
+	probe.SetTargetMode(TargetModeQuorum)
+
+	probe.SetQuorum(2)
+	res, _ := probe.Check(context.Background(), "localhost.test,unreachable.test,localhost.test")
+	if !res.Success || res.Message != "2/3 up (need 2)" {
+		t.Errorf("expected quorum success, got %v: %s", res.Success, res.Message)
+	}
+	if len(res.TargetResults) != 3 || res.TargetResults[1].Success {
+		t.Errorf("expected every target tried, got %+v", res.TargetResults)
+	}
+
+	probe.SetQuorum(3)
+	res, _ = probe.Check(context.Background(), "localhost.test,unreachable.test,localhost.test")
+	if res.Success || res.Target != "unreachable.test" {
+		t.Errorf("expected quorum failure on unreachable.test, got %v (%s): %s", res.Success, res.Target, res.Message)
+	}
+}
+
 func TestParsePingTime_Manual(t *testing.T) {
 	_, _ = parsePingTime("time=abc ms")
 	_, _ = parsePingTime("no time here")
@@ -217,19 +285,38 @@ func TestPingProbe_Check_EmptyTargets(t *testing.T) {
 }
 
 func TestGetPingArgs(t *testing.T) {
+	mtu := pingOptions{PacketSize: 1472, DontFragment: true}
 	tests := []struct {
 		goos     string
 		target   string
+		opts     pingOptions
 		wantName string
 		wantArgs []string
 	}{
-		{"windows", "1.2.3.4", "ping", []string{"-n", "1", "-w", "5000", "1.2.3.4"}},
-		{"linux", "1.2.3.4", "ping", []string{"-c", "1", "-W", "5", "1.2.3.4"}},
-		{"darwin", "1.2.3.4", "ping", []string{"-c", "1", "-W", "5", "1.2.3.4"}},
+		{"windows", "1.2.3.4", pingOptions{}, "ping", []string{"-n", "1", "-w", "5000", "1.2.3.4"}},
+		{"linux", "1.2.3.4", pingOptions{}, "ping", []string{"-c", "1", "-W", "5", "1.2.3.4"}},
+		{"darwin", "1.2.3.4", pingOptions{}, "ping", []string{"-c", "1", "-W", "5", "1.2.3.4"}},
+		{"windows", "1.2.3.4", mtu, "ping", []string{"-n", "1", "-w", "5000", "-l", "1472", "-f", "1.2.3.4"}},
+		{"linux", "1.2.3.4", mtu, "ping", []string{"-c", "1", "-W", "5", "-s", "1472", "-M", "do", "1.2.3.4"}},
+		{"darwin", "1.2.3.4", mtu, "ping", []string{"-c", "1", "-W", "5", "-s", "1472", "-D", "1.2.3.4"}},
+		{"linux", "1.2.3.4", pingOptions{PacketSize: 56}, "ping", []string{"-c", "1", "-W", "5", "-s", "56", "1.2.3.4"}},
+		{"windows", "1.2.3.4", pingOptions{DSCP: 46}, "ping", []string{"-n", "1", "-w", "5000", "-v", "184", "1.2.3.4"}},
+		{"linux", "1.2.3.4", pingOptions{DSCP: 46}, "ping", []string{"-c", "1", "-W", "5", "-Q", "184", "1.2.3.4"}},
+		{"darwin", "1.2.3.4", pingOptions{DSCP: 46}, "ping", []string{"-c", "1", "-W", "5", "-z", "184", "1.2.3.4"}},
+		{"windows", "1.2.3.4", pingOptions{Source: "10.0.0.5"}, "ping", []string{"-n", "1", "-w", "5000", "-S", "10.0.0.5", "1.2.3.4"}},
+		{"linux", "1.2.3.4", pingOptions{Source: "10.0.0.5"}, "ping", []string{"-c", "1", "-W", "5", "-I", "10.0.0.5", "1.2.3.4"}},
+		{"darwin", "1.2.3.4", pingOptions{Source: "10.0.0.5"}, "ping", []string{"-c", "1", "-W", "5", "-S", "10.0.0.5", "1.2.3.4"}},
+		{"windows", "1.2.3.4", pingOptions{TTL: 3}, "ping", []string{"-n", "1", "-w", "5000", "-i", "3", "1.2.3.4"}},
+		{"linux", "1.2.3.4", pingOptions{TTL: 3}, "ping", []string{"-c", "1", "-W", "5", "-n", "-t", "3", "1.2.3.4"}},
+		{"darwin", "1.2.3.4", pingOptions{TTL: 3}, "ping", []string{"-c", "1", "-W", "5", "-n", "-m", "3", "1.2.3.4"}},
+		{"windows", "db.test", pingOptions{Family: FamilyIPv4}, "ping", []string{"-n", "1", "-w", "5000", "-4", "db.test"}},
+		{"linux", "db.test", pingOptions{Family: FamilyIPv6}, "ping", []string{"-c", "1", "-W", "5", "-6", "db.test"}},
+		{"darwin", "db.test", pingOptions{Family: FamilyIPv4}, "ping", []string{"-c", "1", "-W", "5", "db.test"}},
+		{"darwin", "db.test", pingOptions{Family: FamilyIPv6, Source: "2001:db8::5"}, "ping6", []string{"-c", "1", "-S", "2001:db8::5", "db.test"}},
 	}
 
 	for _, tt := range tests {
-		name, args := getPingArgs(tt.goos, tt.target, 0)
+		name, args := getPingArgs(tt.goos, tt.target, 0, tt.opts)
 		if name != tt.wantName {
 			t.Errorf("getPingArgs(%s) name = %v, want %v", tt.goos, name, tt.wantName)
 		}
@@ -304,6 +391,168 @@ func TestPingProbe_Builtin(t *testing.T) {
 		t.Errorf("Expected success, got failure: %s", res.Message)
 	}
 }
+
+// v6PingConn answers echo requests with ICMPv6 echo replies.
+type v6PingConn struct {
+	*mockPingConn
+}
+
+func (m *v6PingConn) Write(b []byte) (int, error) {
+	n, err := m.mockPingConn.Write(b)
+	m.readData[0] = 129 // Echo Reply
+	return n, err
+}
+
+func TestPingProbe_Builtin_Family(t *testing.T) {
+	var network string
+	probe := &PingProbe{
+		Family: FamilyIPv6,
+		DialContext: func(ctx context.Context, n, address string) (net.Conn, error) {
+			network = n
+			return &v6PingConn{&mockPingConn{}}, nil
+		},
+	}
+	res, err := probe.Check(context.Background(), "2001:db8::1")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if network != "ping6" {
+		t.Errorf("Expected a ping6 socket, got %s", network)
+	}
+	if !res.Success || res.Message != "OK (ipv6)" {
+		t.Errorf("Expected success over ipv6, got %q", res.Message)
+	}
+}
+
+func TestPingProbe_Builtin_PacketSize(t *testing.T) {
+	var sent int
+	probe := &PingProbe{
+		PacketSize: 1000,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return &recordingPingConn{mockPingConn: &mockPingConn{}, sent: &sent}, nil
+		},
+	}
+	res, err := probe.Check(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Success {
+		t.Fatalf("Expected success, got failure: %s", res.Message)
+	}
+	// 8 byte ICMP header plus the padded payload
+	if sent != 1008 {
+		t.Errorf("Expected 1008 bytes on the wire, got %d", sent)
+	}
+}
+
+func TestPingProbe_Builtin_DontFragmentUnsupported(t *testing.T) {
+	probe := &PingProbe{
+		DontFragment: true,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return &mockPingConn{}, nil
+		},
+	}
+	res, err := probe.Check(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if res.Success || !strings.Contains(res.Message, "dont_fragment is not supported") {
+		t.Errorf("Expected dont_fragment unsupported failure, got %q", res.Message)
+	}
+}
+
+func TestPingProbe_Builtin_DSCPUnsupported(t *testing.T) {
+	probe := &PingProbe{
+		DSCP: 46,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return &mockPingConn{}, nil
+		},
+	}
+	res, err := probe.Check(context.Background(), "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if res.Success || !strings.Contains(res.Message, "dscp is not supported") {
+		t.Errorf("Expected dscp unsupported failure, got %q", res.Message)
+	}
+}
+
+type recordingPingConn struct {
+	*mockPingConn
+	sent *int
+}
+
+func (c *recordingPingConn) Write(b []byte) (int, error) {
+	*c.sent = len(b)
+	return c.mockPingConn.Write(b)
+}
+
+func TestPingProbe_FragmentationNeeded(t *testing.T) {
+	oldExec := execCommand
+	defer func() { execCommand = oldExec }()
+
+	outputs := map[string]string{
+		"linux":   "ping: local error: message too long, mtu=1420",
+		"router":  "From 10.0.0.1 icmp_seq=1 Frag needed and DF set (mtu = 1400)",
+		"windows": "Packet needs to be fragmented but DF set.",
+	}
+	for name, out := range outputs {
+		t.Run(name, func(t *testing.T) {
+			execCommand = func(ctx context.Context, _ string, arg ...string) *exec.Cmd {
+				return exec.Command("sh", "-c", "echo \"$0\"; exit 1", out) //nolint:gosec // G204: fixed test output
+			}
+
+			p := &PingProbe{PacketSize: 1472, DontFragment: true}
+			res, err := p.Check(context.Background(), "127.0.0.1")
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success {
+				t.Fatal("Expected failure")
+			}
+			if !strings.Contains(res.Message, "packet of 1472 bytes needs fragmentation but DF is set") {
+				t.Errorf("Expected fragmentation message, got %q", res.Message)
+			}
+			if m := mtuPattern.FindStringSubmatch(out); m != nil && !strings.Contains(res.Message, "path mtu "+m[1]) {
+				t.Errorf("Expected path mtu in message, got %q", res.Message)
+			}
+		})
+	}
+
+	// A plain timeout is not reported as a fragmentation failure
+	execCommand = func(ctx context.Context, _ string, arg ...string) *exec.Cmd {
+		return exec.Command("false")
+	}
+	p := &PingProbe{PacketSize: 1472, DontFragment: true}
+	res, _ := p.Check(context.Background(), "127.0.0.1")
+	if res.Success || strings.Contains(res.Message, "fragmentation") {
+		t.Errorf("Expected plain failure, got %q", res.Message)
+	}
+}
+
+func TestPingProbe_SourceUnavailable(t *testing.T) {
+	oldExec := execCommand
+	defer func() { execCommand = oldExec }()
+
+	outputs := map[string]string{
+		"linux":  "ping: bind icmp socket: Cannot assign requested address",
+		"darwin": "ping: bind: Can't assign requested address",
+	}
+	for name, out := range outputs {
+		t.Run(name, func(t *testing.T) {
+			execCommand = func(ctx context.Context, _ string, arg ...string) *exec.Cmd {
+				return exec.Command("sh", "-c", "echo \"$0\"; exit 2", out) //nolint:gosec // G204: fixed test output
+			}
+
+			p := &PingProbe{SourceAddress: "192.0.2.1"}
+			res, _ := p.Check(context.Background(), "127.0.0.1")
+			if res.Success || !strings.Contains(res.Message, "source address 192.0.2.1 is not available on this host") {
+				t.Errorf("Expected source address failure, got %q", res.Message)
+			}
+		})
+	}
+}
+
 func TestPingProbe_Timeout(t *testing.T) {
 	oldExec := execCommand
 	defer func() { execCommand = oldExec }()
@@ -680,3 +929,43 @@ func TestPingProbe_pingTarget_FallbackToExecutable(t *testing.T) {
 		t.Error("Expected fallback to executable ping")
 	}
 }
+
+// blockingPingConn blocks reads until it is closed.
+type blockingPingConn struct {
+	mockPingConn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (c *blockingPingConn) Read(b []byte) (int, error) {
+	<-c.closed
+	return 0, net.ErrClosed
+}
+
+func (c *blockingPingConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+func TestPingProbe_Builtin_AbortsOnCancel(t *testing.T) {
+	probe := &PingProbe{
+		Timeout: 30 * time.Second,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return &blockingPingConn{closed: make(chan struct{})}, nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	res, err := probe.Check(ctx, "8.8.8.8")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected Check to return promptly after cancel, took %v", elapsed)
+	}
+	if res.Success || !strings.Contains(res.Message, "ping aborted") {
+		t.Errorf("Expected aborted ping, got %q", res.Message)
+	}
+}
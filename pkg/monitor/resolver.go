@@ -0,0 +1,23 @@
+package monitor
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+)
+
+// NewResolver returns a resolver sending its queries to servers, host:port
+// addresses of DNS servers, instead of the system's. Successive queries go
+// to the servers in turn, so the resolver's own retries reach the next one
+// when a server doesn't answer.
+func NewResolver(servers []string) *net.Resolver {
+	var next atomic.Uint32
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			server := servers[int(next.Add(1)-1)%len(servers)]
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
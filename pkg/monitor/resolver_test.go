@@ -0,0 +1,109 @@
+package monitor
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newMockResolver starts a UDP DNS server resolving app.internal to
+// 127.0.0.1 and nothing else. It returns its address and a count of the
+// queries for app.internal it answered.
+func newMockResolver(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+
+	var queries atomic.Int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			q := query.Questions[0]
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+			if q.Name.String() != "app.internal." {
+				resp.RCode = dnsmessage.RCodeNameError
+			} else {
+				queries.Add(1)
+				if q.Type == dnsmessage.TypeA {
+					resp.Answers = []dnsmessage.Resource{{
+						Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+						Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+					}}
+				}
+			}
+			packed, _ := resp.Pack()
+			_, _ = pc.WriteTo(packed, addr)
+		}
+	}()
+	return pc.LocalAddr().String(), &queries
+}
+
+func TestNewResolver(t *testing.T) {
+	first, firstQueries := newMockResolver(t)
+	second, secondQueries := newMockResolver(t)
+	r := NewResolver([]string{first, second})
+
+	for range 4 {
+		addrs, err := r.LookupHost(context.Background(), "app.internal")
+		if err != nil {
+			t.Fatalf("LookupHost failed: %v", err)
+		}
+		if len(addrs) != 1 || addrs[0] != "127.0.0.1" {
+			t.Fatalf("Expected 127.0.0.1, got %v", addrs)
+		}
+	}
+	if firstQueries.Load() == 0 || secondQueries.Load() == 0 {
+		t.Errorf("Expected queries to go to both servers, got %d and %d", firstQueries.Load(), secondQueries.Load())
+	}
+
+	if _, err := r.LookupHost(context.Background(), "unknown.internal"); err == nil {
+		t.Error("Expected unknown names not to resolve")
+	}
+}
+
+func TestProbes_NameResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+
+	addr, queries := newMockResolver(t)
+	resolver := NewResolver([]string{addr})
+
+	tcp := &TCPProbe{}
+	tcp.SetNameResolver(resolver)
+	res, err := tcp.Check(context.Background(), "app.internal:"+port)
+	if err != nil || !res.Success {
+		t.Errorf("Expected the tcp probe to resolve through the resolver, got %v: %s", err, res.Message)
+	}
+
+	web := &HTTPProbe{Method: "GET"}
+	web.SetNameResolver(resolver)
+	res, err = web.Check(context.Background(), "http://app.internal:"+port)
+	if err != nil || !res.Success {
+		t.Errorf("Expected the http probe to resolve through the resolver, got %v: %s", err, res.Message)
+	}
+
+	if got := queries.Load(); got < 2 {
+		t.Errorf("Expected each probe to query the resolver, got %d queries", got)
+	}
+}
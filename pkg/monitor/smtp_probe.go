@@ -0,0 +1,300 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"probixel/pkg/tunnels"
+)
+
+// SMTPProbe connects to a mail server, reads its greeting and introduces
+// itself with EHLO, optionally upgrading the session with STARTTLS and
+// checking the expiry of the server certificate, then says QUIT.
+type SMTPProbe struct {
+	StartTLS           bool
+	ExpiryThreshold    time.Duration // Fail when the STARTTLS certificate expires sooner, 0 to only report it
+	InsecureSkipVerify bool
+	CACert             string // CA bundle to verify the server, file path or inline PEM
+	Timeout            time.Duration
+	ConnectTimeout     time.Duration // Bounds the dial, 0 to use Timeout
+	DialContext        func(ctx context.Context, network, address string) (net.Conn, error)
+	NameResolver       *net.Resolver // Optional, resolves hostnames instead of the system resolver
+	targetMode         string
+	tunnel             tunnels.Tunnel
+}
+
+// smtpHelloName is the name the probe introduces itself with in EHLO.
+const smtpHelloName = "localhost"
+
+func (p *SMTPProbe) SetTunnel(t tunnels.Tunnel) {
+	p.tunnel = t
+}
+
+func (p *SMTPProbe) Name() string {
+	return MonitorTypeSMTP
+}
+
+func (p *SMTPProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeSMTP,
+		Description: "Greets a mail server with EHLO, optionally checking its STARTTLS certificate",
+		Fields: []string{
+			"targets",
+			"target_mode",
+			"timeout",
+			"connect_timeout",
+			"tunnel",
+			"resolver",
+			"smtp.starttls",
+			"smtp.certificate_expiry",
+			"smtp.insecure_skip_verify",
+			"smtp.ca_cert",
+		},
+	}
+}
+
+func (p *SMTPProbe) SetTargetMode(mode string) {
+	p.targetMode = mode
+}
+
+func (p *SMTPProbe) Check(ctx context.Context, target string) (Result, error) {
+	startTotal := time.Now()
+
+	// Strict stabilization adherence: always return Pending if tunnel not stabilized
+	if p.tunnel != nil && !p.tunnel.IsStabilized() {
+		return Result{
+			Success:   false,
+			Pending:   true,
+			Duration:  time.Since(startTotal),
+			Message:   fmt.Sprintf("waiting for tunnel %q to stabilize", p.tunnel.Name()),
+			Timestamp: startTotal,
+		}, nil
+	}
+
+	targets := strings.Split(target, ",")
+	var lastErr error
+	var lastTarget string
+	var lastTLS *TLSInfo
+	var results []TargetResult
+	var totalDuration time.Duration
+	successCount := 0
+
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if err := checkExpired(ctx); err != nil {
+			lastErr, lastTarget = err, t
+			break
+		}
+
+		duration, tlsInfo, err := p.checkOne(ctx, t)
+		results = append(results, newTargetResult(t, duration, err))
+		if err != nil {
+			if p.targetMode == TargetModeAll {
+				return Result{
+					Success:       false,
+					Message:       fmt.Sprintf("target %s failed: %v", t, err),
+					Target:        t,
+					Timestamp:     startTotal,
+					TargetResults: results,
+					TLS:           tlsInfo,
+				}, nil
+			}
+			lastErr, lastTarget, lastTLS = err, t, tlsInfo
+			continue
+		}
+		if p.targetMode != TargetModeAll {
+			return Result{
+				Success:       true,
+				Duration:      duration,
+				Message:       withNote(targetMessage(targets, t, "OK"), p.note(tlsInfo)),
+				Target:        t,
+				Timestamp:     startTotal,
+				TargetResults: results,
+				TLS:           tlsInfo,
+			}, nil
+		}
+		totalDuration += duration
+		successCount++
+	}
+
+	if successCount > 0 && lastErr == nil {
+		return Result{
+			Success:       true,
+			Duration:      totalDuration / time.Duration(successCount),
+			Message:       fmt.Sprintf("all %d targets OK", successCount),
+			Timestamp:     startTotal,
+			TargetResults: results,
+		}, nil
+	}
+	if lastErr == nil {
+		return Result{Success: false, Message: "empty target", Timestamp: startTotal}, nil
+	}
+	return Result{
+		Success:       false,
+		Message:       fmt.Sprintf("all targets failed, last error: target %s: %v", lastTarget, lastErr),
+		Target:        lastTarget,
+		Timestamp:     startTotal,
+		TargetResults: results,
+		TLS:           lastTLS,
+	}, nil
+}
+
+// note reports the certificate of a STARTTLS session in the result message.
+func (p *SMTPProbe) note(tlsInfo *TLSInfo) string {
+	if tlsInfo == nil {
+		return ""
+	}
+	days := int(time.Until(tlsInfo.NotAfter).Hours() / 24)
+	return fmt.Sprintf("STARTTLS, TLS expires in %d days: %s", days, tlsInfo)
+}
+
+// checkOne runs an SMTP session with target, returning the certificate of
+// the server when STARTTLS was used.
+func (p *SMTPProbe) checkOne(ctx context.Context, target string) (time.Duration, *TLSInfo, error) {
+	addr, host, err := hostPortTarget(target, "25")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := dialTarget(ctx, "tcp", addr, p.DialContext, p.tunnel, p.NameResolver, connectTimeout(p.ConnectTimeout, timeout))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	// The session is read with plain deadlines, abort it by closing the
+	// connection once ctx is done
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	tlsInfo, err := p.session(ctx, conn, host)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("smtp session aborted: %w", ctx.Err())
+		}
+		return 0, tlsInfo, timeoutError(err, true)
+	}
+	return time.Since(start), tlsInfo, nil
+}
+
+// session speaks SMTP over conn up to QUIT.
+func (p *SMTPProbe) session(ctx context.Context, conn net.Conn, host string) (*TLSInfo, error) {
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return nil, fmt.Errorf("unexpected greeting: %w", smtpReplyError(err))
+	}
+
+	extensions, err := smtpCmd(text, 250, "EHLO %s", smtpHelloName)
+	if err != nil {
+		return nil, fmt.Errorf("EHLO rejected: %w", err)
+	}
+	if !p.StartTLS {
+		_, _ = smtpCmd(text, 221, "QUIT")
+		return nil, nil
+	}
+
+	if !smtpExtension(extensions, "STARTTLS") {
+		return nil, errors.New("server does not offer STARTTLS")
+	}
+	if _, err := smtpCmd(text, 220, "STARTTLS"); err != nil {
+		return nil, fmt.Errorf("STARTTLS rejected: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: p.InsecureSkipVerify, // nolint:gosec // deliberate feature
+		ServerName:         host,
+	}
+	if tlsConfig.RootCAs, err = rootCAs(p.CACert); err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("STARTTLS handshake failed: %w", err)
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("STARTTLS: no certificates found")
+	}
+	tlsInfo := newTLSInfo(certs[0])
+
+	// The session starts over once encrypted
+	text = textproto.NewConn(tlsConn)
+	if _, err := smtpCmd(text, 250, "EHLO %s", smtpHelloName); err != nil {
+		return tlsInfo, fmt.Errorf("EHLO rejected after STARTTLS: %w", err)
+	}
+	_, _ = smtpCmd(text, 221, "QUIT")
+
+	remaining := time.Until(tlsInfo.NotAfter)
+	if remaining < 0 {
+		return tlsInfo, fmt.Errorf("certificate EXPIRED on %s: %s by %s", tlsInfo.NotAfter.Format("2006-01-02"), tlsInfo.Subject, tlsInfo.Issuer)
+	}
+	if remaining < p.ExpiryThreshold {
+		return tlsInfo, fmt.Errorf("certificate expires soon: %d days remaining (threshold: %v): %s", int(remaining.Hours()/24), p.ExpiryThreshold, tlsInfo)
+	}
+	return tlsInfo, nil
+}
+
+// smtpCmd sends a command and reads its reply, which must have code.
+func smtpCmd(text *textproto.Conn, code int, format string, args ...any) (string, error) {
+	id, err := text.Cmd(format, args...)
+	if err != nil {
+		return "", err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	_, msg, err := text.ReadResponse(code)
+	return msg, smtpReplyError(err)
+}
+
+// smtpReplyError reports an unexpected reply as the server sent it, rather
+// than with the quoted text of textproto.Error.
+func smtpReplyError(err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return fmt.Errorf("%03d %s", reply.Code, strings.ReplaceAll(reply.Msg, "\n", " "))
+	}
+	return err
+}
+
+// smtpExtension reports whether the EHLO reply msg advertises name.
+func smtpExtension(msg, name string) bool {
+	lines := strings.Split(msg, "\n")
+	// The first line greets the client
+	for _, line := range lines[1:] {
+		keyword, _, _ := strings.Cut(line, " ")
+		if strings.EqualFold(keyword, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *SMTPProbe) SetTimeout(timeout time.Duration) {
+	p.Timeout = timeout
+}
+
+func (p *SMTPProbe) SetConnectTimeout(timeout time.Duration) {
+	p.ConnectTimeout = timeout
+}
+
+func (p *SMTPProbe) SetNameResolver(r *net.Resolver) {
+	p.NameResolver = r
+}
@@ -0,0 +1,274 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"probixel/pkg/tunnels"
+)
+
+// mockSMTPServer answers SMTP sessions with the given greeting, offering
+// STARTTLS with cert when it is set.
+type mockSMTPServer struct {
+	greeting   string
+	rejectEHLO bool
+	cert       *tls.Certificate
+}
+
+// start serves SMTP on a local port until the test ends and returns its address.
+func (m mockSMTPServer) start(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func (m mockSMTPServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("%s", m.greeting)
+	if !strings.HasPrefix(m.greeting, "220") {
+		return
+	}
+	encrypted := false
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			switch {
+			case m.rejectEHLO:
+				_ = text.PrintfLine("502 5.5.1 EHLO not implemented")
+			case m.cert != nil && !encrypted:
+				_ = text.PrintfLine("250-mail.example.com greets you")
+				_ = text.PrintfLine("250-SIZE 10240000")
+				_ = text.PrintfLine("250 STARTTLS")
+			default:
+				_ = text.PrintfLine("250 mail.example.com greets you")
+			}
+		case "STARTTLS":
+			if m.cert == nil || encrypted {
+				_ = text.PrintfLine("502 5.5.1 STARTTLS not available")
+				continue
+			}
+			_ = text.PrintfLine("220 2.0.0 Ready to start TLS")
+			tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*m.cert}})
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			text = textproto.NewConn(tlsConn)
+			encrypted = true
+		case "QUIT":
+			_ = text.PrintfLine("221 2.0.0 Bye")
+			return
+		default:
+			_ = text.PrintfLine("500 5.5.2 Unknown command")
+		}
+	}
+}
+
+// generateSMTPCert returns a self-signed certificate for 127.0.0.1 valid for
+// validFor, and its PEM for use as a CA bundle.
+func generateSMTPCert(t *testing.T, validFor time.Duration) (*tls.Certificate, string) {
+	t.Helper()
+	cert := generateTestCert(t, x509.Certificate{
+		SerialNumber:          big.NewInt(3),
+		Subject:               pkix.Name{CommonName: "mail.example.com"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(validFor),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+	})
+	return &cert, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}))
+}
+
+func TestSMTPProbe_Name(t *testing.T) {
+	p := &SMTPProbe{}
+	if p.Name() != MonitorTypeSMTP {
+		t.Errorf("Expected name %s, got %s", MonitorTypeSMTP, p.Name())
+	}
+}
+
+func TestSMTPProbe_Check(t *testing.T) {
+	cert, caPEM := generateSMTPCert(t, 90*24*time.Hour)
+	expiringCert, expiringCAPEM := generateSMTPCert(t, 10*24*time.Hour)
+
+	tests := []struct {
+		name        string
+		server      mockSMTPServer
+		probe       SMTPProbe
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{
+			name:        "plain",
+			server:      mockSMTPServer{greeting: "220 mail.example.com ESMTP ready"},
+			probe:       SMTPProbe{},
+			wantSuccess: true,
+			wantMsg:     "OK",
+		},
+		{
+			name:    "service not available",
+			server:  mockSMTPServer{greeting: "554 5.3.2 No service"},
+			probe:   SMTPProbe{},
+			wantMsg: "unexpected greeting: 554 5.3.2 No service",
+		},
+		{
+			name:    "EHLO rejected",
+			server:  mockSMTPServer{greeting: "220 mail.example.com ESMTP ready", rejectEHLO: true},
+			probe:   SMTPProbe{},
+			wantMsg: "EHLO rejected: 502 5.5.1 EHLO not implemented",
+		},
+		{
+			name:        "plain ignores STARTTLS",
+			server:      mockSMTPServer{greeting: "220 mail.example.com ESMTP ready", cert: cert},
+			probe:       SMTPProbe{},
+			wantSuccess: true,
+			wantMsg:     "OK",
+		},
+		{
+			name:        "STARTTLS",
+			server:      mockSMTPServer{greeting: "220 mail.example.com ESMTP ready", cert: cert},
+			probe:       SMTPProbe{StartTLS: true, CACert: caPEM, ExpiryThreshold: 30 * 24 * time.Hour},
+			wantSuccess: true,
+			wantMsg:     "OK (STARTTLS, TLS expires in 89 days: mail.example.com by mail.example.com",
+		},
+		{
+			name:        "STARTTLS insecure",
+			server:      mockSMTPServer{greeting: "220 mail.example.com ESMTP ready", cert: cert},
+			probe:       SMTPProbe{StartTLS: true, InsecureSkipVerify: true},
+			wantSuccess: true,
+			wantMsg:     "STARTTLS, TLS expires in",
+		},
+		{
+			name:    "STARTTLS untrusted",
+			server:  mockSMTPServer{greeting: "220 mail.example.com ESMTP ready", cert: cert},
+			probe:   SMTPProbe{StartTLS: true},
+			wantMsg: "STARTTLS handshake failed",
+		},
+		{
+			name:    "STARTTLS not offered",
+			server:  mockSMTPServer{greeting: "220 mail.example.com ESMTP ready"},
+			probe:   SMTPProbe{StartTLS: true},
+			wantMsg: "server does not offer STARTTLS",
+		},
+		{
+			name:    "certificate expires soon",
+			server:  mockSMTPServer{greeting: "220 mail.example.com ESMTP ready", cert: expiringCert},
+			probe:   SMTPProbe{StartTLS: true, CACert: expiringCAPEM, ExpiryThreshold: 30 * 24 * time.Hour},
+			wantMsg: "certificate expires soon: 9 days remaining",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := tt.server.start(t)
+			probe := tt.probe
+			probe.SetTimeout(2 * time.Second)
+			res, err := probe.Check(context.Background(), addr)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess {
+				t.Errorf("Expected success %v, got %v: %s", tt.wantSuccess, res.Success, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("Expected message containing %q, got %q", tt.wantMsg, res.Message)
+			}
+			if probe.StartTLS && probe.CACert != "" && res.TLS == nil {
+				t.Error("Expected TLS info with STARTTLS")
+			}
+		})
+	}
+}
+
+func TestSMTPProbe_TargetModeAll(t *testing.T) {
+	up := mockSMTPServer{greeting: "220 mail.example.com ESMTP ready"}.start(t)
+	down := mockSMTPServer{greeting: "421 4.3.2 Shutting down"}.start(t)
+
+	probe := &SMTPProbe{}
+	probe.SetTargetMode(TargetModeAll)
+	res, _ := probe.Check(context.Background(), up+","+down)
+	if res.Success || !strings.Contains(res.Message, "target "+down+" failed: unexpected greeting: 421") {
+		t.Errorf("Expected failure of %s, got: %s", down, res.Message)
+	}
+	if len(res.TargetResults) != 2 || !res.TargetResults[0].Success {
+		t.Errorf("Expected 2 target results, the first successful, got %+v", res.TargetResults)
+	}
+
+	probe.SetTargetMode(TargetModeAny)
+	res, _ = probe.Check(context.Background(), down+","+up)
+	if !res.Success || res.Target != up {
+		t.Errorf("Expected success on %s, got: %s", up, res.Message)
+	}
+}
+
+func TestSMTPProbe_Timeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	// Accept connections but never greet
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer func() { _ = conn.Close() }()
+		}
+	}()
+
+	probe := &SMTPProbe{}
+	probe.SetTimeout(100 * time.Millisecond)
+	start := time.Now()
+	res, _ := probe.Check(context.Background(), ln.Addr().String())
+	if res.Success || !strings.Contains(res.Message, "timeout") {
+		t.Errorf("Expected a timeout, got: %s", res.Message)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Check took %v, expected it to give up after the timeout", elapsed)
+	}
+}
+
+func TestSMTPProbe_Stabilization(t *testing.T) {
+	mt := &tunnels.MockTunnel{IsStabilizedResult: false}
+	probe := &SMTPProbe{}
+	probe.SetTunnel(mt)
+
+	res, err := probe.Check(context.Background(), "localhost:25")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Pending {
+		t.Error("Expected Pending: true")
+	}
+}
@@ -1,7 +1,9 @@
 package monitor
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"probixel/pkg/config"
@@ -13,11 +15,12 @@ import (
 )
 
 type SSHProbe struct {
-	Config      *config.SSHConfig
-	targetMode  string
-	Timeout     time.Duration
-	tunnel      tunnels.Tunnel
-	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+	Config         *config.SSHConfig
+	targetMode     string
+	Timeout        time.Duration
+	ConnectTimeout time.Duration // Bounds the dial, 0 to use Timeout
+	tunnel         tunnels.Tunnel
+	DialContext    func(ctx context.Context, network, address string) (net.Conn, error)
 }
 
 func (p *SSHProbe) SetTunnel(t tunnels.Tunnel) {
@@ -28,6 +31,28 @@ func (p *SSHProbe) Name() string {
 	return MonitorTypeSSH
 }
 
+func (p *SSHProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeSSH,
+		Description: "Connects to an SSH server, optionally authenticating and running a command",
+		Fields: []string{
+			"target",
+			"timeout",
+			"connect_timeout",
+			"tunnel",
+			"ssh.user",
+			"ssh.password",
+			"ssh.password_file",
+			"ssh.private_key",
+			"ssh.private_key_file",
+			"ssh.auth_required",
+			"ssh.port",
+			"ssh.command",
+			"ssh.match_data",
+		},
+	}
+}
+
 func (p *SSHProbe) SetTargetMode(mode string) {
 	p.targetMode = mode
 }
@@ -84,6 +109,11 @@ func (p *SSHProbe) checkOne(ctx context.Context, target string) Result {
 
 	start := time.Now()
 
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
 	authRequired := true
 	if cfg != nil && cfg.AuthRequired != nil {
 		authRequired = *cfg.AuthRequired
@@ -91,19 +121,7 @@ func (p *SSHProbe) checkOne(ctx context.Context, target string) Result {
 
 	if !authRequired {
 		// Just check TCP connection
-		var conn net.Conn
-		var err error
-		if p.DialContext != nil {
-			conn, err = p.DialContext(ctx, "tcp", host)
-		} else {
-			timeout := p.Timeout
-			if timeout == 0 {
-				timeout = 5 * time.Second
-			}
-			d := net.Dialer{Timeout: timeout}
-			conn, err = d.DialContext(ctx, "tcp", host)
-		}
-
+		conn, err := p.dial(ctx, host, timeout)
 		if err != nil {
 			return Result{Success: false, Message: err.Error()}
 		}
@@ -128,11 +146,6 @@ func (p *SSHProbe) checkOne(ctx context.Context, target string) Result {
 		authMethods = append(authMethods, ssh.PublicKeys(signer))
 	}
 
-	timeout := p.Timeout
-	if timeout == 0 {
-		timeout = 5 * time.Second
-	}
-
 	sshConfig := &ssh.ClientConfig{
 		User:            cfg.User,
 		Auth:            authMethods,
@@ -140,30 +153,120 @@ func (p *SSHProbe) checkOne(ctx context.Context, target string) Result {
 		Timeout:         timeout,
 	}
 
-	var conn net.Conn
-	var err error
-	if p.DialContext != nil {
-		conn, err = p.DialContext(ctx, "tcp", host)
-	} else {
-		d := net.Dialer{Timeout: timeout}
-		conn, err = d.DialContext(ctx, "tcp", host)
-	}
+	conn, err := p.dial(ctx, host, timeout)
 	if err != nil {
 		return Result{Success: false, Message: err.Error()}
 	}
 
+	// NewClientConn doesn't take a context, so bound the handshake with the
+	// timeout and abort it by closing the connection once ctx is done
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
 	ncc, chans, reqs, err := ssh.NewClientConn(conn, host, sshConfig)
+	stop()
 	if err != nil {
 		_ = conn.Close()
-		return Result{Success: false, Message: err.Error()}
+		if ctx.Err() != nil {
+			err = fmt.Errorf("ssh handshake aborted: %w", ctx.Err())
+		}
+		return Result{Success: false, Message: timeoutError(err, true).Error()}
 	}
+	_ = conn.SetDeadline(time.Time{})
 
 	client := ssh.NewClient(ncc, chans, reqs)
-	_ = client.Close()
+	defer func() { _ = client.Close() }()
 
-	return Result{Success: true, Duration: time.Since(start), Message: "Login OK", Target: target}
+	if cfg.Command == "" {
+		return Result{Success: true, Duration: time.Since(start), Message: "Login OK", Target: target}
+	}
+	msg, err := p.runCommand(ctx, client, cfg, start, timeout)
+	if err != nil {
+		return Result{Success: false, Duration: time.Since(start), Message: err.Error(), Target: target}
+	}
+	return Result{Success: true, Duration: time.Since(start), Message: msg, Target: target}
+}
+
+// runCommand runs cfg.Command in a session of client, aborting it once the
+// check started at start has taken timeout. Without expectations the command
+// must exit with status 0, otherwise its stdout must meet them whatever the
+// status.
+func (p *SSHProbe) runCommand(ctx context.Context, client *ssh.Client, cfg *config.SSHConfig, start time.Time, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithDeadline(ctx, start.Add(timeout))
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { _ = client.Close() })
+	defer stop()
+
+	session, err := client.NewSession()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("command aborted: %w", ctx.Err())
+		}
+		return "", fmt.Errorf("failed to open session: %w", err)
+	}
+	defer func() { _ = session.Close() }()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	err = session.Run(cfg.Command)
+	if ctx.Err() != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("command timed out after %v", timeout)
+		}
+		return "", fmt.Errorf("command aborted: %w", ctx.Err())
+	}
+
+	status := 0
+	if err != nil {
+		var exitErr *ssh.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", fmt.Errorf("failed to run command: %w", err)
+		}
+		status = exitErr.ExitStatus()
+	}
+
+	if cfg.MatchData != nil && len(cfg.MatchData.Expectations) > 0 {
+		// Stdout is matched like an HTTP body
+		matcher := &HTTPProbe{MatchData: cfg.MatchData}
+		if ok, note := matcher.evaluateExpectations(stdout.Bytes(), nil); !ok {
+			return "", fmt.Errorf("exit status %d, %s", status, note)
+		}
+		return fmt.Sprintf("exit status %d, Expectations met", status), nil
+	}
+	if status != 0 {
+		out := lastLine(stderr.String())
+		if out == "" {
+			out = lastLine(stdout.String())
+		}
+		return "", errors.New(withOutput(fmt.Sprintf("exit status %d", status), out))
+	}
+	return withOutput("OK (exit status 0)", lastLine(stdout.String())), nil
+}
+
+// dial connects to host, giving up after the connect timeout.
+func (p *SSHProbe) dial(ctx context.Context, host string, timeout time.Duration) (net.Conn, error) {
+	connTimeout := connectTimeout(p.ConnectTimeout, timeout)
+	dialCtx, cancel := context.WithTimeout(ctx, connTimeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if p.DialContext != nil {
+		conn, err = p.DialContext(dialCtx, "tcp", host)
+	} else {
+		d := net.Dialer{Timeout: connTimeout}
+		conn, err = d.DialContext(dialCtx, "tcp", host)
+	}
+	if err != nil {
+		return nil, timeoutError(err, false)
+	}
+	return conn, nil
 }
 
 func (p *SSHProbe) SetTimeout(timeout time.Duration) {
 	p.Timeout = timeout
 }
+
+func (p *SSHProbe) SetConnectTimeout(timeout time.Duration) {
+	p.ConnectTimeout = timeout
+}
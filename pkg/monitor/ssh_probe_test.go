@@ -67,13 +67,68 @@ func startMockSSHServer(t *testing.T, password string, privateKey string) (strin
 			if err != nil {
 				return
 			}
-			_, _, _, _ = ssh.NewServerConn(nConn, config)
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(nConn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				serveMockSSHSessions(chans)
+			}()
 		}
 	}()
 
 	return listener.Addr().String(), func() { listener.Close() }
 }
 
+// mockSSHCommands are the commands the mock SSH server runs in exec requests.
+var mockSSHCommands = map[string]struct {
+	stdout, stderr string
+	status         uint32
+	delay          time.Duration
+}{
+	"systemctl is-active nginx": {stdout: "active\n"},
+	"systemctl is-active redis": {stdout: "inactive\n", status: 3},
+	"check-disk":                {stdout: "checking /\n", stderr: "disk 95% full\n", status: 1},
+	"status --json":             {stdout: `{"status":"ok","load":0.4}`},
+	"sleep":                     {delay: 2 * time.Second},
+}
+
+// serveMockSSHSessions answers exec requests of session channels with
+// mockSSHCommands, rejecting unknown commands.
+func serveMockSSHSessions(chans <-chan ssh.NewChannel) {
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			_ = newChan.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, reqs, err := newChan.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer func() { _ = ch.Close() }()
+			for req := range reqs {
+				var payload struct{ Command string }
+				if req.Type != "exec" || ssh.Unmarshal(req.Payload, &payload) != nil {
+					_ = req.Reply(false, nil)
+					continue
+				}
+				cmd, ok := mockSSHCommands[payload.Command]
+				_ = req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				time.Sleep(cmd.delay)
+				_, _ = ch.Write([]byte(cmd.stdout))
+				_, _ = ch.Stderr().Write([]byte(cmd.stderr))
+				_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{cmd.status}))
+				return
+			}
+		}()
+	}
+}
+
 func TestSSHProbe_Check(t *testing.T) {
 	privKey, _ := generateTestKey()
 	addr, cleanup := startMockSSHServer(t, "secret", privKey)
@@ -204,6 +259,93 @@ func TestSSHProbe_Check(t *testing.T) {
 	})
 }
 
+func TestSSHProbe_Command(t *testing.T) {
+	addr, cleanup := startMockSSHServer(t, "secret", "")
+	defer cleanup()
+
+	tests := []struct {
+		name        string
+		command     string
+		match       *config.MatchDataConfig
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{
+			name:        "exit status 0",
+			command:     "systemctl is-active nginx",
+			wantSuccess: true,
+			wantMsg:     "OK (exit status 0): active",
+		},
+		{
+			name:    "non-zero exit status",
+			command: "systemctl is-active redis",
+			wantMsg: "exit status 3: inactive",
+		},
+		{
+			name:    "stderr in failure",
+			command: "check-disk",
+			wantMsg: "exit status 1: disk 95% full",
+		},
+		{
+			name:    "output decides over exit status",
+			command: "systemctl is-active redis",
+			match: &config.MatchDataConfig{Expectations: []config.Expectation{
+				{Type: "body", Operator: "contains", Value: "inactive"},
+			}},
+			wantSuccess: true,
+			wantMsg:     "exit status 3, Expectations met",
+		},
+		{
+			name:    "json output matched",
+			command: "status --json",
+			match: &config.MatchDataConfig{Expectations: []config.Expectation{
+				{Type: "json", JSONPath: "status", Operator: "==", Value: "ok"},
+				{Type: "json", JSONPath: "load", Operator: "<", Value: "1"},
+			}},
+			wantSuccess: true,
+			wantMsg:     "exit status 0, Expectations met",
+		},
+		{
+			name:    "output not matched",
+			command: "systemctl is-active nginx",
+			match: &config.MatchDataConfig{Expectations: []config.Expectation{
+				{Type: "body", Operator: "matches", Value: "^inactive"},
+			}},
+			wantMsg: "exit status 0, expectation failed",
+		},
+		{
+			name:    "rejected command",
+			command: "reboot",
+			wantMsg: "failed to run command",
+		},
+		{
+			name:    "timeout",
+			command: "sleep",
+			wantMsg: "command timed out after 500ms",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &SSHProbe{
+				Config:  &config.SSHConfig{User: "test", Password: "secret", Command: tt.command, MatchData: tt.match},
+				Timeout: 500 * time.Millisecond,
+			}
+			start := time.Now()
+			res, err := p.Check(context.Background(), addr)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess || !strings.HasPrefix(res.Message, tt.wantMsg) {
+				t.Errorf("got success=%v message=%q, want success=%v message %q", res.Success, res.Message, tt.wantSuccess, tt.wantMsg)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Expected the check to end within the timeout, took %v", elapsed)
+			}
+		})
+	}
+}
+
 func TestSSHProbe_ManualConfigFallback(t *testing.T) {
 	privKey, _ := generateTestKey()
 	addr, cleanup := startMockSSHServer(t, "secret", privKey)
@@ -393,3 +535,40 @@ func TestSSHProbe_Check_MissingSSHConfig(t *testing.T) {
 		t.Errorf("Expected 'missing ssh config' message, got %s", res.Message)
 	}
 }
+
+func TestSSHProbe_AbortsHandshakeOnCancel(t *testing.T) {
+	// A server that accepts but never speaks SSH hangs the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer func() { _ = conn.Close() }()
+		}
+	}()
+
+	p := &SSHProbe{
+		Config:  &config.SSHConfig{User: "user", Password: "password"},
+		Timeout: 30 * time.Second,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	res, err := p.Check(ctx, listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected Check to return promptly after cancel, took %v", elapsed)
+	}
+	if res.Success || !strings.Contains(res.Message, "ssh handshake aborted") {
+		t.Errorf("Expected aborted handshake, got %q", res.Message)
+	}
+}
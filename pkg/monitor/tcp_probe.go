@@ -1,21 +1,47 @@
 package monitor
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"regexp"
 	"strings"
+	"syscall"
 	"time"
 
 	"probixel/pkg/tunnels"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
+// tcpReadLimit bounds how much of a response is read to match tcp.expect.
+const tcpReadLimit = 4096
+
+// tcpExcerptLimit bounds the received bytes quoted when tcp.expect doesn't match.
+const tcpExcerptLimit = 64
+
 type TCPProbe struct {
 	// DialContext allows mocking the network connection. If nil, net.Dialer is used.
-	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
-	Timeout     time.Duration
-	targetMode  string
-	tunnel      tunnels.Tunnel
+	DialContext    func(ctx context.Context, network, address string) (net.Conn, error)
+	Timeout        time.Duration
+	ConnectTimeout time.Duration   // Bounds the dial itself, 0 to use Timeout
+	Resolver       *TargetResolver // Optional, resolves hostname targets before dialing
+	DSCP           int             // DSCP value marked on direct connections, 0 to leave unmarked
+	SourceAddress  string          // Local IP direct connections are made from, empty for the OS default
+	Family         string          // FamilyIPv4 or FamilyIPv6 to connect over that family only, empty for either
+	DNSCache       *DNSCache       // Optional, reuses resolutions of direct connections within its TTL
+	NameResolver   *net.Resolver   // Optional, resolves hostnames instead of the system resolver
+	Send           string          // Optional, written once connected
+	Expect         string          // Optional, the response must contain it
+	ExpectRegex    bool            // Expect is a regular expression rather than a substring
+	targetMode     string
+	quorum         int
+	concurrency    int
+	tunnel         tunnels.Tunnel
 }
 
 func (p *TCPProbe) SetTunnel(t tunnels.Tunnel) {
@@ -26,14 +52,28 @@ func (p *TCPProbe) Name() string {
 	return MonitorTypeTCP
 }
 
+func (p *TCPProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeTCP,
+		Description: "Opens a TCP connection to each target",
+		Fields:      []string{"targets", "target_mode", "quorum", "concurrency", "timeout", "connect_timeout", "tunnel", "source_address", "family", "resolver", "tcp.resolve", "tcp.resolve_to", "tcp.dscp", "tcp.send", "tcp.expect", "tcp.expect_regex"},
+	}
+}
+
 func (p *TCPProbe) SetTargetMode(mode string) {
 	p.targetMode = mode
 }
 
+func (p *TCPProbe) SetQuorum(n int) {
+	p.quorum = n
+}
+
+func (p *TCPProbe) SetConcurrency(n int) {
+	p.concurrency = n
+}
+
 func (p *TCPProbe) Check(ctx context.Context, target string) (Result, error) {
 	targets := strings.Split(target, ",")
-	var lastErr error
-
 	startTotal := time.Now()
 
 	// Strict stabilization adherence: always return Pending if tunnel not stabilized
@@ -47,105 +87,211 @@ func (p *TCPProbe) Check(ctx context.Context, target string) (Result, error) {
 		}, nil
 	}
 
-	// For "all" mode, track successes
-	if p.targetMode == TargetModeAll {
-		var totalDuration time.Duration
-		successCount := 0
-
-		for _, t := range targets {
-			t = strings.TrimSpace(t)
-			if t == "" {
-				continue
+	return checkTargets(ctx, targets, targetCheck{
+		mode:        p.targetMode,
+		quorum:      p.quorum,
+		concurrency: p.concurrency,
+		okMessage:   withNote("all %d targets OK", familyNote(p.Family)),
+		failMessage: func(t string, err error) string {
+			return fmt.Sprintf("all targets failed, last error: target %s: %v", t, err)
+		},
+		check: func(ctx context.Context, t string) (time.Duration, string, error) {
+			duration, note, err := p.check(ctx, t)
+			if err != nil {
+				return 0, "", err
 			}
+			return duration, withNote("OK", note), nil
+		},
+	}, startTotal), nil
+}
 
-			start := time.Now()
-			var conn net.Conn
-			var err error
-
-			if p.DialContext != nil {
-				conn, err = p.DialContext(ctx, "tcp", t)
-			} else {
-				timeout := p.Timeout
-				if timeout == 0 {
-					timeout = 5 * time.Second
-				}
-				d := net.Dialer{Timeout: timeout}
-				conn, err = d.DialContext(ctx, "tcp", t)
-			}
+// check connects to target and, with tcp.send or tcp.expect set, exchanges
+// them over the connection. It returns the time taken and the dial's note.
+func (p *TCPProbe) check(ctx context.Context, target string) (time.Duration, string, error) {
+	start := time.Now()
+	conn, note, err := p.dial(ctx, target)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() { _ = conn.Close() }()
+	if err := p.exchange(ctx, conn); err != nil {
+		return 0, note, err
+	}
+	return time.Since(start), note, nil
+}
 
-			if err != nil {
-				// In "all" mode, any failure means overall failure
-				return Result{
-					Success:   false,
-					Duration:  0,
-					Message:   fmt.Sprintf("target %s failed: %v", t, err),
-					Timestamp: startTotal,
-				}, nil
-			}
-			_ = conn.Close()
-			totalDuration += time.Since(start)
-			successCount++
-		}
+// exchange writes Send to conn, then reads the response until it matches
+// Expect, the peer closes the connection, tcpReadLimit bytes were read or the
+// probe timeout passes.
+func (p *TCPProbe) exchange(ctx context.Context, conn net.Conn) error {
+	if p.Send == "" && p.Expect == "" {
+		return nil
+	}
+	match, err := expectMatcher(p.Expect, p.ExpectRegex)
+	if err != nil {
+		return fmt.Errorf("invalid tcp.expect: %w", err)
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
 
-		if successCount > 0 {
-			return Result{
-				Success:   true,
-				Duration:  totalDuration / time.Duration(successCount),
-				Message:   fmt.Sprintf("all %d targets OK", successCount),
-				Timestamp: startTotal,
-			}, nil
+	if p.Send != "" {
+		if _, err := conn.Write([]byte(p.Send)); err != nil {
+			return fmt.Errorf("send failed: %w", timeoutError(err, true))
 		}
 	}
+	if p.Expect == "" {
+		return nil
+	}
 
-	// Default "any" mode - return on first success
-	for _, t := range targets {
-		t = strings.TrimSpace(t)
-		if t == "" {
-			continue
+	buf := make([]byte, 0, tcpReadLimit)
+	for len(buf) < cap(buf) {
+		n, err := conn.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if match(buf) {
+			return nil
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && len(buf) == 0 {
+				return fmt.Errorf("no response: %w", timeoutError(err, true))
+			}
+			break
 		}
+	}
+	return fmt.Errorf("response does not match %q, got %s", p.Expect, excerpt(buf))
+}
+
+// expectMatcher returns a function matching responses containing expect, or
+// matching it as a regular expression with regex set.
+func expectMatcher(expect string, regex bool) (func([]byte) bool, error) {
+	if !regex {
+		return func(b []byte) bool { return bytes.Contains(b, []byte(expect)) }, nil
+	}
+	re, err := regexp.Compile(expect)
+	if err != nil {
+		return nil, err
+	}
+	return re.Match, nil
+}
 
-		// Try to connect
-		start := time.Now()
-		var conn net.Conn
-		var err error
+// excerpt quotes the start of b, truncated to tcpExcerptLimit bytes.
+func excerpt(b []byte) string {
+	if len(b) > tcpExcerptLimit {
+		return fmt.Sprintf("%q...", b[:tcpExcerptLimit])
+	}
+	return fmt.Sprintf("%q", b)
+}
 
-		if p.DialContext != nil {
-			// Create timeout context for tunnel dial
-			timeout := p.Timeout
-			if timeout == 0 {
-				timeout = 5 * time.Second
-			}
-			dialCtx, cancel := context.WithTimeout(ctx, timeout)
-			conn, err = p.DialContext(dialCtx, "tcp", t)
-			cancel()
-		} else {
-			timeout := p.Timeout
-			if timeout == 0 {
-				timeout = 5 * time.Second
-			}
-			d := net.Dialer{Timeout: timeout}
-			conn, err = d.DialContext(ctx, "tcp", t)
+// dial connects to target, preferring the injected DialContext, then the
+// tunnel's own dialer, and only falling back to a direct connection when no
+// tunnel is set. The probe timeout bounds the dial in every case. The returned
+// note names the forced family and describes the target resolution, if any.
+func (p *TCPProbe) dial(ctx context.Context, target string) (net.Conn, string, error) {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addr, _, err := hostPortTarget(target, "")
+	if err != nil {
+		return nil, "", err
+	}
+	network := familyNetwork("tcp", p.Family)
+	addr, note, err := p.Resolver.resolve(dialCtx, network, addr)
+	if err != nil {
+		return nil, "", err
+	}
+	note = joinNotes(familyNote(p.Family), note)
+
+	connTimeout := connectTimeout(p.ConnectTimeout, timeout)
+	connCtx, cancelConn := context.WithTimeout(dialCtx, connTimeout)
+	defer cancelConn()
+
+	var conn net.Conn
+	switch {
+	case p.DialContext != nil:
+		conn, err = p.DialContext(connCtx, network, addr)
+	case p.tunnel != nil:
+		conn, err = p.tunnel.DialContext(connCtx, network, addr)
+		if err != nil {
+			err = fmt.Errorf("via tunnel %q: %w", p.tunnel.Name(), err)
 		}
-		if err == nil {
-			_ = conn.Close()
-			return Result{
-				Success:   true,
-				Duration:  time.Since(start),
-				Message:   "OK",
-				Target:    t, // Return the specific target that worked
-				Timestamp: startTotal,
-			}, nil
+	default:
+		d := net.Dialer{Timeout: connTimeout, LocalAddr: localAddr("tcp", p.SourceAddress), Resolver: p.NameResolver}
+		if p.DSCP > 0 {
+			d.Control = dscpControl(p.DSCP)
+		}
+		var dialAddr string
+		if dialAddr, err = p.DNSCache.resolve(connCtx, network, addr); err == nil {
+			conn, err = d.DialContext(connCtx, network, dialAddr)
+			err = sourceError(err, p.SourceAddress)
 		}
-		lastErr = err
 	}
-
-	return Result{
-		Success:   false,
-		Duration:  0, // Duration is 0 on failure per bash script convention for "down 0"
-		Message:   fmt.Sprintf("all targets failed, last error: %v", lastErr),
-		Timestamp: startTotal,
-	}, nil
+	if err != nil {
+		p.DNSCache.forget(target)
+		err = timeoutError(err, false)
+		if note != "" {
+			err = fmt.Errorf("%w (%s)", err, note)
+		}
+		return nil, note, err
+	}
+	return conn, note, nil
 }
+
 func (p *TCPProbe) SetTimeout(timeout time.Duration) {
 	p.Timeout = timeout
 }
+
+func (p *TCPProbe) SetConnectTimeout(timeout time.Duration) {
+	p.ConnectTimeout = timeout
+}
+
+func (p *TCPProbe) SetSourceAddress(addr string) {
+	p.SourceAddress = addr
+}
+
+func (p *TCPProbe) SetFamily(family string) {
+	p.Family = family
+}
+
+func (p *TCPProbe) SetNameResolver(r *net.Resolver) {
+	p.NameResolver = r
+}
+
+// rawSocket hands a socket that is still being dialed to golang.org/x/net,
+// whose option setters only accept connections.
+type rawSocket struct {
+	net.Conn
+	raw syscall.RawConn
+}
+
+func (s rawSocket) SyscallConn() (syscall.RawConn, error) {
+	return s.raw, nil
+}
+
+func (s rawSocket) SetLinger(int) error {
+	return nil
+}
+
+// dscpControl returns a net.Dialer Control hook setting the socket's ToS or
+// traffic class before connecting, so the SYN is marked with dscp too.
+func dscpControl(dscp int) func(network, address string, c syscall.RawConn) error {
+	tos := dscp << 2
+	return func(network, _ string, c syscall.RawConn) error {
+		if network == "tcp6" {
+			return ipv6.NewConn(rawSocket{raw: c}).SetTrafficClass(tos)
+		}
+		return ipv4.NewConn(rawSocket{raw: c}).SetTOS(tos)
+	}
+}
@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"net"
@@ -8,6 +9,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"golang.org/x/net/ipv4"
 )
 
 type mockConn struct {
@@ -125,6 +128,161 @@ func TestTCPProbe_Check_AllMode_RealDial(t *testing.T) {
 	_, _ = probe.Check(ctx, "127.0.0.1:1")
 }
 
+func TestTCPProbe_DSCP(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	d := net.Dialer{Control: dscpControl(46)}
+	conn, err := d.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	tos, err := ipv4.NewConn(conn).TOS()
+	_ = conn.Close()
+	if err != nil {
+		t.Fatalf("TOS failed: %v", err)
+	}
+	if tos != 46<<2 {
+		t.Errorf("Expected ToS %d, got %d", 46<<2, tos)
+	}
+
+	probe := &TCPProbe{DSCP: 46}
+	res, err := probe.Check(context.Background(), ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Success {
+		t.Errorf("Expected success, got failure: %s", res.Message)
+	}
+}
+
+// bannerServer accepts connections on a local port, sends banner and, when
+// reply is set, answers the first line it receives with it.
+func bannerServer(t *testing.T, banner, reply string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = conn.Write([]byte(banner))
+				if reply == "" {
+					return
+				}
+				if _, err := bufio.NewReader(conn).ReadString('\n'); err == nil {
+					_, _ = conn.Write([]byte(reply))
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestTCPProbe_SendExpect(t *testing.T) {
+	longBanner := "220 " + strings.Repeat("x", 100) + "\r\n"
+	tests := []struct {
+		name        string
+		banner      string
+		reply       string
+		probe       TCPProbe
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{
+			name:        "banner contains expect",
+			banner:      "SSH-2.0-OpenSSH_9.6\r\n",
+			probe:       TCPProbe{Expect: "OpenSSH"},
+			wantSuccess: true,
+		},
+		{
+			name:        "banner matches regex",
+			banner:      "SSH-2.0-OpenSSH_9.6\r\n",
+			probe:       TCPProbe{Expect: `^SSH-2\.0-\w+`, ExpectRegex: true},
+			wantSuccess: true,
+		},
+		{
+			name:        "reply to send",
+			banner:      "+READY\r\n",
+			reply:       "+PONG\r\n",
+			probe:       TCPProbe{Send: "PING\r\n", Expect: "+PONG"},
+			wantSuccess: true,
+		},
+		{
+			name:    "mismatch",
+			banner:  "SSH-2.0-dropbear\r\n",
+			probe:   TCPProbe{Expect: "OpenSSH"},
+			wantMsg: `response does not match "OpenSSH", got "SSH-2.0-dropbear\r\n"`,
+		},
+		{
+			name:    "mismatch is truncated",
+			banner:  longBanner,
+			probe:   TCPProbe{Expect: "ESMTP"},
+			wantMsg: fmt.Sprintf("got %q...", longBanner[:tcpExcerptLimit]),
+		},
+		{
+			name:    "no response",
+			probe:   TCPProbe{Expect: "OpenSSH", Timeout: 100 * time.Millisecond},
+			reply:   "never sent",
+			wantMsg: "no response: read timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := bannerServer(t, tt.banner, tt.reply)
+			probe := tt.probe
+			res, err := probe.Check(context.Background(), addr)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess {
+				t.Errorf("Expected success %v, got %v: %s", tt.wantSuccess, res.Success, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("Expected message containing %q, got %q", tt.wantMsg, res.Message)
+			}
+		})
+	}
+}
+
+func TestTCPProbe_SendExpect_TargetModes(t *testing.T) {
+	good := bannerServer(t, "220 mail ESMTP\r\n", "")
+	bad := bannerServer(t, "220 mail\r\n", "")
+	probe := &TCPProbe{Expect: "ESMTP"}
+
+	probe.SetTargetMode(TargetModeAll)
+	res, _ := probe.Check(context.Background(), good+","+bad)
+	if res.Success || !strings.Contains(res.Message, "target "+bad+" failed: response does not match") {
+		t.Errorf("Expected failure of %s, got: %s", bad, res.Message)
+	}
+
+	probe.SetTargetMode(TargetModeAny)
+	res, _ = probe.Check(context.Background(), bad+","+good)
+	if !res.Success || res.Target != good {
+		t.Errorf("Expected success on %s, got: %s", good, res.Message)
+	}
+}
+
 func TestTCPProbe_Extra_Failures(t *testing.T) {
 	t.Run("TCP target failed AllMode", func(t *testing.T) {
 		probe := &TCPProbe{
@@ -192,3 +350,87 @@ func TestTCPProbe_SetTimeout(t *testing.T) {
 		t.Errorf("Expected timeout 10s, got %v", p.Timeout)
 	}
 }
+
+func TestTCPProbe_UsesTunnelDialer(t *testing.T) {
+	var dialed []string
+	mt := &tunnels.MockTunnel{
+		IsStabilizedResult: true,
+		DialContextFunc: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = append(dialed, address)
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("Expected tunnel dial to be bounded by the probe timeout")
+			}
+			return &mockConn{}, nil
+		},
+	}
+	probe := &TCPProbe{}
+	probe.SetTunnel(mt)
+
+	// The target is unresolvable, so a direct dial would fail
+	res, err := probe.Check(context.Background(), "db.internal.invalid:5432")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Success {
+		t.Errorf("Expected success via tunnel, got: %s", res.Message)
+	}
+	if len(dialed) != 1 || dialed[0] != "db.internal.invalid:5432" {
+		t.Errorf("Expected dial through tunnel, got %v", dialed)
+	}
+}
+
+func TestTCPProbe_TunnelDialError(t *testing.T) {
+	mt := &tunnels.MockTunnel{
+		IsStabilizedResult: true,
+		NameFunc:           func() string { return "bastion" },
+		DialContextFunc: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, fmt.Errorf("connection refused")
+		},
+	}
+	probe := &TCPProbe{}
+	probe.SetTunnel(mt)
+	probe.SetTargetMode(TargetModeAll)
+
+	res, _ := probe.Check(context.Background(), "db:5432")
+	if res.Success {
+		t.Fatal("Expected failure")
+	}
+	if !strings.Contains(res.Message, `via tunnel "bastion"`) {
+		t.Errorf("Expected tunnel name in error, got: %s", res.Message)
+	}
+}
+
+func TestTCPProbe_StopsAtCheckDeadline(t *testing.T) {
+	var dialed []string
+	p := &TCPProbe{
+		Timeout: 5 * time.Second,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = append(dialed, address)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	for _, mode := range []string{TargetModeAny, TargetModeQuorum} {
+		t.Run(mode, func(t *testing.T) {
+			dialed = nil
+			p.SetTargetMode(mode)
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			res, err := p.Check(ctx, "a:80,b:80,c:80")
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success {
+				t.Fatal("Expected failure")
+			}
+			if len(dialed) != 1 {
+				t.Errorf("Expected remaining targets to be skipped after the deadline, dialed %v", dialed)
+			}
+			if mode == TargetModeAny && !strings.Contains(res.Message, "target c:80: check timed out") {
+				t.Errorf("Expected timed out message, got %q", res.Message)
+			}
+		})
+	}
+}
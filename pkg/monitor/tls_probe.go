@@ -1,22 +1,45 @@
 package monitor
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 
+	"golang.org/x/crypto/ocsp"
+
+	"probixel/pkg/config"
 	"probixel/pkg/tunnels"
 )
 
+// revocationMaxBody bounds the OCSP responses and CRLs read.
+const revocationMaxBody = 10 << 20
+
 type TLSProbe struct {
 	targetMode         string
 	ExpiryThreshold    time.Duration
-	InsecureSkipVerify bool
+	InsecureSkipVerify bool   // Skip chain and hostname verification
+	SkipChain          bool   // Accept certificates not chaining to a trusted root, still verifying the hostname
+	ExpectedIssuer     string // Must be part of the issuer's common name or an organization
+	ExpectedSAN        string // Hostname the certificate must be valid for
+	ClientCert         string // mTLS client certificate, file path or inline PEM
+	ClientKey          string // mTLS client key, file path or inline PEM
+	CACert             string // CA bundle used to verify the server, file path or inline PEM
+	CheckRevocation    bool   // Fail certificates revoked according to their OCSP responder, or CRL without one
+	RevocationRequired bool   // Also fail when the revocation status can't be fetched
 	Timeout            time.Duration
+	ConnectTimeout     time.Duration // Bounds the dial, 0 to use Timeout
 	DialContext        func(ctx context.Context, network, address string) (net.Conn, error)
+	NameResolver       *net.Resolver // Optional, resolves hostnames instead of the system resolver
 	tunnel             tunnels.Tunnel
 }
 
@@ -28,12 +51,37 @@ func (p *TLSProbe) Name() string {
 	return MonitorTypeTLS
 }
 
+func (p *TLSProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeTLS,
+		Description: "Performs a TLS handshake and checks the certificate's chain, names, issuer and expiry",
+		Fields: []string{
+			"url",
+			"timeout",
+			"connect_timeout",
+			"tunnel",
+			"resolver",
+			"tls.certificate_expiry",
+			"tls.insecure_skip_verify",
+			"tls.verify_chain",
+			"tls.expected_issuer",
+			"tls.expected_san",
+			"tls.client_cert",
+			"tls.client_key",
+			"tls.ca_cert",
+			"tls.check_revocation",
+			"tls.revocation_required",
+		},
+	}
+}
+
 func (p *TLSProbe) SetTargetMode(mode string) {
 	p.targetMode = mode
 }
 
 func (p *TLSProbe) Check(ctx context.Context, target string) (Result, error) {
 	var lastErr error
+	var lastTarget string
 	startTotal := time.Now()
 
 	// Strict stabilization adherence: always return Pending if tunnel not stabilized
@@ -82,6 +130,7 @@ func (p *TLSProbe) Check(ctx context.Context, target string) (Result, error) {
 				return Result{
 					Success:   false,
 					Message:   errMsg,
+					Target:    t,
 					Timestamp: startTotal,
 				}, nil
 			}
@@ -108,8 +157,10 @@ func (p *TLSProbe) Check(ctx context.Context, target string) (Result, error) {
 
 		res, err := p.checkTarget(ctx, t, threshold)
 		if err == nil && res.Success {
+			res.Message = targetMessage(targets, t, res.Message)
 			return res, nil
 		}
+		lastTarget = t
 		if err != nil {
 			lastErr = err
 		} else {
@@ -119,7 +170,8 @@ func (p *TLSProbe) Check(ctx context.Context, target string) (Result, error) {
 
 	return Result{
 		Success:   false,
-		Message:   fmt.Sprintf("all targets failed, last error: %v", lastErr),
+		Message:   fmt.Sprintf("all targets failed, last error: target %s: %v", lastTarget, lastErr),
+		Target:    lastTarget,
 		Timestamp: startTotal,
 	}, nil
 }
@@ -127,36 +179,49 @@ func (p *TLSProbe) Check(ctx context.Context, target string) (Result, error) {
 func (p *TLSProbe) checkTarget(ctx context.Context, target string, threshold time.Duration) (Result, error) {
 	start := time.Now()
 
-	// Ensure target has a port
-	host, _, err := net.SplitHostPort(target)
+	// Ensure target has a port, defaulting to HTTPS
+	target, host, err := hostPortTarget(target, "443")
 	if err != nil {
-		// Try adding default HTTPS port if missing
-		host = target
-		target = net.JoinHostPort(host, "443")
+		return Result{}, err
 	}
 
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	dialer := p.DialContext
 	if dialer == nil {
-		timeout := p.Timeout
-		if timeout == 0 {
-			timeout = 5 * time.Second
-		}
-		d := net.Dialer{Timeout: timeout}
+		d := net.Dialer{Resolver: p.NameResolver}
 		dialer = d.DialContext
 	}
 
-	rawConn, err := dialer(ctx, "tcp", target)
-	if err != nil {
+	// The certificate is verified after the handshake, so that the message
+	// can tell which check failed
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: true, // nolint:gosec // Verified by verifyCertificate
+		ServerName:         host,
+	}
+	if tlsConfig.Certificates, err = clientCertificates(p.ClientCert, p.ClientKey); err != nil {
+		return Result{}, err
+	}
+	if tlsConfig.RootCAs, err = rootCAs(p.CACert); err != nil {
 		return Result{}, err
 	}
 
-	conn := tls.Client(rawConn, &tls.Config{
-		InsecureSkipVerify: p.InsecureSkipVerify, // nolint:gosec // deliberate feature
-		ServerName:         host,
-	})
+	dialCtx, cancelDial := context.WithTimeout(ctx, connectTimeout(p.ConnectTimeout, timeout))
+	rawConn, err := dialer(dialCtx, "tcp", target)
+	cancelDial()
+	if err != nil {
+		return Result{}, timeoutError(err, false)
+	}
+
+	conn := tls.Client(rawConn, tlsConfig)
 	if err := conn.HandshakeContext(ctx); err != nil {
 		_ = rawConn.Close()
-		return Result{}, err
+		return Result{}, timeoutError(err, true)
 	}
 	defer func() { _ = conn.Close() }()
 
@@ -168,6 +233,14 @@ func (p *TLSProbe) checkTarget(ctx context.Context, target string, threshold tim
 		}, nil
 	}
 
+	if msg := p.verifyCertificate(conn.ConnectionState().PeerCertificates, tlsConfig.RootCAs, host); msg != "" {
+		return Result{
+			Success:   false,
+			Message:   msg,
+			Timestamp: start,
+		}, nil
+	}
+
 	cert := conn.ConnectionState().PeerCertificates[0]
 	expiry := cert.NotAfter
 	remaining := time.Until(expiry)
@@ -189,15 +262,220 @@ func (p *TLSProbe) checkTarget(ctx context.Context, target string, threshold tim
 		}, nil
 	}
 
-	daysRemaining := int(remaining.Hours() / 24)
+	msg := fmt.Sprintf("expires in %d days", int(remaining.Hours()/24))
+	if p.CheckRevocation {
+		revoked, err := p.checkRevocation(ctx, conn.ConnectionState().PeerCertificates, tlsConfig.RootCAs, dialer)
+		switch {
+		case revoked != "":
+			return Result{
+				Success:   false,
+				Message:   revoked,
+				Timestamp: start,
+			}, nil
+		case err != nil && p.RevocationRequired:
+			return Result{
+				Success:   false,
+				Message:   fmt.Sprintf("revocation check failed: %v", err),
+				Timestamp: start,
+			}, nil
+		case err != nil:
+			msg += fmt.Sprintf(", revocation not checked: %v", err)
+		default:
+			msg += ", not revoked"
+		}
+	}
+
 	return Result{
 		Success:   true,
 		Duration:  time.Since(start),
-		Message:   fmt.Sprintf("OK (expires in %d days)", daysRemaining),
+		Message:   fmt.Sprintf("OK (%s)", msg),
 		Target:    target,
 		Timestamp: start,
 	}, nil
 }
+
+// verifyCertificate runs the chain, hostname, issuer and SAN checks on the
+// server's certificates, returning the failure message of the first failing
+// one, or "" if they all pass.
+func (p *TLSProbe) verifyCertificate(certs []*x509.Certificate, roots *x509.CertPool, host string) string {
+	cert := certs[0]
+	if !p.InsecureSkipVerify {
+		if !p.SkipChain {
+			intermediates := x509.NewCertPool()
+			for _, c := range certs[1:] {
+				intermediates.AddCert(c)
+			}
+			if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+				return fmt.Sprintf("certificate chain verification failed: %v", err)
+			}
+		}
+		if err := cert.VerifyHostname(host); err != nil {
+			return fmt.Sprintf("hostname verification failed: %v", err)
+		}
+	}
+
+	if p.ExpectedIssuer != "" {
+		names := append([]string{cert.Issuer.CommonName}, cert.Issuer.Organization...)
+		if !slices.ContainsFunc(names, func(n string) bool { return strings.Contains(n, p.ExpectedIssuer) }) {
+			return fmt.Sprintf("certificate issuer %q does not match %q", cert.Issuer.String(), p.ExpectedIssuer)
+		}
+	}
+
+	if p.ExpectedSAN != "" {
+		if err := cert.VerifyHostname(p.ExpectedSAN); err != nil {
+			sans := slices.Clone(cert.DNSNames)
+			for _, ip := range cert.IPAddresses {
+				sans = append(sans, ip.String())
+			}
+			return fmt.Sprintf("certificate SANs [%s] do not include %q", strings.Join(sans, ", "), p.ExpectedSAN)
+		}
+	}
+	return ""
+}
+
+// checkRevocation asks the OCSP responder of the server's certificate, or its
+// CRL distribution point when it names no responder, whether it was revoked.
+// A revoked certificate, or one the responder doesn't know, is reported as a
+// failure message; err is set when the status couldn't be fetched.
+func (p *TLSProbe) checkRevocation(ctx context.Context, certs []*x509.Certificate, roots *x509.CertPool, dialer func(ctx context.Context, network, address string) (net.Conn, error)) (string, error) {
+	cert := certs[0]
+	issuer, err := issuerOf(certs, roots)
+	if err != nil {
+		return "", err
+	}
+
+	transport := &http.Transport{DialContext: dialer}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	switch {
+	case len(cert.OCSPServer) > 0:
+		req, err := ocsp.CreateRequest(cert, issuer, nil)
+		if err != nil {
+			return "", fmt.Errorf("ocsp request: %w", err)
+		}
+		body, err := fetchRevocation(ctx, client, http.MethodPost, cert.OCSPServer[0], req)
+		if err != nil {
+			return "", fmt.Errorf("ocsp responder %s: %w", cert.OCSPServer[0], err)
+		}
+		resp, err := ocsp.ParseResponseForCert(body, cert, issuer)
+		if err != nil {
+			return "", fmt.Errorf("ocsp responder %s: %w", cert.OCSPServer[0], err)
+		}
+		switch resp.Status {
+		case ocsp.Good:
+			return "", nil
+		case ocsp.Revoked:
+			return fmt.Sprintf("certificate REVOKED on %s (OCSP)", resp.RevokedAt.Format("2006-01-02")), nil
+		default:
+			return "certificate status unknown to the OCSP responder", nil
+		}
+	case len(cert.CRLDistributionPoints) > 0:
+		body, err := fetchRevocation(ctx, client, http.MethodGet, cert.CRLDistributionPoints[0], nil)
+		if err != nil {
+			return "", fmt.Errorf("crl %s: %w", cert.CRLDistributionPoints[0], err)
+		}
+		crl, err := x509.ParseRevocationList(body)
+		if err == nil {
+			err = crl.CheckSignatureFrom(issuer)
+		}
+		if err != nil {
+			return "", fmt.Errorf("crl %s: %w", cert.CRLDistributionPoints[0], err)
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return fmt.Sprintf("certificate REVOKED on %s (CRL)", entry.RevocationTime.Format("2006-01-02")), nil
+			}
+		}
+		return "", nil
+	}
+	return "", errors.New("certificate names no OCSP responder or CRL")
+}
+
+// issuerOf returns the certificate that signed the server's, sent along with
+// it or found in roots.
+func issuerOf(certs []*x509.Certificate, roots *x509.CertPool) (*x509.Certificate, error) {
+	cert := certs[0]
+	for _, c := range certs[1:] {
+		if cert.CheckSignatureFrom(c) == nil {
+			return c, nil
+		}
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	chains, err := cert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	if err != nil || len(chains[0]) < 2 {
+		return nil, errors.New("issuer certificate not found")
+	}
+	return chains[0][1], nil
+}
+
+// fetchRevocation sends an OCSP request, or fetches a CRL when body is nil,
+// returning the response body.
+func fetchRevocation(ctx context.Context, client *http.Client, method, target string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/ocsp-request")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// The caller names the URL already
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, timeoutError(err, false)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, revocationMaxBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > revocationMaxBody {
+		return nil, fmt.Errorf("response exceeds %d bytes", revocationMaxBody)
+	}
+	return data, nil
+}
+
+// clientCertificates loads the mTLS client certificate for a probe, returning
+// nil when none is configured.
+func clientCertificates(certValue, keyValue string) ([]tls.Certificate, error) {
+	if certValue == "" && keyValue == "" {
+		return nil, nil
+	}
+	cert, err := config.LoadClientCertificate(certValue, keyValue)
+	if err != nil {
+		return nil, err
+	}
+	return []tls.Certificate{cert}, nil
+}
+
+// rootCAs loads the CA bundle for a probe, returning nil (system roots) when
+// none is configured.
+func rootCAs(caValue string) (*x509.CertPool, error) {
+	if caValue == "" {
+		return nil, nil
+	}
+	return config.LoadCertPool(caValue)
+}
+
 func (p *TLSProbe) SetTimeout(timeout time.Duration) {
 	p.Timeout = timeout
 }
+
+func (p *TLSProbe) SetConnectTimeout(timeout time.Duration) {
+	p.ConnectTimeout = timeout
+}
+
+func (p *TLSProbe) SetNameResolver(r *net.Resolver) {
+	p.NameResolver = r
+}
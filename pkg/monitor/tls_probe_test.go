@@ -7,6 +7,9 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
 	"math/big"
 	"net"
 	"net/http"
@@ -15,6 +18,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"golang.org/x/crypto/ocsp"
 )
 
 func TestTLSProbe_Name(t *testing.T) {
@@ -195,3 +200,501 @@ func TestTLSProbe_SetTimeout(t *testing.T) {
 		t.Errorf("Expected timeout 10s, got %v", p.Timeout)
 	}
 }
+
+// generateTestClientCert returns a self-signed client certificate and key as
+// PEM, plus a pool trusting it for use as a server's ClientCAs.
+func generateTestClientCert(t *testing.T) (certPEM, keyPEM string, pool *x509.CertPool) {
+	t.Helper()
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "probixel-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		IsCA:         true,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	cert := generateTestCert(t, template)
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(parsed)
+
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(cert.PrivateKey.(*rsa.PrivateKey))}))
+	return certPEM, keyPEM, pool
+}
+
+// newMTLSServer starts a TLS server that requires a client certificate signed by clientCAs.
+func newMTLSServer(t *testing.T, clientCAs *x509.CertPool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	return server
+}
+
+func TestTLSProbe_ClientCertificate(t *testing.T) {
+	certPEM, keyPEM, pool := generateTestClientCert(t)
+	server := newMTLSServer(t, pool)
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "https://")
+
+	t.Run("with client cert", func(t *testing.T) {
+		probe := &TLSProbe{InsecureSkipVerify: true, ClientCert: certPEM, ClientKey: keyPEM}
+		res, err := probe.Check(context.Background(), addr)
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+		if !res.Success {
+			t.Errorf("Expected success with client cert, got: %s", res.Message)
+		}
+	})
+
+	t.Run("invalid pair", func(t *testing.T) {
+		otherCert, _, _ := generateTestClientCert(t)
+		probe := &TLSProbe{InsecureSkipVerify: true, ClientCert: otherCert, ClientKey: keyPEM}
+		res, _ := probe.Check(context.Background(), addr)
+		if res.Success || !strings.Contains(res.Message, "invalid client certificate pair") {
+			t.Errorf("Expected invalid pair error, got: %s", res.Message)
+		}
+	})
+}
+
+// serverCAPEM returns the httptest server's certificate as a PEM CA bundle.
+func serverCAPEM(server *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
+
+func TestTLSProbe_CACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "https://")
+
+	probe := &TLSProbe{CACert: serverCAPEM(server)}
+	res, err := probe.Check(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Success {
+		t.Errorf("Expected verification to succeed with ca_cert, got: %s", res.Message)
+	}
+
+	probe = &TLSProbe{}
+	res, _ = probe.Check(context.Background(), addr)
+	if res.Success {
+		t.Error("Expected verification to fail without ca_cert")
+	}
+}
+
+// newTestCA returns a CA certificate and a server certificate it signed for
+// dnsNames, without IP SANs.
+func newTestCA(t *testing.T, dnsNames ...string) (ca *x509.Certificate, leaf tls.Certificate) {
+	t.Helper()
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	caTemplate := x509.Certificate{
+		SerialNumber:          big.NewInt(10),
+		Subject:               pkix.Name{CommonName: "Probixel Test CA", Organization: []string{"Probixel"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, &caTemplate, &caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	if ca, err = x509.ParseCertificate(caDER); err != nil {
+		t.Fatalf("failed to parse CA: %v", err)
+	}
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	leafTemplate := x509.Certificate{
+		SerialNumber: big.NewInt(11),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		DNSNames:     dnsNames,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, &leafTemplate, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return ca, tls.Certificate{Certificate: [][]byte{leafDER}, PrivateKey: leafKey}
+}
+
+func TestTLSProbe_Verification(t *testing.T) {
+	ca, leaf := newTestCA(t, "api.probixel.test")
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+
+	signed := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	signed.TLS = &tls.Config{Certificates: []tls.Certificate{leaf}}
+	signed.Config.ErrorLog = log.New(io.Discard, "", 0)
+	signed.StartTLS()
+	defer signed.Close()
+	signedAddr := strings.TrimPrefix(signed.URL, "https://")
+
+	// Valid for example.com and 127.0.0.1, issued by itself for "Acme Co"
+	selfSigned := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	selfSigned.Config.ErrorLog = log.New(io.Discard, "", 0)
+	defer selfSigned.Close()
+	selfSignedAddr := strings.TrimPrefix(selfSigned.URL, "https://")
+
+	// Names are resolved to the test server, whatever the target
+	dialTo := func(addr string) func(ctx context.Context, network, address string) (net.Conn, error) {
+		return func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		probe       TLSProbe
+		server      string
+		target      string
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{
+			name:        "ca signed",
+			probe:       TLSProbe{CACert: caPEM, ExpectedIssuer: "Probixel", ExpectedSAN: "api.probixel.test"},
+			server:      signedAddr,
+			target:      "api.probixel.test",
+			wantSuccess: true,
+			wantMsg:     "OK (expires in",
+		},
+		{
+			name:    "untrusted chain",
+			server:  signedAddr,
+			target:  "api.probixel.test",
+			wantMsg: "certificate chain verification failed: x509: certificate signed by unknown authority",
+		},
+		{
+			name:    "hostname mismatch",
+			probe:   TLSProbe{CACert: caPEM},
+			server:  signedAddr,
+			target:  "www.probixel.test",
+			wantMsg: "hostname verification failed: x509: certificate is valid for api.probixel.test, not www.probixel.test",
+		},
+		{
+			name:    "issuer mismatch",
+			probe:   TLSProbe{CACert: caPEM, ExpectedIssuer: "Let's Encrypt"},
+			server:  signedAddr,
+			target:  "api.probixel.test",
+			wantMsg: `certificate issuer "CN=Probixel Test CA,O=Probixel" does not match "Let's Encrypt"`,
+		},
+		{
+			name:    "san mismatch",
+			probe:   TLSProbe{CACert: caPEM, ExpectedSAN: "www.probixel.test"},
+			server:  signedAddr,
+			target:  "api.probixel.test",
+			wantMsg: `certificate SANs [api.probixel.test] do not include "www.probixel.test"`,
+		},
+		{
+			name:        "insecure skips chain and hostname",
+			probe:       TLSProbe{InsecureSkipVerify: true},
+			server:      signedAddr,
+			target:      "www.probixel.test",
+			wantSuccess: true,
+		},
+		{
+			name:    "insecure still checks san",
+			probe:   TLSProbe{InsecureSkipVerify: true, ExpectedSAN: "www.probixel.test"},
+			server:  signedAddr,
+			target:  "api.probixel.test",
+			wantMsg: `certificate SANs [api.probixel.test] do not include "www.probixel.test"`,
+		},
+		{
+			name:    "self signed",
+			server:  selfSignedAddr,
+			target:  "example.com",
+			wantMsg: "certificate chain verification failed",
+		},
+		{
+			name:        "self signed without chain verification",
+			probe:       TLSProbe{SkipChain: true, ExpectedIssuer: "Acme", ExpectedSAN: "127.0.0.1"},
+			server:      selfSignedAddr,
+			target:      "example.com",
+			wantSuccess: true,
+		},
+		{
+			name:    "self signed hostname still verified",
+			probe:   TLSProbe{SkipChain: true},
+			server:  selfSignedAddr,
+			target:  "api.probixel.test",
+			wantMsg: "hostname verification failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.probe
+			p.ExpiryThreshold = 24 * time.Hour
+			p.DialContext = dialTo(tt.server)
+			res, err := p.Check(context.Background(), tt.target)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess {
+				t.Errorf("Expected success %v, got %v: %s", tt.wantSuccess, res.Success, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("Expected message containing %q, got %q", tt.wantMsg, res.Message)
+			}
+		})
+	}
+}
+
+// revocationCA signs server certificates and answers for them as an OCSP
+// responder and CRL distribution point, reporting status for every serial.
+type revocationCA struct {
+	cert   *x509.Certificate
+	key    *rsa.PrivateKey
+	status int // ocsp.Good, ocsp.Revoked or ocsp.Unknown
+	server *httptest.Server
+}
+
+func newRevocationCA(t *testing.T) *revocationCA {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(20),
+		Subject:               pkix.Name{CommonName: "Probixel Revocation CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	ca := &revocationCA{key: key}
+	if ca.cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatalf("failed to parse CA: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /ocsp", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+			Status:       ca.status,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Hour),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		}, ca.key)
+		if err != nil {
+			t.Errorf("failed to create OCSP response: %v", err)
+		}
+		_, _ = w.Write(resp)
+	})
+	mux.HandleFunc("GET /crl", func(w http.ResponseWriter, r *http.Request) {
+		list := &x509.RevocationList{Number: big.NewInt(1), ThisUpdate: time.Now().Add(-time.Hour), NextUpdate: time.Now().Add(time.Hour)}
+		if ca.status == ocsp.Revoked {
+			list.RevokedCertificateEntries = []x509.RevocationListEntry{{SerialNumber: big.NewInt(21), RevocationTime: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}}
+		}
+		crl, err := x509.CreateRevocationList(rand.Reader, list, ca.cert, ca.key)
+		if err != nil {
+			t.Errorf("failed to create CRL: %v", err)
+		}
+		_, _ = w.Write(crl)
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	ca.server = httptest.NewServer(mux)
+	t.Cleanup(ca.server.Close)
+	return ca
+}
+
+// issue returns a certificate for api.probixel.test naming the responder at
+// ocspPath and the CRL at crlPath of the CA's server, when set.
+func (ca *revocationCA) issue(t *testing.T, ocspPath, crlPath string) tls.Certificate {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(21),
+		Subject:      pkix.Name{CommonName: "api.probixel.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		DNSNames:     []string{"api.probixel.test"},
+	}
+	if ocspPath != "" {
+		template.OCSPServer = []string{ca.server.URL + ocspPath}
+	}
+	if crlPath != "" {
+		template.CRLDistributionPoints = []string{ca.server.URL + crlPath}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestTLSProbe_Revocation(t *testing.T) {
+	ca := newRevocationCA(t)
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}))
+
+	tests := []struct {
+		name        string
+		probe       TLSProbe
+		ocspPath    string
+		crlPath     string
+		status      int
+		withIssuer  bool // The server sends the CA along with its certificate
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{
+			name:        "ocsp good",
+			probe:       TLSProbe{CACert: caPEM},
+			ocspPath:    "/ocsp",
+			status:      ocsp.Good,
+			wantSuccess: true,
+			wantMsg:     "days, not revoked)",
+		},
+		{
+			name:     "ocsp revoked",
+			probe:    TLSProbe{CACert: caPEM},
+			ocspPath: "/ocsp",
+			status:   ocsp.Revoked,
+			wantMsg:  "certificate REVOKED on 2026-03-01 (OCSP)",
+		},
+		{
+			name:     "ocsp unknown",
+			probe:    TLSProbe{CACert: caPEM},
+			ocspPath: "/ocsp",
+			status:   ocsp.Unknown,
+			wantMsg:  "certificate status unknown to the OCSP responder",
+		},
+		{
+			name:     "ocsp preferred over crl",
+			probe:    TLSProbe{CACert: caPEM},
+			ocspPath: "/ocsp",
+			crlPath:  "/down",
+			status:   ocsp.Revoked,
+			wantMsg:  "(OCSP)",
+		},
+		{
+			name:        "responder down",
+			probe:       TLSProbe{CACert: caPEM},
+			ocspPath:    "/down",
+			wantSuccess: true,
+			wantMsg:     "revocation not checked: ocsp responder " + ca.server.URL + "/down: HTTP 503",
+		},
+		{
+			name:     "responder down and required",
+			probe:    TLSProbe{CACert: caPEM, RevocationRequired: true},
+			ocspPath: "/down",
+			wantMsg:  "revocation check failed: ocsp responder " + ca.server.URL + "/down: HTTP 503",
+		},
+		{
+			name:        "crl good",
+			probe:       TLSProbe{CACert: caPEM},
+			crlPath:     "/crl",
+			status:      ocsp.Good,
+			wantSuccess: true,
+			wantMsg:     "days, not revoked)",
+		},
+		{
+			name:    "crl revoked",
+			probe:   TLSProbe{CACert: caPEM},
+			crlPath: "/crl",
+			status:  ocsp.Revoked,
+			wantMsg: "certificate REVOKED on 2026-03-01 (CRL)",
+		},
+		{
+			name:        "no responder",
+			probe:       TLSProbe{CACert: caPEM},
+			wantSuccess: true,
+			wantMsg:     "revocation not checked: certificate names no OCSP responder or CRL",
+		},
+		{
+			name:       "issuer sent by the server",
+			probe:      TLSProbe{InsecureSkipVerify: true},
+			ocspPath:   "/ocsp",
+			status:     ocsp.Revoked,
+			withIssuer: true,
+			wantMsg:    "(OCSP)",
+		},
+		{
+			name:        "issuer not found",
+			probe:       TLSProbe{InsecureSkipVerify: true},
+			ocspPath:    "/ocsp",
+			status:      ocsp.Revoked,
+			wantSuccess: true,
+			wantMsg:     "revocation not checked: issuer certificate not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ca.status = tt.status
+			leaf := ca.issue(t, tt.ocspPath, tt.crlPath)
+			if tt.withIssuer {
+				leaf.Certificate = append(leaf.Certificate, ca.cert.Raw)
+			}
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			server.TLS = &tls.Config{Certificates: []tls.Certificate{leaf}}
+			server.Config.ErrorLog = log.New(io.Discard, "", 0)
+			server.StartTLS()
+			defer server.Close()
+
+			p := tt.probe
+			p.CheckRevocation = true
+			p.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+				// The responder is reached through the same dialer
+				if address == "api.probixel.test:443" {
+					address = strings.TrimPrefix(server.URL, "https://")
+				}
+				var d net.Dialer
+				return d.DialContext(ctx, network, address)
+			}
+			res, err := p.Check(context.Background(), "api.probixel.test")
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess {
+				t.Errorf("Expected success %v, got %v: %s", tt.wantSuccess, res.Success, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("Expected message containing %q, got %q", tt.wantMsg, res.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"probixel/pkg/tunnels"
+)
+
+// defaultMaxHops is how far TracerouteProbe looks for a target by default,
+// the same as traceroute.
+const defaultMaxHops = 30
+
+// TracerouteProbe pings each target with increasing TTLs, the way traceroute
+// does, so a target that can't be reached is reported with the last router
+// on the path that answered. Each hop is pinged like PingProbe does, over the
+// tunnel's ICMP socket, the SSH server's ping or the local one.
+type TracerouteProbe struct {
+	MaxHops       int           // Hops to try before giving up, defaults to 30
+	Timeout       time.Duration // Wait for each hop's answer
+	SourceAddress string        // Local IP echo requests are sent from, empty for the OS default
+	DialContext   func(ctx context.Context, network, address string) (net.Conn, error)
+	targetMode    string
+	tunnel        tunnels.Tunnel
+}
+
+func (p *TracerouteProbe) SetTunnel(t tunnels.Tunnel) {
+	p.tunnel = t
+}
+
+func (p *TracerouteProbe) Name() string {
+	return MonitorTypeTraceroute
+}
+
+func (p *TracerouteProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeTraceroute,
+		Description: "Traces the path to each target with increasing TTLs, reporting the last hop reached",
+		Fields:      []string{"targets", "target_mode", "timeout", "tunnel", "source_address", "traceroute.max_hops"},
+	}
+}
+
+func (p *TracerouteProbe) SetTargetMode(mode string) {
+	p.targetMode = mode
+}
+
+func (p *TracerouteProbe) Check(ctx context.Context, target string) (Result, error) {
+	startTotal := time.Now()
+
+	// Strict stabilization adherence: always return Pending if tunnel not stabilized
+	if p.tunnel != nil && !p.tunnel.IsStabilized() {
+		return Result{
+			Success:   false,
+			Pending:   true,
+			Duration:  time.Since(startTotal),
+			Message:   fmt.Sprintf("waiting for tunnel %q to stabilize", p.tunnel.Name()),
+			Timestamp: startTotal,
+		}, nil
+	}
+
+	targets := strings.Split(target, ",")
+	var lastErr error
+	var lastTarget string
+	var results []TargetResult
+	var totalDuration time.Duration
+	successCount := 0
+
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if err := checkExpired(ctx); err != nil {
+			lastErr, lastTarget = err, t
+			break
+		}
+
+		duration, note, err := p.trace(ctx, t)
+		results = append(results, newTargetResult(t, duration, err))
+		if err != nil {
+			if p.targetMode == TargetModeAll {
+				return Result{
+					Success:       false,
+					Message:       fmt.Sprintf("target %s failed: %v", t, err),
+					Target:        t,
+					Timestamp:     startTotal,
+					TargetResults: results,
+				}, nil
+			}
+			lastErr, lastTarget = err, t
+			continue
+		}
+		if p.targetMode != TargetModeAll {
+			return Result{
+				Success:       true,
+				Duration:      duration,
+				Message:       withNote(targetMessage(targets, t, "OK"), note),
+				Target:        t,
+				Timestamp:     startTotal,
+				TargetResults: results,
+			}, nil
+		}
+		totalDuration += duration
+		successCount++
+	}
+
+	if successCount > 0 && lastErr == nil {
+		return Result{
+			Success:       true,
+			Duration:      totalDuration / time.Duration(successCount),
+			Message:       fmt.Sprintf("all %d targets OK", successCount),
+			Timestamp:     startTotal,
+			TargetResults: results,
+		}, nil
+	}
+	if lastErr == nil {
+		return Result{Success: false, Message: "empty target", Timestamp: startTotal}, nil
+	}
+	return Result{
+		Success:       false,
+		Message:       fmt.Sprintf("all targets failed, last error: target %s: %v", lastTarget, lastErr),
+		Target:        lastTarget,
+		Timestamp:     startTotal,
+		TargetResults: results,
+	}, nil
+}
+
+// trace pings target with a TTL of 1 and up until it answers, returning its
+// round-trip and a note with the number of hops. Hops that don't answer are
+// skipped like traceroute does; the error names the last one that did.
+func (p *TracerouteProbe) trace(ctx context.Context, target string) (time.Duration, string, error) {
+	host, port, err := parseTarget(target)
+	if err != nil {
+		return 0, "", err
+	}
+	if port != "" {
+		return 0, "", fmt.Errorf("invalid target %q: traceroute targets take no port", target)
+	}
+
+	maxHops := p.MaxHops
+	if maxHops <= 0 {
+		maxHops = defaultMaxHops
+	}
+	pinger := &PingProbe{Timeout: p.Timeout, DialContext: p.DialContext, tunnel: p.tunnel}
+
+	lastHop, lastTTL := "", 0
+	var silentErr error
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		if err := checkExpired(ctx); err != nil {
+			return 0, "", withLastHop(err, lastTTL, lastHop)
+		}
+
+		start := time.Now()
+		duration, _, err := pinger.ping(ctx, host, pingOptions{Source: p.SourceAddress, TTL: ttl})
+		var exceeded *ttlExceededError
+		switch {
+		case err == nil:
+			if duration == 0 {
+				duration = time.Since(start)
+			}
+			return duration, fmt.Sprintf("%d hops", ttl), nil
+		case errors.As(err, &exceeded):
+			lastHop, lastTTL = exceeded.hop, ttl
+		case errors.Is(err, errTTLUnsupported):
+			return 0, "", err
+		default:
+			silentErr = err // No answer from this hop
+		}
+	}
+
+	if lastTTL == 0 {
+		return 0, "", fmt.Errorf("not reached within %d hops, no hop answered: %w", maxHops, silentErr)
+	}
+	return 0, "", withLastHop(fmt.Errorf("not reached within %d hops", maxHops), lastTTL, lastHop)
+}
+
+// withLastHop adds the last hop that answered a trace to err.
+func withLastHop(err error, ttl int, hop string) error {
+	if ttl == 0 {
+		return err
+	}
+	if hop == "" {
+		hop = "unknown address"
+	}
+	return fmt.Errorf("%w, last hop %d: %s", err, ttl, hop)
+}
+
+func (p *TracerouteProbe) SetTimeout(timeout time.Duration) {
+	p.Timeout = timeout
+}
+
+func (p *TracerouteProbe) SetSourceAddress(addr string) {
+	p.SourceAddress = addr
+}
@@ -0,0 +1,279 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"probixel/pkg/tunnels"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// mockTracerouteConn is an ICMP socket to a target hops away: echo requests
+// sent with a lower TTL are answered with time exceeded by router 10.0.0.<ttl>,
+// unless the router is silent.
+type mockTracerouteConn struct {
+	net.Conn
+	hops   int
+	silent map[int]bool
+	ttl    int
+	reply  []byte
+	from   net.Addr
+}
+
+func (c *mockTracerouteConn) SetTTL(ttl int) error {
+	c.ttl = ttl
+	return nil
+}
+
+func (c *mockTracerouteConn) Write(b []byte) (int, error) {
+	msg := icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 1, Seq: 1}}
+	c.from = &net.IPAddr{IP: net.IPv4(192, 0, 2, 1)}
+	if c.ttl < c.hops {
+		if c.silent[c.ttl] {
+			return len(b), nil
+		}
+		msg = icmp.Message{Type: ipv4.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: b}}
+		c.from = &net.IPAddr{IP: net.IPv4(10, 0, 0, byte(c.ttl))}
+	}
+	c.reply, _ = msg.Marshal(nil)
+	return len(b), nil
+}
+
+func (c *mockTracerouteConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	if c.reply == nil {
+		return 0, nil, errors.New("i/o timeout")
+	}
+	return copy(b, c.reply), c.from, nil
+}
+
+func (c *mockTracerouteConn) Read(b []byte) (int, error) {
+	n, _, err := c.ReadFrom(b)
+	return n, err
+}
+
+func (c *mockTracerouteConn) Close() error                       { return nil }
+func (c *mockTracerouteConn) SetDeadline(t time.Time) error      { return nil }
+func (c *mockTracerouteConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *mockTracerouteConn) SetWriteDeadline(t time.Time) error { return nil }
+func (c *mockTracerouteConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.Write(b)
+}
+func (c *mockTracerouteConn) LocalAddr() net.Addr { return &net.IPAddr{} }
+
+func TestTracerouteProbe_Name(t *testing.T) {
+	p := &TracerouteProbe{}
+	if p.Name() != MonitorTypeTraceroute {
+		t.Errorf("Expected name %s, got %s", MonitorTypeTraceroute, p.Name())
+	}
+}
+
+func TestTracerouteProbe_Builtin(t *testing.T) {
+	tests := []struct {
+		name        string
+		hops        int
+		silent      map[int]bool
+		maxHops     int
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{
+			name:        "reached",
+			hops:        4,
+			wantSuccess: true,
+			wantMsg:     "OK (4 hops)",
+		},
+		{
+			name:        "silent router on the way",
+			hops:        4,
+			silent:      map[int]bool{2: true},
+			wantSuccess: true,
+			wantMsg:     "OK (4 hops)",
+		},
+		{
+			name:    "beyond max_hops",
+			hops:    10,
+			maxHops: 5,
+			wantMsg: "not reached within 5 hops, last hop 5: 10.0.0.5",
+		},
+		{
+			name:    "path broken after the third hop",
+			hops:    10,
+			silent:  map[int]bool{4: true, 5: true, 6: true},
+			maxHops: 6,
+			wantMsg: "not reached within 6 hops, last hop 3: 10.0.0.3",
+		},
+		{
+			name:    "no hop answers",
+			hops:    10,
+			silent:  map[int]bool{1: true, 2: true},
+			maxHops: 2,
+			wantMsg: "not reached within 2 hops, no hop answered: ping read: i/o timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ttls []int
+			p := &TracerouteProbe{
+				MaxHops: tt.maxHops,
+				DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+					conn := &mockTracerouteConn{hops: tt.hops, silent: tt.silent}
+					return &ttlRecordingConn{mockTracerouteConn: conn, ttls: &ttls}, nil
+				},
+			}
+			res, err := p.Check(context.Background(), "192.0.2.1")
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess {
+				t.Errorf("Expected success %v, got %v: %s", tt.wantSuccess, res.Success, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("Expected message containing %q, got %q", tt.wantMsg, res.Message)
+			}
+			for i, ttl := range ttls {
+				if ttl != i+1 {
+					t.Fatalf("Expected increasing TTLs from 1, got %v", ttls)
+				}
+			}
+		})
+	}
+}
+
+// ttlRecordingConn records the TTL of each echo request.
+type ttlRecordingConn struct {
+	*mockTracerouteConn
+	ttls *[]int
+}
+
+func (c *ttlRecordingConn) SetTTL(ttl int) error {
+	*c.ttls = append(*c.ttls, ttl)
+	return c.mockTracerouteConn.SetTTL(ttl)
+}
+
+func TestTracerouteProbe_BuiltinTTLUnsupported(t *testing.T) {
+	dials := 0
+	p := &TracerouteProbe{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dials++
+			return &mockPingConn{}, nil
+		},
+	}
+	res, err := p.Check(context.Background(), "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if res.Success || !strings.Contains(res.Message, "ttl is not supported") {
+		t.Errorf("Expected ttl unsupported failure, got %q", res.Message)
+	}
+	if dials != 1 {
+		t.Errorf("Expected the trace to stop at the first hop, got %d", dials)
+	}
+}
+
+func TestTracerouteProbe_Executable(t *testing.T) {
+	oldExec := execCommand
+	execCommand = fakeExecCommand
+	defer func() { execCommand = oldExec }()
+
+	p := &TracerouteProbe{MaxHops: 4}
+	p.SetTimeout(time.Second)
+
+	res, err := p.Check(context.Background(), "hops.test")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Success || res.Message != "OK (3 hops)" {
+		t.Errorf("Expected success in 3 hops, got %v: %s", res.Success, res.Message)
+	}
+	if res.Duration != 20500*time.Microsecond {
+		t.Errorf("Expected the target's round-trip, got %v", res.Duration)
+	}
+
+	res, _ = p.Check(context.Background(), "blackhole.test")
+	want := "not reached within 4 hops, last hop 2: 10.0.0.2"
+	if res.Success || !strings.Contains(res.Message, want) {
+		t.Errorf("Expected failure containing %q, got %q", want, res.Message)
+	}
+}
+
+func TestTracerouteProbe_TargetModeAll(t *testing.T) {
+	p := &TracerouteProbe{
+		MaxHops: 3,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			hops := 2
+			if address == "192.0.2.2" {
+				hops = 5
+			}
+			return &mockTracerouteConn{hops: hops}, nil
+		},
+	}
+	p.SetTargetMode(TargetModeAll)
+	res, _ := p.Check(context.Background(), "192.0.2.1,192.0.2.2")
+	if res.Success || !strings.Contains(res.Message, "target 192.0.2.2 failed: not reached within 3 hops") {
+		t.Errorf("Expected failure of 192.0.2.2, got: %s", res.Message)
+	}
+
+	p.SetTargetMode(TargetModeAny)
+	res, _ = p.Check(context.Background(), "192.0.2.2,192.0.2.1")
+	if !res.Success || res.Target != "192.0.2.1" {
+		t.Errorf("Expected success on 192.0.2.1, got: %s", res.Message)
+	}
+}
+
+func TestTracerouteProbe_InvalidTarget(t *testing.T) {
+	p := &TracerouteProbe{}
+	res, _ := p.Check(context.Background(), "192.0.2.1:80")
+	if res.Success || !strings.Contains(res.Message, "traceroute targets take no port") {
+		t.Errorf("Expected invalid target failure, got %q", res.Message)
+	}
+}
+
+func TestTracerouteProbe_Stabilization(t *testing.T) {
+	mt := &tunnels.MockTunnel{IsStabilizedResult: false}
+	p := &TracerouteProbe{}
+	p.SetTunnel(mt)
+
+	res, err := p.Check(context.Background(), "192.0.2.1")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Pending {
+		t.Error("Expected Pending: true")
+	}
+}
+
+func TestTTLExceeded(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"From 10.0.0.1 icmp_seq=1 Time to live exceeded", "10.0.0.1"}, // Linux
+		{"36 bytes from 10.0.0.1: Time to live exceeded", "10.0.0.1"},  // macOS
+		{"Reply from 10.0.0.1: TTL expired in transit.", "10.0.0.1"},   // Windows
+		{"From fe80::1 icmp_seq=1 Time to live exceeded", "fe80::1"},   // IPv6 address
+		{"1 packets transmitted, 0 received, 100% packet loss", ""},    // No answer
+	}
+	for _, tt := range tests {
+		err := ttlExceeded(pingOptions{TTL: 1}, tt.output)
+		var exceeded *ttlExceededError
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("Expected no hop in %q, got %v", tt.output, err)
+			}
+			continue
+		}
+		if !errors.As(err, &exceeded) || exceeded.hop != tt.want {
+			t.Errorf("Expected hop %s in %q, got %v", tt.want, tt.output, err)
+		}
+	}
+	if err := ttlExceeded(pingOptions{}, "From 10.0.0.1 icmp_seq=1 Time to live exceeded"); err != nil {
+		t.Errorf("Expected no hop without a TTL, got %v", err)
+	}
+}
@@ -10,12 +10,25 @@ import (
 	"probixel/pkg/tunnels"
 )
 
+// udpMaxDatagram is the largest response datagram read to match udp.expect.
+const udpMaxDatagram = 65535
+
 type UDPProbe struct {
 	// DialContext allows mocking the dialer for tests
-	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
-	Timeout     time.Duration
-	targetMode  string
-	tunnel      tunnels.Tunnel
+	DialContext   func(ctx context.Context, network, address string) (net.Conn, error)
+	Timeout       time.Duration
+	Resolver      *TargetResolver // Optional, resolves hostname targets before dialing
+	SourceAddress string          // Local IP sockets are bound to, empty for the OS default
+	Family        string          // FamilyIPv4 or FamilyIPv6 to send over that family only, empty for either
+	DNSCache      *DNSCache       // Optional, reuses resolutions of direct sockets within its TTL
+	NameResolver  *net.Resolver   // Optional, resolves hostnames instead of the system resolver
+	Send          string          // Optional, the datagram sent instead of an empty one
+	Expect        string          // Optional, a response datagram must contain it
+	ExpectRegex   bool            // Expect is a regular expression rather than a substring
+	targetMode    string
+	quorum        int
+	concurrency   int
+	tunnel        tunnels.Tunnel
 }
 
 func (p *UDPProbe) SetTunnel(t tunnels.Tunnel) {
@@ -26,10 +39,26 @@ func (p *UDPProbe) Name() string {
 	return MonitorTypeUDP
 }
 
+func (p *UDPProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeUDP,
+		Description: "Sends a UDP datagram to each target, optionally matching the response",
+		Fields:      []string{"targets", "target_mode", "quorum", "concurrency", "timeout", "tunnel", "source_address", "family", "resolver", "udp.resolve", "udp.resolve_to", "udp.send", "udp.expect", "udp.expect_regex"},
+	}
+}
+
 func (p *UDPProbe) SetTargetMode(mode string) {
 	p.targetMode = mode
 }
 
+func (p *UDPProbe) SetQuorum(n int) {
+	p.quorum = n
+}
+
+func (p *UDPProbe) SetConcurrency(n int) {
+	p.concurrency = n
+}
+
 func (p *UDPProbe) Check(ctx context.Context, target string) (Result, error) {
 	// Support multiple targets (can be comma-separated or single)
 	targets := strings.Split(target, ",")
@@ -46,124 +75,134 @@ func (p *UDPProbe) Check(ctx context.Context, target string) (Result, error) {
 		}, nil
 	}
 
-	// For "all" mode, track successes
-	if p.targetMode == TargetModeAll {
-		var totalDuration time.Duration
-		successCount := 0
-
-		for _, t := range targets {
-			t = strings.TrimSpace(t)
-			if t == "" {
-				continue
-			}
-
-			start := time.Now()
-			var conn net.Conn
-			var err error
-
-			// Use mocked DialContext if available, else net.Dialer
-			if p.DialContext != nil {
-				conn, err = p.DialContext(ctx, "udp", t)
-			} else {
-				timeout := p.Timeout
-				if timeout == 0 {
-					timeout = 5 * time.Second
-				}
-				d := net.Dialer{Timeout: timeout}
-				conn, err = d.DialContext(ctx, "udp", t)
-			}
-
+	return checkTargets(ctx, targets, targetCheck{
+		mode:        p.targetMode,
+		quorum:      p.quorum,
+		concurrency: p.concurrency,
+		okMessage:   withNote("all %d targets OK", familyNote(p.Family)),
+		failMessage: func(t string, err error) string {
+			return fmt.Sprintf("all udp targets failed, last error: target %s: %v", t, err)
+		},
+		check: func(ctx context.Context, t string) (time.Duration, string, error) {
+			duration, note, err := p.check(ctx, t)
 			if err != nil {
-				return Result{
-					Success:   false,
-					Duration:  0,
-					Message:   fmt.Sprintf("target %s failed: %v", t, err),
-					Timestamp: startTotal,
-				}, nil
+				return 0, "", err
 			}
+			return duration, withNote("OK", note), nil
+		},
+	}, startTotal), nil
+}
 
-			_, err = conn.Write([]byte{})
-			_ = conn.Close()
-
-			if err != nil {
-				return Result{
-					Success:   false,
-					Duration:  0,
-					Message:   fmt.Sprintf("target %s write failed: %v", t, err),
-					Timestamp: startTotal,
-				}, nil
-			}
-
-			totalDuration += time.Since(start)
-			successCount++
-		}
-
-		if successCount > 0 {
-			return Result{
-				Success:   true,
-				Duration:  totalDuration / time.Duration(successCount),
-				Message:   fmt.Sprintf("all %d targets OK", successCount),
-				Timestamp: startTotal,
-			}, nil
-		}
+// check opens a socket to target and exchanges datagrams over it. It returns
+// the time taken and the dial's note.
+func (p *UDPProbe) check(ctx context.Context, target string) (time.Duration, string, error) {
+	start := time.Now()
+	conn, note, err := p.dial(ctx, target)
+	if err != nil {
+		return 0, "", err
 	}
+	defer func() { _ = conn.Close() }()
+	if err := p.exchange(ctx, conn); err != nil {
+		return 0, note, err
+	}
+	return time.Since(start), note, nil
+}
 
-	// Default "any" mode - return on first success
-	var lastErr error
-	for _, t := range targets {
-		t = strings.TrimSpace(t)
-		if t == "" {
-			continue
-		}
+// exchange writes Send, or an empty datagram, to conn. That only proves the
+// datagram could be sent, so with Expect set it then reads datagrams until one
+// matches or the probe timeout passes.
+func (p *UDPProbe) exchange(ctx context.Context, conn net.Conn) error {
+	if _, err := conn.Write([]byte(p.Send)); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	if p.Expect == "" {
+		return nil
+	}
+	match, err := expectMatcher(p.Expect, p.ExpectRegex)
+	if err != nil {
+		return fmt.Errorf("invalid udp.expect: %w", err)
+	}
 
-		start := time.Now()
-		var conn net.Conn
-		var err error
-
-		// Use mocked DialContext if available, else net.Dialer
-		if p.DialContext != nil {
-			conn, err = p.DialContext(ctx, "udp", t)
-		} else {
-			timeout := p.Timeout
-			if timeout == 0 {
-				timeout = 5 * time.Second
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	buf := make([]byte, udpMaxDatagram)
+	var last []byte
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if last == nil {
+				return fmt.Errorf("no response: %w", timeoutError(err, true))
 			}
-			d := net.Dialer{Timeout: timeout}
-			conn, err = d.DialContext(ctx, "udp", t)
+			return fmt.Errorf("response does not match %q, got %s", p.Expect, excerpt(last))
 		}
-
-		if err != nil {
-			lastErr = err
-			continue
+		if match(buf[:n]) {
+			return nil
 		}
+		last = append(last[:0], buf[:n]...)
+	}
+}
 
-		// For UDP, Dial just creates a socket then write something to check reachability/routing.
-		// This doesn't guarantee the server receives it or replies, but it validates sendings.
-		_, err = conn.Write([]byte{})
-		_ = conn.Close()
-
-		if err != nil {
-			lastErr = err
-			continue
+// dial opens a UDP socket to target using the mocked DialContext if available,
+// else net.Dialer. The returned note names the forced family and describes the
+// target resolution, if any.
+func (p *UDPProbe) dial(ctx context.Context, target string) (net.Conn, string, error) {
+	addr, _, err := hostPortTarget(target, "")
+	if err != nil {
+		return nil, "", err
+	}
+	network := familyNetwork("udp", p.Family)
+	addr, note, err := p.Resolver.resolve(ctx, network, addr)
+	if err != nil {
+		return nil, "", err
+	}
+	note = joinNotes(familyNote(p.Family), note)
+
+	var conn net.Conn
+	if p.DialContext != nil {
+		conn, err = p.DialContext(ctx, network, addr)
+	} else {
+		timeout := p.Timeout
+		if timeout == 0 {
+			timeout = 5 * time.Second
+		}
+		d := net.Dialer{Timeout: timeout, LocalAddr: localAddr("udp", p.SourceAddress), Resolver: p.NameResolver}
+		var dialAddr string
+		if dialAddr, err = p.DNSCache.resolve(ctx, network, addr); err == nil {
+			conn, err = d.DialContext(ctx, network, dialAddr)
+			err = sourceError(err, p.SourceAddress)
 		}
-
-		// Success
-		return Result{
-			Success:   true,
-			Duration:  time.Since(start),
-			Message:   "OK",
-			Target:    t,
-			Timestamp: startTotal,
-		}, nil
 	}
-
-	return Result{
-		Success:   false,
-		Duration:  0,
-		Message:   fmt.Sprintf("all udp targets failed, last error: %v", lastErr),
-		Timestamp: startTotal,
-	}, nil
+	if err != nil {
+		p.DNSCache.forget(target)
+	}
+	if err != nil && note != "" {
+		err = fmt.Errorf("%w (%s)", err, note)
+	}
+	return conn, note, err
 }
+
 func (p *UDPProbe) SetTimeout(timeout time.Duration) {
 	p.Timeout = timeout
 }
+
+func (p *UDPProbe) SetSourceAddress(addr string) {
+	p.SourceAddress = addr
+}
+
+func (p *UDPProbe) SetFamily(family string) {
+	p.Family = family
+}
+
+func (p *UDPProbe) SetNameResolver(r *net.Resolver) {
+	p.NameResolver = r
+}
@@ -214,3 +214,68 @@ func TestUDPProbe_SetTimeout(t *testing.T) {
 		t.Errorf("Expected timeout 10s, got %v", p.Timeout)
 	}
 }
+
+// startUDPServer answers every datagram received with reply(datagram), or
+// not at all when reply returns nil.
+func startUDPServer(t *testing.T, reply func([]byte) []byte) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = pc.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if out := reply(buf[:n]); out != nil {
+				_, _ = pc.WriteTo(out, addr)
+			}
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestUDPProbe_SendExpect(t *testing.T) {
+	echo := startUDPServer(t, func(b []byte) []byte { return append([]byte("echo: "), b...) })
+	silent := startUDPServer(t, func([]byte) []byte { return nil })
+
+	tests := []struct {
+		name        string
+		probe       UDPProbe
+		target      string
+		mode        string
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{"echo matched", UDPProbe{Send: "ping", Expect: "echo: ping"}, echo, "", true, "OK"},
+		{"echo matched by regex", UDPProbe{Send: "ping", Expect: `^echo: p[io]ng$`, ExpectRegex: true}, echo, "", true, "OK"},
+		{"empty datagram answered", UDPProbe{Expect: "echo"}, echo, "", true, "OK"},
+		{"echo not matched", UDPProbe{Send: "ping", Expect: "pong"}, echo, "", false, `response does not match "pong", got "echo: ping"`},
+		{"no reply", UDPProbe{Send: "ping", Expect: "pong"}, silent, "", false, "no response: read timeout"},
+		{"send without expect", UDPProbe{Send: "ping"}, silent, "", true, "OK"},
+		{"any target replies", UDPProbe{Send: "ping", Expect: "ping"}, silent + "," + echo, TargetModeAny, true, "target " + echo + ": OK"},
+		{"all targets must reply", UDPProbe{Send: "ping", Expect: "ping"}, echo + "," + silent, TargetModeAll, false, "target " + silent + " failed: no response"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.probe
+			p.SetTimeout(200 * time.Millisecond)
+			p.SetTargetMode(tt.mode)
+			res, err := p.Check(context.Background(), tt.target)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess {
+				t.Errorf("Expected success %v, got %v: %s", tt.wantSuccess, res.Success, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("Expected message containing %q, got %q", tt.wantMsg, res.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // G505: mandated by the WebSocket handshake
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"probixel/pkg/config"
+	"probixel/pkg/tunnels"
+)
+
+// WebSocket frame opcodes (RFC 6455).
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// wsMaxMessage bounds the messages read from the server.
+const wsMaxMessage = 1 << 20
+
+// wsCloseWait bounds how long the server is given to echo the close frame.
+const wsCloseWait = time.Second
+
+// wsGUID is appended to the handshake key to derive Sec-WebSocket-Accept.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocketProbe performs the WebSocket handshake with a ws:// or wss:// URL,
+// optionally sends a message and waits for a reply matching MatchData, then
+// closes the connection cleanly.
+type WebSocketProbe struct {
+	Headers            map[string]string // Sent with the handshake request
+	Send               string            // Text message sent once connected, empty to send nothing
+	MatchData          *config.MatchDataConfig
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+	DialContext        func(ctx context.Context, network, address string) (net.Conn, error)
+	targetMode         string
+	tunnel             tunnels.Tunnel
+}
+
+func (p *WebSocketProbe) SetTunnel(t tunnels.Tunnel) {
+	p.tunnel = t
+}
+
+func (p *WebSocketProbe) Name() string {
+	return MonitorTypeWebSocket
+}
+
+func (p *WebSocketProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeWebSocket,
+		Description: "Performs a WebSocket handshake, optionally sending a message and matching the reply",
+		Fields: []string{
+			"url",
+			"timeout",
+			"tunnel",
+			"websocket.headers",
+			"websocket.send",
+			"websocket.match_data",
+			"websocket.insecure_skip_verify",
+		},
+	}
+}
+
+func (p *WebSocketProbe) SetTargetMode(mode string) {
+	p.targetMode = mode
+}
+
+func (p *WebSocketProbe) Check(ctx context.Context, target string) (Result, error) {
+	start := time.Now()
+
+	// Strict stabilization adherence: always return Pending if tunnel not stabilized
+	if p.tunnel != nil && !p.tunnel.IsStabilized() {
+		return Result{
+			Success:   false,
+			Pending:   true,
+			Duration:  time.Since(start),
+			Message:   fmt.Sprintf("waiting for tunnel %q to stabilize", p.tunnel.Name()),
+			Timestamp: start,
+		}, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return Result{
+			Success:   false,
+			Message:   fmt.Sprintf("invalid websocket url %q", target),
+			Target:    target,
+			Timestamp: start,
+		}, nil
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	msg, err := p.session(ctx, u, timeout)
+	duration := time.Since(start)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("websocket session aborted: %w", ctx.Err())
+		}
+		msg = joinStatus(msg, err.Error())
+	}
+	return Result{
+		Success:   err == nil,
+		Duration:  duration,
+		Message:   msg,
+		Target:    target,
+		Timestamp: start,
+	}, nil
+}
+
+// joinStatus appends detail to the handshake status, if it got that far.
+func joinStatus(status, detail string) string {
+	if status == "" {
+		return detail
+	}
+	return status + ", " + detail
+}
+
+// session connects to u and runs the exchange, returning the handshake status
+// and, when a reply was awaited, what was made of it.
+func (p *WebSocketProbe) session(ctx context.Context, u *url.URL, timeout time.Duration) (string, error) {
+	defaultPort := "80"
+	if u.Scheme == "wss" {
+		defaultPort = "443"
+	}
+	addr, host, err := hostPortTarget(u.Host, defaultPort)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := dialTarget(ctx, "tcp", addr, p.DialContext, p.tunnel, nil, 0)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = conn.Close() }()
+
+	// The session is read with plain deadlines, abort it by closing the
+	// connection once ctx is done
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	if u.Scheme == "wss" {
+		tlsConn := tls.Client(conn, &tls.Config{
+			InsecureSkipVerify: p.InsecureSkipVerify, //nolint:gosec // G402: Optional skip for untrusted endpoints
+			ServerName:         host,
+		})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return "", fmt.Errorf("tls handshake failed: %w", timeoutError(err, true))
+		}
+		conn = tlsConn
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := p.handshake(conn, r, u)
+	if err != nil {
+		return "", timeoutError(err, true)
+	}
+	status := fmt.Sprintf("HTTP %d", resp.StatusCode)
+
+	if p.Send != "" {
+		if err := wsWriteFrame(conn, wsText, []byte(p.Send)); err != nil {
+			return status, fmt.Errorf("send failed: %w", err)
+		}
+	}
+	if p.Send != "" || (p.MatchData != nil && len(p.MatchData.Expectations) > 0) {
+		reply, err := wsReadMessage(conn, r)
+		if err != nil {
+			return status, fmt.Errorf("no reply: %w", timeoutError(err, true))
+		}
+		if p.MatchData != nil && len(p.MatchData.Expectations) > 0 {
+			// Replies are matched like HTTP bodies, header expectations
+			// against the handshake response
+			matcher := &HTTPProbe{MatchData: p.MatchData}
+			if ok, note := matcher.evaluateExpectations(reply, resp.Header); !ok {
+				return status, errors.New(note)
+			}
+			status += ", Expectations met"
+		} else {
+			status += ", reply received"
+		}
+	}
+
+	// Close cleanly, giving the server a moment to echo the close frame
+	if err := wsWriteFrame(conn, wsClose, binary.BigEndian.AppendUint16(nil, 1000)); err == nil {
+		_ = conn.SetReadDeadline(time.Now().Add(wsCloseWait))
+		for {
+			if _, err := wsReadMessage(conn, r); err != nil {
+				break
+			}
+		}
+	}
+	return status, nil
+}
+
+// handshake sends the upgrade request and checks the server switched
+// protocols with the expected Sec-WebSocket-Accept.
+func (p *WebSocketProbe) handshake(conn net.Conn, r *bufio.Reader, u *url.URL) (*http.Response, error) {
+	key := make([]byte, 16)
+	_, _ = rand.Read(key)
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+
+	httpURL := *u
+	httpURL.Scheme = "http"
+	if u.Scheme == "wss" {
+		httpURL.Scheme = "https"
+	}
+	req, err := http.NewRequest(http.MethodGet, httpURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", encodedKey)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("handshake failed: %w", err)
+	}
+
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, fmt.Errorf("handshake failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("handshake failed: HTTP %s", resp.Status)
+	}
+	sum := sha1.Sum([]byte(encodedKey + wsGUID)) //nolint:gosec // G401: mandated by the WebSocket handshake
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return nil, errors.New("handshake failed: invalid Sec-WebSocket-Accept")
+	}
+	return resp, nil
+}
+
+// wsWriteFrame writes a single masked frame, as clients must.
+func wsWriteFrame(w io.Writer, opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	mask := make([]byte, 4)
+	_, _ = rand.Read(mask)
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+// wsReadMessage reads the next text or binary message, reassembling
+// fragments and answering pings on the way. A close frame ends the session
+// and is reported as an error carrying its status code.
+func wsReadMessage(w io.Writer, r *bufio.Reader) ([]byte, error) {
+	var message []byte
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		fin, opcode := header[0]&0x80 != 0, header[0]&0x0f
+		masked, length := header[1]&0x80 != 0, uint64(header[1]&0x7f)
+		switch length {
+		case 126:
+			ext := make([]byte, 2)
+			if _, err := io.ReadFull(r, ext); err != nil {
+				return nil, err
+			}
+			length = uint64(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			if _, err := io.ReadFull(r, ext); err != nil {
+				return nil, err
+			}
+			length = binary.BigEndian.Uint64(ext)
+		}
+		if length > wsMaxMessage || uint64(len(message))+length > wsMaxMessage {
+			return nil, fmt.Errorf("message exceeds %d bytes", wsMaxMessage)
+		}
+		var mask []byte
+		if masked {
+			mask = make([]byte, 4)
+			if _, err := io.ReadFull(r, mask); err != nil {
+				return nil, err
+			}
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, err
+		}
+		for i := range payload {
+			if mask != nil {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case wsClose:
+			if len(payload) >= 2 {
+				return nil, fmt.Errorf("connection closed with code %d", binary.BigEndian.Uint16(payload))
+			}
+			return nil, errors.New("connection closed")
+		case wsPing:
+			if err := wsWriteFrame(w, wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		}
+		if opcode != wsContinuation && message != nil {
+			return nil, errors.New("malformed fragmented message")
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+		if message == nil {
+			message = []byte{}
+		}
+	}
+}
+
+func (p *WebSocketProbe) SetTimeout(timeout time.Duration) {
+	p.Timeout = timeout
+}
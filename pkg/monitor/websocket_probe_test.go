@@ -0,0 +1,277 @@
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" //nolint:gosec // G505: mandated by the WebSocket handshake
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"probixel/pkg/config"
+	"probixel/pkg/tunnels"
+)
+
+// websocketHandler upgrades requests to WebSocket and hands the connection to
+// serve, then answers the client's close frame.
+func websocketHandler(t *testing.T, serve func(conn net.Conn, r *bufio.Reader)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsGUID)) //nolint:gosec // G401: mandated by the WebSocket handshake
+		w.Header().Set("Upgrade", "websocket")
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Sec-WebSocket-Accept", base64.StdEncoding.EncodeToString(sum[:]))
+		w.Header().Set("X-Server", "mock")
+		w.WriteHeader(http.StatusSwitchingProtocols)
+
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("hijack failed: %v", err)
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_ = rw.Flush()
+		if serve != nil {
+			serve(conn, rw.Reader)
+		}
+		// Read up to the client's close frame and echo it
+		for {
+			if _, err := wsReadMessage(conn, rw.Reader); err != nil {
+				if strings.Contains(err.Error(), "connection closed") {
+					_ = wsWriteFrame(conn, wsClose, []byte{0x03, 0xe8})
+				}
+				return
+			}
+		}
+	}
+}
+
+// echo sends every message back to the client.
+func echo(conn net.Conn, r *bufio.Reader) {
+	msg, err := wsReadMessage(conn, r)
+	if err != nil {
+		return
+	}
+	_ = wsWriteFrame(conn, wsText, msg)
+}
+
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestWebSocketProbe_Name(t *testing.T) {
+	p := &WebSocketProbe{}
+	if p.Name() != MonitorTypeWebSocket {
+		t.Errorf("Expected name %s, got %s", MonitorTypeWebSocket, p.Name())
+	}
+}
+
+func TestWebSocketProbe_Check(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     func(t *testing.T) http.HandlerFunc
+		probe       WebSocketProbe
+		wantSuccess bool
+		wantMsg     string
+	}{
+		{
+			name:        "handshake",
+			handler:     func(t *testing.T) http.HandlerFunc { return websocketHandler(t, nil) },
+			wantSuccess: true,
+			wantMsg:     "HTTP 101",
+		},
+		{
+			name:        "echo",
+			handler:     func(t *testing.T) http.HandlerFunc { return websocketHandler(t, echo) },
+			probe:       WebSocketProbe{Send: "ping"},
+			wantSuccess: true,
+			wantMsg:     "HTTP 101, reply received",
+		},
+		{
+			name: "reply matched",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return websocketHandler(t, func(conn net.Conn, r *bufio.Reader) {
+					if _, err := wsReadMessage(conn, r); err == nil {
+						_ = wsWriteFrame(conn, wsText, []byte(`{"status":"ok"}`))
+					}
+				})
+			},
+			probe: WebSocketProbe{Send: `{"op":"status"}`, MatchData: &config.MatchDataConfig{Expectations: []config.Expectation{
+				{Type: "json", JSONPath: "status", Operator: "==", Value: "ok"},
+				{Type: "header", Header: "X-Server", Operator: "==", Value: "mock"},
+			}}},
+			wantSuccess: true,
+			wantMsg:     "HTTP 101, Expectations met",
+		},
+		{
+			name:    "reply not matched",
+			handler: func(t *testing.T) http.HandlerFunc { return websocketHandler(t, echo) },
+			probe: WebSocketProbe{Send: "ping", MatchData: &config.MatchDataConfig{Expectations: []config.Expectation{
+				{Type: "body", Operator: "==", Value: "pong"},
+			}}},
+			wantMsg: "HTTP 101, expectation failed:  == pong (actual: ping)",
+		},
+		{
+			name: "greeting matched without send",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return websocketHandler(t, func(conn net.Conn, r *bufio.Reader) {
+					_ = wsWriteFrame(conn, wsText, []byte("welcome"))
+				})
+			},
+			probe: WebSocketProbe{MatchData: &config.MatchDataConfig{Expectations: []config.Expectation{
+				{Type: "body", Operator: "contains", Value: "welcome"},
+			}}},
+			wantSuccess: true,
+			wantMsg:     "HTTP 101, Expectations met",
+		},
+		{
+			name: "ping answered before the reply",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return websocketHandler(t, func(conn net.Conn, r *bufio.Reader) {
+					if _, err := wsReadMessage(conn, r); err != nil {
+						return
+					}
+					_ = wsWriteFrame(conn, wsPing, []byte("hb"))
+					// The pong isn't a message, the next one is the close frame
+					header := make([]byte, 2)
+					if _, err := r.Read(header); err != nil || header[0]&0x0f != wsPong {
+						t.Errorf("Expected a pong, got %x", header)
+					}
+					_, _ = r.Discard(4 + 2) // Mask and payload
+					_ = wsWriteFrame(conn, wsText, []byte("pong"))
+				})
+			},
+			probe:       WebSocketProbe{Send: "ping"},
+			wantSuccess: true,
+			wantMsg:     "reply received",
+		},
+		{
+			name: "no reply",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return websocketHandler(t, func(conn net.Conn, r *bufio.Reader) {
+					time.Sleep(time.Second)
+				})
+			},
+			probe: WebSocketProbe{Send: "ping"},
+			// Either the read deadline or the check's context ends the wait
+			wantMsg: "HTTP 101, ",
+		},
+		{
+			name: "closed by server",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return websocketHandler(t, func(conn net.Conn, r *bufio.Reader) {
+					_ = wsWriteFrame(conn, wsClose, []byte{0x03, 0xf0}) // 1008
+				})
+			},
+			probe:   WebSocketProbe{Send: "ping"},
+			wantMsg: "no reply: connection closed with code 1008",
+		},
+		{
+			name: "upgrade refused",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "forbidden", http.StatusForbidden)
+				}
+			},
+			wantMsg: "handshake failed: HTTP 403 Forbidden",
+		},
+		{
+			name: "invalid accept",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Upgrade", "websocket")
+					w.Header().Set("Connection", "Upgrade")
+					w.Header().Set("Sec-WebSocket-Accept", "bogus")
+					w.WriteHeader(http.StatusSwitchingProtocols)
+				}
+			},
+			wantMsg: "invalid Sec-WebSocket-Accept",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler(t))
+			defer server.Close()
+
+			p := tt.probe
+			p.SetTimeout(500 * time.Millisecond)
+			res, err := p.Check(context.Background(), wsURL(server)+"/live")
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if res.Success != tt.wantSuccess {
+				t.Errorf("Expected success %v, got %v: %s", tt.wantSuccess, res.Success, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.wantMsg) {
+				t.Errorf("Expected message containing %q, got %q", tt.wantMsg, res.Message)
+			}
+		})
+	}
+}
+
+func TestWebSocketProbe_Headers(t *testing.T) {
+	var got http.Header
+	handler := websocketHandler(t, nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		handler(w, r)
+	}))
+	defer server.Close()
+
+	p := &WebSocketProbe{Headers: map[string]string{"Authorization": "Bearer token"}}
+	res, _ := p.Check(context.Background(), wsURL(server))
+	if !res.Success {
+		t.Fatalf("Expected success, got: %s", res.Message)
+	}
+	if got.Get("Authorization") != "Bearer token" || got.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("Unexpected handshake headers: %v", got)
+	}
+}
+
+func TestWebSocketProbe_WSS(t *testing.T) {
+	server := httptest.NewTLSServer(websocketHandler(t, echo))
+	defer server.Close()
+	target := "wss" + strings.TrimPrefix(server.URL, "https")
+
+	p := &WebSocketProbe{Send: "ping", Timeout: time.Second}
+	res, _ := p.Check(context.Background(), target)
+	if res.Success || !strings.Contains(res.Message, "tls handshake failed") {
+		t.Errorf("Expected certificate verification failure, got: %s", res.Message)
+	}
+
+	p.InsecureSkipVerify = true
+	res, _ = p.Check(context.Background(), target)
+	if !res.Success || res.Message != "HTTP 101, reply received" {
+		t.Errorf("Expected success with insecure_skip_verify, got: %s", res.Message)
+	}
+}
+
+func TestWebSocketProbe_InvalidURL(t *testing.T) {
+	p := &WebSocketProbe{}
+	res, _ := p.Check(context.Background(), "http://example.com/live")
+	if res.Success || !strings.Contains(res.Message, "invalid websocket url") {
+		t.Errorf("Expected invalid url failure, got %q", res.Message)
+	}
+}
+
+func TestWebSocketProbe_Stabilization(t *testing.T) {
+	mt := &tunnels.MockTunnel{IsStabilizedResult: false}
+	p := &WebSocketProbe{}
+	p.SetTunnel(mt)
+
+	res, err := p.Check(context.Background(), "ws://127.0.0.1/live")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !res.Pending {
+		t.Error("Expected Pending: true")
+	}
+}
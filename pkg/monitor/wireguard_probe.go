@@ -3,6 +3,7 @@ package monitor
 import (
 	"context"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -23,6 +24,7 @@ type WireguardProbe struct {
 	// Internal fields for manual config (no root tunnel)
 	dev      tunnels.WGDevice
 	initTime time.Time
+	failures int // Consecutive failed checks of the standalone device
 }
 
 func (p *WireguardProbe) SetTunnel(t tunnels.Tunnel) {
@@ -35,6 +37,29 @@ func (p *WireguardProbe) Name() string {
 	return MonitorTypeWireguard
 }
 
+func (p *WireguardProbe) Describe() ProbeInfo {
+	return ProbeInfo{
+		Type:        MonitorTypeWireguard,
+		Description: "Checks the latest WireGuard handshake age, or connectivity through the tunnel",
+		Fields: []string{
+			"tunnel",
+			"targets",
+			"timeout",
+			"wireguard.endpoint",
+			"wireguard.public_key",
+			"wireguard.private_key",
+			"wireguard.private_key_file",
+			"wireguard.preshared_key",
+			"wireguard.preshared_key_file",
+			"wireguard.addresses",
+			"wireguard.allowed_ips",
+			"wireguard.persistent_keepalive",
+			"wireguard.max_age",
+			"wireguard.restart_threshold",
+		},
+	}
+}
+
 func (p *WireguardProbe) SetTargetMode(mode string) {
 	p.targetMode = mode
 }
@@ -71,6 +96,30 @@ func (p *WireguardProbe) stop() {
 	}
 }
 
+// reportFailure handles a failed handshake check. A root tunnel decides on its
+// own whether to restart. The standalone device is only torn down once
+// restart_threshold consecutive checks failed, so a single transient miss
+// doesn't flap it.
+func (p *WireguardProbe) reportFailure() {
+	if p.tunnel != nil {
+		p.tunnel.ReportFailure()
+		return
+	}
+
+	threshold := 1
+	if p.Config != nil && p.Config.RestartThreshold != nil && *p.Config.RestartThreshold > 1 {
+		threshold = *p.Config.RestartThreshold
+	}
+	p.failures++
+	if p.failures < threshold {
+		log.Printf("[WireGuard] Handshake check failed (%d/%d), not restarting yet", p.failures, threshold)
+		return
+	}
+	log.Printf("[WireGuard] Handshake check failed (%d/%d), restarting device", p.failures, threshold)
+	p.failures = 0
+	p.stop()
+}
+
 func (p *WireguardProbe) Check(ctx context.Context, target string) (Result, error) {
 	start := time.Now()
 	_ = target // WireGuard monitor is now heartbeat-only (ignores target)
@@ -155,11 +204,7 @@ func (p *WireguardProbe) Check(ctx context.Context, target string) (Result, erro
 
 	lastHandshake, err := parseLatestHandshake(uapi)
 	if err != nil {
-		if p.tunnel != nil {
-			p.tunnel.ReportFailure()
-		} else {
-			p.stop()
-		}
+		p.reportFailure()
 		return Result{
 			Success:   false,
 			Duration:  time.Since(start),
@@ -190,11 +235,7 @@ func (p *WireguardProbe) Check(ctx context.Context, target string) (Result, erro
 	}
 
 	if lastHandshake.IsZero() {
-		if p.tunnel != nil {
-			p.tunnel.ReportFailure()
-		} else {
-			p.stop()
-		}
+		p.reportFailure()
 		return Result{
 			Success:   false,
 			Pending:   true,
@@ -206,11 +247,7 @@ func (p *WireguardProbe) Check(ctx context.Context, target string) (Result, erro
 
 	age := time.Since(lastHandshake)
 	if age > maxAge {
-		if p.tunnel != nil {
-			p.tunnel.ReportFailure()
-		} else {
-			p.stop()
-		}
+		p.reportFailure()
 		return Result{
 			Success:   false,
 			Duration:  time.Since(start),
@@ -222,6 +259,7 @@ func (p *WireguardProbe) Check(ctx context.Context, target string) (Result, erro
 	if p.tunnel != nil {
 		p.tunnel.ReportSuccess()
 	}
+	p.failures = 0
 
 	return Result{
 		Success:   true,
@@ -269,6 +269,49 @@ func TestWireguardProbe_Check_StaleHandshake(t *testing.T) {
 	}
 }
 
+func TestWireguardProbe_Check_RestartThreshold(t *testing.T) {
+	staleTime := time.Now().Add(-10 * time.Minute).Unix()
+	threshold := 3
+	mock := &mockWGDevice{
+		uapi: fmt.Sprintf("last_handshake_time_sec=%d\n", staleTime),
+	}
+	p := &WireguardProbe{
+		Config: &config.WireguardConfig{
+			MaxAge:           "5m",
+			RestartThreshold: &threshold,
+		},
+		dev:      mock,
+		initTime: time.Now().Add(-1 * time.Hour),
+	}
+
+	for i := 1; i < threshold; i++ {
+		if res, _ := p.Check(context.Background(), ""); res.Success {
+			t.Fatal("expected failure for stale handshake")
+		}
+		if mock.closed || p.dev == nil {
+			t.Fatalf("device restarted after %d failures, threshold is %d", i, threshold)
+		}
+	}
+
+	// A success resets the count
+	mock.uapi = fmt.Sprintf("last_handshake_time_sec=%d\n", time.Now().Unix())
+	if res, _ := p.Check(context.Background(), ""); !res.Success {
+		t.Fatalf("expected success, got %s", res.Message)
+	}
+	mock.uapi = fmt.Sprintf("last_handshake_time_sec=%d\n", staleTime)
+	for i := 1; i < threshold; i++ {
+		_, _ = p.Check(context.Background(), "")
+	}
+	if mock.closed {
+		t.Fatal("device restarted although a success reset the failure count")
+	}
+
+	_, _ = p.Check(context.Background(), "")
+	if !mock.closed || p.dev != nil {
+		t.Errorf("expected the device to be torn down after %d consecutive failures", threshold)
+	}
+}
+
 func TestWireguardProbe_Check_GracePeriod(t *testing.T) {
 	mock := &mockWGDevice{
 		uapi: "last_handshake_time_sec=0\n",
@@ -1,9 +1,13 @@
 package notifier
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"net/http"
@@ -21,6 +25,43 @@ type Pusher struct {
 	mu        sync.Mutex
 	lastPush  time.Time
 	rateLimit time.Duration
+	escalated map[string]bool      // Services whose escalation was delivered during their current outage
+	deferred  map[string]time.Time // Endpoint -> end of the window it asked us to back off for
+	failing   map[string]*backoff  // Endpoint -> its consecutive failed deliveries
+
+	backoffBase time.Duration // Backoff after the first failed delivery, doubled on each further one
+	maxBackoff  time.Duration // Caps the backoff, 0 disables it
+}
+
+// backoff tracks an endpoint whose deliveries keep failing.
+type backoff struct {
+	failures int
+	until    time.Time // No delivery is attempted before
+}
+
+// defaultRetryAfter is how long an endpoint answering 429 is left alone when
+// it doesn't send a usable Retry-After, and maxRetryAfter caps what it can ask.
+const (
+	defaultRetryAfter = time.Minute
+	maxRetryAfter     = time.Hour
+)
+
+// An endpoint whose deliveries keep failing, retries included, is left alone
+// for defaultBackoffBase after the first failure, twice as long after each
+// further one, up to the notifier.max_backoff cap.
+const (
+	defaultBackoffBase = 10 * time.Second
+	defaultMaxBackoff  = 15 * time.Minute
+)
+
+// rateLimitedError is returned by doPush when the endpoint answered 429 Too
+// Many Requests.
+type rateLimitedError struct {
+	retryAfter time.Duration
+}
+
+func (e *rateLimitedError) Error() string {
+	return fmt.Sprintf("alert endpoint rate limited (429), retry after %v", e.retryAfter)
 }
 
 func NewPusher() *Pusher {
@@ -28,7 +69,9 @@ func NewPusher() *Pusher {
 		Client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		rateLimit: 100 * time.Millisecond,
+		rateLimit:   100 * time.Millisecond,
+		backoffBase: defaultBackoffBase,
+		maxBackoff:  defaultMaxBackoff,
 	}
 }
 
@@ -45,37 +88,152 @@ func (p *Pusher) SetRateLimit(interval *string) {
 	p.rateLimit = d
 }
 
+// SetMaxBackoff sets the cap on the backoff of failing endpoints, "" for the
+// default and "0" to disable the backoff.
+func (p *Pusher) SetMaxBackoff(value string) {
+	d := defaultMaxBackoff
+	if value != "" {
+		parsed, err := config.ParseDuration(value)
+		if err != nil {
+			return
+		}
+		d = parsed
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxBackoff = d
+}
+
 // replaceTemplateVars replaces template variables in the URL with actual values
 func replaceTemplateVars(urlStr string, result monitor.Result) string {
+	return renderTemplate(urlStr, result, url.QueryEscape)
+}
+
+// renderTemplate replaces template variables in tmpl, passing the free-form
+// values (message, error, target) through escape.
+func renderTemplate(tmpl string, result monitor.Result, escape func(string) string) string {
 	// Replace duration (in milliseconds, rounded to nearest)
 	durationMs := int64(math.Round(float64(result.Duration) / float64(time.Millisecond)))
-	urlStr = strings.ReplaceAll(urlStr, "{%duration%}", strconv.FormatInt(durationMs, 10))
+	tmpl = strings.ReplaceAll(tmpl, "{%duration%}", strconv.FormatInt(durationMs, 10))
 
 	// Replace error/message
 	errorMsg := ""
 	if !result.Success {
-		errorMsg = url.QueryEscape(result.Message)
+		errorMsg = escape(result.Message)
 	}
-	urlStr = strings.ReplaceAll(urlStr, "{%error%}", errorMsg)
+	tmpl = strings.ReplaceAll(tmpl, "{%error%}", errorMsg)
 
 	// Replace message (always available)
-	urlStr = strings.ReplaceAll(urlStr, "{%message%}", url.QueryEscape(result.Message))
+	tmpl = strings.ReplaceAll(tmpl, "{%message%}", escape(result.Message))
 
 	// Replace target
-	urlStr = strings.ReplaceAll(urlStr, "{%target%}", url.QueryEscape(result.Target))
+	tmpl = strings.ReplaceAll(tmpl, "{%target%}", escape(result.Target))
 
 	// Replace timestamp (Unix timestamp)
 	timestamp := strconv.FormatInt(result.Timestamp.Unix(), 10)
-	urlStr = strings.ReplaceAll(urlStr, "{%timestamp%}", timestamp)
+	tmpl = strings.ReplaceAll(tmpl, "{%timestamp%}", timestamp)
+
+	// Replace since (seconds in the current status, rounded to nearest)
+	since := int64(math.Round(result.Since.Seconds()))
+	tmpl = strings.ReplaceAll(tmpl, "{%since%}", strconv.FormatInt(since, 10))
 
 	// Replace success ("true" or "false")
 	successStr := "false"
 	if result.Success {
 		successStr = "true"
 	}
-	urlStr = strings.ReplaceAll(urlStr, "{%success%}", successStr)
+	tmpl = strings.ReplaceAll(tmpl, "{%success%}", successStr)
+
+	return tmpl
+}
 
-	return urlStr
+// slackMessage is the body of a Slack incoming webhook call: a headline, and
+// the rendered template in an attachment colored by the result.
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color string `json:"color"`
+	Text  string `json:"text"`
+}
+
+// slackBody renders the Slack message for result, green with a check mark on
+// success and red with a cross on failure.
+func slackBody(serviceName string, result monitor.Result, endpoint *config.EndpointConfig) ([]byte, error) {
+	tmpl := endpoint.Template
+	if tmpl == "" {
+		tmpl = "{%message%}"
+	}
+	emoji, color, state := ":x:", "danger", "down"
+	if result.Success {
+		emoji, color, state = ":white_check_mark:", "good", "up"
+	}
+	return json.Marshal(slackMessage{
+		Text:        fmt.Sprintf("%s *%s* is %s", emoji, serviceName, state),
+		Attachments: []slackAttachment{{Color: color, Text: renderTemplate(tmpl, result, func(v string) string { return v })}},
+	})
+}
+
+// discordContentLimit is the longest message content Discord accepts.
+const discordContentLimit = 2000
+
+// discordMessage is the body of a Discord webhook call.
+type discordMessage struct {
+	Content string `json:"content"`
+}
+
+// discordBody renders the Discord message for result: a headline naming the
+// service and the rendered template, cut to what Discord accepts.
+func discordBody(serviceName string, result monitor.Result, endpoint *config.EndpointConfig) ([]byte, error) {
+	tmpl := endpoint.Template
+	if tmpl == "" {
+		tmpl = "{%message%}"
+	}
+	emoji, state := ":x:", "down"
+	if result.Success {
+		emoji, state = ":white_check_mark:", "up"
+	}
+	content := fmt.Sprintf("%s **%s** is %s\n%s", emoji, serviceName, state, renderTemplate(tmpl, result, func(v string) string { return v }))
+	if runes := []rune(content); len(runes) > discordContentLimit {
+		content = string(runes[:discordContentLimit-3]) + "..."
+	}
+	return json.Marshal(discordMessage{Content: content})
+}
+
+// BodyData is what a body_template is rendered with.
+type BodyData struct {
+	ServiceName string
+	Success     bool
+	Message     string
+	Target      string
+	Duration    time.Duration
+	Timestamp   time.Time
+	Since       time.Duration // How long the service has been in its current status
+}
+
+// templateBody renders the endpoint's body_template for result, as parsed by
+// config validation.
+func templateBody(serviceName string, result monitor.Result, endpoint *config.EndpointConfig) ([]byte, error) {
+	tmpl, err := endpoint.ParsedBodyTemplate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load body_template: %w", err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, BodyData{
+		ServiceName: serviceName,
+		Success:     result.Success,
+		Message:     result.Message,
+		Target:      result.Target,
+		Duration:    result.Duration,
+		Timestamp:   result.Timestamp,
+		Since:       result.Since,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render body_template: %w", err)
+	}
+	return buf.Bytes(), nil
 }
 
 func (p *Pusher) Push(ctx context.Context, serviceName string, result monitor.Result, endpointCfg config.MonitorEndpointConfig, globalEndpointCfg config.GlobalMonitorEndpointConfig) error {
@@ -84,6 +242,11 @@ func (p *Pusher) Push(ctx context.Context, serviceName string, result monitor.Re
 	}
 	// Enforce rate limit
 	p.mu.Lock()
+	escalate := p.escalate(serviceName, result, endpointCfg)
+	if escalate {
+		result.Message = fmt.Sprintf("escalated, down for %v: %s", result.Since.Round(time.Second), result.Message)
+		log.Printf("[%s] Down for %v, escalating", serviceName, result.Since.Round(time.Second))
+	}
 	if p.rateLimit > 0 {
 		elapsed := time.Since(p.lastPush)
 		if elapsed < p.rateLimit {
@@ -93,21 +256,44 @@ func (p *Pusher) Push(ctx context.Context, serviceName string, result monitor.Re
 	p.lastPush = time.Now()
 	p.mu.Unlock()
 
-	var endpoint *config.EndpointConfig
-
-	// Determine which endpoint definition to use
-	if result.Success {
-		// Success is required (value in struct)
-		endpoint = &endpointCfg.Success
-	} else {
-		// Failure is optional (pointer in struct)
-		endpoint = endpointCfg.Failure
+	// Determine which endpoint definitions to use, failure ones are optional
+	endpoints := endpointCfg.Success
+	if !result.Success {
+		endpoints = endpointCfg.Failure
 	}
 
-	if endpoint == nil || endpoint.URL == "" {
-		return nil // No endpoint configured or optional failure omitted
+	// One unreachable endpoint must not keep the others from being notified
+	var errs []error
+	for i := range endpoints {
+		endpoint := &endpoints[i]
+		if endpoint.URL == "" {
+			continue // Optional failure omitted
+		}
+		if err := p.pushEndpoint(ctx, serviceName, result, endpoint, endpointCfg, globalEndpointCfg); err != nil {
+			if len(endpoints) > 1 {
+				err = fmt.Errorf("endpoint %d: %w", i+1, err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		// An undelivered escalation is escalated again on the next push
+		return errors.Join(errs...)
 	}
+	if escalate {
+		p.mu.Lock()
+		if p.escalated == nil {
+			p.escalated = make(map[string]bool)
+		}
+		p.escalated[serviceName] = true
+		p.mu.Unlock()
+	}
+	return nil
+}
 
+// pushEndpoint sends result to a single endpoint, retrying per the service's
+// and global notification settings.
+func (p *Pusher) pushEndpoint(ctx context.Context, serviceName string, result monitor.Result, endpoint *config.EndpointConfig, endpointCfg config.MonitorEndpointConfig, globalEndpointCfg config.GlobalMonitorEndpointConfig) error {
 	targetURL := endpoint.URL
 
 	// Replace template variables in URL
@@ -118,10 +304,43 @@ func (p *Pusher) Push(ctx context.Context, serviceName string, result monitor.Re
 		method = "GET"
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, finalURL, nil) // Empty body as per bash script (uses query params)
+	var body io.Reader // Empty body as per bash script (uses query params)
+	contentType := "application/json"
+	var render func(string, monitor.Result, *config.EndpointConfig) ([]byte, error)
+	switch endpoint.Format {
+	case "slack":
+		render = slackBody
+	case "discord":
+		render = discordBody
+	}
+	if render != nil {
+		data, err := render(serviceName, result, endpoint)
+		if err != nil {
+			return err
+		}
+		method = http.MethodPost
+		body = bytes.NewReader(data)
+	} else if endpoint.BodyTemplate != "" {
+		data, err := templateBody(serviceName, result, endpoint)
+		if err != nil {
+			return err
+		}
+		if endpoint.Method == "" {
+			method = http.MethodPost
+		}
+		if !json.Valid(data) {
+			contentType = "text/plain; charset=utf-8"
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, finalURL, body)
 	if err != nil {
 		return err
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
 
 	// Set Global Common Headers
 	for k, v := range globalEndpointCfg.Headers {
@@ -163,16 +382,46 @@ func (p *Pusher) Push(ctx context.Context, serviceName string, result monitor.Re
 		retries = *endpointCfg.Retries
 	}
 
+	// Respect a backoff window requested by the endpoint. Later pushes carry
+	// the then-current state, so this one is dropped rather than queued.
+	key := endpointKey(req.URL)
+	p.mu.Lock()
+	until := p.deferred[key]
+	p.mu.Unlock()
+	if wait := time.Until(until); wait > 0 {
+		log.Printf("[%s] Alert endpoint asked to back off, skipping push for another %v", serviceName, wait.Round(time.Second))
+		return fmt.Errorf("alert endpoint rate limited for another %v", wait.Round(time.Second))
+	}
+	p.mu.Lock()
+	failing := p.failing[key]
+	p.mu.Unlock()
+	if failing != nil {
+		if wait := time.Until(failing.until); wait > 0 {
+			log.Printf("[%s] Alert endpoint failed %d times in a row, skipping push for another %v", serviceName, failing.failures, wait.Round(time.Second))
+			return fmt.Errorf("alert endpoint backing off for another %v after %d failures", wait.Round(time.Second), failing.failures)
+		}
+	}
+
 	log.Printf("[%s] Sending notifications to -> %s", serviceName, finalURL)
 
 	var lastErr error
+	waitDeadline := time.Now().Add(timeout) // Bounds the time spent honoring Retry-After
+	waits := 0
 	for attempt := 0; attempt <= retries; attempt++ {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
 
-		if attempt > 0 {
-			log.Printf("[%s] Retrying alert push (attempt %d/%d)...", serviceName, attempt, retries)
+		if attempt > 0 || waits > 0 {
+			if attempt > 0 {
+				log.Printf("[%s] Retrying alert push (attempt %d/%d)...", serviceName, attempt, retries)
+			}
+			if req.GetBody != nil {
+				// The previous attempt consumed the body
+				if req.Body, err = req.GetBody(); err != nil {
+					return err
+				}
+			}
 		}
 
 		startPush := time.Now()
@@ -181,11 +430,37 @@ func (p *Pusher) Push(ctx context.Context, serviceName string, result monitor.Re
 
 		if lastErr == nil {
 			log.Printf("[%s] Alert push successful (%v)", serviceName, pushDur)
+			p.mu.Lock()
+			delete(p.failing, key)
+			p.mu.Unlock()
 			return nil
 		}
 
 		log.Printf("[%s] Alert push failed: %v", serviceName, lastErr)
 
+		var limited *rateLimitedError
+		if errors.As(lastErr, &limited) && endpoint.Format == "discord" && !time.Now().Add(limited.retryAfter).After(waitDeadline) {
+			// Discord asks for short waits routinely, wait it out without
+			// using up a retry
+			log.Printf("[%s] Waiting %v as asked by the alert endpoint", serviceName, limited.retryAfter)
+			if !sleepContext(ctx, limited.retryAfter) {
+				return ctx.Err()
+			}
+			waits++
+			attempt--
+			continue
+		}
+		if errors.As(lastErr, &limited) {
+			// Retrying now would only get us rate limited harder
+			p.mu.Lock()
+			if p.deferred == nil {
+				p.deferred = make(map[string]time.Time)
+			}
+			p.deferred[key] = time.Now().Add(limited.retryAfter)
+			p.mu.Unlock()
+			return lastErr
+		}
+
 		if attempt < retries {
 			// Check context before sleeping or continuing
 			if ctx.Err() != nil {
@@ -195,9 +470,48 @@ func (p *Pusher) Push(ctx context.Context, serviceName string, result monitor.Re
 		}
 	}
 
+	p.backOff(key)
 	return lastErr
 }
 
+// backOff records a failed delivery to the endpoint identified by key and
+// holds off the next ones, exponentially longer the more failures in a row.
+func (p *Pusher) backOff(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.backoffBase <= 0 || p.maxBackoff <= 0 {
+		return
+	}
+	if p.failing == nil {
+		p.failing = make(map[string]*backoff)
+	}
+	b := p.failing[key]
+	if b == nil {
+		b = &backoff{}
+		p.failing[key] = b
+	}
+	b.failures++
+	wait := p.maxBackoff
+	if shift := b.failures - 1; shift < 32 && p.backoffBase<<shift < p.maxBackoff {
+		wait = p.backoffBase << shift
+	}
+	b.until = time.Now().Add(wait)
+}
+
+// escalate reports whether this failure should be escalated per
+// escalate_after, going by how long the service has been down as set in
+// result.Since. Without escalate_repeat only the first delivered escalation of
+// an outage counts. Must be called with p.mu held.
+func (p *Pusher) escalate(serviceName string, result monitor.Result, endpointCfg config.MonitorEndpointConfig) bool {
+	after, err := config.ParseDuration(endpointCfg.EscalateAfter)
+	if result.Success || err != nil || after <= 0 || result.Since < after {
+		// Up, or in an outage too recent to escalate
+		delete(p.escalated, serviceName)
+		return false
+	}
+	return !p.escalated[serviceName] || endpointCfg.EscalateRepeat
+}
+
 func (p *Pusher) doPush(req *http.Request, endpoint *config.EndpointConfig, timeout time.Duration) error {
 	client := p.Client
 	if endpoint.InsecureSkipVerify {
@@ -215,11 +529,27 @@ func (p *Pusher) doPush(req *http.Request, endpoint *config.EndpointConfig, time
 		newClient.Timeout = timeout
 		client = &newClient
 	}
+	if endpoint.AcceptedStatusCodes != "" {
+		// An accepted redirect is the receiver's answer, not something to follow
+		newClient := *client
+		newClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			accepted, err := monitor.MatchStatusCode(endpoint.AcceptedStatusCodes, req.Response.StatusCode)
+			if err != nil {
+				return fmt.Errorf("invalid accepted_status_codes: %w", err)
+			}
+			if accepted {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		}
+		client = &newClient
+	}
 
-	// We need to be careful with req.Body if it were present, but NewRequest used nil.
-	// However, client.Do can modify the request (headers, etc).
-	// Actually, req.Header is modified by p.Push before the loop.
-	// If we were sending a body, we'd need to reset it.
+	// A request body (slack format) is rewound by p.Push before each retry.
+	// req.Header is set by p.Push before the loop.
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -227,9 +557,50 @@ func (p *Pusher) doPush(req *http.Request, endpoint *config.EndpointConfig, time
 	}
 	defer func() { _ = resp.Body.Close() }()
 
-	if resp.StatusCode >= 300 {
+	accepted := resp.StatusCode >= 200 && resp.StatusCode < 300
+	if endpoint.AcceptedStatusCodes != "" {
+		if accepted, err = monitor.MatchStatusCode(endpoint.AcceptedStatusCodes, resp.StatusCode); err != nil {
+			return fmt.Errorf("invalid accepted_status_codes: %w", err)
+		}
+	}
+	if !accepted && resp.StatusCode == http.StatusTooManyRequests {
+		return &rateLimitedError{retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	}
+	if !accepted {
 		return fmt.Errorf("bad status code from alert endpoint: %d", resp.StatusCode)
 	}
 
 	return nil
 }
+
+// sleepContext waits for d, returning false if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// endpointKey identifies the endpoint u is sent to, ignoring the query string
+// that template variables make different for every push.
+func endpointKey(u *url.URL) string {
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+// parseRetryAfter returns the backoff requested by a Retry-After header,
+// given either in seconds, fractional ones included as Discord sends them, or
+// as an HTTP date, bounded by maxRetryAfter.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	d := defaultRetryAfter
+	if secs, err := strconv.ParseFloat(header, 64); err == nil && secs >= 0 {
+		d = time.Duration(min(secs, maxRetryAfter.Seconds()) * float64(time.Second))
+	} else if date, err := http.ParseTime(header); err == nil {
+		d = max(date.Sub(now), 0)
+	}
+	return min(d, maxRetryAfter)
+}
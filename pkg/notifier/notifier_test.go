@@ -3,14 +3,20 @@ package notifier
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"probixel/pkg/config"
 	"probixel/pkg/monitor"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -51,14 +57,14 @@ func TestPusher_Push(t *testing.T) {
 	pusher := NewPusher()
 
 	alertCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{
+		Success: config.EndpointList{{
 			URL:    testServer.URL + "?duration={%duration%}",
 			Method: "POST",
-		},
-		Failure: &config.EndpointConfig{
+		}},
+		Failure: config.EndpointList{{
 			URL:    testServer.URL + "?duration={%duration%}",
 			Method: "POST",
-		},
+		}},
 	}
 
 	res := monitor.Result{
@@ -88,7 +94,7 @@ func TestPusher_Push_Failure(t *testing.T) {
 	pusher := NewPusher()
 
 	alertCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{URL: testServer.URL + "?duration={%duration%}"},
+		Success: config.EndpointList{{URL: testServer.URL + "?duration={%duration%}"}},
 	}
 	res := monitor.Result{Success: true}
 
@@ -98,6 +104,103 @@ func TestPusher_Push_Failure(t *testing.T) {
 	}
 }
 
+func TestPusher_Push_FanOut(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]string) // Server -> messages pushed to it
+	newServer := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			received[name] = append(received[name], r.URL.Query().Get("msg"))
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	pager := newServer("pager")
+	logger := newServer("logger")
+
+	pusher := NewPusher()
+	pusher.SetRateLimit(ptr("0"))
+	alertCfg := config.MonitorEndpointConfig{
+		Success: config.EndpointList{
+			{URL: pager.URL + "?msg={%message%}"},
+			{URL: logger.URL + "?msg={%message%}"},
+		},
+		Failure: config.EndpointList{
+			{URL: "http://127.0.0.1:1"}, // Unreachable
+			{URL: logger.URL + "?msg={%message%}"},
+		},
+		Retries: ptrInt(0),
+	}
+
+	if err := pusher.Push(context.Background(), "API", monitor.Result{Success: true, Message: "up"}, alertCfg, config.GlobalMonitorEndpointConfig{}); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	err := pusher.Push(context.Background(), "API", monitor.Result{Success: false, Message: "down"}, alertCfg, config.GlobalMonitorEndpointConfig{})
+	if err == nil || !strings.Contains(err.Error(), "endpoint 1: ") {
+		t.Errorf("Expected the unreachable endpoint's error, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"up"}; !slices.Equal(received["pager"], want) {
+		t.Errorf("Expected pager to receive %v, got %v", want, received["pager"])
+	}
+	if want := []string{"up", "down"}; !slices.Equal(received["logger"], want) {
+		t.Errorf("Expected logger to receive %v despite the unreachable endpoint, got %v", want, received["logger"])
+	}
+}
+
+func TestPusher_Push_AcceptedStatusCodes(t *testing.T) {
+	var redirected bool
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		case "/found":
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		case "/elsewhere":
+			redirected = true
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		accepted string
+		wantErr  bool
+	}{
+		{"default accepts 2xx", "/created", "", false},
+		{"default follows redirects", "/found", "", true},
+		{"custom list", "/created", "200, 204", true},
+		{"custom range", "/created", "200-201", false},
+		{"accepted redirect is not followed", "/found", "200-299, 302", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redirected = false
+			pusher := NewPusher()
+			pusher.SetRateLimit(ptr("0"))
+			alertCfg := config.MonitorEndpointConfig{
+				Success: config.EndpointList{{URL: testServer.URL + tt.path, AcceptedStatusCodes: tt.accepted}},
+			}
+			err := pusher.Push(context.Background(), "test-service", monitor.Result{Success: true}, alertCfg, config.GlobalMonitorEndpointConfig{Retries: ptrInt(0)})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Push() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.accepted != "" && tt.path == "/found" && redirected {
+				t.Error("expected the accepted redirect not to be followed")
+			}
+		})
+	}
+}
+
 func TestPusher_TemplateVariables(t *testing.T) {
 	// Test that template variables are correctly replaced
 	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -120,6 +223,10 @@ func TestPusher_TemplateVariables(t *testing.T) {
 			t.Error("Expected target query param")
 		}
 
+		if since := r.URL.Query().Get("since"); since != "90" {
+			t.Errorf("Expected since 90s, got %s", since)
+		}
+
 		w.WriteHeader(http.StatusOK)
 	}))
 	defer testServer.Close()
@@ -128,9 +235,9 @@ func TestPusher_TemplateVariables(t *testing.T) {
 
 	// URL with template variables
 	alertCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{
-			URL: testServer.URL + "?d={%duration%}&msg={%message%}&target={%target%}&success={%success%}",
-		},
+		Success: config.EndpointList{{
+			URL: testServer.URL + "?d={%duration%}&msg={%message%}&target={%target%}&success={%success%}&since={%since%}",
+		}},
 	}
 
 	res := monitor.Result{
@@ -139,6 +246,7 @@ func TestPusher_TemplateVariables(t *testing.T) {
 		Message:   "Test OK",
 		Target:    "test.example.com",
 		Timestamp: time.Now(),
+		Since:     90 * time.Second,
 	}
 
 	err := pusher.Push(context.Background(), "test-service", res, alertCfg, config.GlobalMonitorEndpointConfig{})
@@ -170,9 +278,9 @@ func TestPusher_TemplateVariables_Error(t *testing.T) {
 	pusher := NewPusher()
 
 	alertCfg := config.MonitorEndpointConfig{
-		Failure: &config.EndpointConfig{
+		Failure: config.EndpointList{{
 			URL: testServer.URL + "?error={%error%}&success={%success%}",
-		},
+		}},
 	}
 
 	res := monitor.Result{
@@ -187,6 +295,310 @@ func TestPusher_TemplateVariables_Error(t *testing.T) {
 	}
 }
 
+func TestPusher_Push_SlackFormat(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []slackMessage
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Expected POST method, got %s", r.Method)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected JSON content type, got %q", ct)
+		}
+		var msg slackMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("Invalid JSON body: %v", err)
+		}
+		mu.Lock()
+		bodies = append(bodies, msg)
+		fail := len(bodies) == 1
+		mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError) // The retry must resend the body
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		name      string
+		result    monitor.Result
+		template  string
+		wantText  string
+		wantColor string
+		wantBody  string
+	}{
+		{
+			name:      "success",
+			result:    monitor.Result{Success: true, Message: "OK", Target: "api:443", Duration: 42 * time.Millisecond},
+			template:  "{%target%} answered in {%duration%}ms ({%success%}): {%message%}",
+			wantText:  ":white_check_mark: *API* is up",
+			wantColor: "good",
+			wantBody:  "api:443 answered in 42ms (true): OK",
+		},
+		{
+			name:      "failure",
+			result:    monitor.Result{Success: false, Message: "connection refused & timed out", Target: "api:443"},
+			wantText:  ":x: *API* is down",
+			wantColor: "danger",
+			wantBody:  "connection refused & timed out",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			bodies = nil
+			mu.Unlock()
+
+			pusher := NewPusher()
+			pusher.SetRateLimit(ptr("0"))
+			endpoint := config.EndpointConfig{URL: testServer.URL, Format: "slack", Template: tt.template}
+			endpointCfg := config.MonitorEndpointConfig{Success: config.EndpointList{endpoint}, Failure: config.EndpointList{endpoint}}
+
+			if err := pusher.Push(context.Background(), "API", tt.result, endpointCfg, config.GlobalMonitorEndpointConfig{}); err != nil {
+				t.Fatalf("Push failed: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(bodies) != 2 {
+				t.Fatalf("Expected 2 attempts, got %d", len(bodies))
+			}
+			for _, msg := range bodies {
+				if msg.Text != tt.wantText {
+					t.Errorf("Expected text %q, got %q", tt.wantText, msg.Text)
+				}
+				if len(msg.Attachments) != 1 || msg.Attachments[0].Color != tt.wantColor || msg.Attachments[0].Text != tt.wantBody {
+					t.Errorf("Expected a %s attachment with %q, got %+v", tt.wantColor, tt.wantBody, msg.Attachments)
+				}
+			}
+		})
+	}
+}
+
+func TestPusher_Push_DiscordFormat(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		contents []string
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Expected POST method, got %s", r.Method)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected JSON content type, got %q", ct)
+		}
+		var msg discordMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("Invalid JSON body: %v", err)
+		}
+		mu.Lock()
+		contents = append(contents, msg.Content)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	pusher := NewPusher()
+	pusher.SetRateLimit(ptr("0"))
+	endpoint := config.EndpointConfig{URL: testServer.URL, Format: "discord", Template: "{%target%} after {%duration%}ms: {%message%}"}
+	endpointCfg := config.MonitorEndpointConfig{Success: config.EndpointList{endpoint}, Failure: config.EndpointList{endpoint}}
+
+	results := []monitor.Result{
+		{Success: true, Message: "OK", Target: "api:443", Duration: 42 * time.Millisecond},
+		{Success: false, Message: "connection refused & timed out", Target: "api:443"},
+		{Success: false, Message: strings.Repeat("x", 3000)},
+	}
+	for _, res := range results {
+		if err := pusher.Push(context.Background(), "API", res, endpointCfg, config.GlobalMonitorEndpointConfig{}); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(contents) != 3 {
+		t.Fatalf("Expected 3 pushes, got %d", len(contents))
+	}
+	if want := ":white_check_mark: **API** is up\napi:443 after 42ms: OK"; contents[0] != want {
+		t.Errorf("Expected content %q, got %q", want, contents[0])
+	}
+	if want := ":x: **API** is down\napi:443 after 0ms: connection refused & timed out"; contents[1] != want {
+		t.Errorf("Expected content %q, got %q", want, contents[1])
+	}
+	if n := len([]rune(contents[2])); n != discordContentLimit || !strings.HasSuffix(contents[2], "...") {
+		t.Errorf("Expected the content to be cut to %d characters, got %d", discordContentLimit, n)
+	}
+}
+
+func TestPusher_Push_BodyTemplate(t *testing.T) {
+	dir := t.TempDir()
+	jsonTemplate := filepath.Join(dir, "alert.json.tmpl")
+	if err := os.WriteFile(jsonTemplate, []byte(`{"service":{{printf "%q" .ServiceName}},"up":{{.Success}},"message":{{printf "%q" .Message}},"target":{{printf "%q" .Target}},"ms":{{.Duration.Milliseconds}},"at":{{.Timestamp.Unix}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	textTemplate := filepath.Join(dir, "alert.txt.tmpl")
+	if err := os.WriteFile(textTemplate, []byte(`{{.ServiceName}} is {{if .Success}}up{{else}}down: {{.Message}}{{end}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	type request struct {
+		method, contentType, body string
+	}
+	requests := make(chan request, 1)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Method, r.Header.Get("Content-Type"), string(body)}
+	}))
+	defer testServer.Close()
+
+	at := time.Unix(1700000000, 0)
+	tests := []struct {
+		name     string
+		endpoint config.EndpointConfig
+		result   monitor.Result
+		want     request
+	}{
+		{
+			name:     "json",
+			endpoint: config.EndpointConfig{URL: testServer.URL, BodyTemplate: jsonTemplate},
+			result:   monitor.Result{Success: false, Message: `HTTP 503 "unavailable"`, Target: "api:443", Duration: 42 * time.Millisecond, Timestamp: at},
+			want:     request{"POST", "application/json", `{"service":"API","up":false,"message":"HTTP 503 \"unavailable\"","target":"api:443","ms":42,"at":1700000000}`},
+		},
+		{
+			name:     "plaintext with method",
+			endpoint: config.EndpointConfig{URL: testServer.URL, Method: "PUT", BodyTemplate: textTemplate},
+			result:   monitor.Result{Success: false, Message: "connection refused"},
+			want:     request{"PUT", "text/plain; charset=utf-8", "API is down: connection refused"},
+		},
+	}
+
+	pusher := NewPusher()
+	pusher.SetRateLimit(ptr("0"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpointCfg := config.MonitorEndpointConfig{Failure: config.EndpointList{tt.endpoint}}
+			if err := pusher.Push(context.Background(), "API", tt.result, endpointCfg, config.GlobalMonitorEndpointConfig{}); err != nil {
+				t.Fatalf("Push failed: %v", err)
+			}
+			if got := <-requests; got != tt.want {
+				t.Errorf("Expected request %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("template removed since validation", func(t *testing.T) {
+		removed := filepath.Join(dir, "removed.tmpl")
+		if err := os.WriteFile(removed, []byte(`{{.ServiceName}} is up`), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		cfg := &config.Config{
+			Global: config.GlobalConfig{DefaultInterval: "1m"},
+			Services: []config.Service{{
+				Name:            "API",
+				Type:            "host",
+				MonitorEndpoint: config.MonitorEndpointConfig{Success: config.EndpointList{{URL: testServer.URL, BodyTemplate: removed}}},
+			}},
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate failed: %v", err)
+		}
+		if err := os.Remove(removed); err != nil {
+			t.Fatal(err)
+		}
+		if err := pusher.Push(context.Background(), "API", monitor.Result{Success: true}, cfg.Services[0].MonitorEndpoint, config.GlobalMonitorEndpointConfig{}); err != nil {
+			t.Fatalf("Expected the template parsed at validation to be used, got %v", err)
+		}
+		if got := <-requests; got.body != "API is up" {
+			t.Errorf("Expected the rendered template, got %+v", got)
+		}
+	})
+
+	t.Run("template of an endpoint not validated", func(t *testing.T) {
+		endpoint := config.EndpointConfig{URL: testServer.URL, BodyTemplate: filepath.Join(dir, "missing.tmpl")}
+		err := pusher.Push(context.Background(), "API", monitor.Result{Success: true}, config.MonitorEndpointConfig{Success: config.EndpointList{endpoint}}, config.GlobalMonitorEndpointConfig{})
+		if err == nil || !strings.Contains(err.Error(), "failed to load body_template") {
+			t.Errorf("Expected a body_template error, got %v", err)
+		}
+	})
+}
+
+func TestPusher_Push_DiscordRetryAfter(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		hits int
+	)
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg discordMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || msg.Content == "" {
+			t.Errorf("Expected the message on every attempt, got %q (%v)", msg.Content, err)
+		}
+		mu.Lock()
+		hits++
+		n := hits
+		mu.Unlock()
+		if n == 1 {
+			w.Header().Set("Retry-After", "0.2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer testServer.Close()
+
+	pusher := NewPusher()
+	pusher.SetRateLimit(ptr("0"))
+	endpointCfg := config.MonitorEndpointConfig{
+		Success: config.EndpointList{{URL: testServer.URL, Format: "discord"}},
+		Retries: ptrInt(0),
+	}
+
+	start := time.Now()
+	if err := pusher.Push(context.Background(), "API", monitor.Result{Success: true, Message: "OK"}, endpointCfg, config.GlobalMonitorEndpointConfig{}); err != nil {
+		t.Fatalf("Expected the push to succeed after waiting, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected the push to wait for Retry-After, took %v", elapsed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if hits != 2 {
+		t.Errorf("Expected 2 requests, got %d", hits)
+	}
+}
+
+func TestPusher_Push_DiscordRetryAfterBeyondTimeout(t *testing.T) {
+	var hits atomic.Int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer testServer.Close()
+
+	pusher := NewPusher()
+	pusher.SetRateLimit(ptr("0"))
+	endpointCfg := config.MonitorEndpointConfig{
+		Success: config.EndpointList{{URL: testServer.URL, Format: "discord", Timeout: "1s"}},
+	}
+
+	err := pusher.Push(context.Background(), "API", monitor.Result{Success: true}, endpointCfg, config.GlobalMonitorEndpointConfig{})
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("Expected a rate limited error, got %v", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("Expected a wait beyond the timeout not to be honored, got %d requests", got)
+	}
+	err = pusher.Push(context.Background(), "API", monitor.Result{Success: true}, endpointCfg, config.GlobalMonitorEndpointConfig{})
+	if err == nil || !strings.Contains(err.Error(), "rate limited for another") {
+		t.Errorf("Expected the next push to be deferred, got %v", err)
+	}
+}
+
 func TestPusher_Push_EmptyEndpoint(t *testing.T) {
 	pusher := NewPusher()
 	res := monitor.Result{Success: true}
@@ -209,10 +621,10 @@ func TestPusher_Push_HeaderOverride(t *testing.T) {
 	res := monitor.Result{Success: true}
 
 	alertCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{
+		Success: config.EndpointList{{
 			URL:     testServer.URL,
 			Headers: map[string]string{"X-Test": "specific"},
-		},
+		}},
 		Headers: map[string]string{"X-Test": "common"},
 	}
 	globalCfg := config.GlobalMonitorEndpointConfig{
@@ -231,7 +643,7 @@ func TestPusher_Push_NewRequestError(t *testing.T) {
 
 	// Control character in URL should cause NewRequest to fail
 	alertCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{URL: "http://example.com/\x7f"},
+		Success: config.EndpointList{{URL: "http://example.com/\x7f"}},
 	}
 
 	err := pusher.Push(context.Background(), "test-service", res, alertCfg, config.GlobalMonitorEndpointConfig{})
@@ -246,7 +658,7 @@ func TestPusher_Push_DoError(t *testing.T) {
 
 	// This URL should fail Do() because it's a non-existent local port
 	alertCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{URL: "http://127.0.0.1:1"},
+		Success: config.EndpointList{{URL: "http://127.0.0.1:1"}},
 	}
 
 	err := pusher.Push(context.Background(), "test-service", res, alertCfg, config.GlobalMonitorEndpointConfig{})
@@ -263,6 +675,7 @@ func TestPushInsecure(t *testing.T) {
 	defer server.Close()
 
 	pusher := NewPusher()
+	pusher.SetMaxBackoff("0") // The failed push must not hold off the next one
 	result := monitor.Result{
 		Success:   true,
 		Message:   "OK",
@@ -274,10 +687,10 @@ func TestPushInsecure(t *testing.T) {
 
 	t.Run("Fail with default client (TLS verification on)", func(t *testing.T) {
 		endpointCfg := config.MonitorEndpointConfig{
-			Success: config.EndpointConfig{
+			Success: config.EndpointList{{
 				URL:                server.URL,
 				InsecureSkipVerify: false,
-			},
+			}},
 		}
 
 		err := pusher.Push(context.Background(), "test-service", result, endpointCfg, globalCfg)
@@ -288,10 +701,10 @@ func TestPushInsecure(t *testing.T) {
 
 	t.Run("Succeed with InsecureSkipVerify: true", func(t *testing.T) {
 		endpointCfg := config.MonitorEndpointConfig{
-			Success: config.EndpointConfig{
+			Success: config.EndpointList{{
 				URL:                server.URL,
 				InsecureSkipVerify: true,
-			},
+			}},
 		}
 
 		err := pusher.Push(context.Background(), "test-service", result, endpointCfg, globalCfg)
@@ -310,7 +723,7 @@ func TestPushOptionalFailure(t *testing.T) {
 
 	t.Run("Skip alert when failure endpoint is nil", func(t *testing.T) {
 		endpointCfg := config.MonitorEndpointConfig{
-			Success: config.EndpointConfig{URL: "http://success.test"},
+			Success: config.EndpointList{{URL: "http://success.test"}},
 			Failure: nil,
 		}
 		globalCfg := config.GlobalMonitorEndpointConfig{}
@@ -323,8 +736,8 @@ func TestPushOptionalFailure(t *testing.T) {
 
 	t.Run("Skip alert when failure URL is empty", func(t *testing.T) {
 		endpointCfg := config.MonitorEndpointConfig{
-			Success: config.EndpointConfig{URL: "http://success.test"},
-			Failure: &config.EndpointConfig{URL: ""},
+			Success: config.EndpointList{{URL: "http://success.test"}},
+			Failure: config.EndpointList{{URL: ""}},
 		}
 		globalCfg := config.GlobalMonitorEndpointConfig{}
 
@@ -345,7 +758,7 @@ func TestPusher_RateLimit(t *testing.T) {
 	pusher.SetRateLimit(ptr("100ms"))
 
 	alertCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{URL: testServer.URL},
+		Success: config.EndpointList{{URL: testServer.URL}},
 	}
 	res := monitor.Result{Success: true}
 
@@ -392,7 +805,7 @@ func TestPusher_DefaultRateLimit(t *testing.T) {
 	pusher := NewPusher()
 
 	alertCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{URL: testServer.URL},
+		Success: config.EndpointList{{URL: testServer.URL}},
 	}
 	res := monitor.Result{Success: true}
 
@@ -467,14 +880,15 @@ func TestPusher_Push_TimeoutHierarchy(t *testing.T) {
 	defer testServer.Close()
 
 	pusher := NewPusher()
+	pusher.SetMaxBackoff("0") // Every subtest pushes to the same endpoint
 	res := monitor.Result{Success: true}
 
 	t.Run("Endpoint timeout (shortest)", func(t *testing.T) {
 		alertCfg := config.MonitorEndpointConfig{
-			Success: config.EndpointConfig{
+			Success: config.EndpointList{{
 				URL:     testServer.URL,
 				Timeout: "50ms",
-			},
+			}},
 			Timeout: "500ms",
 		}
 		globalCfg := config.GlobalMonitorEndpointConfig{Timeout: "1s"}
@@ -489,7 +903,7 @@ func TestPusher_Push_TimeoutHierarchy(t *testing.T) {
 
 	t.Run("Service-shared timeout", func(t *testing.T) {
 		alertCfg := config.MonitorEndpointConfig{
-			Success: config.EndpointConfig{URL: testServer.URL},
+			Success: config.EndpointList{{URL: testServer.URL}},
 			Timeout: "50ms",
 		}
 		globalCfg := config.GlobalMonitorEndpointConfig{Timeout: "1s"}
@@ -504,7 +918,7 @@ func TestPusher_Push_TimeoutHierarchy(t *testing.T) {
 
 	t.Run("Global timeout", func(t *testing.T) {
 		alertCfg := config.MonitorEndpointConfig{
-			Success: config.EndpointConfig{URL: testServer.URL},
+			Success: config.EndpointList{{URL: testServer.URL}},
 		}
 		globalCfg := config.GlobalMonitorEndpointConfig{Timeout: "50ms"}
 
@@ -518,7 +932,7 @@ func TestPusher_Push_TimeoutHierarchy(t *testing.T) {
 
 	t.Run("Default timeout (succeeds with 5s)", func(t *testing.T) {
 		alertCfg := config.MonitorEndpointConfig{
-			Success: config.EndpointConfig{URL: testServer.URL},
+			Success: config.EndpointList{{URL: testServer.URL}},
 		}
 		globalCfg := config.GlobalMonitorEndpointConfig{}
 
@@ -546,7 +960,7 @@ func TestPusher_Push_Retries(t *testing.T) {
 
 	result := monitor.Result{Success: true}
 	endpointCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{URL: testServer.URL},
+		Success: config.EndpointList{{URL: testServer.URL}},
 	}
 	globalCfg := config.GlobalMonitorEndpointConfig{
 		Retries: ptrInt(3),
@@ -575,7 +989,7 @@ func TestPusher_Push_Retries_Exhausted(t *testing.T) {
 
 	result := monitor.Result{Success: true}
 	endpointCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{URL: testServer.URL},
+		Success: config.EndpointList{{URL: testServer.URL}},
 	}
 	globalCfg := config.GlobalMonitorEndpointConfig{
 		Retries: ptrInt(2),
@@ -605,7 +1019,7 @@ func TestPusher_Push_Retries_ServiceOverride(t *testing.T) {
 	result := monitor.Result{Success: true}
 	retriesOverride := 1
 	endpointCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{URL: testServer.URL},
+		Success: config.EndpointList{{URL: testServer.URL}},
 		Retries: &retriesOverride,
 	}
 	globalCfg := config.GlobalMonitorEndpointConfig{
@@ -630,7 +1044,7 @@ func TestPusher_Push_ContextCancellation(t *testing.T) {
 	pusher := NewPusher()
 	res := monitor.Result{Success: true}
 	alertCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{URL: testServer.URL},
+		Success: config.EndpointList{{URL: testServer.URL}},
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
@@ -660,9 +1074,9 @@ func TestPusher_Push_LogVerification(t *testing.T) {
 
 	// Create a URL with variables to verify full URL logging
 	endpointCfg := config.MonitorEndpointConfig{
-		Success: config.EndpointConfig{
+		Success: config.EndpointList{{
 			URL: testServer.URL + "?d={%duration%}",
-		},
+		}},
 	}
 
 	svcName := "TestLogger"
@@ -687,3 +1101,240 @@ func TestPusher_Push_LogVerification(t *testing.T) {
 		t.Errorf("Expected success log, got:\n%s", logs)
 	}
 }
+
+func TestPusher_Push_Escalation(t *testing.T) {
+	var mu sync.Mutex
+	var errs []string
+	failNext := false
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, r.URL.Query().Get("error"))
+		if failNext {
+			failNext = false
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer testServer.Close()
+
+	noRetries := 0
+	run := func(repeat, failFirst bool) []string {
+		mu.Lock()
+		errs = nil
+		failNext = failFirst
+		mu.Unlock()
+
+		pusher := &Pusher{Client: testServer.Client()}
+		alertCfg := config.MonitorEndpointConfig{
+			Success:        config.EndpointList{{URL: testServer.URL + "?error=up"}},
+			Failure:        config.EndpointList{{URL: testServer.URL + "?error={%error%}"}},
+			Retries:        &noRetries,
+			EscalateAfter:  "1h",
+			EscalateRepeat: repeat,
+		}
+		// Since is how long the service has been down, as tracked by the agent
+		results := []monitor.Result{
+			{Success: false, Message: "down", Since: 2 * time.Hour},
+			{Success: false, Message: "down", Since: 2 * time.Hour},
+			{Success: false, Message: "down", Since: 2 * time.Hour},
+			{Success: true, Message: "OK"},
+			{Success: false, Message: "down"}, // New outage
+		}
+		for i, res := range results {
+			err := pusher.Push(context.Background(), "svc", res, alertCfg, config.GlobalMonitorEndpointConfig{})
+			if err != nil && !(failFirst && i == 0) {
+				t.Fatalf("Push %d failed: %v", i, err)
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return errs
+	}
+
+	escalated := func(msg string) bool { return strings.HasPrefix(msg, "escalated, down for 2h0m0s: down") }
+
+	once := run(false, false)
+	if len(once) != 5 || !escalated(once[0]) || escalated(once[1]) || escalated(once[2]) || escalated(once[4]) {
+		t.Errorf("Expected only the first failure past escalate_after to escalate, got %q", once)
+	}
+
+	repeated := run(true, false)
+	if len(repeated) != 5 || !escalated(repeated[0]) || !escalated(repeated[1]) || !escalated(repeated[2]) || escalated(repeated[4]) {
+		t.Errorf("Expected every failure past escalate_after to escalate, got %q", repeated)
+	}
+
+	undelivered := run(false, true)
+	if len(undelivered) != 5 || !escalated(undelivered[0]) || !escalated(undelivered[1]) || escalated(undelivered[2]) {
+		t.Errorf("Expected an undelivered escalation to be escalated again, got %q", undelivered)
+	}
+}
+
+func TestPusher_Push_RetryAfter(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		n := hits[r.URL.Path]
+		mu.Unlock()
+		if r.URL.Path == "/limited" && n == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	pusher := NewPusher()
+	pusher.SetRateLimit(ptr("0"))
+	globalCfg := config.GlobalMonitorEndpointConfig{Retries: ptrInt(3)}
+	push := func(path string) error {
+		// The query string differs per push, the endpoint is the same
+		endpointCfg := config.MonitorEndpointConfig{
+			Success: config.EndpointList{{URL: testServer.URL + path + "?ping={%timestamp%}"}},
+		}
+		return pusher.Push(context.Background(), "test-service", monitor.Result{Success: true, Timestamp: time.Now()}, endpointCfg, globalCfg)
+	}
+	count := func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return hits[path]
+	}
+
+	if err := push("/limited"); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("expected a rate limited error, got %v", err)
+	}
+	if got := count("/limited"); got != 1 {
+		t.Errorf("expected no retries after a 429, got %d requests", got)
+	}
+
+	if err := push("/limited"); err == nil || !strings.Contains(err.Error(), "rate limited for another") {
+		t.Errorf("expected the push to be deferred, got %v", err)
+	}
+	if got := count("/limited"); got != 1 {
+		t.Errorf("expected the deferred push not to reach the endpoint, got %d requests", got)
+	}
+
+	// Other endpoints are not affected
+	if err := push("/other"); err != nil {
+		t.Errorf("unexpected error for another endpoint: %v", err)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if err := push("/limited"); err != nil {
+		t.Errorf("expected success once the window passed, got %v", err)
+	}
+	if got := count("/limited"); got != 2 {
+		t.Errorf("expected 2 requests, got %d", got)
+	}
+}
+
+func TestPusher_Push_FailureBackoff(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		hits []time.Time
+	)
+	// Down for 4 deliveries, up for one, then down again
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits = append(hits, time.Now())
+		n := len(hits)
+		mu.Unlock()
+		if n == 5 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	pusher := NewPusher()
+	pusher.SetRateLimit(ptr("0"))
+	pusher.backoffBase = 20 * time.Millisecond
+	pusher.SetMaxBackoff("1s")
+	endpointCfg := config.MonitorEndpointConfig{Success: config.EndpointList{{URL: testServer.URL}}}
+	globalCfg := config.GlobalMonitorEndpointConfig{Retries: ptrInt(0)}
+
+	var skipped int
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(hits)
+		mu.Unlock()
+		if n == 7 {
+			break
+		}
+		err := pusher.Push(context.Background(), "API", monitor.Result{Success: true}, endpointCfg, globalCfg)
+		if err != nil && strings.Contains(err.Error(), "backing off") {
+			skipped++
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hits) != 7 {
+		t.Fatalf("Expected 7 deliveries, got %d", len(hits))
+	}
+	if skipped == 0 {
+		t.Error("Expected pushes to be skipped while backing off")
+	}
+	var gaps []time.Duration
+	for i := 1; i < len(hits); i++ {
+		gaps = append(gaps, hits[i].Sub(hits[i-1]))
+	}
+	// 20ms, 40ms, 80ms and 160ms after failures 1 to 4
+	for i, want := range []time.Duration{20, 40, 80, 160} {
+		if gaps[i] < want*time.Millisecond {
+			t.Errorf("Expected attempt %d at least %v after the previous one, got %v", i+2, want*time.Millisecond, gaps[i])
+		}
+		if i > 0 && gaps[i] <= gaps[i-1] {
+			t.Errorf("Expected increasing gaps, got %v", gaps)
+		}
+	}
+	// The success reset the count, the next failure backs off from the start
+	if gaps[5] < 20*time.Millisecond || gaps[5] >= gaps[3] {
+		t.Errorf("Expected the backoff to restart after a success, got %v", gaps)
+	}
+}
+
+func TestPusher_BackOffCap(t *testing.T) {
+	pusher := NewPusher()
+	pusher.SetMaxBackoff("1m")
+	for range 20 {
+		pusher.backOff("http://alerts")
+	}
+	if wait := time.Until(pusher.failing["http://alerts"].until); wait > time.Minute || wait < 59*time.Second {
+		t.Errorf("Expected the backoff capped at 1m, got %v", wait)
+	}
+
+	pusher.SetMaxBackoff("0")
+	pusher.backOff("http://other")
+	if pusher.failing["http://other"] != nil {
+		t.Error("Expected no backoff with max_backoff 0")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"30", 30 * time.Second},
+		{"0.25", 250 * time.Millisecond},
+		{" 0 ", 0},
+		{now.Add(2 * time.Minute).Format(http.TimeFormat), 2 * time.Minute},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"", defaultRetryAfter},
+		{"soon", defaultRetryAfter},
+		{"-5", defaultRetryAfter},
+		{"86400", maxRetryAfter},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
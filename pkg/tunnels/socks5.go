@@ -0,0 +1,113 @@
+package tunnels
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"probixel/pkg/config"
+
+	"golang.org/x/net/proxy"
+)
+
+// SOCKS5Tunnel routes connections through a SOCKS5 proxy, such as Tor or an
+// SSH dynamic port forward. Only TCP can be carried.
+type SOCKS5Tunnel struct {
+	name   string
+	target string
+	cfg    *config.SOCKS5Config
+
+	mu       sync.Mutex
+	dialer   proxy.ContextDialer
+	initTime time.Time
+}
+
+func NewSOCKS5Tunnel(name string, target string, cfg *config.SOCKS5Config) *SOCKS5Tunnel {
+	return &SOCKS5Tunnel{
+		name:   name,
+		target: target,
+		cfg:    cfg,
+	}
+}
+
+func (t *SOCKS5Tunnel) Name() string { return t.name }
+func (t *SOCKS5Tunnel) Type() string { return "socks5" }
+
+// Initialize prepares the proxy dialer. The proxy is only contacted by
+// DialContext, each connection being negotiated separately.
+func (t *SOCKS5Tunnel) Initialize() error {
+	host, port, err := config.ParseTarget(t.target)
+	if err != nil {
+		return fmt.Errorf("socks5 tunnel %q: invalid target %q: %w", t.name, t.target, err)
+	}
+	if port == "" {
+		port = "1080"
+	}
+	target := net.JoinHostPort(host, port)
+
+	var auth *proxy.Auth
+	if t.cfg != nil && t.cfg.Username != "" {
+		auth = &proxy.Auth{User: t.cfg.Username, Password: t.cfg.Password}
+	}
+	d, err := proxy.SOCKS5("tcp", target, auth, &net.Dialer{})
+	if err != nil {
+		return fmt.Errorf("socks5 tunnel %q: %w", t.name, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dialer = d.(proxy.ContextDialer)
+	t.initTime = time.Now()
+	return nil
+}
+
+func (t *SOCKS5Tunnel) Stop() {
+	// Connections are owned by the probes, there is nothing to tear down
+}
+
+func (t *SOCKS5Tunnel) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if !strings.HasPrefix(network, "tcp") {
+		return nil, fmt.Errorf("socks5 tunnel %q: unsupported protocol %s, only tcp is supported", t.name, network)
+	}
+
+	t.mu.Lock()
+	d := t.dialer
+	t.mu.Unlock()
+	if d == nil {
+		if err := t.Initialize(); err != nil {
+			return nil, err
+		}
+		t.mu.Lock()
+		d = t.dialer
+		t.mu.Unlock()
+	}
+
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, fmt.Errorf("socks5 dial failed: %w", err)
+	}
+	return conn, nil
+}
+
+func (t *SOCKS5Tunnel) LastInitTime() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.initTime
+}
+
+func (t *SOCKS5Tunnel) ReportFailure() {
+	// Every connection is negotiated on its own, there is no session to reset
+}
+
+func (t *SOCKS5Tunnel) ReportSuccess() {
+	// SOCKS5 tunnels don't need restart prevention logic
+}
+
+func (t *SOCKS5Tunnel) Target() string { return t.target }
+
+func (t *SOCKS5Tunnel) IsStabilized() bool {
+	return true // Nothing to establish before the first connection
+}
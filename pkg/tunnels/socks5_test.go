@@ -0,0 +1,251 @@
+package tunnels
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"probixel/pkg/config"
+)
+
+// startSOCKS5Server runs a minimal SOCKS5 proxy supporting CONNECT, requiring
+// username/password authentication when user is set. It returns the proxy
+// address and a counter of the connections it forwarded.
+func startSOCKS5Server(t *testing.T, user, pass string) (string, *atomic.Int32) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	var forwarded atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				if serveSOCKS5(conn, user, pass) {
+					forwarded.Add(1)
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), &forwarded
+}
+
+func serveSOCKS5(conn net.Conn, user, pass string) bool {
+	// Greeting: version, methods
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return false
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return false
+	}
+	if user == "" {
+		_, _ = conn.Write([]byte{5, 0})
+	} else {
+		_, _ = conn.Write([]byte{5, 2})
+		// Username/password: version, ulen, user, plen, pass
+		buf := make([]byte, 2)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return false
+		}
+		u := make([]byte, buf[1])
+		_, _ = io.ReadFull(conn, u)
+		_, _ = io.ReadFull(conn, buf[:1])
+		p := make([]byte, buf[0])
+		_, _ = io.ReadFull(conn, p)
+		if string(u) != user || string(p) != pass {
+			_, _ = conn.Write([]byte{1, 1})
+			return false
+		}
+		_, _ = conn.Write([]byte{1, 0})
+	}
+
+	// Request: version, cmd, reserved, address type, address, port
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return false
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		_, _ = io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		l := make([]byte, 1)
+		_, _ = io.ReadFull(conn, l)
+		name := make([]byte, l[0])
+		_, _ = io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return false
+	}
+	portBuf := make([]byte, 2)
+	_, _ = io.ReadFull(conn, portBuf)
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(portBuf))))
+
+	upstream, err := net.Dial("tcp", addr)
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0}) // Connection refused
+		return false
+	}
+	defer func() { _ = upstream.Close() }()
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go func() { _, _ = io.Copy(upstream, conn) }()
+	_, _ = io.Copy(conn, upstream)
+	return true
+}
+
+// startEchoServer answers every connection with "hello".
+func startEchoServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("hello"))
+			_ = conn.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestSOCKS5Tunnel_DialContext(t *testing.T) {
+	target := startEchoServer(t)
+
+	tests := []struct {
+		name    string
+		user    string
+		cfg     *config.SOCKS5Config
+		wantErr bool
+	}{
+		{name: "no auth"},
+		{name: "auth", user: "monitor", cfg: &config.SOCKS5Config{Username: "monitor", Password: "secret"}},
+		{name: "wrong password", user: "monitor", cfg: &config.SOCKS5Config{Username: "monitor", Password: "wrong"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyAddr, forwarded := startSOCKS5Server(t, tt.user, "secret")
+			tun := NewSOCKS5Tunnel("proxy", proxyAddr, tt.cfg)
+			if err := tun.Initialize(); err != nil {
+				t.Fatalf("Initialize failed: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			conn, err := tun.DialContext(ctx, "tcp", target)
+			if tt.wantErr {
+				if err == nil {
+					_ = conn.Close()
+					t.Fatal("expected an authentication error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DialContext failed: %v", err)
+			}
+			defer func() { _ = conn.Close() }()
+
+			got, _ := io.ReadAll(conn)
+			if string(got) != "hello" {
+				t.Errorf("expected the target's greeting through the proxy, got %q", got)
+			}
+			_ = conn.Close()
+			time.Sleep(10 * time.Millisecond)
+			if forwarded.Load() != 1 {
+				t.Errorf("expected the connection to go through the proxy")
+			}
+		})
+	}
+}
+
+func TestSOCKS5Tunnel_UDPUnsupported(t *testing.T) {
+	tun := NewSOCKS5Tunnel("proxy", "127.0.0.1", nil)
+	if tun.Type() != "socks5" || !tun.IsStabilized() {
+		t.Errorf("unexpected tunnel state: type %q", tun.Type())
+	}
+	_, err := tun.DialContext(context.Background(), "udp", "10.0.0.1:53")
+	if err == nil || !strings.Contains(err.Error(), "only tcp is supported") {
+		t.Errorf("expected an unsupported protocol error, got %v", err)
+	}
+}
+
+func TestSOCKS5Tunnel_ProxyDown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	tun := NewSOCKS5Tunnel("proxy", addr, nil)
+	_, err = tun.DialContext(context.Background(), "tcp", "10.0.0.1:80")
+	if err == nil || !strings.Contains(err.Error(), "socks5 dial failed") {
+		t.Errorf("expected a dial error, got %v", err)
+	}
+	if tun.LastInitTime().IsZero() {
+		t.Error("expected DialContext to initialize the tunnel")
+	}
+}
+
+func TestSOCKS5Tunnel_IPv6Proxy(t *testing.T) {
+	target := startEchoServer(t)
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		serveSOCKS5(conn, "", "")
+	}()
+
+	tun := NewSOCKS5Tunnel("proxy", listener.Addr().String(), nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	conn, err := tun.DialContext(ctx, "tcp", target)
+	if err != nil {
+		t.Fatalf("DialContext through [::1] failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if got, _ := io.ReadAll(conn); string(got) != "hello" {
+		t.Errorf("expected the target's greeting through the proxy, got %q", got)
+	}
+}
+
+func TestSOCKS5Tunnel_InvalidTarget(t *testing.T) {
+	for _, target := range []string{"[::1]", "2001:db8::1"} {
+		if err := NewSOCKS5Tunnel("proxy", target, nil).Initialize(); err != nil {
+			t.Errorf("%s: unexpected error: %v", target, err)
+		}
+	}
+	err := NewSOCKS5Tunnel("proxy", "proxy:1080:1", nil).Initialize()
+	if err == nil || !strings.Contains(err.Error(), "invalid target") {
+		t.Errorf("expected an invalid target error, got %v", err)
+	}
+}
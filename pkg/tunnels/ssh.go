@@ -55,7 +55,7 @@ func (t *SSHTunnel) DialContext(ctx context.Context, network, address string) (n
 	if err != nil {
 		return nil, err
 	}
-	return client.Dial(network, address)
+	return client.DialContext(ctx, network, address)
 }
 
 func (t *SSHTunnel) GetClient(ctx context.Context) (*ssh.Client, error) {
@@ -105,10 +105,20 @@ func (t *SSHTunnel) GetClient(ctx context.Context) (*ssh.Client, error) {
 		return nil, fmt.Errorf("ssh dial failed: %w", err)
 	}
 
+	// NewClientConn doesn't take a context, so bound the handshake and abort it
+	// by closing the connection once ctx is done
+	_ = conn.SetDeadline(time.Now().Add(sshConfig.Timeout))
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
 	c, channel, req, err := ssh.NewClientConn(conn, target, sshConfig)
+	stop()
 	if err != nil {
+		_ = conn.Close()
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("ssh handshake aborted: %w", ctx.Err())
+		}
 		return nil, fmt.Errorf("ssh handshake failed: %w", err)
 	}
+	_ = conn.SetDeadline(time.Time{})
 
 	t.client = ssh.NewClient(c, channel, req)
 	t.initTime = time.Now()
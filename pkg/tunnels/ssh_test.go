@@ -10,6 +10,7 @@ import (
 	"net"
 	"probixel/pkg/config"
 	"testing"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -210,6 +211,47 @@ func TestSSHTunnel_HandshakeError(t *testing.T) {
 	}
 }
 
+func TestSSHTunnel_HandshakeAbortsOnCancel(t *testing.T) {
+	// Start a TCP server that accepts but never speaks SSH
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	host, portStr, _ := net.SplitHostPort(listener.Addr().String())
+	var port int
+	fmt.Sscanf(portStr, "%d", &port)
+
+	tun := NewSSHTunnel("hung-tun", host, &config.SSHConfig{
+		User:     "user",
+		Password: "password",
+		Port:     port,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err = tun.GetClient(ctx)
+	if err == nil {
+		t.Fatal("expected aborted handshake error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected GetClient to return promptly after cancel, took %v", elapsed)
+	}
+}
+
 func TestSSHTunnel_ReconnectOnFailure(t *testing.T) {
 	// 1. Start a flaky server that can be stopped and started
 	startServer := func() (net.Listener, int) {
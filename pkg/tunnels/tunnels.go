@@ -21,13 +21,22 @@ type Tunnel interface {
 }
 
 type Registry struct {
-	mu      sync.RWMutex
-	tunnels map[string]Tunnel
+	mu            sync.RWMutex
+	tunnels       map[string]Tunnel
+	stabilization map[string]*stabilization
+}
+
+// stabilization tracks how long a tunnel has gone without stabilizing.
+type stabilization struct {
+	max      time.Duration // 0 for no limit
+	since    time.Time     // Registration, or the last time the tunnel was seen stabilized
+	reported time.Time     // Last failure reported for exceeding max
 }
 
 func NewRegistry() *Registry {
 	return &Registry{
-		tunnels: make(map[string]Tunnel),
+		tunnels:       make(map[string]Tunnel),
+		stabilization: make(map[string]*stabilization),
 	}
 }
 
@@ -38,6 +47,49 @@ func (r *Registry) Register(t Tunnel) error {
 		return fmt.Errorf("tunnel %q already registered", t.Name())
 	}
 	r.tunnels[t.Name()] = t
+	r.stabilization[t.Name()] = &stabilization{since: time.Now()}
+	return nil
+}
+
+// SetMaxStabilizationTime bounds how long the named tunnel may go without
+// stabilizing before CheckStabilization reports it failed, 0 for no limit.
+func (r *Registry) SetMaxStabilizationTime(name string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.stabilization[name]; ok {
+		s.max = d
+	}
+}
+
+// CheckStabilization returns an error once the named tunnel has gone without
+// stabilizing for longer than its max stabilization time. The failure is
+// reported to the tunnel, so it can restart, again every time that long passes
+// while it still doesn't stabilize.
+func (r *Registry) CheckStabilization(name string) error {
+	var limit time.Duration
+	var failed, report bool
+
+	r.mu.Lock()
+	t, ok := r.tunnels[name]
+	if s := r.stabilization[name]; ok && s != nil && s.max > 0 {
+		switch {
+		case t.IsStabilized():
+			s.since, s.reported = time.Now(), time.Time{}
+		case time.Since(s.since) >= s.max:
+			limit, failed = s.max, true
+			if s.reported.IsZero() || time.Since(s.reported) >= s.max {
+				s.reported, report = time.Now(), true
+			}
+		}
+	}
+	r.mu.Unlock()
+
+	if report {
+		t.ReportFailure()
+	}
+	if failed {
+		return fmt.Errorf("tunnel %q not stabilized after %v", name, limit)
+	}
 	return nil
 }
 
@@ -55,4 +107,16 @@ func (r *Registry) StopAll() {
 		t.Stop()
 	}
 	r.tunnels = make(map[string]Tunnel)
+	r.stabilization = make(map[string]*stabilization)
+}
+
+// Remove stops the named tunnel and removes it from the registry.
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.tunnels[name]; ok {
+		t.Stop()
+		delete(r.tunnels, name)
+		delete(r.stabilization, name)
+	}
 }
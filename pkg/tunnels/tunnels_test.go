@@ -39,6 +39,77 @@ func TestRegistry_StopAll(t *testing.T) {
 	}
 }
 
+func TestRegistry_Remove(t *testing.T) {
+	r := NewRegistry()
+	stopped := false
+	mock := &MockTunnel{
+		NameFunc: func() string { return "t1" },
+		StopFunc: func() { stopped = true },
+	}
+	_ = r.Register(mock)
+
+	r.Remove("t1")
+	if !stopped {
+		t.Error("expected Stop to be called")
+	}
+	if _, ok := r.Get("t1"); ok {
+		t.Error("expected tunnel to be removed from registry")
+	}
+
+	// Removing an unknown tunnel is a no-op
+	r.Remove("unknown")
+
+	// The name can be registered again after removal
+	if err := r.Register(mock); err != nil {
+		t.Errorf("expected re-register to succeed, got %v", err)
+	}
+}
+
+func TestRegistry_CheckStabilization(t *testing.T) {
+	r := NewRegistry()
+	failures := 0
+	mock := &MockTunnel{
+		NameFunc:          func() string { return "t1" },
+		ReportFailureFunc: func() { failures++ },
+	}
+	_ = r.Register(mock)
+
+	// Without a max stabilization time the tunnel may stay pending forever
+	if err := r.CheckStabilization("t1"); err != nil {
+		t.Fatalf("expected no error without a limit, got %v", err)
+	}
+
+	r.SetMaxStabilizationTime("t1", 50*time.Millisecond)
+	if err := r.CheckStabilization("t1"); err != nil {
+		t.Fatalf("expected no error within the limit, got %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	for range 2 {
+		err := r.CheckStabilization("t1")
+		if err == nil || err.Error() != `tunnel "t1" not stabilized after 50ms` {
+			t.Fatalf("expected a stabilization failure, got %v", err)
+		}
+	}
+	if failures != 1 {
+		t.Errorf("expected the failure to be reported once per limit, got %d", failures)
+	}
+
+	// Stabilizing restarts the window
+	mock.IsStabilizedResult = true
+	if err := r.CheckStabilization("t1"); err != nil {
+		t.Fatalf("expected no error once stabilized, got %v", err)
+	}
+	mock.IsStabilizedResult = false
+	if err := r.CheckStabilization("t1"); err != nil {
+		t.Errorf("expected a new window after stabilizing, got %v", err)
+	}
+
+	if err := r.CheckStabilization("unknown"); err != nil {
+		t.Errorf("expected no error for an unknown tunnel, got %v", err)
+	}
+}
+
 func TestMockTunnel_AllMethods(t *testing.T) {
 	// Test default behaviors (no funcs set)
 	m := &MockTunnel{}
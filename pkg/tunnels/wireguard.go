@@ -3,6 +3,7 @@ package tunnels
 import (
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -118,7 +119,15 @@ func (t *WireguardTunnel) Initialize() error {
 		uapiConf += fmt.Sprintf("preshared_key=%s\n", hex.EncodeToString(psk[:]))
 	}
 
-	resolvedAddr, err := net.ResolveUDPAddr("udp", t.cfg.Endpoint)
+	host, port, err := config.ParseTarget(t.cfg.Endpoint)
+	if err == nil && port == "" {
+		err = errors.New("missing port")
+	}
+	if err != nil {
+		dev.Close()
+		return fmt.Errorf("invalid wireguard endpoint %q: %w", t.cfg.Endpoint, err)
+	}
+	resolvedAddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(host, port))
 	if err != nil {
 		dev.Close()
 		return fmt.Errorf("failed to resolve wireguard endpoint %q: %w", t.cfg.Endpoint, err)
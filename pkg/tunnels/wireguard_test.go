@@ -103,6 +103,11 @@ func TestWireguardTunnel_InitializeErrors(t *testing.T) {
 			&config.WireguardConfig{Addresses: "10.0.0.1/32", PrivateKey: "wOEI9rqqbDwnN8/Bpp22sVz48T71vJ4fYmFWujulwUU=", PublicKey: "wAUaJMhAq3NFutLHIdF8AN0B5WG8RndfQKLPTEDHal0=", Endpoint: "invalid-host:51820"},
 			"failed to resolve wireguard endpoint",
 		},
+		{
+			"endpoint without port",
+			&config.WireguardConfig{Addresses: "10.0.0.1/32", PrivateKey: "wOEI9rqqbDwnN8/Bpp22sVz48T71vJ4fYmFWujulwUU=", PublicKey: "wAUaJMhAq3NFutLHIdF8AN0B5WG8RndfQKLPTEDHal0=", Endpoint: "2001:db8::1"},
+			"invalid wireguard endpoint \"2001:db8::1\": missing port",
+		},
 	}
 
 	for _, tt := range tests {
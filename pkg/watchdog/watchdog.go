@@ -1,13 +1,24 @@
 package watchdog
 
 import (
+	"bytes"
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"text/tabwriter"
 	"time"
 
 	"probixel/pkg/agent"
 	"probixel/pkg/config"
+	"probixel/pkg/logging"
 	"probixel/pkg/monitor"
 	"probixel/pkg/notifier"
 	"probixel/pkg/tunnels"
@@ -19,6 +30,10 @@ import (
 // This can be set to a shorter duration in tests.
 var ReloadDelay = 10 * time.Second
 
+// MaxReloadDelay caps how long modifications can keep deferring a reload, so
+// a file that is written continuously is still picked up.
+var MaxReloadDelay = 30 * time.Second
+
 // StartingWindow is the duration at startup/reload during which the application stabilizes.
 // This can be set to 0 in tests to prevent delays.
 var StartingWindow = 10 * time.Second
@@ -30,13 +45,37 @@ type Watchdog struct {
 	tunnelRegistry *tunnels.Registry
 	pusher         *notifier.Pusher
 	reloadChan     chan struct{}
+	reloadMu       sync.Mutex // Serializes reloads from the watcher and Reload callers
 
-	mu     sync.Mutex
+	mu     sync.Mutex // Guards cancel and the metrics and API servers
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 
-	monitorCancel context.CancelFunc
-	monitorWg     sync.WaitGroup
+	// Running metrics server, nil when global.metrics is not configured.
+	metrics       *http.Server
+	metricsListen string // Configured listen address
+	metricsAddr   string // Address actually listened on
+
+	// Running status API server, nil when global.api is not configured.
+	api       *http.Server
+	apiListen string // Configured listen address
+	apiAddr   string // Address actually listened on
+
+	// Running monitors and tunnels, keyed by name. Only accessed from run.
+	monitors map[string]*serviceMonitor
+	tunnels  map[string]string // tunnel name -> fingerprint of its running definition
+
+	// Running heartbeat, nil when global.heartbeat is not configured.
+	heartbeat *serviceMonitor
+}
+
+// serviceMonitor is a running service monitor along with the fingerprint of
+// the configuration it was started from.
+type serviceMonitor struct {
+	fingerprint string
+	probe       monitor.Probe
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
 }
 
 func NewWatchdog(configPath string, cfg *config.Config) *Watchdog {
@@ -46,6 +85,8 @@ func NewWatchdog(configPath string, cfg *config.Config) *Watchdog {
 		tunnelRegistry: tunnels.NewRegistry(),
 		pusher:         notifier.NewPusher(),
 		reloadChan:     make(chan struct{}, 1),
+		monitors:       make(map[string]*serviceMonitor),
+		tunnels:        make(map[string]string),
 	}
 }
 
@@ -53,16 +94,19 @@ func (w *Watchdog) Start(ctx context.Context) {
 	w.mu.Lock()
 	ctx, w.cancel = context.WithCancel(ctx)
 	w.mu.Unlock()
+	logging.Configure(w.shared.Get().Global.Logging.Format, w.shared.Get().Global.Logging.Level)
 	w.pusher.SetRateLimit(w.shared.Get().Global.Notifier.RateLimit)
+	w.pusher.SetMaxBackoff(w.shared.Get().Global.Notifier.MaxBackoff)
+	w.applyMetrics(w.shared.Get())
+	w.applyAPI(w.shared.Get())
 
 	// Start config watcher
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Printf("Failed to create file watcher: %v", err)
+		logging.Errorf("", "Failed to create file watcher: %v", err)
 	} else {
-		err = watcher.Add(w.configPath)
-		if err != nil {
-			log.Printf("Failed to watch config file: %v", err)
+		w.watchSources(watcher)
+		if len(watcher.WatchList()) == 0 {
 			_ = watcher.Close()
 		} else {
 			w.wg.Add(1)
@@ -77,84 +121,200 @@ func (w *Watchdog) Start(ctx context.Context) {
 func (w *Watchdog) run(ctx context.Context) {
 	defer w.wg.Done()
 
+	w.apply(ctx, w.shared.Get())
+
 	for {
-		// New monitoring context for this configuration run
-		monitorCtx, monitorCancel := context.WithCancel(ctx)
-		w.monitorCancel = monitorCancel
-		w.monitorWg = sync.WaitGroup{}
-
-		currentCfg := w.shared.Get()
-
-		// Phase 0: Initialize root-level tunnels
-		// Stop any existing tunnels from previous run
-		w.tunnelRegistry.StopAll()
-
-		for name, tCfg := range currentCfg.Tunnels {
-			var t tunnels.Tunnel
-			switch tCfg.Type {
-			case "wireguard":
-				if tCfg.Wireguard != nil {
-					t = tunnels.NewWireguardTunnel(name, tCfg.Wireguard)
-				}
-			case "ssh":
-				if tCfg.SSH != nil {
-					t = tunnels.NewSSHTunnel(name, tCfg.Target, tCfg.SSH)
-				}
+		// Wait for reload or shutdown
+		select {
+		case <-ctx.Done():
+			for name := range w.monitors {
+				w.stopMonitor(name)
 			}
+			w.stopHeartbeat()
+			return
+		case <-w.reloadChan:
+			logging.Infof("", "Applying new configuration to monitors...")
+			w.apply(ctx, w.shared.Get())
+		}
+	}
+}
 
-			if t != nil {
-				if err := t.Initialize(); err != nil {
-					log.Printf("[Tunnel:%s] Failed to initialize: %v", name, err)
-				} else {
-					log.Printf("[Tunnel:%s] Initialized", name)
-				}
-				_ = w.tunnelRegistry.Register(t)
-			}
+// apply reconciles the running monitors and tunnels with cfg. Monitors whose
+// service definition (including the tunnel and docker socket it uses) is
+// unchanged keep running undisturbed; only removed, added, or changed services
+// are stopped and started.
+func (w *Watchdog) apply(ctx context.Context, cfg *config.Config) {
+	desired := make(map[string]string, len(cfg.Services))
+	for _, svc := range cfg.Services {
+		desired[svc.Name] = cfg.ServiceFingerprint(svc)
+	}
+
+	// Phase 0: Stop monitors that were removed or whose definition changed
+	for name, m := range w.monitors {
+		if fp, ok := desired[name]; !ok || fp != m.fingerprint {
+			logging.Infof(name, "Stopping monitor (service removed or changed)")
+			w.stopMonitor(name)
 		}
+	}
 
-		// Calculate and set success window for WireGuard tunnels
-		agent.SetupWireguardWindows(currentCfg, w.tunnelRegistry)
+	// Phase 1: Reconcile root-level tunnels. Services using a changed tunnel
+	// have a changed fingerprint, so they were already stopped above.
+	for name, fp := range w.tunnels {
+		if cfg.TunnelFingerprint(name) != fp {
+			logging.Infof("Tunnel:"+name, "Stopping (tunnel removed or changed)")
+			w.tunnelRegistry.Remove(name)
+			delete(w.tunnels, name)
+		}
+	}
 
-		// Initialize all probes
-		type serviceProbe struct {
-			svc   config.Service
-			probe monitor.Probe
+	for name, tCfg := range cfg.Tunnels {
+		if _, ok := w.tunnels[name]; ok {
+			continue
 		}
-		var serviceProbes []serviceProbe
 
-		for _, svc := range currentCfg.Services {
-			probe, err := agent.SetupProbe(svc, currentCfg, w.tunnelRegistry)
-			if err != nil {
-				log.Printf("[%s] Failed to setup probe: %v. Service will be skipped.", svc.Name, err)
-				continue
+		if t := agent.NewTunnel(name, tCfg); t != nil {
+			if err := t.Initialize(); err != nil {
+				logging.Errorf("Tunnel:"+name, "Failed to initialize: %v", err)
+			} else {
+				logging.Infof("Tunnel:"+name, "Initialized")
+			}
+			_ = w.tunnelRegistry.Register(t)
+			if d, err := config.ParseDuration(tCfg.MaxStabilizationTime); err == nil {
+				w.tunnelRegistry.SetMaxStabilizationTime(name, d)
 			}
-			serviceProbes = append(serviceProbes, serviceProbe{svc: svc, probe: probe})
+			w.tunnels[name] = cfg.TunnelFingerprint(name)
 		}
+	}
+
+	// Calculate and set success window for WireGuard tunnels
+	agent.SetupWireguardWindows(cfg, w.tunnelRegistry)
+	agent.ConfigureDNSCache(cfg)
 
-		// Start all service monitors
-		if StartingWindow > 0 {
-			log.Printf("Waiting %v for application to start...", StartingWindow)
-			time.Sleep(StartingWindow)
+	// Phase 2: Initialize probes for new or changed services
+	var started []*config.Service
+	pending := make(map[string]*serviceMonitor)
+	for i := range cfg.Services {
+		svc := &cfg.Services[i]
+		if _, ok := w.monitors[svc.Name]; ok {
+			continue
 		}
-		for _, sp := range serviceProbes {
-			w.monitorWg.Add(1)
-			go agent.RunServiceMonitor(monitorCtx, sp.svc, sp.probe, w.shared, w.tunnelRegistry, w.pusher, &w.monitorWg)
+		probe, err := agent.SetupProbe(*svc, cfg, w.tunnelRegistry)
+		if err != nil {
+			logging.Errorf(svc.Name, "Failed to setup probe: %v. Service will be skipped.", err)
+			continue
 		}
+		pending[svc.Name] = &serviceMonitor{fingerprint: desired[svc.Name], probe: probe}
+		started = append(started, svc)
+	}
 
-		log.Printf("Agent components started with %d services", len(serviceProbes))
+	logSummary(cfg, started)
 
-		// Wait for reload or shutdown
-		select {
-		case <-ctx.Done():
-			w.monitorCancel()
-			w.monitorWg.Wait()
-			return
-		case <-w.reloadChan:
-			log.Println("Restarting monitors with new configuration...")
-			w.monitorCancel()
-			w.monitorWg.Wait()
+	// Phase 3: Start the new monitors
+	if len(started) > 0 && StartingWindow > 0 {
+		logging.Infof("", "Waiting %v for application to start...", StartingWindow)
+		time.Sleep(StartingWindow)
+	}
+	for _, svc := range started {
+		m := pending[svc.Name]
+		monitorCtx, cancel := context.WithCancel(ctx)
+		m.cancel = cancel
+		m.wg.Add(1)
+		go agent.RunServiceMonitor(monitorCtx, *svc, m.probe, w.shared, w.tunnelRegistry, w.pusher, &m.wg)
+		w.monitors[svc.Name] = m
+	}
+
+	w.applyHeartbeat(ctx, cfg)
+
+	logging.Infof("", "Agent components started with %d services (%d started, %d unchanged)", len(w.monitors), len(started), len(w.monitors)-len(started))
+}
+
+// logSummary logs a table of the services about to be started, so operators
+// can check the effective configuration matches their intent.
+func logSummary(cfg *config.Config, services []*config.Service) {
+	if len(services) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	writeSummary(&buf, cfg, services)
+	logging.Infof("", "Monitoring %d services:", len(services))
+	for line := range strings.Lines(buf.String()) {
+		logging.Infof("", "  %s", strings.TrimRight(line, "\n"))
+	}
+}
+
+// writeSummary writes one aligned row per service: name, type, interval,
+// targets and tunnel.
+func writeSummary(out io.Writer, cfg *config.Config, services []*config.Service) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tTYPE\tINTERVAL\tTARGETS\tTUNNEL")
+	for _, svc := range services {
+		interval := svc.Interval
+		if interval == "" {
+			interval = cfg.Global.DefaultInterval
+		}
+		tunnel := svc.Tunnel
+		if tunnel == "" {
+			tunnel = "-"
 		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", svc.Name, svc.Type, interval, summaryTargets(svc), tunnel)
+	}
+	_ = tw.Flush()
+}
+
+// summaryTargets describes what svc checks, leaving out credentials.
+func summaryTargets(svc *config.Service) string {
+	switch {
+	case len(svc.Targets) > 0:
+		return strings.Join(svc.Targets, ", ")
+	case svc.Target != "":
+		return svc.Target
+	case svc.URL != "":
+		if u, err := url.Parse(svc.URL); err == nil {
+			return u.Redacted()
+		}
+		return svc.URL
+	case svc.Exec != nil:
+		return svc.Exec.Command
+	case svc.Database != nil && svc.Database.DSN != "":
+		return "(dsn)"
+	}
+	return "-"
+}
+
+func (w *Watchdog) stopMonitor(name string) {
+	m, ok := w.monitors[name]
+	if !ok {
+		return
+	}
+	m.cancel()
+	m.wg.Wait()
+	delete(w.monitors, name)
+}
+
+// applyHeartbeat (re)starts the heartbeat when its definition changed.
+func (w *Watchdog) applyHeartbeat(ctx context.Context, cfg *config.Config) {
+	fp := cfg.HeartbeatFingerprint()
+	if w.heartbeat != nil && w.heartbeat.fingerprint == fp {
+		return
+	}
+	w.stopHeartbeat()
+	if cfg.Global.Heartbeat == nil {
+		return
+	}
+
+	hbCtx, cancel := context.WithCancel(ctx)
+	w.heartbeat = &serviceMonitor{fingerprint: fp, cancel: cancel}
+	w.heartbeat.wg.Add(1)
+	go agent.RunHeartbeat(hbCtx, *cfg.Global.Heartbeat, w.shared, w.pusher, &w.heartbeat.wg)
+}
+
+func (w *Watchdog) stopHeartbeat() {
+	if w.heartbeat == nil {
+		return
 	}
+	w.heartbeat.cancel()
+	w.heartbeat.wg.Wait()
+	w.heartbeat = nil
 }
 
 func (w *Watchdog) watchConfigFile(ctx context.Context, watcher *fsnotify.Watcher) {
@@ -164,6 +324,7 @@ func (w *Watchdog) watchConfigFile(ctx context.Context, watcher *fsnotify.Watche
 	var (
 		timer     *time.Timer
 		timerChan <-chan time.Time
+		firstMod  time.Time // First modification since the last reload, zero when none is pending
 	)
 
 	for {
@@ -177,36 +338,103 @@ func (w *Watchdog) watchConfigFile(ctx context.Context, watcher *fsnotify.Watche
 			if !ok {
 				return
 			}
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				log.Printf("Config file modified, scheduling reload in %v...", ReloadDelay)
+			if !w.isSource(event.Name) {
+				continue
+			}
+			// A file renamed over the config path shows up as Create. Remove
+			// and Rename of the path itself are followed by one once the new
+			// file is in place, so they don't need a reload on their own.
+			if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				// Every modification restarts the delay, up to MaxReloadDelay
+				// after the first one
+				if firstMod.IsZero() {
+					firstMod = time.Now()
+				}
+				delay := min(ReloadDelay, max(MaxReloadDelay-time.Since(firstMod), 0))
+				logging.Infof("", "Config file modified, scheduling reload in %v...", delay)
 				if timer != nil {
 					timer.Stop()
 				}
-				timer = time.NewTimer(ReloadDelay)
+				timer = time.NewTimer(delay)
 				timerChan = timer.C
 			}
 		case <-timerChan:
 			timerChan = nil // Reset timer chan
-			newCfg, err := config.LoadConfig(w.configPath)
-			if err != nil {
-				log.Printf("Failed to reload config: %v. Keeping old configuration.", err)
-			} else {
-				w.shared.Set(newCfg)
-				w.pusher.SetRateLimit(newCfg.Global.Notifier.RateLimit)
-				log.Printf("Config reloaded successfully with %d services", len(newCfg.Services))
-
-				select {
-				case w.reloadChan <- struct{}{}:
-				default:
-				}
-			}
+			firstMod = time.Time{}
+			w.Reload()
+			w.watchSources(watcher) // The reload may have added includes
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				return
 			}
-			log.Printf("Config watcher error: %v", err)
+			logging.Warnf("", "Config watcher error: %v", err)
+		}
+	}
+}
+
+// sources returns the config file and the include patterns it was loaded from.
+func (w *Watchdog) sources() []string {
+	if sources := w.shared.Get().Sources(); len(sources) > 0 {
+		return sources
+	}
+	return []string{filepath.Clean(w.configPath)}
+}
+
+// watchSources adds the directories of the config file and its includes that
+// are not watched yet. Directories are watched rather than files, so the
+// watch survives a file being replaced by a rename, as config management
+// tools do.
+func (w *Watchdog) watchSources(watcher *fsnotify.Watcher) {
+	watched := watcher.WatchList()
+	for _, src := range w.sources() {
+		dir := filepath.Dir(src)
+		if strings.ContainsAny(dir, "*?[") || slices.Contains(watched, dir) {
+			continue // Files matched by a glob directory are sources of their own
+		}
+		if err := watcher.Add(dir); err != nil {
+			logging.Warnf("", "Failed to watch config directory: %v", err)
+			continue
+		}
+		watched = append(watched, dir)
+	}
+}
+
+// isSource reports whether name is the config file, one of its includes or a
+// file an include pattern would pick up.
+func (w *Watchdog) isSource(name string) bool {
+	name = filepath.Clean(name)
+	for _, src := range w.sources() {
+		if ok, _ := filepath.Match(src, name); ok {
+			return true
 		}
 	}
+	return false
+}
+
+// Reload loads the config file and, if it is valid, applies it to the running
+// monitors. On error the old configuration is kept. It is used by the file
+// watcher and can be called directly, e.g. on SIGHUP.
+func (w *Watchdog) Reload() {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	newCfg, err := config.LoadConfig(w.configPath)
+	if err != nil {
+		logging.Errorf("", "Failed to reload config: %v. Keeping old configuration.", err)
+		return
+	}
+	w.shared.Set(newCfg)
+	logging.Configure(newCfg.Global.Logging.Format, newCfg.Global.Logging.Level)
+	w.pusher.SetRateLimit(newCfg.Global.Notifier.RateLimit)
+	w.pusher.SetMaxBackoff(newCfg.Global.Notifier.MaxBackoff)
+	w.applyMetrics(newCfg)
+	w.applyAPI(newCfg)
+	logging.Infof("", "Config reloaded successfully with %d services", len(newCfg.Services))
+
+	select {
+	case w.reloadChan <- struct{}{}:
+	default:
+	}
 }
 
 func (w *Watchdog) Stop() {
@@ -218,4 +446,112 @@ func (w *Watchdog) Stop() {
 	}
 	w.wg.Wait()
 	w.tunnelRegistry.StopAll()
+
+	w.mu.Lock()
+	w.stopMetrics()
+	w.stopAPI()
+	w.mu.Unlock()
+}
+
+// applyMetrics (re)starts the metrics server when its listen address changed.
+func (w *Watchdog) applyMetrics(cfg *config.Config) {
+	listen := ""
+	if cfg.Global.Metrics != nil {
+		listen = cfg.Global.Metrics.Listen
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.metrics != nil && w.metricsListen == listen {
+		return
+	}
+	w.stopMetrics()
+	if listen == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", w.shared.Metrics)
+	srv, addr, err := serveHTTP("metrics", listen, mux)
+	if err != nil {
+		logging.Errorf("", "Failed to start metrics server: %v", err)
+		return
+	}
+	w.metrics, w.metricsListen, w.metricsAddr = srv, listen, addr
+	logging.Infof("", "Serving metrics on http://%s/metrics", w.metricsAddr)
+}
+
+// stopMetrics shuts the metrics server down, letting in-flight scrapes
+// finish. w.mu must be held.
+func (w *Watchdog) stopMetrics() {
+	if w.metrics == nil {
+		return
+	}
+	shutdownHTTP("metrics", w.metrics)
+	w.metrics, w.metricsListen, w.metricsAddr = nil, "", ""
+}
+
+// applyAPI (re)starts the status API server when its listen address changed.
+func (w *Watchdog) applyAPI(cfg *config.Config) {
+	listen := ""
+	if cfg.Global.API != nil {
+		listen = cfg.Global.API.Listen
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.api != nil && w.apiListen == listen {
+		return
+	}
+	w.stopAPI()
+	if listen == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /status", w.shared.Status)
+	mux.Handle("GET /status/{service}", w.shared.Status)
+	mux.Handle("POST /check/{service}", w.shared.Checks)
+	srv, addr, err := serveHTTP("status API", listen, mux)
+	if err != nil {
+		logging.Errorf("", "Failed to start status API server: %v", err)
+		return
+	}
+	w.api, w.apiListen, w.apiAddr = srv, listen, addr
+	logging.Infof("", "Serving the status API on http://%s/status", w.apiAddr)
+}
+
+// stopAPI shuts the status API server down, letting in-flight requests
+// finish. w.mu must be held.
+func (w *Watchdog) stopAPI() {
+	if w.api == nil {
+		return
+	}
+	shutdownHTTP("status API", w.api)
+	w.api, w.apiListen, w.apiAddr = nil, "", ""
+}
+
+// serveHTTP starts serving handler on listen in the background, returning the
+// server and the address it actually listens on.
+func serveHTTP(name, listen string, handler http.Handler) (*http.Server, string, error) {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, "", err
+	}
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Warnf("", "%s server stopped: %v", name, err)
+		}
+	}()
+	return srv, ln.Addr().String(), nil
+}
+
+// shutdownHTTP shuts srv down, waiting up to 5s for in-flight requests.
+func shutdownHTTP(name string, srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logging.Warnf("", "Failed to stop %s server: %v", name, err)
+	}
 }